@@ -0,0 +1,237 @@
+// Package agent distributes a benchmark across multiple machines: a coordinator splits the
+// queries in shards that are run by agents, whose per-request results are aggregated into a
+// single summary. A single client box can't saturate a large Promscale cluster.
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/report"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// ShardsPath is the path of the agent endpoint receiving shards.
+const ShardsPath = "/v1/shards"
+
+// Shard is the work assigned by a coordinator to an agent.
+type Shard struct {
+	// Target is the URL of the benchmarked server
+	Target string `json:"target"`
+	// Workers is the number of concurrent workers the agent runs the shard with
+	Workers int           `json:"workers"`
+	Queries []query.Query `json:"queries"`
+	// Client holds the settings of the client the agent sends the queries with
+	Client ClientOptions `json:"client"`
+}
+
+// ClientOptions are the settings of the client.Client of the coordinator its agents send the
+// queries with, so every agent sends the same requests. Credentials fetched or derived by the
+// coordinator, e.g. OAuth2 tokens or SigV4 signatures, can't be shared with the agents.
+type ClientOptions struct {
+	Timeout   time.Duration           `json:"timeout,omitempty"`
+	Transport client.TransportOptions `json:"transport,omitzero"`
+	// Header holds the headers sent with every request, nil if none
+	Header      http.Header  `json:"header,omitempty"`
+	Gzip        bool         `json:"gzip,omitempty"`
+	Trace       bool         `json:"trace,omitempty"`
+	Body        string       `json:"body,omitempty"`
+	CacheBust   string       `json:"cache_bust,omitempty"`
+	AlignStep   bool         `json:"align_step,omitempty"`
+	Thanos      query.Thanos `json:"thanos,omitzero"`
+	Fingerprint bool         `json:"fingerprint,omitempty"`
+}
+
+// NewClient returns the client sending the queries to the target with the options.
+func (o ClientOptions) NewClient(target string) (*client.Client, error) {
+	transport, err := client.NewRoundTripper(o.Transport)
+	if err != nil {
+		return nil, err
+	}
+	if o.Header != nil {
+		transport = &client.HeaderTransport{Base: transport, Header: o.Header}
+	}
+	c := client.New(target)
+	c.Client = &http.Client{Transport: transport}
+	if o.Timeout > 0 {
+		c.Timeout = o.Timeout
+	}
+	c.Gzip, c.Trace, c.Body, c.CacheBust = o.Gzip, o.Trace, o.Body, o.CacheBust
+	c.AlignStep, c.Thanos, c.Fingerprint = o.AlignStep, o.Thanos, o.Fingerprint
+	return c, nil
+}
+
+// Handler serves the agent API. Every shard POSTed is run right away against its target, and the
+// per-request results are streamed back as NDJSON report.RequestEvent lines as they complete.
+// The API is not authenticated, so anyone reaching it can send load to any target.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ShardsPath, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var shard Shard
+		if err := json.NewDecoder(req.Body).Decode(&shard); err != nil {
+			http.Error(w, fmt.Sprintf("unable to decode shard. err=%v", err), http.StatusBadRequest)
+			return
+		}
+		if shard.Workers < 1 {
+			http.Error(w, "at least one worker is required", http.StatusBadRequest)
+			return
+		}
+		c, err := shard.Client.NewClient(shard.Target)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid client options. err=%v", err), http.StatusBadRequest)
+			return
+		}
+
+		slog.Info("running shard", "queries", len(shard.Queries), "target", shard.Target)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		r := &runner.Runner{
+			Client:    c,
+			Workers:   shard.Workers,
+			Recorders: []runner.Recorder{&streamRecorder{w: w, logger: report.NewRequestLogger(w)}},
+		}
+		r.Run(shard.Queries)
+	})
+	return mux
+}
+
+// streamRecorder logs every result to a response, flushing it so the coordinator receives the
+// results as they happen.
+type streamRecorder struct {
+	mu     sync.Mutex
+	w      http.ResponseWriter
+	logger *report.RequestLogger
+}
+
+func (s *streamRecorder) Record(r *runner.Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.logger.Record(r); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Coordinator distributes the queries of a benchmark across agents.
+type Coordinator struct {
+	// Agents are the addresses of the agents, with an optional scheme defaulting to http
+	Agents []string
+	Client *http.Client
+	// Options are the settings of the client the agents send the queries with
+	Options ClientOptions
+	// Recorders are notified of every Result received from the agents
+	Recorders []runner.Recorder
+}
+
+// Shards splits the queries round-robin into n shards.
+func Shards(queries []query.Query, n int) [][]query.Query {
+	shards := make([][]query.Query, n)
+	for i, q := range queries {
+		shards[i%n] = append(shards[i%n], q)
+	}
+	return shards
+}
+
+// Run sends a shard of the queries to every agent, which runs it with the given number of
+// workers against the target, and returns the stats of all their results.
+func (c *Coordinator) Run(target string, workers int, queries []query.Query) (*stats.Stats, error) {
+	httpClient := c.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	var mu sync.Mutex
+	var results []runner.Result
+	errs := make([]error, len(c.Agents))
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, shard := range Shards(queries, len(c.Agents)) {
+		wg.Add(1)
+		go func(i int, shard []query.Query) {
+			defer wg.Done()
+
+			errs[i] = c.runShard(httpClient, i, Shard{Target: target, Workers: workers, Queries: shard, Client: c.Options}, func(r *runner.Result) {
+				// Worker indexes are local to every agent, make them unique across the run
+				r.Worker += i * workers
+				for _, rec := range c.Recorders {
+					if err := rec.Record(r); err != nil {
+						slog.Warn("unable to record result", "err", err)
+					}
+				}
+
+				mu.Lock()
+				results = append(results, *r)
+				mu.Unlock()
+			})
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("agent %s failed. err=%w", c.Agents[i], err)
+		}
+	}
+
+	return runner.Aggregate(results, time.Since(start)), nil
+}
+
+func (c *Coordinator) runShard(httpClient *http.Client, i int, shard Shard, onResult func(*runner.Result)) error {
+	body, err := json.Marshal(shard)
+	if err != nil {
+		return err
+	}
+
+	addr := c.Agents[i]
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	resp, err := httpClient.Post(strings.TrimRight(addr, "/")+ShardsPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &client.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	received := 0
+	for scanner.Scan() {
+		var event report.RequestEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("unable to decode result. err=%w", err)
+		}
+		onResult(event.Result())
+		received++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if received != len(shard.Queries) {
+		return fmt.Errorf("received %d results for a shard of %d queries", received, len(shard.Queries))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestShards(t *testing.T) {
+	queries := []query.Query{{Query: "a"}, {Query: "b"}, {Query: "c"}}
+	want := [][]query.Query{{{Query: "a"}, {Query: "c"}}, {{Query: "b"}}}
+	if got := Shards(queries, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shards() = %v, want %v", got, want)
+	}
+}
+
+// workersRecorder keeps the workers of every Result recorded.
+type workersRecorder struct {
+	mu      sync.Mutex
+	workers map[int]int
+}
+
+func (r *workersRecorder) Record(res *runner.Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workers[res.Worker]++
+	return nil
+}
+
+func TestCoordinator_Run(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("query") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+
+	var agents []string
+	for i := 0; i < 2; i++ {
+		a := httptest.NewServer(Handler())
+		defer a.Close()
+		agents = append(agents, a.URL)
+	}
+
+	rec := &workersRecorder{workers: map[int]int{}}
+	c := &Coordinator{Agents: agents, Recorders: []runner.Recorder{rec}}
+	queries := []query.Query{{Query: "up"}, {Query: "up"}, {Query: "broken"}, {Query: "up"}, {Query: "up"}}
+
+	s, err := c.Run(target.URL, 1, queries)
+	if err != nil {
+		t.Fatalf("Coordinator.Run() error = %v", err)
+	}
+	if s.Processed != 4 || s.Errors.Total() != 1 {
+		t.Errorf("Coordinator.Run() processed = %d, errors = %d, want 4 and 1", s.Processed, s.Errors.Total())
+	}
+	if want := map[int]int{0: 3, 1: 2}; !reflect.DeepEqual(rec.workers, want) {
+		t.Errorf("Coordinator.Run() workers = %v, want %v", rec.workers, want)
+	}
+}
+
+func TestCoordinator_Run_agentDown(t *testing.T) {
+	a := httptest.NewServer(Handler())
+	a.Close()
+
+	c := &Coordinator{Agents: []string{a.URL}}
+	if _, err := c.Run("http://localhost:1", 1, []query.Query{{Query: "up"}}); err == nil {
+		t.Errorf("Coordinator.Run() error = nil, want the agent failure")
+	}
+}
+
+func TestCoordinator_Run_options(t *testing.T) {
+	var mu sync.Mutex
+	var tenants []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		tenants = append(tenants, req.Header.Get("X-Scope-OrgID"))
+	}))
+	defer target.Close()
+	a := httptest.NewServer(Handler())
+	defer a.Close()
+
+	c := &Coordinator{Agents: []string{a.URL}, Options: ClientOptions{Header: http.Header{"X-Scope-OrgID": {"tenant"}}}}
+	if _, err := c.Run(target.URL, 1, []query.Query{{Query: "up"}, {Query: "up"}}); err != nil {
+		t.Fatalf("Coordinator.Run() error = %v", err)
+	}
+	if want := []string{"tenant", "tenant"}; !reflect.DeepEqual(tenants, want) {
+		t.Errorf("Coordinator.Run() tenants = %v, want %v", tenants, want)
+	}
+}
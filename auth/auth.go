@@ -0,0 +1,98 @@
+// Package auth attaches the bearer tokens of identity providers to the requests sent to the
+// target, refreshing them as they expire during long runs.
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// expiryDelta is how long before their expiry tokens are refreshed, so requests in flight don't
+// carry expired tokens.
+const expiryDelta = time.Minute
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken string
+	// Expiry is the time the token expires at, zero if it doesn't
+	Expiry time.Time
+}
+
+func (t *Token) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(expiryDelta).Before(t.Expiry))
+}
+
+// Source issues new tokens.
+type Source interface {
+	Token() (*Token, error)
+}
+
+// Transport is an http.RoundTripper sending every request through the Base transport with the
+// bearer token of the Source, which is only asked for a new one as the current token expires.
+type Transport struct {
+	Base   http.RoundTripper
+	Source Source
+
+	mu    sync.Mutex
+	token *Token
+}
+
+// Token returns the current token, refreshing it if it is about to expire.
+func (t *Transport) Token() (*Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.valid(time.Now()) {
+		return t.token, nil
+	}
+	token, err := t.Source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to refresh token. err=%w", err)
+	}
+	t.token = token
+	return token, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the given request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return t.Base.RoundTrip(req)
+}
+
+// fetchToken sends the request of a new token to a token endpoint and decodes its response.
+func fetchToken(httpClient client.HttpClient, req *http.Request) (*Token, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &client.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var r struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("unable to decode token. err=%w", err)
+	}
+	if r.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access token")
+	}
+	token := &Token{AccessToken: r.AccessToken}
+	if r.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
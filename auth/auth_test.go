@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// SourceMock issues numbered tokens expiring after the given time.
+type SourceMock struct {
+	expiresIn time.Duration
+	issued    int
+}
+
+func (s *SourceMock) Token() (*Token, error) {
+	s.issued++
+	return &Token{AccessToken: fmt.Sprintf("token-%d", s.issued), Expiry: time.Now().Add(s.expiresIn)}, nil
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		want      []string
+	}{
+		{name: "cached", expiresIn: time.Hour, want: []string{"Bearer token-1", "Bearer token-1"}},
+		{name: "about to expire", expiresIn: 30 * time.Second, want: []string{"Bearer token-1", "Bearer token-2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			c := &http.Client{Transport: &Transport{Base: http.DefaultTransport, Source: &SourceMock{expiresIn: tt.expiresIn}}}
+			for range tt.want {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if req.Header.Get("Authorization") != "" {
+					t.Errorf("Transport.RoundTrip() modified the given request")
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Transport.RoundTrip() sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// ClientCredentials is the Source of the tokens of the OAuth2 client credentials flow, in which the
+// tool authenticates as a client of the identity provider rather than on behalf of a user.
+type ClientCredentials struct {
+	Client       client.HttpClient
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+func (c *ClientCredentials) Token() (*Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	return fetchToken(c.Client, req)
+}
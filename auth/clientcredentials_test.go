@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClientCredentials_Token(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		status    int
+		wantScope string
+		wantErr   bool
+	}{
+		{name: "scopes", scopes: []string{"metrics:read", "openid"}, status: http.StatusOK, wantScope: "metrics:read openid"},
+		{name: "no scopes", status: http.StatusOK},
+		{name: "rejected", status: http.StatusUnauthorized, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form url.Values
+			var user, password string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				form = r.PostForm
+				user, password, _ = r.BasicAuth()
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":300}`))
+			}))
+			defer srv.Close()
+
+			c := &ClientCredentials{Client: http.DefaultClient, TokenURL: srv.URL, ClientID: "bench", ClientSecret: "s3cr=t", Scopes: tt.scopes}
+			token, err := c.Token()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ClientCredentials.Token() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if form.Get("grant_type") != "client_credentials" || form.Get("scope") != tt.wantScope {
+				t.Errorf("ClientCredentials.Token() posted %v, want scope %q", form, tt.wantScope)
+			}
+			if user != "bench" || password != "s3cr%3Dt" {
+				t.Errorf("ClientCredentials.Token() authenticated as %s:%s", user, password)
+			}
+			if err == nil && (token.AccessToken != "abc" || token.Expiry.IsZero()) {
+				t.Errorf("ClientCredentials.Token() = %+v", token)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// GoogleScope is the scope of the Google tokens, covering the query API of Google Cloud Managed
+// Service for Prometheus.
+const GoogleScope = "https://www.googleapis.com/auth/monitoring.read"
+
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// googleCredentials is a credentials file of a service account or of a gcloud user.
+type googleCredentials struct {
+	Type string `json:"type"`
+	// Service account
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+	// Authorized user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Google returns the Source of the Google Application Default Credentials: those of the given
+// credentials file if any, else of the file in GOOGLE_APPLICATION_CREDENTIALS, else of the user
+// logged in with gcloud auth application-default login, else of the service account of the Google
+// Cloud instance running the tool.
+func Google(httpClient client.HttpClient, credentialsFile string) (Source, error) {
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	wellKnown := credentialsFile == ""
+	if wellKnown {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return &googleMetadataSource{client: httpClient}, nil
+		}
+		credentialsFile = filepath.Join(dir, "gcloud", "application_default_credentials.json")
+	}
+
+	b, err := os.ReadFile(credentialsFile)
+	if wellKnown && errors.Is(err, fs.ErrNotExist) {
+		return &googleMetadataSource{client: httpClient}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Google credentials. err=%w", err)
+	}
+	var creds googleCredentials
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return nil, fmt.Errorf("unable to decode Google credentials %s. err=%w", credentialsFile, err)
+	}
+
+	switch creds.Type {
+	case "service_account":
+		key, err := parsePrivateKey(creds.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the key of %s. err=%w", creds.ClientEmail, err)
+		}
+		if creds.TokenURI == "" {
+			creds.TokenURI = googleTokenURL
+		}
+		return &googleServiceAccountSource{client: httpClient, email: creds.ClientEmail, key: key, tokenURI: creds.TokenURI}, nil
+	case "authorized_user":
+		return &googleUserSource{client: httpClient, creds: creds}, nil
+	}
+	return nil, fmt.Errorf("unsupported Google credentials type %q", creds.Type)
+}
+
+func parsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// googleServiceAccountSource exchanges JWTs signed with the key of a service account for tokens.
+type googleServiceAccountSource struct {
+	client   client.HttpClient
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+}
+
+func (s *googleServiceAccountSource) Token() (*Token, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.email,
+		"scope": GoogleScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return postForm(s.client, s.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+}
+
+// googleUserSource refreshes the tokens of a user logged in with gcloud.
+type googleUserSource struct {
+	client client.HttpClient
+	creds  googleCredentials
+}
+
+func (s *googleUserSource) Token() (*Token, error) {
+	return postForm(s.client, googleTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {s.creds.ClientID},
+		"client_secret": {s.creds.ClientSecret},
+		"refresh_token": {s.creds.RefreshToken},
+	})
+}
+
+// googleMetadataSource gets the tokens of the service account of a Google Cloud instance from its
+// metadata server, whose host can be overridden with GCE_METADATA_HOST.
+type googleMetadataSource struct {
+	client client.HttpClient
+}
+
+func (s *googleMetadataSource) Token() (*Token, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	u := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=" + url.QueryEscape(GoogleScope)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	token, err := fetchToken(s.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("no Google credentials found and the metadata server is unavailable. err=%w", err)
+	}
+	return token, nil
+}
+
+// postForm requests a token by posting the given form to a token endpoint.
+func postForm(httpClient client.HttpClient, tokenURL string, form url.Values) (*Token, error) {
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return fetchToken(httpClient, req)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TokenClientMock answers every request with a token, keeping the last request and its form.
+type TokenClientMock struct {
+	req  *http.Request
+	form url.Values
+}
+
+func (c *TokenClientMock) Do(req *http.Request) (*http.Response, error) {
+	c.req = req
+	if req.Body != nil {
+		b, _ := io.ReadAll(req.Body)
+		c.form, _ = url.ParseQuery(string(b))
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"access_token":"ya29","expires_in":3599,"token_type":"Bearer"}`))}, nil
+}
+
+func writeCredentials(t *testing.T, creds map[string]string) string {
+	path := t.TempDir() + "/credentials.json"
+	b, _ := json.Marshal(creds)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGoogle_serviceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	path := writeCredentials(t, map[string]string{
+		"type":         "service_account",
+		"client_email": "bench@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    "https://oauth2.example/token",
+	})
+
+	mock := &TokenClientMock{}
+	source, err := Google(mock, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "ya29" || token.Expiry.IsZero() {
+		t.Errorf("Google() token = %+v", token)
+	}
+	if mock.req.URL.String() != "https://oauth2.example/token" || mock.form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		t.Errorf("Google() requested %s with %v", mock.req.URL, mock.form)
+	}
+
+	parts := strings.Split(mock.form.Get("assertion"), ".")
+	if len(parts) != 3 {
+		t.Fatalf("Google() assertion has %d parts, want 3", len(parts))
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+		t.Errorf("Google() assertion signature error = %v", err)
+	}
+	var claims map[string]any
+	b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(b, &claims)
+	if claims["iss"] != "bench@project.iam.gserviceaccount.com" || claims["aud"] != "https://oauth2.example/token" || claims["scope"] != GoogleScope {
+		t.Errorf("Google() assertion claims = %v", claims)
+	}
+}
+
+func TestGoogle(t *testing.T) {
+	tests := []struct {
+		name     string
+		creds    map[string]string
+		wantURL  string
+		wantForm url.Values
+		wantErr  bool
+	}{
+		{
+			name:     "authorized user",
+			creds:    map[string]string{"type": "authorized_user", "client_id": "id", "client_secret": "secret", "refresh_token": "refresh"},
+			wantURL:  googleTokenURL,
+			wantForm: url.Values{"grant_type": {"refresh_token"}, "client_id": {"id"}, "client_secret": {"secret"}, "refresh_token": {"refresh"}},
+		},
+		{
+			name:    "unsupported type",
+			creds:   map[string]string{"type": "external_account"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			creds:   map[string]string{"type": "service_account", "private_key": "not a key"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &TokenClientMock{}
+			source, err := Google(mock, writeCredentials(t, tt.creds))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Google() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := source.Token(); err != nil {
+				t.Fatal(err)
+			}
+			if mock.req.URL.String() != tt.wantURL || mock.form.Encode() != tt.wantForm.Encode() {
+				t.Errorf("Google() requested %s with %v, want %s with %v", mock.req.URL, mock.form, tt.wantURL, tt.wantForm)
+			}
+		})
+	}
+}
+
+func TestGoogle_metadata(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GCE_METADATA_HOST", "metadata.test")
+
+	mock := &TokenClientMock{}
+	source, err := Google(mock, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if mock.req.URL.Host != "metadata.test" || mock.req.Header.Get("Metadata-Flavor") != "Google" {
+		t.Errorf("Google() requested %s with %v, want the metadata server", mock.req.URL, mock.req.Header)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BuildInfo describes the build of the target, as reported by the Prometheus buildinfo endpoint.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	BuildUser string `json:"buildUser,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+}
+
+// BuildInfo returns the build of the target from its /api/v1/status/buildinfo endpoint, falling back
+// to the /version endpoint served by older Promscale releases, as JSON or plain text.
+func (c *Client) BuildInfo() (*BuildInfo, error) {
+	body, err := c.get("/api/"+c.Version+"/status/buildinfo", nil)
+	if err == nil {
+		var r struct {
+			Data BuildInfo `json:"data"`
+		}
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("BuildInfo() decoding response. error=%w", err)
+		}
+		return &r.Data, nil
+	}
+
+	body, fallbackErr := c.get("/version", nil)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("BuildInfo() requesting build info. error=%w", err)
+	}
+	var info BuildInfo
+	if json.Unmarshal(body, &info) != nil || info.Version == "" {
+		info = BuildInfo{Version: strings.TrimSpace(string(body))}
+	}
+	return &info, nil
+}
+
+// get returns the body of a successful GET request of the given path of the target.
+func (c *Client) get(path string, params url.Values) ([]byte, error) {
+	u := *c.URL
+	u.Path, u.RawQuery = PathPrefix(u.Path)+path, params.Encode()
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClient_BuildInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   map[string]string
+		want    *BuildInfo
+		wantErr bool
+	}{
+		{
+			name: "buildinfo",
+			paths: map[string]string{
+				"/api/v1/status/buildinfo": `{"status":"success","data":{"version":"2.53.0","revision":"1f5e3c2","goVersion":"go1.22.4"}}`,
+			},
+			want: &BuildInfo{Version: "2.53.0", Revision: "1f5e3c2", GoVersion: "go1.22.4"},
+		},
+		{
+			name:  "json version",
+			paths: map[string]string{"/version": `{"version":"0.17.0","commit_hash":"abc"}`},
+			want:  &BuildInfo{Version: "0.17.0"},
+		},
+		{
+			name:  "plain version",
+			paths: map[string]string{"/version": "0.17.0\n"},
+			want:  &BuildInfo{Version: "0.17.0"},
+		},
+		{
+			name:    "unavailable",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.paths[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(body))
+			}))
+			defer srv.Close()
+
+			got, err := New(srv.URL).BuildInfo()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.BuildInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Client.BuildInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,598 @@
+// Package client sends the PromQL queries to the benchmarked server over HTTP.
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+type HttpClient interface {
+	Do(req *http.Request) (resp *http.Response, err error)
+}
+
+type Client struct {
+	Client  HttpClient
+	URL     *url.URL
+	Version string
+	// CacheBust defeats the response caches in front of the target: CacheBustHeader asks them not
+	// to answer from cache and CacheBustMatcher makes every query unique with Query.WithNonce
+	CacheBust string
+	// Fingerprint decodes every response to report the Fingerprint of its result
+	Fingerprint bool
+	// Trace sends every request as the root span of a new trace, propagated with a W3C traceparent
+	// header, and with a unique X-Request-ID header, both reported in the Response, so server-side
+	// traces and logs can be correlated with the requests
+	Trace bool
+	// Body is how the bodies of the responses are handled, BodyCount if empty. They are read whole
+	// regardless when needed, e.g. to check the assertions of the query
+	Body string
+	// Gzip asks for gzip compressed responses, which the Client decompresses itself so their
+	// compressed size and the time spent decompressing them are measured. Responses are asked
+	// uncompressed otherwise
+	Gzip bool
+	// AlignStep snaps the range of every query to its step before sending it, see
+	// query.Query.AlignStep
+	AlignStep bool
+	// Timeout is the deadline of every request, including reading its response, unless overridden
+	// by the Timeout of the query. Zero means no deadline
+	Timeout time.Duration
+	// Thanos holds the parameters specific to the Thanos Querier sent with every query, unless
+	// overridden by those of the query
+	Thanos query.Thanos
+}
+
+// Ways a Client handles the bodies of the responses.
+const (
+	// BodyDiscard drains the bodies so the connection can be reused, without measuring them
+	BodyDiscard = "discard"
+	// BodyCount drains the bodies, measuring their size and, if compressed, their decompression
+	BodyCount = "count"
+	// BodyDecode decodes the whole JSON of the successful responses, measuring the time spent
+	BodyDecode = "decode"
+)
+
+// Ways a Client defeats response caches.
+const (
+	CacheBustHeader  = "header"
+	CacheBustMatcher = "matcher"
+)
+
+var schemeRegex = regexp.MustCompile(`^((http[s]?|ftp):\/)\/`)
+
+func getScheme(text string) *string {
+	if match := schemeRegex.FindString(text); match != "" {
+		return &match
+	}
+	return nil
+}
+
+// New instantiates a new Client given a host url. The url can (optionally) contain the scheme,
+// which will be set to 'https' otherwise, and a path, the prefix the API of the target lives under,
+// e.g. https://host/prometheus. unix:// URLs give the path of a Unix domain socket the target
+// listens on instead.
+func New(host string) *Client {
+	if socket, ok := SocketPath(host); ok {
+		transport, _ := NewTransport(TransportOptions{Socket: socket})
+		return &Client{
+			Client:  &http.Client{Transport: transport},
+			URL:     &url.URL{Host: "localhost", Scheme: "http"},
+			Version: "v1",
+			Timeout: time.Second,
+			Gzip:    true,
+		}
+	}
+
+	scheme := "https"
+	if s := getScheme(host); s != nil {
+		host = strings.TrimPrefix(host, *s)
+		scheme = strings.TrimSuffix(*s, "://")
+	}
+	host, prefix, _ := strings.Cut(host, "/")
+	return &Client{
+		Client:  &http.Client{},
+		URL:     &url.URL{Host: host, Scheme: scheme, Path: PathPrefix("/" + prefix)},
+		Version: "v1",
+		Timeout: time.Second,
+		Gzip:    true,
+	}
+}
+
+// PathPrefix returns the path of a target URL as the prefix of the paths requested, without its
+// trailing slash, e.g. /prometheus for https://host/prometheus/ and nothing for https://host.
+func PathPrefix(path string) string {
+	return strings.TrimRight(path, "/")
+}
+
+// SocketPath returns the path of the Unix domain socket of a unix:// URL, e.g.
+// unix:///var/run/promscale.sock, and whether the URL is one.
+func SocketPath(host string) (string, bool) {
+	path, ok := strings.CutPrefix(host, "unix://")
+	if !ok || path == "" {
+		return "", false
+	}
+	return path, true
+}
+
+// NewRequest builds the HTTP request sent to the target server for a given query, with every header
+// the Client sets. Queries targeting the metadata endpoints send their expression as the series
+// selector to match.
+func (c *Client) NewRequest(q *query.Query) (*http.Request, error) {
+	if c.CacheBust == CacheBustMatcher {
+		unique := q.WithNonce(query.NewNonce())
+		q = &unique
+	}
+	if c.AlignStep {
+		aligned := q.AlignStep()
+		q = &aligned
+	}
+	u := *c.URL
+	u.Path = PathPrefix(u.Path)
+	var params = url.Values{}
+	switch {
+	case q.RangeQuery():
+		u.Path += "/api/" + c.Version + "/query_range"
+		params.Add("query", q.Query)
+		params.Add("step", fmt.Sprintf("%d", q.Step))
+	case q.Endpoint == query.EndpointQueryExemplars:
+		u.Path += "/api/" + c.Version + "/" + q.Endpoint
+		params.Add("query", q.Query)
+	default:
+		u.Path += "/api/" + c.Version + "/" + q.Endpoint
+		if q.Query != "" {
+			params.Add("match[]", q.Query)
+		}
+	}
+	params.Add("start", time.UnixMilli(q.Start).UTC().Format(time.RFC3339))
+	params.Add("end", time.UnixMilli(q.End).UTC().Format(time.RFC3339))
+	q.Thanos.Or(c.Thanos).Encode(params)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.CacheBust == CacheBustHeader {
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+	}
+	if c.Trace {
+		traceID, spanID := NewTraceContext()
+		req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+		req.Header.Set("X-Request-ID", NewRequestID())
+	}
+	// Setting the header keeps the transport from decompressing the responses transparently
+	if c.Gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	} else {
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	return req, nil
+}
+
+// Query sends the HTTP request for a given query and returns a Response containing the elapsed
+// time from the beginning to the end of the call to the target server.
+func (c *Client) Query(q *query.Query) (*Response, error) {
+	req, err := c.NewRequest(q)
+	if err != nil {
+		return nil, fmt.Errorf("Query() building request. error=%w", err)
+	}
+	// The trace context is reported with the response, e.g. to correlate it with server-side traces
+	var traceID, spanID string
+	if parts := strings.Split(req.Header.Get("traceparent"), "-"); len(parts) == 4 {
+		traceID, spanID = parts[1], parts[2]
+	}
+	requestID := req.Header.Get("X-Request-ID")
+
+	// The deadline bounds the whole request, until its response has been read
+	if timeout := cmp.Or(q.Timeout, c.Timeout); timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	// Time spent opening a connection, if an idle one is not reused, and its TLS handshake, and
+	// until the first byte of the response was received
+	var connStart, tlsStart, firstByte time.Time
+	var connect, tlsHandshake time.Duration
+	var tlsResumed bool
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GetConn: func(string) { connStart = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				connect = time.Since(connStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				tlsHandshake, tlsResumed = time.Since(tlsStart), state.DidResume
+			}
+		},
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}))
+
+	start := time.Now()
+	resp, err := c.Client.Do(req)
+	end := time.Now()
+
+	if err != nil {
+		return nil, fmt.Errorf("Query() sending request to server. error=%w", err)
+	}
+
+	// Exemplars and the series checked by assertions are counted from the body, which is also
+	// fingerprinted or decoded whole if enabled. Any other body is only drained so the connection
+	// can be reused, and its size accounted unless discarded
+	ok := resp.StatusCode == http.StatusOK && resp.Body != nil
+	countExemplars := ok && q.Endpoint == query.EndpointQueryExemplars
+	countSeries := ok && q.Asserts()
+	fingerprint := ok && c.Fingerprint
+	decodeAll := ok && c.Body == BodyDecode
+	decode := countExemplars || countSeries || fingerprint || decodeAll
+	var body []byte
+	var size, compressed int64
+	var transfer, decompress time.Duration
+	if resp.Body != nil {
+		switch {
+		case c.Body == BodyDiscard && !decode:
+			_, err = io.Copy(io.Discard, resp.Body)
+		case resp.Header.Get("Content-Encoding") == "gzip":
+			// The compressed body is received whole before being decompressed, so the time spent
+			// decompressing it is told apart from the time spent receiving it
+			var raw []byte
+			raw, err = io.ReadAll(resp.Body)
+			transfer = time.Since(start)
+			if err == nil {
+				compressed = int64(len(raw))
+				decompressStart := time.Now()
+				body, err = gunzip(raw)
+				decompress = time.Since(decompressStart)
+				size = int64(len(body))
+			}
+		case decode:
+			body, err = io.ReadAll(resp.Body)
+			size = int64(len(body))
+		default:
+			size, err = io.Copy(io.Discard, resp.Body)
+		}
+		if transfer == 0 {
+			transfer = time.Since(start)
+		}
+		resp.Body.Close()
+	}
+
+	response := &Response{Response: resp, Timestamp: Timestamp{Start: start, End: end}, Bytes: size, Connect: connect, TraceID: traceID, SpanID: spanID}
+	response.RequestID = requestID
+	response.Compressed, response.Decompress = compressed, decompress
+	response.Transfer = transfer
+	response.TLSHandshake, response.TLSResumed = tlsHandshake, tlsResumed
+	if !firstByte.IsZero() {
+		response.FirstByte = firstByte.Sub(start)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("Query() unexpected response. error=%w", &StatusError{StatusCode: resp.StatusCode})
+	}
+	// The body only matters if decoded or decompressed
+	if err != nil && (decode || compressed > 0) {
+		return response, fmt.Errorf("Query() reading response. error=%w", err)
+	}
+	if decode {
+		decodeStart := time.Now()
+		if countExemplars {
+			response.Exemplars, err = CountExemplars(body)
+		}
+		if countSeries && err == nil {
+			response.Series, err = CountSeries(body)
+		}
+		if fingerprint && err == nil {
+			response.Fingerprint, err = Fingerprint(body)
+		}
+		if decodeAll && err == nil {
+			var v any
+			err = json.Unmarshal(body, &v)
+		}
+		response.Decode = time.Since(decodeStart)
+		if err != nil {
+			return response, fmt.Errorf("Query() decoding response. error=%w", err)
+		}
+	}
+
+	return response, nil
+}
+
+// gunzip returns the decompressed gzip data.
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// CountExemplars returns the number of exemplars held by the body of a query_exemplars response.
+func CountExemplars(body []byte) (int, error) {
+	var r struct {
+		Data []struct {
+			Exemplars []json.RawMessage `json:"exemplars"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, series := range r.Data {
+		count += len(series.Exemplars)
+	}
+	return count, nil
+}
+
+// CountSeries returns the number of series held by the body of a response of the HTTP API, i.e. of
+// the vector or matrix result of a query, or of the items of the data of any other endpoint (e.g.
+// series, label names or values). Scalar and string results count as a single series.
+func CountSeries(body []byte) (int, error) {
+	var r struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return 0, err
+	}
+
+	var items []json.RawMessage
+	if len(r.Data) > 0 && r.Data[0] == '[' {
+		err := json.Unmarshal(r.Data, &items)
+		return len(items), err
+	}
+	var result struct {
+		ResultType string            `json:"resultType"`
+		Result     []json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(r.Data, &result); err != nil {
+		return 0, err
+	}
+	if result.ResultType == "scalar" || result.ResultType == "string" {
+		return 1, nil
+	}
+	return len(result.Result), nil
+}
+
+// Fingerprint returns a stable hash of the result held by the body of a response of the HTTP API,
+// i.e. of its series and their samples, or of the items of the data of any other endpoint. The
+// order of the series and of their labels doesn't change the hash, so it only differs between
+// responses holding different results.
+func Fingerprint(body []byte) (string, error) {
+	var r struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return "", err
+	}
+
+	// Vector and matrix results, and the data of the other endpoints, are lists of items whose
+	// order is not significant, while scalar and string results are hashed as a whole
+	items := []any{}
+	if len(r.Data) > 0 && r.Data[0] == '[' {
+		if err := json.Unmarshal(r.Data, &items); err != nil {
+			return "", err
+		}
+	} else {
+		var result struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(r.Data, &result); err != nil {
+			return "", err
+		}
+		var err error
+		if result.ResultType == "vector" || result.ResultType == "matrix" {
+			err = json.Unmarshal(result.Result, &items)
+		} else {
+			var whole any
+			err = json.Unmarshal(r.Data, &whole)
+			items = append(items, whole)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Objects are encoded with their keys sorted, e.g. the labels of every series
+	encoded := make([]string, len(items))
+	for i, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return "", err
+		}
+		encoded[i] = string(b)
+	}
+	sort.Strings(encoded)
+
+	h := fnv.New64a()
+	for _, e := range encoded {
+		h.Write([]byte(e))
+		h.Write([]byte{'\n'})
+	}
+	return strconv.FormatUint(h.Sum64(), 16), nil
+}
+
+// StatusError is returned when the target answers with a non successful status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status code: %d", e.StatusCode)
+}
+
+// ErrorClass groups query failures by their cause.
+type ErrorClass string
+
+const (
+	ErrorConnectionRefused ErrorClass = "connection refused"
+	ErrorTimeout           ErrorClass = "timeout"
+	ErrorClientStatus      ErrorClass = "4xx"
+	ErrorServerStatus      ErrorClass = "5xx"
+	ErrorBodyDecode        ErrorClass = "body decode"
+	ErrorOther             ErrorClass = "other"
+)
+
+// ErrorClasses lists every ErrorClass in the order they are reported.
+var ErrorClasses = []ErrorClass{
+	ErrorConnectionRefused, ErrorTimeout, ErrorClientStatus, ErrorServerStatus, ErrorBodyDecode, ErrorOther,
+}
+
+// ClassError is an error whose class is already known, e.g. because it was decoded from the
+// results of another process.
+type ClassError struct {
+	Class   ErrorClass
+	Message string
+}
+
+func (e *ClassError) Error() string {
+	return e.Message
+}
+
+// Classify returns the ErrorClass a query error belongs to.
+func Classify(err error) ErrorClass {
+	var classErr *ClassError
+	if errors.As(err, &classErr) {
+		return classErr.Class
+	}
+
+	var statusErr *StatusError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 500:
+		return ErrorServerStatus
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 400:
+		return ErrorClientStatus
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum):
+		return ErrorBodyDecode
+	}
+	return ErrorOther
+}
+
+// Timestamp the elapsed time from the beginning to the end of a specific Response.
+type Timestamp struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Response of a query. The embedded *http.Response is nil for queries not sent over HTTP.
+type Response struct {
+	*http.Response
+	Timestamp struct {
+		Start time.Time
+		End   time.Time
+	}
+	// FirstByte is the time from the start of the request until the first byte of its response was
+	// received, and Transfer until its whole body was, if measured
+	FirstByte time.Duration
+	Transfer  time.Duration
+	// Bytes is the size of the response body, once decompressed
+	Bytes int64
+	// Compressed is the size of the response body as received, if gzip compressed, and Decompress
+	// the time spent decompressing it, not included in the latency
+	Compressed int64
+	Decompress time.Duration
+	// Connect is the time spent opening a new connection for the request, included in the latency,
+	// zero if an idle connection was reused
+	Connect time.Duration
+	// TLSHandshake is the time spent on the TLS handshake of a new connection, part of the Connect
+	// time, zero if none was made, and TLSResumed whether it resumed a previous session
+	TLSHandshake time.Duration
+	TLSResumed   bool
+	// Decode is the time spent decoding the response body, zero if it is not decoded
+	Decode time.Duration
+	// Exemplars is the number of exemplars returned by a query_exemplars request
+	Exemplars int
+	// Series is the number of series returned, counted for the queries with assertions and remote
+	// read requests
+	Series int
+	// Fingerprint is the hash of the result returned, if fingerprinted
+	Fingerprint string
+	// TraceID and SpanID are the hex encoded W3C trace context the request was sent with, and
+	// RequestID its X-Request-ID header, if traced
+	TraceID   string
+	SpanID    string
+	RequestID string
+}
+
+// NewTraceContext returns the hex encoded IDs of a new random trace and of its root span.
+func NewTraceContext() (traceID, spanID string) {
+	var ids [24]byte
+	rand.Read(ids[:])
+	return hex.EncodeToString(ids[:16]), hex.EncodeToString(ids[16:])
+}
+
+// NewRequestID returns a new random request ID, formatted as a version 4 UUID as proxies such as
+// Envoy generate them.
+func NewRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	h := hex.EncodeToString(id[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// RenderRequest writes the canonical form of an HTTP request: the method and URL (whose query
+// parameters are sorted) followed by the headers sorted by name, one per line.
+func RenderRequest(w io.Writer, req *http.Request) {
+	fmt.Fprintf(w, "%s %s\n", req.Method, req.URL.String())
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(w, "  %s: %s\n", name, value)
+		}
+	}
+}
+
+// RenderRequests writes the canonical form of every request that would be sent for the queries.
+func (c *Client) RenderRequests(w io.Writer, queries []query.Query) error {
+	for i := range queries {
+		req, err := c.NewRequest(&queries[i])
+		if err != nil {
+			return fmt.Errorf("unable to build request for query=%v. err=%w", queries[i], err)
+		}
+		RenderRequest(w, req)
+	}
+	return nil
+}
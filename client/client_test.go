@@ -0,0 +1,632 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+func Test_getScheme(t *testing.T) {
+	var newStringPtr = func(s string) *string {
+		return &s
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want *string
+	}{
+		{
+			name: "https scheme",
+			text: "https://example.xyz",
+			want: newStringPtr("https://"),
+		},
+		{
+			name: "ftp scheme",
+			text: "ftp://example.xyz",
+			want: newStringPtr("ftp://"),
+		},
+		{
+			name: "http scheme",
+			text: "http://example.xyz",
+			want: newStringPtr("http://"),
+		},
+		{
+			name: "wrong scheme",
+			text: "://example.xyz",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getScheme(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getScheme() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ClientMock answers every request successfully, keeping the URL of the last one requested.
+type ClientMock struct {
+	URL *url.URL
+}
+
+func (c *ClientMock) Do(req *http.Request) (resp *http.Response, err error) {
+	c.URL = req.URL
+	return &http.Response{StatusCode: 200}, nil
+}
+
+// TestURL_Query checks the integrity of the url constructed by the method Query
+func TestURL_Query(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *query.Query
+		url     *url.URL
+		version string
+		want    *url.URL
+	}{
+		{
+			url: &url.URL{Scheme: "https", Host: "promscale.xyz"},
+			query: &query.Query{
+				Query: "some query",
+				Start: 100000,
+				End:   999999,
+				Step:  50,
+			},
+			version: "v1",
+			want: &url.URL{
+				Scheme:   "https",
+				Host:     "promscale.xyz",
+				Path:     "/api/v1/query_range",
+				RawQuery: "end=1970-01-01T00%3A16%3A39Z&query=some+query&start=1970-01-01T00%3A01%3A40Z&step=50",
+			},
+		},
+		{
+			name: "series",
+			url:  &url.URL{Scheme: "https", Host: "promscale.xyz"},
+			query: &query.Query{
+				Query:    `{job="api"}`,
+				Start:    100000,
+				End:      999999,
+				Endpoint: query.EndpointSeries,
+			},
+			version: "v1",
+			want: &url.URL{
+				Scheme:   "https",
+				Host:     "promscale.xyz",
+				Path:     "/api/v1/series",
+				RawQuery: "end=1970-01-01T00%3A16%3A39Z&match%5B%5D=%7Bjob%3D%22api%22%7D&start=1970-01-01T00%3A01%3A40Z",
+			},
+		},
+		{
+			name: "exemplars",
+			url:  &url.URL{Scheme: "https", Host: "promscale.xyz"},
+			query: &query.Query{
+				Query:    "up",
+				Start:    100000,
+				End:      999999,
+				Endpoint: query.EndpointQueryExemplars,
+			},
+			version: "v1",
+			want: &url.URL{
+				Scheme:   "https",
+				Host:     "promscale.xyz",
+				Path:     "/api/v1/query_exemplars",
+				RawQuery: "end=1970-01-01T00%3A16%3A39Z&query=up&start=1970-01-01T00%3A01%3A40Z",
+			},
+		},
+		{
+			name: "label values without matcher",
+			url:  &url.URL{Scheme: "https", Host: "promscale.xyz"},
+			query: &query.Query{
+				Start:    100000,
+				End:      999999,
+				Endpoint: "label/job/values",
+			},
+			version: "v1",
+			want: &url.URL{
+				Scheme:   "https",
+				Host:     "promscale.xyz",
+				Path:     "/api/v1/label/job/values",
+				RawQuery: "end=1970-01-01T00%3A16%3A39Z&start=1970-01-01T00%3A01%3A40Z",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &ClientMock{}
+			c := &Client{
+				Client:  mock,
+				URL:     tt.url,
+				Version: "v1",
+			}
+			_, err := c.Query(tt.query)
+			if err != nil {
+				t.Errorf("Client.Query() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(mock.URL, tt.want) {
+				t.Errorf("Client.Query() = %v, want %v", mock.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{
+			name: "connection refused",
+			err:  fmt.Errorf("wrapped: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+			want: ErrorConnectionRefused,
+		},
+		{
+			name: "timeout",
+			err:  fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			want: ErrorTimeout,
+		},
+		{
+			name: "4xx",
+			err:  fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 400}),
+			want: ErrorClientStatus,
+		},
+		{
+			name: "5xx",
+			err:  fmt.Errorf("wrapped: %w", &StatusError{StatusCode: 503}),
+			want: ErrorServerStatus,
+		},
+		{
+			name: "body decode",
+			err:  fmt.Errorf("wrapped: %w", json.Unmarshal([]byte("{"), &struct{}{})),
+			want: ErrorBodyDecode,
+		},
+		{
+			name: "other",
+			err:  errors.New("something else"),
+			want: ErrorOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountExemplars(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "exemplars of several series",
+			body: `{"status":"success","data":[{"seriesLabels":{"job":"a"},"exemplars":[{"labels":{"trace_id":"1"},"value":"1","timestamp":1},{"labels":{"trace_id":"2"},"value":"2","timestamp":2}]},{"seriesLabels":{"job":"b"},"exemplars":[{"labels":{"trace_id":"3"},"value":"3","timestamp":3}]}]}`,
+			want: 3,
+		},
+		{name: "no exemplars", body: `{"status":"success","data":[]}`},
+		{name: "invalid body", body: `{"status":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CountExemplars([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CountExemplars() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CountExemplars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountSeries(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{name: "matrix", body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[1,"1"]]},{"metric":{"job":"b"},"values":[[1,"1"]]}]}}`, want: 2},
+		{name: "empty vector", body: `{"status":"success","data":{"resultType":"vector","result":[]}}`},
+		{name: "scalar", body: `{"status":"success","data":{"resultType":"scalar","result":[1,"1"]}}`, want: 1},
+		{name: "series", body: `{"status":"success","data":[{"__name__":"up","job":"a"}]}`, want: 1},
+		{name: "label values", body: `{"status":"success","data":["a","b","c"]}`, want: 3},
+		{name: "invalid body", body: `{"status":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CountSeries([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CountSeries() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CountSeries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	matrix := `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a","instance":"x"},"values":[[1,"1"]]},{"metric":{"job":"b"},"values":[[1,"2"]]}]}}`
+	tests := []struct {
+		name    string
+		body    string
+		same    bool
+		wantErr bool
+	}{
+		{name: "same result", body: matrix, same: true},
+		{name: "series and labels reordered", body: `{"data":{"result":[{"values":[[1,"2"]],"metric":{"job":"b"}},{"metric":{"instance":"x","job":"a"},"values":[[1,"1"]]}],"resultType":"matrix"},"status":"success"}`, same: true},
+		{name: "different sample", body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a","instance":"x"},"values":[[1,"1"]]},{"metric":{"job":"b"},"values":[[1,"3"]]}]}}`},
+		{name: "missing series", body: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"b"},"values":[[1,"2"]]}]}}`},
+		{name: "scalar", body: `{"status":"success","data":{"resultType":"scalar","result":[1,"1"]}}`},
+		{name: "label values", body: `{"status":"success","data":["a","b"]}`},
+		{name: "invalid body", body: `{"status":`, wantErr: true},
+	}
+	want, err := Fingerprint([]byte(matrix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Fingerprint([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fingerprint() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (got == want) != tt.same {
+				t.Errorf("Fingerprint() = %v, want the same as %v: %v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func TestClient_RenderRequests(t *testing.T) {
+	queries := []query.Query{
+		{Query: `rate(demo_cpu_usage_seconds_total{mode=~"idle|user"}[5m])`, Start: 1597056698698, End: 1597059548699, Step: 15000},
+		{Query: "up", Start: 0, End: 60000, Step: 15},
+	}
+	want := `GET https://promscale.xyz/api/v1/query_range?end=2020-08-10T11%3A39%3A08Z&query=rate%28demo_cpu_usage_seconds_total%7Bmode%3D~%22idle%7Cuser%22%7D%5B5m%5D%29&start=2020-08-10T10%3A51%3A38Z&step=15000
+  Accept-Encoding: gzip
+GET https://promscale.xyz/api/v1/query_range?end=1970-01-01T00%3A01%3A00Z&query=up&start=1970-01-01T00%3A00%3A00Z&step=15
+  Accept-Encoding: gzip
+`
+
+	var buf bytes.Buffer
+	if err := New("promscale.xyz").RenderRequests(&buf, queries); err != nil {
+		t.Fatalf("Client.RenderRequests() error = %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("Client.RenderRequests() = %v, want %v", got, want)
+	}
+}
+
+// HeaderClientMock answers every request successfully, keeping the headers of the last one.
+type HeaderClientMock struct {
+	Header http.Header
+}
+
+func (c *HeaderClientMock) Do(req *http.Request) (resp *http.Response, err error) {
+	c.Header = req.Header
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func TestClient_Query_trace(t *testing.T) {
+	mock := &HeaderClientMock{}
+	c := &Client{Client: mock, URL: &url.URL{Scheme: "http", Host: "promscale.xyz"}, Version: "v1", Trace: true}
+	resp, err := c.Query(&query.Query{Query: "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.TraceID) != 32 || len(resp.SpanID) != 16 {
+		t.Fatalf("Client.Query() trace context = %q %q, want 16 and 8 hex encoded bytes", resp.TraceID, resp.SpanID)
+	}
+	if got, want := mock.Header.Get("traceparent"), "00-"+resp.TraceID+"-"+resp.SpanID+"-01"; got != want {
+		t.Errorf("Client.Query() traceparent = %q, want %q", got, want)
+	}
+	if got := mock.Header.Get("X-Request-ID"); got != resp.RequestID || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got) {
+		t.Errorf("Client.Query() X-Request-ID = %q, want the UUID %q", got, resp.RequestID)
+	}
+	if again, _ := c.Query(&query.Query{Query: "up"}); again.RequestID == resp.RequestID {
+		t.Errorf("Client.Query() sent two requests with the same ID %q", resp.RequestID)
+	}
+
+	c.Trace = false
+	if resp, _ := c.Query(&query.Query{Query: "up"}); resp.TraceID != "" || resp.RequestID != "" || mock.Header.Get("traceparent") != "" {
+		t.Errorf("Client.Query() traced an untraced request")
+	}
+}
+
+func TestClient_Query_connect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tests := []struct {
+		name             string
+		disableKeepAlive bool
+		wantConnects     []bool
+	}{
+		{name: "keep-alive", wantConnects: []bool{true, false}},
+		{name: "no keep-alive", disableKeepAlive: true, wantConnects: []bool{true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(srv.URL)
+			transport, err := NewTransport(TransportOptions{DisableKeepAlives: tt.disableKeepAlive})
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Client = &http.Client{Transport: transport}
+			for i, want := range tt.wantConnects {
+				resp, err := c.Query(&query.Query{Query: "up"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := resp.Connect > 0; got != want {
+					t.Errorf("Client.Query() #%d opened a connection = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_Query_tls(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	roots := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	tests := []struct {
+		name         string
+		sessionCache bool
+		wantResumed  []bool
+	}{
+		{name: "full handshakes", wantResumed: []bool{false, false}},
+		{name: "session cache", sessionCache: true, wantResumed: []bool{false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(srv.URL)
+			transport, err := NewTransport(TransportOptions{DisableKeepAlives: true, TLSSessionCache: tt.sessionCache})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.RootCAs = roots
+			c.Client = &http.Client{Transport: transport}
+			for i, want := range tt.wantResumed {
+				resp, err := c.Query(&query.Query{Query: "up"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.TLSHandshake <= 0 || resp.TLSHandshake > resp.Connect || resp.TLSResumed != want {
+					t.Errorf("Client.Query() #%d TLS handshake = %v of %v, resumed %v, want resumed %v", i, resp.TLSHandshake, resp.Connect, resp.TLSResumed, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_Query_timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.Timeout = 10 * time.Millisecond
+	_, err := c.Query(&query.Query{Query: "up"})
+	if got := Classify(err); got != ErrorTimeout {
+		t.Errorf("Client.Query() past the deadline error = %v of class %s, want %s", err, got, ErrorTimeout)
+	}
+	if _, err := c.Query(&query.Query{Query: "up", Timeout: time.Second}); err != nil {
+		t.Errorf("Client.Query() with a longer query timeout error = %v, want nil", err)
+	}
+}
+
+func TestClient_Query_gzip(t *testing.T) {
+	body := strings.Repeat(`{"status":"success","data":{"resultType":"matrix","result":[]}}`, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			io.WriteString(w, body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, body)
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	resp, err := c.Query(&query.Query{Query: "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Bytes != int64(len(body)) || resp.Compressed == 0 || resp.Compressed >= resp.Bytes {
+		t.Errorf("Client.Query() = %d bytes, %d compressed, want %d bytes compressed", resp.Bytes, resp.Compressed, len(body))
+	}
+	if resp.FirstByte <= 0 || resp.Transfer < resp.FirstByte {
+		t.Errorf("Client.Query() first byte after %v, transfer after %v, want the first byte before the whole body", resp.FirstByte, resp.Transfer)
+	}
+
+	c.Gzip = false
+	if resp, err := c.Query(&query.Query{Query: "up"}); err != nil || resp.Bytes != int64(len(body)) || resp.Compressed != 0 {
+		t.Errorf("Client.Query() without gzip = %d bytes, %d compressed, %v, want %d uncompressed bytes", resp.Bytes, resp.Compressed, err, len(body))
+	}
+}
+
+func TestClient_Query_body(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"vector","result":[]}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		mode       string
+		wantBytes  int64
+		wantDecode bool
+	}{
+		{mode: BodyDiscard},
+		{mode: BodyCount, wantBytes: int64(len(body))},
+		{mode: BodyDecode, wantBytes: int64(len(body)), wantDecode: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			c := New(srv.URL)
+			c.Body = tt.mode
+			resp, err := c.Query(&query.Query{Query: "up"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Bytes != tt.wantBytes || (resp.Decode > 0) != tt.wantDecode {
+				t.Errorf("Client.Query() = %d bytes, decoded in %v, want %d bytes, decoded %v", resp.Bytes, resp.Decode, tt.wantBytes, tt.wantDecode)
+			}
+		})
+	}
+}
+
+func TestClient_Query_socket(t *testing.T) {
+	socket := t.TempDir() + "/promscale.sock"
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *url.URL
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	if path, ok := SocketPath("http://localhost:9201"); ok || path != "" {
+		t.Errorf("SocketPath() of a TCP target = %q, %v, want none", path, ok)
+	}
+	if _, err := New("unix://" + socket).Query(&query.Query{Query: "up", Step: 60}); err != nil {
+		t.Fatalf("Client.Query() error = %v", err)
+	}
+	if got == nil || got.Path != "/api/v1/query_range" {
+		t.Errorf("Client.Query() requested %v over the socket, want /api/v1/query_range", got)
+	}
+}
+
+func TestClient_NewRequest_alignStep(t *testing.T) {
+	c := New("promscale.xyz")
+	c.AlignStep = true
+	req, err := c.NewRequest(&query.Query{Query: "up", Start: 1597056698698, End: 1597059548699, Step: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := req.URL.Query()
+	if start, end := params.Get("start"), params.Get("end"); start != "2020-08-10T10:51:00Z" || end != "2020-08-10T11:39:00Z" {
+		t.Errorf("Client.NewRequest() range = %s-%s, want 2020-08-10T10:51:00Z-2020-08-10T11:39:00Z", start, end)
+	}
+}
+
+func TestClient_NewRequest_thanos(t *testing.T) {
+	yes, no := true, false
+	c := New("promscale.xyz")
+	c.Thanos = query.Thanos{Dedup: &yes, MaxSourceResolution: "auto"}
+	req, err := c.NewRequest(&query.Query{Query: "up", Step: 60, Thanos: query.Thanos{Dedup: &no, PartialResponse: &yes}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := req.URL.Query()
+	if got := params.Get("dedup") + "," + params.Get("partial_response") + "," + params.Get("max_source_resolution"); got != "false,true,auto" {
+		t.Errorf("Client.NewRequest() dedup,partial_response,max_source_resolution = %s, want false,true,auto", got)
+	}
+}
+
+func TestClient_NewRequest_pathPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{name: "no path", host: "https://promscale.xyz", want: "https://promscale.xyz/api/v1/query_range"},
+		{name: "root path", host: "https://promscale.xyz/", want: "https://promscale.xyz/api/v1/query_range"},
+		{name: "prefix", host: "https://promscale.xyz/prometheus", want: "https://promscale.xyz/prometheus/api/v1/query_range"},
+		{name: "prefix with trailing slash", host: "promscale.xyz:9090/a/b/", want: "https://promscale.xyz:9090/a/b/api/v1/query_range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := New(tt.host).NewRequest(&query.Query{Query: "up", Step: 60})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.URL.RawQuery = ""
+			if got := req.URL.String(); got != tt.want {
+				t.Errorf("Client.NewRequest() url = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_NewRequest_cacheBust(t *testing.T) {
+	c := New("promscale.xyz")
+	q := &query.Query{Query: "up", Step: 60}
+
+	c.CacheBust = CacheBustHeader
+	req, err := c.NewRequest(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Client.NewRequest() Cache-Control = %q, want no-cache", got)
+	}
+
+	c.CacheBust = CacheBustMatcher
+	first, _ := c.NewRequest(q)
+	second, _ := c.NewRequest(q)
+	got := first.URL.Query().Get("query")
+	if !strings.Contains(got, query.NonceLabel) || got == second.URL.Query().Get("query") {
+		t.Errorf("Client.NewRequest() queries = %q and %q, want unique nonce matchers", got, second.URL.Query().Get("query"))
+	}
+	if first.Header.Get("Cache-Control") != "" || q.Query != "up" {
+		t.Errorf("Client.NewRequest() busted the cache with headers or changed the query %q", q.Query)
+	}
+}
+
+func TestClient_NewRequest_headers(t *testing.T) {
+	c := New("promscale.xyz")
+	c.Gzip, c.Trace = false, true
+	req, err := c.NewRequest(&query.Query{Query: "up", Step: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Accept-Encoding"); got != "identity" {
+		t.Errorf("Client.NewRequest() Accept-Encoding = %q, want identity", got)
+	}
+	if req.Header.Get("traceparent") == "" || req.Header.Get("X-Request-ID") == "" {
+		t.Errorf("Client.NewRequest() headers = %v, want a traceparent and X-Request-ID", req.Header)
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Ready checks that the target is ready to answer queries through its /-/ready endpoint, or through
+// a trivial instant query if it doesn't serve that endpoint.
+func (c *Client) Ready() error {
+	_, err := c.get("/-/ready", nil)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		_, err = c.get("/api/"+c.Version+"/query", url.Values{"query": {"vector(1)"}})
+	}
+	if err != nil {
+		return fmt.Errorf("Ready() checking target. error=%w", err)
+	}
+	return nil
+}
+
+// WaitReady checks whether the target is Ready every interval until it is or the timeout elapses,
+// returning the last error in that case. The target is checked once if the timeout is zero.
+func (c *Client) WaitReady(timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.Ready()
+		if err == nil || !time.Now().Add(interval).Before(deadline) {
+			return err
+		}
+		time.Sleep(interval)
+	}
+}
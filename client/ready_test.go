@@ -0,0 +1,58 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Ready(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  map[string]int
+		wantErr bool
+	}{
+		{name: "ready", status: map[string]int{"/-/ready": http.StatusOK}},
+		{name: "not ready", status: map[string]int{"/-/ready": http.StatusServiceUnavailable}, wantErr: true},
+		{name: "instant query", status: map[string]int{"/api/v1/query": http.StatusOK}},
+		{name: "failing instant query", status: map[string]int{"/api/v1/query": http.StatusInternalServerError}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status, ok := tt.status[r.URL.Path]
+				if !ok {
+					status = http.StatusNotFound
+				}
+				if r.URL.Path == "/api/v1/query" && r.URL.Query().Get("query") != "vector(1)" {
+					status = http.StatusBadRequest
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			if err := New(srv.URL).Ready(); (err != nil) != tt.wantErr {
+				t.Errorf("Client.Ready() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_WaitReady(t *testing.T) {
+	var checks int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checks++; checks < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	if err := c.WaitReady(0, time.Millisecond); err == nil {
+		t.Errorf("Client.WaitReady() without timeout error = nil, want an error")
+	}
+	if err := c.WaitReady(time.Second, time.Millisecond); err != nil || checks != 3 {
+		t.Errorf("Client.WaitReady() error = %v after %d checks, want nil after 3", err, checks)
+	}
+}
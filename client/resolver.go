@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IP families the addresses of a host can be preferred of.
+const (
+	IPv4 = "4"
+	IPv6 = "6"
+)
+
+// Resolver resolves the host names connections are opened to with the DNS options of
+// TransportOptions, rather than through the resolver of the system, so benchmarks aren't skewed by
+// a slow or flappy DNS. Connections are opened to the addresses of a host in turn until one
+// succeeds, those of the preferred IP family first.
+type Resolver struct {
+	// Hosts pins host names to an address, skipping their lookup
+	Hosts map[string]netip.Addr
+	// CacheTTL caches the addresses resolved for that long, not cached if zero
+	CacheTTL time.Duration
+	// Family is the IP family preferred, IPv4 or IPv6, the order resolved kept if empty
+	Family string
+
+	dialer *net.Dialer
+	// lookup resolves the addresses of a host, see net.Resolver.LookupNetIP
+	lookup func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+	mu    sync.Mutex
+	cache map[string]cachedAddrs
+}
+
+type cachedAddrs struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// NewResolver returns a Resolver opening connections with the given dialer, looking host names up
+// with the DNS server of the options, if given.
+func NewResolver(o TransportOptions, dialer *net.Dialer) (*Resolver, error) {
+	r := &Resolver{CacheTTL: o.DNSCacheTTL, Family: o.IPFamily, dialer: dialer, lookup: net.DefaultResolver.LookupNetIP, cache: map[string]cachedAddrs{}}
+	if err := ValidateIPFamily(o.IPFamily); err != nil {
+		return nil, err
+	}
+	if len(o.Resolve) > 0 {
+		r.Hosts = map[string]netip.Addr{}
+		for host, ip := range o.Resolve {
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q of host %s. err=%w", ip, host, err)
+			}
+			r.Hosts[host] = addr
+		}
+	}
+	if o.DNSServer != "" {
+		server, err := ParseDNSServer(o.DNSServer)
+		if err != nil {
+			return nil, err
+		}
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+		r.lookup = resolver.LookupNetIP
+	}
+	return r, nil
+}
+
+// Lookup returns the addresses of the host, those of the preferred family first.
+func (r *Resolver) Lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, ok := r.Hosts[host]; ok {
+		return []netip.Addr{addr}, nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	if r.CacheTTL > 0 {
+		r.mu.Lock()
+		cached, ok := r.cache[host]
+		r.mu.Unlock()
+		if ok && time.Now().Before(cached.expires) {
+			return cached.addrs, nil
+		}
+	}
+
+	addrs, err := r.lookup(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for i := range addrs {
+		addrs[i] = addrs[i].Unmap()
+	}
+	if r.Family != "" {
+		sort.SliceStable(addrs, func(i, j int) bool { return r.preferred(addrs[i]) && !r.preferred(addrs[j]) })
+	}
+	if r.CacheTTL > 0 {
+		r.mu.Lock()
+		r.cache[host] = cachedAddrs{addrs: addrs, expires: time.Now().Add(r.CacheTTL)}
+		r.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// preferred reports whether the address is of the preferred family.
+func (r *Resolver) preferred(addr netip.Addr) bool {
+	return (r.Family == IPv4) == addr.Is4()
+}
+
+// DialContext opens a connection to the given address, trying the addresses its host resolves to
+// in turn.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := r.Lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// ParseDNSServer returns the address of a DNS server given as host[:port], the port defaulting to
+// 53.
+func ParseDNSServer(s string) (string, error) {
+	if _, _, err := net.SplitHostPort(s); err == nil {
+		return s, nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if host == "" {
+		return "", fmt.Errorf("invalid DNS server %q", s)
+	}
+	return net.JoinHostPort(host, "53"), nil
+}
+
+// ParseResolve parses a host name pinned to an address, given as host:address like curl --resolve
+// without the port, e.g. promscale.internal:10.0.0.5 or promscale.internal:::1.
+func ParseResolve(s string) (host, addr string, err error) {
+	host, addr, ok := strings.Cut(s, ":")
+	if !ok || host == "" {
+		return "", "", fmt.Errorf("invalid resolve %q, want host:address", s)
+	}
+	if _, err := netip.ParseAddr(addr); err != nil {
+		return "", "", fmt.Errorf("invalid resolve %q, want host:address. err=%w", s, err)
+	}
+	return host, addr, nil
+}
+
+// ValidateIPFamily returns an error if the family is neither empty, IPv4 nor IPv6.
+func ValidateIPFamily(family string) error {
+	switch family {
+	case "", IPv4, IPv6:
+		return nil
+	}
+	return fmt.Errorf("unknown IP family %q, want %s or %s", family, IPv4, IPv6)
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResolver_Lookup(t *testing.T) {
+	v4, v6 := netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("fd00::1")
+	tests := []struct {
+		name        string
+		options     TransportOptions
+		host        string
+		lookups     int
+		want        []netip.Addr
+		wantLookups int
+	}{
+		{name: "resolved order", host: "promscale.internal", lookups: 2, want: []netip.Addr{v6, v4}, wantLookups: 2},
+		{name: "ipv4 first", options: TransportOptions{IPFamily: IPv4}, host: "promscale.internal", lookups: 1, want: []netip.Addr{v4, v6}, wantLookups: 1},
+		{name: "cached", options: TransportOptions{DNSCacheTTL: time.Minute}, host: "promscale.internal", lookups: 3, want: []netip.Addr{v6, v4}, wantLookups: 1},
+		{name: "pinned", options: TransportOptions{Resolve: map[string]string{"promscale.internal": "10.0.0.5"}}, host: "promscale.internal", lookups: 1, want: []netip.Addr{netip.MustParseAddr("10.0.0.5")}},
+		{name: "address", host: "10.0.0.7", lookups: 1, want: []netip.Addr{netip.MustParseAddr("10.0.0.7")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewResolver(tt.options, &net.Dialer{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lookups int
+			r.lookup = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+				lookups++
+				return []netip.Addr{v6, netip.AddrFrom16(v4.As16())}, nil
+			}
+			var got []netip.Addr
+			for range tt.lookups {
+				if got, err = r.Lookup(context.Background(), tt.host); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || lookups != tt.wantLookups {
+				t.Errorf("Resolver.Lookup() = %v after %d lookups, want %v after %d", got, lookups, tt.want, tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestNewTransport_resolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	transport, err := NewTransport(TransportOptions{Resolve: map[string]string{"promscale.invalid": "127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://promscale.invalid:" + port + "/api/v1/query")
+	if err != nil {
+		t.Fatalf("NewTransport() request to a pinned host error = %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := NewTransport(TransportOptions{IPFamily: "5"}); err == nil {
+		t.Errorf("NewTransport() with an unknown IP family error = nil")
+	}
+}
+
+func TestParseDNSServer(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{s: "10.0.0.2", want: "10.0.0.2:53"},
+		{s: "10.0.0.2:5353", want: "10.0.0.2:5353"},
+		{s: "fd00::2", want: "[fd00::2]:53"},
+		{s: "[fd00::2]:5353", want: "[fd00::2]:5353"},
+		{s: "dns.internal", want: "dns.internal:53"},
+		{s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseDNSServer(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDNSServer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDNSServer() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		s        string
+		wantHost string
+		wantAddr string
+		wantErr  bool
+	}{
+		{s: "promscale.internal:10.0.0.5", wantHost: "promscale.internal", wantAddr: "10.0.0.5"},
+		{s: "promscale.internal:::1", wantHost: "promscale.internal", wantAddr: "::1"},
+		{s: "promscale.internal", wantErr: true},
+		{s: "promscale.internal:promscale.local", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			host, addr, err := ParseResolve(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if host != tt.wantHost || addr != tt.wantAddr {
+				t.Errorf("ParseResolve() = %s, %s, want %s, %s", host, addr, tt.wantHost, tt.wantAddr)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name            string
+		options         TransportOptions
+		wantIdleTimeout time.Duration
+	}{
+		{name: "defaults", wantIdleTimeout: 90 * time.Second},
+		{
+			name:            "tuned",
+			options:         TransportOptions{MaxIdleConns: 10, MaxIdleConnsPerHost: 5, MaxConnsPerHost: 8, IdleConnTimeout: time.Second},
+			wantIdleTimeout: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewTransport(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.MaxIdleConns != tt.options.MaxIdleConns || got.MaxIdleConnsPerHost != tt.options.MaxIdleConnsPerHost ||
+				got.MaxConnsPerHost != tt.options.MaxConnsPerHost || got.IdleConnTimeout != tt.wantIdleTimeout {
+				t.Errorf("NewTransport() = %+v, want %+v with idle timeout %v", got, tt.options, tt.wantIdleTimeout)
+			}
+			if got.Proxy == nil || got.DialContext == nil {
+				t.Errorf("NewTransport() dropped the defaults of http.DefaultTransport")
+			}
+		})
+	}
+}
+
+func TestNewRoundTripper(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	tests := []struct {
+		version   string
+		wantProto string
+		wantErr   bool
+	}{
+		{version: "", wantProto: "HTTP/1.1"},
+		{version: HTTP1, wantProto: "HTTP/1.1"},
+		{version: HTTP2, wantProto: "HTTP/2.0"},
+		{version: "4", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			rt, err := NewRoundTripper(TransportOptions{HTTPVersion: tt.version})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRoundTripper() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp, err := (&http.Client{Transport: rt}).Get(srv.URL)
+			if err != nil {
+				t.Fatalf("NewRoundTripper() request error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.Proto != tt.wantProto {
+				t.Errorf("NewRoundTripper() negotiated %s, want %s", resp.Proto, tt.wantProto)
+			}
+		})
+	}
+
+	if rt, _ := NewRoundTripper(TransportOptions{HTTPVersion: HTTP3}); reflect.TypeOf(rt) != reflect.TypeOf(&http3.Transport{}) {
+		t.Errorf("NewRoundTripper() HTTP/3 transport = %T, want *http3.Transport", rt)
+	}
+}
+
+func TestNewRoundTripper_http3(t *testing.T) {
+	rt, err := NewRoundTripper(TransportOptions{HTTPVersion: HTTP3, IdleConnTimeout: time.Minute, TLSSessionCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rt.(*http3.Transport)
+	if got.QUICConfig.MaxIdleTimeout != time.Minute || got.TLSClientConfig == nil || got.TLSClientConfig.ClientSessionCache == nil {
+		t.Errorf("NewRoundTripper() = %+v, want the idle timeout and TLS session cache of the options", got)
+	}
+
+	// Without keep-alives every request opens a connection of its own
+	cert := httptest.NewTLSServer(nil)
+	cert.Close()
+	var conns atomic.Int32
+	srv := &http3.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		TLSConfig: http3.ConfigureTLSConfig(cert.TLS),
+		ConnContext: func(ctx context.Context, c *quic.Conn) context.Context {
+			conns.Add(1)
+			return ctx
+		},
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(conn)
+	defer srv.Close()
+
+	rt, _ = NewRoundTripper(TransportOptions{HTTPVersion: HTTP3, DisableKeepAlives: true})
+	single := rt.(*singleUseTransport)
+	newTransport := single.New
+	single.New = func() *http3.Transport {
+		transport := newTransport()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		return transport
+	}
+	for range 2 {
+		resp, err := (&http.Client{Transport: rt}).Get("https://" + conn.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("NewRoundTripper() request error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if got := conns.Load(); got != 2 {
+		t.Errorf("NewRoundTripper() opened %d connections for 2 requests without keep-alives, want 2", got)
+	}
+}
+
+func TestNewTransport_proxy(t *testing.T) {
+	var got *http.Request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer proxy.Close()
+
+	tests := []struct {
+		name    string
+		proxy   string
+		wantErr bool
+	}{
+		{name: "authenticated", proxy: "http://user:secret@" + proxy.Listener.Addr().String()},
+		{name: "unsupported scheme", proxy: "ftp://" + proxy.Listener.Addr().String(), wantErr: true},
+		{name: "no host", proxy: "socks5://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewTransport(TransportOptions{Proxy: tt.proxy})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			resp, err := (&http.Client{Transport: transport}).Get("http://promscale.invalid/api/v1/query")
+			if err != nil {
+				t.Fatalf("NewTransport() request error = %v", err)
+			}
+			resp.Body.Close()
+			if got.URL.String() != "http://promscale.invalid/api/v1/query" || got.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+				t.Errorf("NewTransport() sent %s with %v through the proxy", got.URL, got.Header)
+			}
+		})
+	}
+
+	if _, err := NewRoundTripper(TransportOptions{HTTPVersion: HTTP3, Proxy: proxy.URL}); err == nil {
+		t.Errorf("NewRoundTripper() HTTP/3 through a proxy error = nil, want an error")
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer server.Close()
+
+	transport := &HeaderTransport{Base: http.DefaultTransport, Header: http.Header{"X-Scope-Orgid": {"tenant"}, "Cache-Control": {"no-store"}}}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get("X-Scope-OrgID") != "tenant" || got.Get("Cache-Control") != "no-store" {
+		t.Errorf("HeaderTransport sent %v, want the headers of the run", got)
+	}
+	if req.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("HeaderTransport modified the request headers %v", req.Header)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{header: "x-scope-orgid: tenant", wantName: "X-Scope-Orgid", wantValue: "tenant"},
+		{header: "Cache-Control:no-store, max-age=0", wantName: "Cache-Control", wantValue: "no-store, max-age=0"},
+		{header: "X-Empty:", wantName: "X-Empty"},
+		{header: "no separator", wantErr: true},
+		{header: ": value", wantErr: true},
+		{header: "Bad Name: value", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			name, value, err := ParseHeader(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || value != tt.wantValue {
+				t.Errorf("ParseHeader() = %q, %q, want %q, %q", name, value, tt.wantName, tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of the tool as completed by the shells.
+type command struct {
+	name  string
+	usage string
+	// args are the words completed as the first argument of the subcommand, e.g. those of results
+	args []string
+}
+
+// commands are the subcommands of the tool, in the order they're offered.
+var commands = []command{
+	{name: "benchmark", usage: "Run the queries of a file against the target"},
+	{name: "estimate", usage: "Predict the requests and duration of a benchmark run"},
+	{name: "merge", usage: "Compare the summaries of runs, or combine their raw results"},
+	{name: "compare", usage: "Tell whether the query times of two runs differ beyond the noise"},
+	{name: "report", usage: "Render the raw results of runs as a Markdown or HTML report"},
+	{name: "render", usage: "Render the requests the benchmark would send"},
+	{name: "serve", usage: "Serve the web UI"},
+	{name: "agent", usage: "Run the queries of the shards sent by a coordinator"},
+	{name: "record", usage: "Record the queries sent to a target through a proxy"},
+	{name: "write", usage: "Push synthetic samples through remote write"},
+	{name: "results", usage: "List or show the runs saved to a store", args: []string{"list", "show"}},
+	{name: "mockserver", usage: "Serve a mock Prometheus"},
+	{name: "version", usage: "Print the build of the tool"},
+	{name: "completion", usage: "Print the completions of a shell", args: shells},
+}
+
+// shells are the shells completions are generated for.
+var shells = []string{"bash", "zsh", "fish"}
+
+// flagSets collects the flag sets of the subcommands as they're created while listing their flags,
+// see commandFlags, nil otherwise.
+var flagSets map[string]*flag.FlagSet
+
+// newFlagSet returns the flag set of the named subcommand, exiting on errors.
+func newFlagSet(name string) *flag.FlagSet {
+	if flagSets == nil {
+		return flag.NewFlagSet(name, flag.ExitOnError)
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flagSets[name] = fs
+	return fs
+}
+
+// commandFlags returns the flags of every subcommand by name, sorted. They're collected by asking
+// every subcommand for its help, which returns as soon as its flags are parsed, so the completions
+// can't drift from the flags actually defined.
+func commandFlags() map[string][]*flag.Flag {
+	flagSets = map[string]*flag.FlagSet{}
+	defer func() { flagSets = nil }()
+
+	help := []string{"-h"}
+	parseArgs(append([]string{"benchmark"}, help...))
+	parseArgs(append([]string{"estimate"}, help...))
+	mergeCommand(help, io.Discard)
+	compareCommand(help, io.Discard)
+	reportCommand(help, io.Discard)
+	renderCommand(help, io.Discard)
+	serveCommand(help)
+	agentCommand(help)
+	recordCommand(help)
+	writeCommand(help, io.Discard)
+	resultsCommand(append([]string{"list"}, help...), io.Discard)
+	resultsCommand(append([]string{"show"}, help...), io.Discard)
+	mockServerCommand(help)
+	versionCommand(help, io.Discard)
+
+	flags := map[string][]*flag.Flag{}
+	for name, fs := range flagSets {
+		// The flags of nested subcommands, e.g. results list, are those of their parent
+		name, _, _ = strings.Cut(name, " ")
+		fs.VisitAll(func(f *flag.Flag) {
+			for _, seen := range flags[name] {
+				if seen.Name == f.Name {
+					return
+				}
+			}
+			flags[name] = append(flags[name], f)
+		})
+	}
+	for _, fs := range flags {
+		sort.Slice(fs, func(i, j int) bool { return fs[i].Name < fs[j].Name })
+	}
+	return flags
+}
+
+// completionCommand implements the `completion` subcommand, which prints the script completing the
+// subcommands and flags of the tool in the given shell.
+func completionCommand(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("completion takes a shell: %s", strings.Join(shells, ", "))
+	}
+	flags := commandFlags()
+	switch args[0] {
+	case "bash":
+		return bashCompletion(w, flags)
+	case "zsh":
+		return zshCompletion(w, flags)
+	case "fish":
+		return fishCompletion(w, flags)
+	}
+	return fmt.Errorf("unknown shell %q, want %s", args[0], strings.Join(shells, ", "))
+}
+
+// isBoolFlag reports whether the flag takes no value, e.g. -has-header.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// flagSummary returns the first sentence of the usage of the flag, without its period, e.g. the
+// abbreviations within it kept.
+func flagSummary(f *flag.Flag) string {
+	usage := f.Usage
+	for i := 0; i < len(usage); i++ {
+		if strings.HasPrefix(usage[i:], ". ") && !strings.HasSuffix(usage[:i], "e.g") && !strings.HasSuffix(usage[:i], "i.e") {
+			usage = usage[:i]
+			break
+		}
+	}
+	return strings.TrimSuffix(usage, ".")
+}
+
+func bashCompletion(w io.Writer, flags map[string][]*flag.Flag) error {
+	var b strings.Builder
+	b.WriteString("# bash completion for pqlbench, loaded with: source <(pqlbench completion bash)\n")
+	b.WriteString("_pqlbench() {\n")
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" flags=\"\" args=\"\"\n")
+	b.WriteString("\tif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tcase \"${COMP_WORDS[1]}\" in\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "\t%s)\n", c.name)
+		var words []string
+		for _, f := range flags[c.name] {
+			words = append(words, "-"+f.Name)
+		}
+		fmt.Fprintf(&b, "\t\tflags=%q\n", strings.Join(words, " "))
+		if c.args != nil {
+			fmt.Fprintf(&b, "\t\targs=%q\n", strings.Join(c.args, " "))
+		}
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tif [[ \"$cur\" == -* ]]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	b.WriteString("\telif [[ -n \"$args\" && $COMP_CWORD -eq 2 ]]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$args\" -- \"$cur\"))\n")
+	b.WriteString("\telse\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o filenames -F _pqlbench pqlbench\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote escapes the description of an _arguments or _describe spec, quoted in single quotes.
+var zshQuote = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+func zshCompletion(w io.Writer, flags map[string][]*flag.Flag) error {
+	var b strings.Builder
+	b.WriteString("#compdef pqlbench\n")
+	b.WriteString("# zsh completion for pqlbench, loaded with: source <(pqlbench completion zsh)\n")
+	b.WriteString("_pqlbench() {\n")
+	b.WriteString("\tlocal -a commands\n\tcommands=(\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "\t\t'%s:%s'\n", c.name, zshQuote.Replace(c.usage))
+	}
+	b.WriteString("\t)\n")
+	b.WriteString("\tif (( CURRENT == 2 )); then\n\t\t_describe 'subcommand' commands\n\t\treturn\n\tfi\n")
+	b.WriteString("\tshift words\n\t(( CURRENT-- ))\n")
+	b.WriteString("\tcase $words[1] in\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "\t%s)\n\t\t_arguments", c.name)
+		if c.args != nil {
+			fmt.Fprintf(&b, " \\\n\t\t\t'1:argument:(%s)'", strings.Join(c.args, " "))
+		}
+		for _, f := range flags[c.name] {
+			if isBoolFlag(f) {
+				fmt.Fprintf(&b, " \\\n\t\t\t'-%s[%s]'", f.Name, zshQuote.Replace(flagSummary(f)))
+				continue
+			}
+			fmt.Fprintf(&b, " \\\n\t\t\t'-%s=[%s]:value:_files'", f.Name, zshQuote.Replace(flagSummary(f)))
+		}
+		if c.args == nil {
+			b.WriteString(" \\\n\t\t\t'*:file:_files'")
+		}
+		b.WriteString("\n\t\t;;\n")
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n")
+	b.WriteString("compdef _pqlbench pqlbench\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishQuote escapes a description quoted in single quotes.
+var fishQuote = strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+func fishCompletion(w io.Writer, flags map[string][]*flag.Flag) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for pqlbench, loaded with: pqlbench completion fish | source\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c pqlbench -n __fish_use_subcommand -f -a %s -d '%s'\n", c.name, fishQuote.Replace(c.usage))
+	}
+	for _, c := range commands {
+		condition := "__fish_seen_subcommand_from " + c.name
+		if c.args != nil {
+			fmt.Fprintf(&b, "complete -c pqlbench -n '%s; and not __fish_seen_subcommand_from %s' -f -a '%s'\n",
+				condition, strings.Join(c.args, " "), strings.Join(c.args, " "))
+		}
+		for _, f := range flags[c.name] {
+			fmt.Fprintf(&b, "complete -c pqlbench -n '%s' -o %s", condition, f.Name)
+			if !isBoolFlag(f) {
+				b.WriteString(" -r -F")
+			}
+			fmt.Fprintf(&b, " -d '%s'\n", fishQuote.Replace(flagSummary(f)))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func Test_commandFlags(t *testing.T) {
+	flags := commandFlags()
+	if flagSets != nil {
+		t.Errorf("commandFlags() left the flag sets collected")
+	}
+	for _, c := range commands {
+		if c.name != "completion" && len(flags[c.name]) == 0 {
+			t.Errorf("commandFlags() has no flags for %s", c.name)
+		}
+	}
+	for name, want := range map[string]string{"benchmark": "filepath", "results": "store", "version": "json"} {
+		var found bool
+		for _, f := range flags[name] {
+			found = found || f.Name == want
+		}
+		if !found {
+			t.Errorf("commandFlags() of %s misses -%s", name, want)
+		}
+	}
+}
+
+func Test_completionCommand(t *testing.T) {
+	tests := []struct {
+		shell   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			shell: "bash",
+			want:  []string{"complete -o filenames -F _pqlbench pqlbench", `args="list show"`, "-filepath -find-max"},
+		},
+		{
+			shell: "zsh",
+			want:  []string{"compdef _pqlbench pqlbench", "'-has-header[The first row of the CSV file names its columns]'", "'1:argument:(list show)'"},
+		},
+		{
+			shell: "fish",
+			want:  []string{"-n __fish_use_subcommand -f -a results", "-n '__fish_seen_subcommand_from benchmark' -o filepath -r -F"},
+		},
+		{shell: "tcsh", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			var b strings.Builder
+			err := completionCommand([]string{tt.shell}, &b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("completionCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("completionCommand() misses %q", want)
+				}
+			}
+		})
+	}
+}
+
+func Test_flagSummary(t *testing.T) {
+	tests := []struct {
+		usage string
+		want  string
+	}{
+		{usage: "Query file to process. (Required).", want: "Query file to process"},
+		{usage: "Percentiles reported, e.g. 50,90,99. Defaults to all.", want: "Percentiles reported, e.g. 50,90,99"},
+		{usage: "Address listened on.", want: "Address listened on"},
+	}
+	for _, tt := range tests {
+		if got := flagSummary(&flag.Flag{Usage: tt.usage}); got != tt.want {
+			t.Errorf("flagSummary(%q) = %q, want %q", tt.usage, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package control exposes an HTTP API triggering and monitoring benchmark runs, so orchestration
+// systems don't have to shell out to the command line tool.
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/loader"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/report"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/store"
+)
+
+// RunConfig is the configuration of a run POSTed to the API.
+type RunConfig struct {
+	// Target is the URL of the benchmarked server
+	Target  string `json:"target"`
+	Workers int    `json:"workers"`
+	// Duration stops the run once elapsed, e.g. 10m, even if the queries were not all run
+	Duration string `json:"duration,omitempty"`
+	// Repeat is the number of times every query is run
+	Repeat  int           `json:"repeat,omitempty"`
+	Queries []query.Query `json:"queries"`
+}
+
+// Statuses of a run.
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+)
+
+// Status is the progress of a run.
+type Status struct {
+	ID       int64     `json:"id"`
+	Status   string    `json:"status"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	// Queries is the number of queries of the run, and Processed and Errors count those completed
+	// so far successfully or not
+	Queries   int `json:"queries"`
+	Processed int `json:"processed"`
+	Errors    int `json:"errors"`
+	// StoreID is the ID the run was saved to the store with, if any
+	StoreID int64 `json:"store_id,omitempty"`
+}
+
+// Server runs the benchmarks POSTed to its API in the background, keeping their status and
+// summary in memory.
+type Server struct {
+	// Store is the results store the finished runs are saved to, if any
+	Store *store.Store
+
+	mu   sync.Mutex
+	runs map[int64]*run
+	last int64
+}
+
+type run struct {
+	mu      sync.Mutex
+	status  Status
+	summary *report.Summary
+}
+
+// Record accounts the progress of the run.
+func (r *run) Record(res *runner.Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if res.Err != nil {
+		r.status.Errors++
+	} else {
+		r.status.Processed++
+	}
+	return nil
+}
+
+// Handler serves the API:
+//
+//	POST /runs               starts a run of the RunConfig in the body, responding with its Status
+//	GET  /runs/{id}/status   responds with the Status of the run
+//	GET  /runs/{id}/results  responds with the report.Summary of the run once done
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.start)
+	mux.HandleFunc("GET /runs/{id}/status", func(w http.ResponseWriter, req *http.Request) {
+		r := s.get(w, req)
+		if r == nil {
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		writeJSON(w, http.StatusOK, r.status)
+	})
+	mux.HandleFunc("GET /runs/{id}/results", func(w http.ResponseWriter, req *http.Request) {
+		r := s.get(w, req)
+		if r == nil {
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.summary == nil {
+			http.Error(w, fmt.Sprintf("run %d is still running", r.status.ID), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, r.summary)
+	})
+	return mux
+}
+
+func (s *Server) start(w http.ResponseWriter, req *http.Request) {
+	var cfg RunConfig
+	if err := json.NewDecoder(req.Body).Decode(&cfg); err != nil {
+		http.Error(w, fmt.Sprintf("unable to decode run config. err=%v", err), http.StatusBadRequest)
+		return
+	}
+	var duration time.Duration
+	if cfg.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(cfg.Duration); err != nil || duration < 0 {
+			http.Error(w, fmt.Sprintf("invalid duration %q", cfg.Duration), http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case cfg.Target == "":
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	case cfg.Workers < 1:
+		http.Error(w, "at least one worker is required", http.StatusBadRequest)
+		return
+	case len(cfg.Queries) == 0:
+		http.Error(w, "at least one query is required", http.StatusBadRequest)
+		return
+	}
+	queries := cfg.Queries
+	if cfg.Repeat > 1 {
+		queries = loader.Cycle(queries, len(queries)*cfg.Repeat)
+	}
+
+	s.mu.Lock()
+	if s.runs == nil {
+		s.runs = map[int64]*run{}
+	}
+	s.last++
+	r := &run{status: Status{ID: s.last, Status: StatusRunning, Started: time.Now(), Queries: len(queries)}}
+	s.runs[r.status.ID] = r
+	s.mu.Unlock()
+	// the run updates its status as soon as it starts, so respond with a copy taken beforehand
+	status := r.status
+
+	go s.run(r, cfg, queries, duration)
+
+	w.Header().Set("Location", fmt.Sprintf("/runs/%d/status", status.ID))
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func (s *Server) run(r *run, cfg RunConfig, queries []query.Query, duration time.Duration) {
+	slog.Info("running benchmark", "run", r.status.ID, "target", cfg.Target, "queries", len(queries), "workers", cfg.Workers)
+	recorders := []runner.Recorder{r}
+	collector := &store.Collector{}
+	if s.Store != nil {
+		recorders = append(recorders, collector)
+	}
+	rn := &runner.Runner{
+		Client:    client.New(cfg.Target),
+		Workers:   cfg.Workers,
+		Duration:  duration,
+		Recorders: recorders,
+	}
+	summary := &report.Summary{
+		Target:   cfg.Target,
+		Metadata: report.NewMetadata(r.status.Started),
+	}
+	summary.Metadata.Config, _ = json.Marshal(cfg)
+	summary.Stats = rn.Run(queries)
+
+	var storeID int64
+	if s.Store != nil {
+		saved := &store.Run{Started: r.status.Started, Target: cfg.Target, Config: summary.Metadata.Config, Summary: summary}
+		var err error
+		if storeID, err = s.Store.Save(saved, collector.Results); err != nil {
+			slog.Warn("unable to save run", "run", r.status.ID, "err", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Status, r.status.Finished, r.status.StoreID = StatusDone, time.Now(), storeID
+	r.summary = summary
+}
+
+// get returns the run of the ID in the path of the request, responding with an error if not found.
+func (s *Server) get(w http.ResponseWriter, req *http.Request) *run {
+	id, err := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid run ID %q", req.PathValue("id")), http.StatusBadRequest)
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[id]
+	if !ok {
+		http.Error(w, fmt.Sprintf("run %d not found", id), http.StatusNotFound)
+		return nil
+	}
+	return r
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("unable to write response", "err", err)
+	}
+}
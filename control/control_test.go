@@ -0,0 +1,151 @@
+package control
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/report"
+)
+
+func TestServer(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("query") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+	api := httptest.NewServer((&Server{}).Handler())
+	defer api.Close()
+
+	body := `{"target": "` + target.URL + `", "workers": 2, "repeat": 2, "queries": [{"query": "up"}, {"query": "broken"}]}`
+	resp, err := http.Post(api.URL+"/runs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /runs error = %v", err)
+	}
+	var status Status
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || status.ID != 1 || status.Queries != 4 {
+		t.Fatalf("POST /runs = %d %+v, want 202 and run 1 of 4 queries", resp.StatusCode, status)
+	}
+	if loc := resp.Header.Get("Location"); loc != "/runs/1/status" {
+		t.Errorf("POST /runs location = %q, want /runs/1/status", loc)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); status.Status != StatusDone; {
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /runs/1/status = %+v, want done", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Get(api.URL + "/runs/1/status")
+		if err != nil {
+			t.Fatalf("GET /runs/1/status error = %v", err)
+		}
+		json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+	}
+	if status.Processed != 2 || status.Errors != 2 {
+		t.Errorf("GET /runs/1/status = %+v, want 2 processed and 2 errors", status)
+	}
+
+	resp, err = http.Get(api.URL + "/runs/1/results")
+	if err != nil {
+		t.Fatalf("GET /runs/1/results error = %v", err)
+	}
+	var summary report.Summary
+	json.NewDecoder(resp.Body).Decode(&summary)
+	resp.Body.Close()
+	if summary.Target != target.URL || summary.Stats == nil || summary.Stats.Processed != 2 {
+		t.Errorf("GET /runs/1/results = %+v, want the summary of the run", summary)
+	}
+}
+
+func TestServer_errors(t *testing.T) {
+	api := httptest.NewServer((&Server{}).Handler())
+	defer api.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "Invalid body", method: http.MethodPost, path: "/runs", body: "{", want: http.StatusBadRequest},
+		{name: "No target", method: http.MethodPost, path: "/runs", body: `{"workers": 1, "queries": [{"query": "up"}]}`, want: http.StatusBadRequest},
+		{name: "No queries", method: http.MethodPost, path: "/runs", body: `{"target": "http://localhost:9201", "workers": 1}`, want: http.StatusBadRequest},
+		{name: "Invalid duration", method: http.MethodPost, path: "/runs", body: `{"target": "http://localhost:9201", "workers": 1, "duration": "soon", "queries": [{"query": "up"}]}`, want: http.StatusBadRequest},
+		{name: "Unknown run", method: http.MethodGet, path: "/runs/42/status", want: http.StatusNotFound},
+		{name: "Invalid ID", method: http.MethodGet, path: "/runs/latest/results", want: http.StatusBadRequest},
+		{name: "Method not allowed", method: http.MethodGet, path: "/runs", want: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, api.URL+tt.path, strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s error = %v", tt.method, tt.path, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_concurrentStatus(t *testing.T) {
+	api := httptest.NewServer((&Server{}).Handler())
+	defer api.Close()
+
+	// the queries of an invalid target fail right away, recording the progress of the runs while
+	// they are being started and polled
+	const runs = 20
+	body := `{"target": "http://%zz", "workers": 4, "repeat": 100, "queries": [{"query": "up"}]}`
+	var wg sync.WaitGroup
+	for i := 1; i <= runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(api.URL+"/runs", "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Errorf("POST /runs error = %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	for i := 1; i <= runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path := fmt.Sprintf("%s/runs/%d/status", api.URL, i)
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+				resp, err := http.Get(path)
+				if err != nil {
+					t.Errorf("GET %s error = %v", path, err)
+					return
+				}
+				var status Status
+				json.NewDecoder(resp.Body).Decode(&status)
+				resp.Body.Close()
+				if status.Status == StatusDone {
+					if status.Errors != 100 {
+						t.Errorf("GET %s = %+v, want 100 errors", path, status)
+					}
+					return
+				}
+			}
+			t.Errorf("GET %s never done", path)
+		}()
+	}
+	wg.Wait()
+}
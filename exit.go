@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/report"
+)
+
+// Exit codes of the benchmark command, so the scripts wrapping the tool can branch on the outcome
+// of a run rather than parsing its logs. The other subcommands exit with ExitFailure on any error.
+const (
+	ExitSuccess = 0
+	// ExitFailure is any failure not classified otherwise, e.g. a report that couldn't be written
+	ExitFailure = 1
+	// ExitConfig is an invalid configuration, e.g. a flag, as the flag package exits with, or a
+	// query file that can't be opened or parsed
+	ExitConfig = 2
+	// ExitConnectivity is a target that couldn't be reached or authenticated with, before the run or
+	// by every query of the run
+	ExitConnectivity = 3
+	// ExitAssertion is a run whose queries were answered but failed their assertions
+	ExitAssertion = 4
+	// ExitPartial is a run that was interrupted, or aborted by fail-fast, before every query ran
+	ExitPartial = 5
+	// ExitQueryFailures is a run some of whose queries failed, e.g. with a 4xx or 5xx status, a body
+	// that couldn't be decoded or a connection error
+	ExitQueryFailures = 6
+)
+
+// exitCode returns the exit code of a run with the given summary, aborted or interrupted before
+// running every query.
+func exitCode(summary *report.Summary, aborted, interrupted bool) int {
+	switch {
+	case aborted || interrupted:
+		return ExitPartial
+	case unreachable(summary):
+		return ExitConnectivity
+	case summary.Stats != nil && summary.Stats.Errors.Total() > 0:
+		return ExitQueryFailures
+	case summary.Stats != nil && summary.Stats.AssertionFailures > 0:
+		return ExitAssertion
+	}
+	return ExitSuccess
+}
+
+// unreachable reports whether every query of the run failed without a response of the target,
+// e.g. refused, timed out, unresolved or held back by the circuit breaker.
+func unreachable(summary *report.Summary) bool {
+	s := summary.Stats
+	if s == nil || s.Processed > 0 || s.Errors.Total() == 0 {
+		return false
+	}
+	for _, class := range []client.ErrorClass{client.ErrorClientStatus, client.ErrorServerStatus, client.ErrorBodyDecode} {
+		if s.Errors.Counts[class] > 0 {
+			return false
+		}
+	}
+	return true
+}
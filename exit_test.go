@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/report"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func Test_exitCode(t *testing.T) {
+	errs := func(errs ...error) stats.ErrorSummary {
+		var summary stats.ErrorSummary
+		for _, err := range errs {
+			summary.Add(err)
+		}
+		return summary
+	}
+	tests := []struct {
+		name        string
+		summary     *report.Summary
+		aborted     bool
+		interrupted bool
+		want        int
+	}{
+		{name: "success", summary: &report.Summary{Stats: &stats.Stats{Processed: 10}}, want: ExitSuccess},
+		{name: "some errors", summary: &report.Summary{Stats: &stats.Stats{Processed: 10, Errors: errs(syscall.ECONNREFUSED)}}, want: ExitQueryFailures},
+		{name: "unreachable", summary: &report.Summary{Stats: &stats.Stats{Errors: errs(syscall.ECONNREFUSED, errors.New("no such host"))}}, want: ExitConnectivity},
+		{name: "circuit open", summary: &report.Summary{Stats: &stats.Stats{Errors: errs(runner.ErrCircuitOpen)}, Outages: []runner.Outage{{Failures: 5}}}, want: ExitConnectivity},
+		{name: "server errors", summary: &report.Summary{Stats: &stats.Stats{Errors: errs(&client.StatusError{StatusCode: 503})}}, want: ExitQueryFailures},
+		{name: "client errors", summary: &report.Summary{Stats: &stats.Stats{Processed: 10, Errors: errs(&client.StatusError{StatusCode: 400})}}, want: ExitQueryFailures},
+		{name: "errors and assertion failures", summary: &report.Summary{Stats: &stats.Stats{Processed: 10, AssertionFailures: 1, Errors: errs(&client.StatusError{StatusCode: 422})}}, want: ExitQueryFailures},
+		{name: "assertion failures", summary: &report.Summary{Stats: &stats.Stats{Processed: 10, AssertionFailures: 1}}, want: ExitAssertion},
+		{name: "aborted", summary: &report.Summary{Stats: &stats.Stats{Processed: 1}}, aborted: true, want: ExitPartial},
+		{name: "interrupted", summary: &report.Summary{Stats: &stats.Stats{Processed: 10, AssertionFailures: 1}}, interrupted: true, want: ExitPartial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.summary, tt.aborted, tt.interrupted); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
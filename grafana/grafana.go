@@ -0,0 +1,105 @@
+// Package grafana marks the window of benchmark runs on the dashboards of the target with Grafana
+// annotations.
+package grafana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// Annotator posts a region annotation spanning a benchmark run through the Grafana HTTP API.
+type Annotator struct {
+	Client client.HttpClient
+	// URL is the address of Grafana, e.g. http://localhost:3000
+	URL string
+	// Token is a service account token or API key allowed to write annotations
+	Token string
+	// DashboardUID restricts the annotation to a dashboard, organization wide if empty
+	DashboardUID string
+	Tags         []string
+
+	// id and start are the ID and time of the annotation posted by Start
+	id    int64
+	start int64
+}
+
+func New(url, token string) *Annotator {
+	return &Annotator{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		URL:    strings.TrimSuffix(url, "/"),
+		Token:  token,
+		Tags:   []string{"pqlbench"},
+	}
+}
+
+type annotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time,omitempty"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Text         string   `json:"text"`
+}
+
+// Start posts the annotation marking the start of a run at the given time.
+func (a *Annotator) Start(t time.Time, text string) error {
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	err := a.do(http.MethodPost, "/api/annotations", annotation{
+		DashboardUID: a.DashboardUID, Time: t.UnixMilli(), Tags: a.Tags, Text: text,
+	}, &resp)
+	if err != nil {
+		return fmt.Errorf("unable to post the start annotation. err=%w", err)
+	}
+	a.id, a.start = resp.ID, t.UnixMilli()
+	return nil
+}
+
+// End extends the annotation posted by Start into a region ending at the given time, replacing
+// its text, e.g. with the outcome of the run.
+func (a *Annotator) End(t time.Time, text string) error {
+	if a.id == 0 {
+		return fmt.Errorf("unable to post the end annotation, the start annotation was not posted")
+	}
+	err := a.do(http.MethodPatch, fmt.Sprintf("/api/annotations/%d", a.id), annotation{
+		Time: a.start, TimeEnd: t.UnixMilli(), Tags: a.Tags, Text: text,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to post the end annotation. err=%w", err)
+	}
+	return nil
+}
+
+func (a *Annotator) do(method, path string, body annotation, out any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, a.URL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &client.StatusError{StatusCode: resp.StatusCode}
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package grafana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnnotator(t *testing.T) {
+	var got []string
+	var bodies []annotation
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var a annotation
+		json.NewDecoder(r.Body).Decode(&a)
+		got = append(got, r.Method+" "+r.URL.Path)
+		bodies = append(bodies, a)
+		w.Write([]byte(`{"id": 42, "message": "Annotation added"}`))
+	}))
+	defer srv.Close()
+
+	a := New(srv.URL+"/", "secret")
+	a.DashboardUID = "promscale"
+	start := time.UnixMilli(1609459200000)
+	if err := a.End(start, "ended"); err == nil {
+		t.Errorf("Annotator.End() before Start() error = nil, want an error")
+	}
+	if err := a.Start(start, "started"); err != nil {
+		t.Fatalf("Annotator.Start() error = %v", err)
+	}
+	if err := a.End(start.Add(time.Minute), "ended"); err != nil {
+		t.Fatalf("Annotator.End() error = %v", err)
+	}
+
+	if want := []string{"POST /api/annotations", "PATCH /api/annotations/42"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Annotator requested %v, want %v", got, want)
+	}
+	want := []annotation{
+		{DashboardUID: "promscale", Time: 1609459200000, Tags: []string{"pqlbench"}, Text: "started"},
+		{Time: 1609459200000, TimeEnd: 1609459260000, Tags: []string{"pqlbench"}, Text: "ended"},
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("Annotator posted %+v, want %+v", bodies, want)
+	}
+
+	a.Token = "wrong"
+	if err := a.Start(start, "started"); err == nil {
+		t.Errorf("Annotator.Start() unauthorized error = nil, want an error")
+	}
+}
@@ -0,0 +1,650 @@
+// Package loader reads the query corpus benchmarked by pqlbench and selects which part of it runs.
+package loader
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/prometheus/common/model"
+)
+
+// endpointPrefix starts the rows targeting an endpoint other than query_range.
+const endpointPrefix = "/api/v1/"
+
+// Read reads a csv file containing a list of queries written in the form provided in the
+// specifications of this tool, which follows the following form: `PromQL_query,start_time,end_time,step_size`.
+// The start and end may be given relative to the time the query is sent, e.g. `now-1h`, and the step is given
+// in seconds or as a duration, e.g. `15s` or `1m`.
+// An optional fifth column holds the SQL statement equivalent to the PromQL query, and an optional
+// sixth column a comma-separated list of tags categorizing the query (e.g. `dashboard`, `alerting`
+// or `adhoc`), so the stats can be broken down by tag.
+//
+// Rows may target the exemplars or metadata endpoints instead of query_range by giving their path in place of
+// the query, optionally followed by the series selector to match, e.g. `/api/v1/series {job="api"}`.
+//
+// The file may start with a header naming its columns, see Format.
+func Read(file io.Reader) ([]query.Query, error) {
+	return ReadFormat(file, Format{})
+}
+
+// Columns a query file can hold. The query, start, end and step columns are required.
+const (
+	ColumnQuery = "query"
+	ColumnStart = "start"
+	ColumnEnd   = "end"
+	ColumnStep  = "step"
+	ColumnSQL   = "sql"
+	ColumnTag   = "tag"
+	// ColumnMinSeries and ColumnExpectNonEmpty hold the assertions checked on the response, see
+	// query.Query.Check
+	ColumnMinSeries      = "min_series"
+	ColumnExpectNonEmpty = "expect_nonempty"
+	// ColumnAt holds when the query was sent since the first one of recorded traffic, as a
+	// duration, e.g. `1.5s`
+	ColumnAt = "at"
+	// ColumnTimeout holds the deadline of the query overriding the global one, as a duration,
+	// e.g. `30s`
+	ColumnTimeout = "timeout"
+	// ColumnDedup, ColumnPartialResponse and ColumnMaxSourceResolution hold the parameters of the
+	// query specific to the Thanos Querier, see query.Thanos
+	ColumnDedup               = "dedup"
+	ColumnPartialResponse     = "partial_response"
+	ColumnMaxSourceResolution = "max_source_resolution"
+)
+
+// DefaultColumns is the order of the columns of the files without a header.
+var DefaultColumns = []string{ColumnQuery, ColumnStart, ColumnEnd, ColumnStep, ColumnSQL, ColumnTag}
+
+var requiredColumns = []string{ColumnQuery, ColumnStart, ColumnEnd, ColumnStep}
+
+// Types of query files.
+const (
+	TypeCSV  = "csv"
+	TypeJSON = "json"
+	TypeYAML = "yaml"
+	// TypeVegeta is a vegeta targets file, see readVegeta
+	TypeVegeta = "vegeta"
+)
+
+// TypeFromPath returns the type of a query file from the extension of its path or URL, i.e. JSON
+// for `.json`, YAML for `.yaml` and `.yml` and CSV otherwise. The `.gz` extension of compressed
+// files is ignored.
+func TypeFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(name(path))) {
+	case ".json":
+		return TypeJSON
+	case ".yaml", ".yml":
+		return TypeYAML
+	}
+	return TypeCSV
+}
+
+// Format describes the layout of a query file.
+type Format struct {
+	// Type of the file, CSV if empty. The other fields only apply to CSV files
+	Type string
+	// Header is true if the first row of the file names its columns. A first row naming both the
+	// query and start columns is detected as a header regardless
+	Header bool
+	// Columns names the columns of every row in order, defaulting to the names in the header, if
+	// any, or to DefaultColumns otherwise. Columns with any other name are ignored
+	Columns []string
+	// Delimiter separates the columns, defaulting to '|'. Fields containing it, e.g. PromQL regex
+	// matchers such as `{job=~"a|b"}`, are enclosed in double quotes, doubling the double quotes
+	// they contain as in CSV: `"{job=~""a|b""}"`
+	Delimiter rune
+}
+
+func (f Format) delimiter() rune {
+	if f.Delimiter == 0 {
+		return '|'
+	}
+	return f.Delimiter
+}
+
+// ParseDelimiter parses a column delimiter given as a single character or as `tab`.
+func ParseDelimiter(s string) (rune, error) {
+	if s == "tab" || s == `\t` {
+		return '\t', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+		return 0, fmt.Errorf("invalid delimiter %q, want a single character other than a double quote or a newline", s)
+	}
+	return r[0], nil
+}
+
+// ParseColumns parses a comma-separated list of column names, e.g. `start,end,step,query`, which
+// must include the required columns.
+func ParseColumns(s string) ([]string, error) {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		columns = append(columns, strings.ToLower(strings.TrimSpace(c)))
+	}
+	if _, err := columnIndex(columns); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// columnIndex returns the index of every named column, failing if a required one is missing.
+func columnIndex(columns []string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, c := range columns {
+		if _, ok := index[c]; !ok {
+			index[c] = i
+		}
+	}
+	for _, c := range requiredColumns {
+		if _, ok := index[c]; !ok {
+			return nil, fmt.Errorf("missing the %s column in %v", c, columns)
+		}
+	}
+	return index, nil
+}
+
+// isHeader reports whether the row names the columns of the file.
+func isHeader(row []string) bool {
+	var query, start bool
+	for _, c := range row {
+		switch strings.ToLower(strings.TrimSpace(c)) {
+		case ColumnQuery:
+			query = true
+		case ColumnStart:
+			start = true
+		}
+	}
+	return query && start
+}
+
+// ReadFormat reads a query file of the given Format, as described by Read for CSV files, by
+// readStructured for JSON and YAML files and by readVegeta for vegeta targets files.
+func ReadFormat(file io.Reader, format Format) ([]query.Query, error) {
+	switch format.Type {
+	case "", TypeCSV:
+	case TypeJSON, TypeYAML:
+		return readStructured(file, format.Type)
+	case TypeVegeta:
+		return readVegeta(file)
+	default:
+		return nil, fmt.Errorf("unsupported query file type %q, want %s, %s, %s or %s", format.Type, TypeCSV, TypeJSON, TypeYAML, TypeVegeta)
+	}
+
+	csvReader := csv.NewReader(file)
+	csvReader.Comma = format.delimiter()
+	csvReader.LazyQuotes = true
+	csvReader.FieldsPerRecord = -1
+
+	csvRecords, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse provided file as CSV. err=%w", err)
+	}
+
+	columns, first := format.Columns, 0
+	if len(csvRecords) > 0 && (format.Header || isHeader(csvRecords[0])) {
+		if columns == nil {
+			for _, c := range csvRecords[0] {
+				columns = append(columns, strings.ToLower(strings.TrimSpace(c)))
+			}
+		}
+		first = 1
+	}
+	if columns == nil {
+		columns = DefaultColumns
+	}
+	index, err := columnIndex(columns)
+	if err != nil {
+		return nil, err
+	}
+	required := 0
+	for _, c := range requiredColumns {
+		required = max(required, index[c]+1)
+	}
+	// column returns the value of the named column of a row, empty if missing
+	column := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	now := time.Now()
+	queries := make([]query.Query, 0, len(csvRecords)-first)
+	for i, line := range csvRecords[first:] {
+		n := i + first + 1
+		if len(line) < required {
+			return nil, fmt.Errorf("line %d has %d columns, want at least %d", n, len(line), required)
+		}
+
+		q, err := parseQuery(func(name string) string { return column(line, name) }, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		queries = append(queries, q)
+	}
+
+	return queries, nil
+}
+
+// parseQuery builds a Query from the value of every column, resolving relative times against now.
+func parseQuery(column func(name string) string, now time.Time) (query.Query, error) {
+	start, startAgo, err := ParseTime(column(ColumnStart), now)
+	if err != nil {
+		return query.Query{}, err
+	}
+
+	end, endAgo, err := ParseTime(column(ColumnEnd), now)
+	if err != nil {
+		return query.Query{}, err
+	}
+
+	step, err := ParseStep(column(ColumnStep))
+	if err != nil {
+		return query.Query{}, err
+	}
+
+	var minSeries int
+	if v := strings.TrimSpace(column(ColumnMinSeries)); v != "" {
+		if minSeries, err = strconv.Atoi(v); err != nil || minSeries < 0 {
+			return query.Query{}, fmt.Errorf("invalid min_series %q, want a non-negative integer", v)
+		}
+	}
+	var expectNonEmpty bool
+	if v := strings.TrimSpace(column(ColumnExpectNonEmpty)); v != "" {
+		if expectNonEmpty, err = strconv.ParseBool(v); err != nil {
+			return query.Query{}, fmt.Errorf("invalid expect_nonempty %q, want true or false", v)
+		}
+	}
+
+	var at *time.Duration
+	if v := strings.TrimSpace(column(ColumnAt)); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return query.Query{}, fmt.Errorf("invalid at %q, want a non-negative duration", v)
+		}
+		at = &d
+	}
+
+	var timeout time.Duration
+	if v := strings.TrimSpace(column(ColumnTimeout)); v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil || timeout <= 0 {
+			return query.Query{}, fmt.Errorf("invalid timeout %q, want a positive duration", v)
+		}
+	}
+
+	thanos, err := query.ParseThanos(column(ColumnDedup), column(ColumnPartialResponse), column(ColumnMaxSourceResolution))
+	if err != nil {
+		return query.Query{}, err
+	}
+
+	q := query.Query{
+		Query: column(ColumnQuery),
+		Start: start,
+		End:   end,
+		Step:  step,
+		SQL:   column(ColumnSQL),
+		Tags:  splitTags(column(ColumnTag)),
+
+		MinSeries:      minSeries,
+		ExpectNonEmpty: expectNonEmpty,
+		At:             at,
+		Timeout:        timeout,
+		Thanos:         thanos,
+		// Relative times are resolved again when the query is sent
+		StartAgo: startAgo,
+		EndAgo:   endAgo,
+	}
+	if path, ok := strings.CutPrefix(q.Query, endpointPrefix); ok {
+		endpoint, expr, _ := strings.Cut(path, " ")
+		if err := query.ValidateEndpoint(endpoint); err != nil {
+			return query.Query{}, err
+		}
+		q.Endpoint, q.Query = endpoint, strings.TrimSpace(expr)
+	}
+	return q, nil
+}
+
+// Write writes the queries in the given Format, which are read back unchanged by ReadFormat as long
+// as it has a column for every field given, e.g. for the assertions. No header is written, and
+// relative times are written in the `now-<duration>` form. Only CSV files can be written.
+func Write(w io.Writer, queries []query.Query, format Format) error {
+	if format.Type != "" && format.Type != TypeCSV {
+		return fmt.Errorf("unable to write a %s query file, only %s is supported", format.Type, TypeCSV)
+	}
+	columns := format.Columns
+	if columns == nil {
+		columns = DefaultColumns
+	}
+	delimiter := string(format.delimiter())
+
+	bw := bufio.NewWriter(w)
+	for _, q := range queries {
+		expr := q.Query
+		if !q.RangeQuery() {
+			expr = strings.TrimSpace(endpointPrefix + q.Endpoint + " " + q.Query)
+		}
+		for i, c := range columns {
+			var field string
+			switch c {
+			case ColumnQuery:
+				field = expr
+			case ColumnStart:
+				field = formatTime(q.Start, q.StartAgo)
+			case ColumnEnd:
+				field = formatTime(q.End, q.EndAgo)
+			case ColumnStep:
+				field = strconv.Itoa(q.Step)
+			case ColumnSQL:
+				field = q.SQL
+			case ColumnTag:
+				field = strings.Join(q.Tags, ",")
+			case ColumnMinSeries:
+				if q.MinSeries > 0 {
+					field = strconv.Itoa(q.MinSeries)
+				}
+			case ColumnExpectNonEmpty:
+				if q.ExpectNonEmpty {
+					field = "true"
+				}
+			case ColumnAt:
+				if q.At != nil {
+					field = q.At.String()
+				}
+			case ColumnTimeout:
+				if q.Timeout > 0 {
+					field = q.Timeout.String()
+				}
+			case ColumnDedup:
+				field = formatOptionalBool(q.Thanos.Dedup)
+			case ColumnPartialResponse:
+				field = formatOptionalBool(q.Thanos.PartialResponse)
+			case ColumnMaxSourceResolution:
+				field = q.Thanos.MaxSourceResolution
+			}
+			if i > 0 {
+				bw.WriteString(delimiter)
+			}
+			bw.WriteString(quoteField(field, delimiter))
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// formatOptionalBool formats a boolean as parsed by query.ParseThanos, empty if unset.
+func formatOptionalBool(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+// splitTags splits a comma-separated list of tags.
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// quoteField encloses a field in double quotes if it could not be read back otherwise.
+func quoteField(field, delimiter string) string {
+	if !strings.Contains(field, delimiter) && !strings.ContainsAny(field, "\r\n") && !strings.HasPrefix(field, `"`) {
+		return field
+	}
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+func formatTime(ms int64, ago *time.Duration) string {
+	switch {
+	case ago == nil:
+		return strconv.FormatInt(ms, 10)
+	case *ago == 0:
+		return "now"
+	}
+	return "now-" + model.Duration(*ago).String()
+}
+
+// ParseTime parses a time given either in unix format in milliseconds or relative to now in the
+// `now[-<duration>]` form, e.g. `now-1h` or `now-7d`. Relative times are returned resolved against
+// now, along with how long before now they are.
+func ParseTime(s string, now time.Time) (int64, *time.Duration, error) {
+	rel, ok := strings.CutPrefix(s, "now")
+	if !ok {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid time %q, want milliseconds or now[-<duration>]. err=%w", s, err)
+		}
+		return ms, nil, nil
+	}
+
+	var ago time.Duration
+	if rel != "" {
+		d, ok := strings.CutPrefix(rel, "-")
+		parsed, err := model.ParseDuration(d)
+		if !ok || err != nil {
+			return 0, nil, fmt.Errorf("invalid relative time %q, want now[-<duration>]", s)
+		}
+		ago = time.Duration(parsed)
+	}
+	return now.Add(-ago).UnixMilli(), &ago, nil
+}
+
+// ParseStep parses a step given either as a number of seconds or as a duration (e.g. `15s`, `1m`
+// or `1h`, as exported by Grafana), returning it in seconds.
+func ParseStep(s string) (int, error) {
+	if step, err := strconv.Atoi(s); err == nil {
+		return step, nil
+	}
+	d, err := model.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid step %q, want a number of seconds or a duration. err=%w", s, err)
+	}
+	if time.Duration(d)%time.Second != 0 {
+		return 0, fmt.Errorf("invalid step %q, want a whole number of seconds", s)
+	}
+	return int(time.Duration(d) / time.Second), nil
+}
+
+// Shard returns the i-th (1-based) of n disjoint slices of the queries, taking every n-th row, so
+// independent invocations can split a corpus deterministically.
+func Shard(queries []query.Query, i, n int) []query.Query {
+	var shard []query.Query
+	for j := i - 1; j < len(queries); j += n {
+		shard = append(shard, queries[j])
+	}
+	return shard
+}
+
+// Select returns the queries whose expression matches the given regexp, if any, leaving out the
+// first skip of them and keeping at most limit, unless zero, so only a subset of a corpus runs
+// without editing it.
+func Select(queries []query.Query, match *regexp.Regexp, skip, limit int) []query.Query {
+	var selected []query.Query
+	for _, q := range queries {
+		if match == nil || match.MatchString(q.Query) {
+			selected = append(selected, q)
+		}
+	}
+	selected = selected[min(skip, len(selected)):]
+	if limit > 0 && limit < len(selected) {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+// Dedup collapses the identical queries, i.e. with the same Key, into the first one, whose Weight
+// counts them so the stats can still reflect how often every query appears in the corpus.
+func Dedup(queries []query.Query) []query.Query {
+	index := map[string]int{}
+	var deduped []query.Query
+	for _, q := range queries {
+		weight := max(q.Weight, 1)
+		if i, ok := index[q.Key()]; ok {
+			deduped[i].Weight += weight
+			continue
+		}
+		index[q.Key()] = len(deduped)
+		q.Weight = weight
+		deduped = append(deduped, q)
+	}
+	return deduped
+}
+
+// Cycle returns n queries going through the given ones in order, as many times as needed.
+func Cycle(queries []query.Query, n int) []query.Query {
+	if len(queries) == 0 {
+		return nil
+	}
+	cycled := make([]query.Query, n)
+	for i := range cycled {
+		cycled[i] = queries[i%len(queries)]
+	}
+	return cycled
+}
+
+// ParseShard parses a shard given in the `i/n` form, e.g. `2/4` for the second of four shards.
+func ParseShard(s string) (i, n int, err error) {
+	if _, err := fmt.Sscanf(s, "%d/%d", &i, &n); err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q, want the i/n form. err=%w", s, err)
+	}
+	if n < 1 || i < 1 || i > n {
+		return 0, 0, fmt.Errorf("invalid shard %q, want 1 <= i <= n", s)
+	}
+	return i, n, nil
+}
+
+// Coverage tracks which queries of a corpus were run across consecutive sampled runs. It is a
+// runner.Recorder covering the queries as they run, so those sampled but never run, e.g. by an
+// aborted run, are left to the next runs.
+type Coverage struct {
+	// Runs is the number of runs in the current cycle over the corpus
+	Runs int `json:"runs"`
+	// Cycles is the number of times the whole corpus has been covered
+	Cycles int `json:"cycles"`
+	// Covered holds the keys of the queries run in the current cycle
+	Covered map[string]bool `json:"covered"`
+	// Corpus is the number of distinct queries in the corpus
+	Corpus int `json:"corpus"`
+
+	mu sync.Mutex
+}
+
+// Record covers the query of the result, unless it was held back by the circuit breaker rather
+// than sent.
+func (c *Coverage) Record(r *runner.Result) error {
+	if errors.Is(r.Err, runner.ErrCircuitOpen) {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Covered[r.Query.Key()] = true
+	return nil
+}
+
+// ReadCoverage loads the coverage state from the given path, returning an empty one if the file
+// does not exist yet.
+func ReadCoverage(path string) (*Coverage, error) {
+	cov := &Coverage{Covered: map[string]bool{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cov, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cov); err != nil {
+		return nil, fmt.Errorf("unable to decode coverage file %s. err=%w", path, err)
+	}
+	if cov.Covered == nil {
+		cov.Covered = map[string]bool{}
+	}
+	return cov, nil
+}
+
+func (c *Coverage) Write(path string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+func (c *Coverage) ToString() string {
+	// An empty corpus, e.g. filtered out entirely, is reported as not covered at all
+	var covered float64
+	if c.Corpus > 0 {
+		covered = 100 * float64(len(c.Covered)) / float64(c.Corpus)
+	}
+	return fmt.Sprintf("Corpus coverage: %d/%d queries (%.1f%%) across %d runs, %d full cycles\n",
+		len(c.Covered), c.Corpus, covered, c.Runs, c.Cycles)
+}
+
+// Sample selects a random subset of the given fraction of the queries, stratified by query class
+// and time range length so every kind of query is represented. Queries not yet covered in the
+// current cycle are preferred, and the run is accounted in cov, which covers the queries as they
+// run. Once every query has been covered a new cycle starts.
+func Sample(queries []query.Query, fraction float64, rnd *rand.Rand, cov *Coverage) []query.Query {
+	keys := map[string]bool{}
+	uncovered := 0
+	for _, q := range queries {
+		if key := q.Key(); !keys[key] {
+			keys[key] = true
+			if !cov.Covered[key] {
+				uncovered++
+			}
+		}
+	}
+	cov.Corpus = len(keys)
+	if uncovered == 0 {
+		cov.Covered = map[string]bool{}
+		cov.Runs = 0
+		cov.Cycles++
+	}
+
+	strata := map[string][]query.Query{}
+	var names []string
+	for _, q := range queries {
+		name := q.Class() + "/" + q.RangeBucket()
+		if _, ok := strata[name]; !ok {
+			names = append(names, name)
+		}
+		strata[name] = append(strata[name], q)
+	}
+
+	var sample []query.Query
+	for _, name := range names {
+		stratum := strata[name]
+		rnd.Shuffle(len(stratum), func(i, j int) { stratum[i], stratum[j] = stratum[j], stratum[i] })
+		// Stable sort moving uncovered queries to the front, keeping the shuffled order otherwise
+		sort.SliceStable(stratum, func(i, j int) bool {
+			return !cov.Covered[stratum[i].Key()] && cov.Covered[stratum[j].Key()]
+		})
+
+		n := int(math.Ceil(fraction * float64(len(stratum))))
+		sample = append(sample, stratum[:n]...)
+	}
+	cov.Runs++
+
+	return sample
+}
@@ -0,0 +1,596 @@
+package loader
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileContents string
+		want         []query.Query
+		wantErr      bool
+	}{
+		{
+			name:         "empty file",
+			fileContents: ``,
+			want:         []query.Query{},
+		},
+		{
+			name:         "one row",
+			fileContents: `demo_cpu_usage_seconds_total{mode="idle"}|1597056698698|1597059548699|15000`,
+			want: []query.Query{
+				{
+					Query: `demo_cpu_usage_seconds_total{mode="idle"}`,
+					Start: 1597056698698,
+					End:   1597059548699,
+					Step:  15000,
+				},
+			},
+		},
+		{
+			name: "multiple rows",
+			fileContents: `demo_cpu_usage_seconds_total{mode="idle"}|1597056698698|1597059548699|15000
+avg by(instance) (demo_cpu_usage_seconds_total)|1597057698698|1597058548699|60000`,
+			want: []query.Query{
+				{
+					Query: `demo_cpu_usage_seconds_total{mode="idle"}`,
+					Start: 1597056698698,
+					End:   1597059548699,
+					Step:  15000,
+				},
+				{
+					Query: `avg by(instance) (demo_cpu_usage_seconds_total)`,
+					Start: 1597057698698,
+					End:   1597058548699,
+					Step:  60000,
+				},
+			},
+		},
+		{
+			name: "sql column",
+			fileContents: `demo_cpu_usage_seconds_total|1597056698698|1597059548699|15000|SELECT * FROM demo_cpu_usage_seconds_total
+up|1597057698698|1597058548699|60000`,
+			want: []query.Query{
+				{
+					Query: `demo_cpu_usage_seconds_total`,
+					Start: 1597056698698,
+					End:   1597059548699,
+					Step:  15000,
+					SQL:   `SELECT * FROM demo_cpu_usage_seconds_total`,
+				},
+				{
+					Query: `up`,
+					Start: 1597057698698,
+					End:   1597058548699,
+					Step:  60000,
+				},
+			},
+		},
+		{
+			name: "duration steps",
+			fileContents: `up|1597056698698|1597059548699|15s
+up|1597057698698|1597058548699|1h`,
+			want: []query.Query{
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15},
+				{Query: `up`, Start: 1597057698698, End: 1597058548699, Step: 3600},
+			},
+		},
+		{
+			name:         "fractional duration step",
+			fileContents: `up|1597056698698|1597059548699|1500ms`,
+			wantErr:      true,
+		},
+		{
+			name: "tag column",
+			fileContents: `up|1597056698698|1597059548699|15000||dashboard
+rate(errors_total[5m])|1597057698698|1597058548699|60000|SELECT 1|alerting, slo`,
+			want: []query.Query{
+				{
+					Query: `up`,
+					Start: 1597056698698,
+					End:   1597059548699,
+					Step:  15000,
+					Tags:  []string{"dashboard"},
+				},
+				{
+					Query: `rate(errors_total[5m])`,
+					Start: 1597057698698,
+					End:   1597058548699,
+					Step:  60000,
+					SQL:   `SELECT 1`,
+					Tags:  []string{"alerting", "slo"},
+				},
+			},
+		},
+		{
+			name: "metadata endpoints",
+			fileContents: `/api/v1/series {job="api"}|1597056698698|1597059548699|0
+/api/v1/labels|1597057698698|1597058548699|0`,
+			want: []query.Query{
+				{
+					Query:    `{job="api"}`,
+					Start:    1597056698698,
+					End:      1597059548699,
+					Endpoint: query.EndpointSeries,
+				},
+				{
+					Start:    1597057698698,
+					End:      1597058548699,
+					Endpoint: query.EndpointLabels,
+				},
+			},
+		},
+		{
+			name:         "unsupported endpoint",
+			fileContents: `/api/v1/targets|1597057698698|1597058548699|0`,
+			wantErr:      true,
+		},
+		{
+			name:         "missing columns",
+			fileContents: `up|1597057698698|1597058548699`,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.fileContents))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Read() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSample(t *testing.T) {
+	var queries []query.Query
+	for i := 0; i < 4; i++ {
+		queries = append(queries,
+			query.Query{Query: fmt.Sprintf("up{i=\"%d\"}", i), Start: 0, End: 1000, Step: 1},
+			query.Query{Query: fmt.Sprintf("sum(rate(up{i=\"%d\"}[5m]))", i), Start: 0, End: 48 * time.Hour.Milliseconds(), Step: 1},
+		)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	cov := &Coverage{Covered: map[string]bool{}}
+
+	// run samples the corpus and runs the sample
+	run := func(corpus []query.Query) []query.Query {
+		sample := Sample(corpus, 0.5, rnd, cov)
+		for _, q := range sample {
+			cov.Record(&runner.Result{Query: q})
+		}
+		return sample
+	}
+
+	// The corpus counts the distinct queries, and only those run are covered
+	Sample(append(queries, queries[0]), 0.5, rnd, cov)
+	if cov.Corpus != len(queries) || len(cov.Covered) != 0 {
+		t.Errorf("Sample() corpus = %d, covered = %d, want %d and 0", cov.Corpus, len(cov.Covered), len(queries))
+	}
+
+	first := run(queries)
+	second := run(queries)
+	if len(first) != 4 || len(second) != 4 {
+		t.Fatalf("Sample() sampled %d and %d queries, want 4", len(first), len(second))
+	}
+
+	// Both strata must be represented in every run
+	for _, sample := range [][]query.Query{first, second} {
+		classes := map[string]int{}
+		for _, q := range sample {
+			classes[q.Class()]++
+		}
+		if classes["selector"] != 2 || classes["aggregation"] != 2 {
+			t.Errorf("Sample() strata = %v, want 2 queries of each class", classes)
+		}
+	}
+
+	// Two consecutive runs of half the corpus cover all of it
+	if len(cov.Covered) != len(queries) || cov.Runs != 3 {
+		t.Errorf("Sample() covered %d queries in %d runs, want %d in 3", len(cov.Covered), cov.Runs, len(queries))
+	}
+
+	run(queries)
+	if cov.Cycles != 1 || cov.Runs != 1 || len(cov.Covered) != 4 {
+		t.Errorf("Sample() = %+v, want a new cycle to start", cov)
+	}
+}
+
+func TestCoverage_ToString(t *testing.T) {
+	tests := []struct {
+		name string
+		cov  *Coverage
+		want string
+	}{
+		{name: "half covered", cov: &Coverage{Corpus: 4, Covered: map[string]bool{"a": true, "b": true}, Runs: 1}, want: "Corpus coverage: 2/4 queries (50.0%) across 1 runs, 0 full cycles\n"},
+		{name: "empty corpus", cov: &Coverage{Runs: 1}, want: "Corpus coverage: 0/0 queries (0.0%) across 1 runs, 0 full cycles\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cov.ToString(); got != tt.want {
+				t.Errorf("Coverage.ToString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShard(t *testing.T) {
+	queries := []query.Query{{Query: "a"}, {Query: "b"}, {Query: "c"}, {Query: "d"}, {Query: "e"}}
+	tests := []struct {
+		name string
+		i, n int
+		want []query.Query
+	}{
+		{name: "first of two", i: 1, n: 2, want: []query.Query{{Query: "a"}, {Query: "c"}, {Query: "e"}}},
+		{name: "second of two", i: 2, n: 2, want: []query.Query{{Query: "b"}, {Query: "d"}}},
+		{name: "single shard", i: 1, n: 1, want: queries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Shard(queries, tt.i, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Shard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		shard   string
+		i, n    int
+		wantErr bool
+	}{
+		{shard: "2/4", i: 2, n: 4},
+		{shard: "0/4", wantErr: true},
+		{shard: "5/4", wantErr: true},
+		{shard: "two", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shard, func(t *testing.T) {
+			i, n, err := ParseShard(tt.shard)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseShard() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if i != tt.i || n != tt.n {
+				t.Errorf("ParseShard() = %d/%d, want %d/%d", i, n, tt.i, tt.n)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	queries := []query.Query{{Query: "up"}, {Query: "rate(a[5m])"}, {Query: "sum(rate(b[1m]))"}, {Query: "b"}, {Query: "rate(c[5m])"}}
+	tests := []struct {
+		name        string
+		match       string
+		skip, limit int
+		want        []query.Query
+	}{
+		{name: "everything", want: queries},
+		{name: "match", match: `rate\(`, want: []query.Query{{Query: "rate(a[5m])"}, {Query: "sum(rate(b[1m]))"}, {Query: "rate(c[5m])"}}},
+		{name: "limit", limit: 2, want: []query.Query{{Query: "up"}, {Query: "rate(a[5m])"}}},
+		{name: "skip", skip: 3, want: []query.Query{{Query: "b"}, {Query: "rate(c[5m])"}}},
+		{name: "match, skip and limit", match: `^rate\(`, skip: 1, limit: 5, want: []query.Query{{Query: "rate(c[5m])"}}},
+		{name: "skip everything", skip: 10, want: []query.Query{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var match *regexp.Regexp
+			if tt.match != "" {
+				match = regexp.MustCompile(tt.match)
+			}
+			if got := Select(queries, match, tt.skip, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedup(t *testing.T) {
+	queries := []query.Query{
+		{Query: "up", Start: 1, End: 2, Step: 15, Tags: []string{"dashboard"}},
+		{Query: "up", Start: 1, End: 2, Step: 30},
+		{Query: "up", Start: 1, End: 2, Step: 15, Tags: []string{"alerting"}},
+		{Query: "rate(x[5m])", Start: 1, End: 2, Step: 15, Weight: 2},
+		{Query: "up", Start: 1, End: 2, Step: 15},
+		{Query: "rate(x[5m])", Start: 1, End: 2, Step: 15},
+	}
+	want := []query.Query{
+		{Query: "up", Start: 1, End: 2, Step: 15, Tags: []string{"dashboard"}, Weight: 3},
+		{Query: "up", Start: 1, End: 2, Step: 30, Weight: 1},
+		{Query: "rate(x[5m])", Start: 1, End: 2, Step: 15, Weight: 3},
+	}
+	if got := Dedup(queries); !reflect.DeepEqual(got, want) {
+		t.Errorf("Dedup() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	queries := []query.Query{{Query: "a"}, {Query: "b"}}
+	got := Cycle(queries, 5)
+	want := []query.Query{{Query: "a"}, {Query: "b"}, {Query: "a"}, {Query: "b"}, {Query: "a"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Cycle() = %v, want %v", got, want)
+	}
+	if got := Cycle(nil, 5); got != nil {
+		t.Errorf("Cycle() of no queries = %v, want none", got)
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		step    string
+		want    int
+		wantErr bool
+	}{
+		{step: "60", want: 60},
+		{step: "15s", want: 15},
+		{step: "1m", want: 60},
+		{step: "1h30m", want: 5400},
+		{step: "1d", want: 86400},
+		{step: "500ms", wantErr: true},
+		{step: "often", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.step, func(t *testing.T) {
+			got, err := ParseStep(tt.step)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseStep() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseStep() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.UnixMilli(1597059548699)
+	tests := []struct {
+		time    string
+		want    int64
+		wantAgo time.Duration
+		wantRel bool
+		wantErr bool
+	}{
+		{time: "1597056698698", want: 1597056698698},
+		{time: "now", want: 1597059548699, wantRel: true},
+		{time: "now-1h", want: 1597059548699 - time.Hour.Milliseconds(), wantAgo: time.Hour, wantRel: true},
+		{time: "now-7d", want: 1597059548699 - 7*24*time.Hour.Milliseconds(), wantAgo: 7 * 24 * time.Hour, wantRel: true},
+		{time: "now+1h", wantErr: true},
+		{time: "now-", wantErr: true},
+		{time: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.time, func(t *testing.T) {
+			got, ago, err := ParseTime(tt.time, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseTime() = %v, want %v", got, tt.want)
+			}
+			if (ago != nil) != tt.wantRel || (ago != nil && *ago != tt.wantAgo) {
+				t.Errorf("ParseTime() ago = %v, want %v (relative %v)", ago, tt.wantAgo, tt.wantRel)
+			}
+		})
+	}
+}
+
+func TestReadFormat(t *testing.T) {
+	zero, sent := time.Duration(0), 1500*time.Millisecond
+	want := []query.Query{{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, Tags: []string{"dashboard"}}}
+	tests := []struct {
+		name         string
+		format       Format
+		fileContents string
+		want         []query.Query
+		wantErr      bool
+	}{
+		{
+			name:         "detected header",
+			fileContents: "Query|Start|End|Step|Tag\nup|1597056698698|1597059548699|15|dashboard",
+			want:         want,
+		},
+		{
+			name:         "header with reordered and extra columns",
+			format:       Format{Header: true},
+			fileContents: "panel|step|end|start|query|tag\n4|15|1597059548699|1597056698698|up|dashboard",
+			want:         want,
+		},
+		{
+			name:         "columns without header",
+			format:       Format{Columns: []string{"tag", "query", "start", "end", "step"}},
+			fileContents: "dashboard|up|1597056698698|1597059548699|15",
+			want:         want,
+		},
+		{
+			name:         "columns overriding header",
+			format:       Format{Header: true, Columns: []string{"tag", "query", "start", "end", "step"}},
+			fileContents: "a|b|c|d|e\ndashboard|up|1597056698698|1597059548699|15",
+			want:         want,
+		},
+		{
+			name:         "assertion columns",
+			fileContents: "query|start|end|step|tag|min_series|expect_nonempty\nup|1597056698698|1597059548699|15|dashboard|3|\nup|1597056698698|1597059548699|15|dashboard||true",
+			want: []query.Query{
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, Tags: []string{"dashboard"}, MinSeries: 3},
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, Tags: []string{"dashboard"}, ExpectNonEmpty: true},
+			},
+		},
+		{
+			name:         "at column",
+			fileContents: "query|start|end|step|at\nup|1597056698698|1597059548699|15|0s\nup|1597056698698|1597059548699|15|1.5s",
+			want: []query.Query{
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, At: &zero},
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, At: &sent},
+			},
+		},
+		{
+			name:         "timeout column",
+			fileContents: "query|start|end|step|timeout\nup|1597056698698|1597059548699|15|30s\nup|1597056698698|1597059548699|15|",
+			want: []query.Query{
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, Timeout: 30 * time.Second},
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15},
+			},
+		},
+		{
+			name:         "thanos columns",
+			fileContents: "query|start|end|step|dedup|partial_response|max_source_resolution\nup|1597056698698|1597059548699|15|false||5m\nup|1597056698698|1597059548699|15|||",
+			want: []query.Query{
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15, Thanos: query.Thanos{Dedup: new(bool), MaxSourceResolution: "5m"}},
+				{Query: `up`, Start: 1597056698698, End: 1597059548699, Step: 15},
+			},
+		},
+		{
+			name:         "invalid max_source_resolution",
+			fileContents: "query|start|end|step|max_source_resolution\nup|1597056698698|1597059548699|15|raw",
+			wantErr:      true,
+		},
+		{
+			name:         "zero timeout",
+			fileContents: "query|start|end|step|timeout\nup|1597056698698|1597059548699|15|0s",
+			wantErr:      true,
+		},
+		{
+			name:         "negative at",
+			fileContents: "query|start|end|step|at\nup|1597056698698|1597059548699|15|-1s",
+			wantErr:      true,
+		},
+		{
+			name:         "invalid assertion",
+			fileContents: "query|start|end|step|min_series\nup|1597056698698|1597059548699|15|many",
+			wantErr:      true,
+		},
+		{
+			name:         "header missing a required column",
+			format:       Format{Header: true},
+			fileContents: "query|start|end\nup|1597056698698|1597059548699",
+			wantErr:      true,
+		},
+		{
+			name:         "row missing a required column",
+			format:       Format{Columns: []string{"tag", "query", "start", "end", "step"}},
+			fileContents: "dashboard|up|1597056698698|1597059548699",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFormat(strings.NewReader(tt.fileContents), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFormat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	got, err := ParseColumns("Start, end,step,query,-")
+	if want := []string{"start", "end", "step", "query", "-"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseColumns() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := ParseColumns("query,start,end"); err == nil {
+		t.Errorf("ParseColumns() without the step column error = nil, want an error")
+	}
+}
+
+func TestWrite_roundTrip(t *testing.T) {
+	hour, zero := time.Hour, time.Duration(0)
+	queries := []query.Query{
+		{Query: `sum by(code) (rate(http_requests_total{code=~"4..|5..", job!~"canary|test"}[5m]))`, Start: 1597056698698, End: 1597059548699, Step: 15, Tags: []string{"dashboard"}},
+		{Query: `up{instance=~"a,b|c\td"} or vector(0)`, Start: 1597056698698, End: 1597059548699, Step: 60, SQL: `SELECT 1 WHERE 'a|b' = $1`},
+		{Query: `"quoted"`, Start: 1597056698698, End: 1597059548699, Step: 60},
+		{Query: `{job=~"api|web"}`, Endpoint: query.EndpointSeries, Start: 1597056698698, End: 1597059548699, Tags: []string{"adhoc|variables", "slo"}},
+		{Query: `up`, StartAgo: &hour, EndAgo: &zero, Step: 30},
+	}
+	for _, delimiter := range []rune{0, ',', '\t', ';'} {
+		t.Run(fmt.Sprintf("%q", delimiter), func(t *testing.T) {
+			format := Format{Delimiter: delimiter}
+			var b strings.Builder
+			if err := Write(&b, queries, format); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFormat(strings.NewReader(b.String()), format)
+			if err != nil {
+				t.Fatalf("ReadFormat() error = %v reading:\n%s", err, b.String())
+			}
+			if len(got) != len(queries) {
+				t.Fatalf("ReadFormat() = %d queries, want %d", len(got), len(queries))
+			}
+			for i, want := range queries {
+				g := got[i]
+				if want.StartAgo != nil {
+					// Relative times are resolved when read, so only their expression round-trips
+					if g.StartAgo == nil || *g.StartAgo != *want.StartAgo || g.EndAgo == nil || *g.EndAgo != *want.EndAgo {
+						t.Errorf("ReadFormat()[%d] relative times = %v-%v, want %v-%v", i, g.StartAgo, g.EndAgo, want.StartAgo, want.EndAgo)
+					}
+					g.Start, g.End, g.StartAgo, g.EndAgo, want.StartAgo, want.EndAgo = 0, 0, nil, nil, nil, nil
+				}
+				if !reflect.DeepEqual(g, want) {
+					t.Errorf("ReadFormat()[%d] = %+v, want %+v", i, g, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFormat_quoting(t *testing.T) {
+	got, err := Read(strings.NewReader(`"sum(rate(errors{code=~""4..|5..""}[5m]))"|1597056698698|1597059548699|15` + "\n" +
+		`up{job="api"}|1597056698698|1597059548699|15`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Query != `sum(rate(errors{code=~"4..|5.."}[5m]))` || got[1].Query != `up{job="api"}` {
+		t.Errorf("Read() = %q, %q", got[0].Query, got[1].Query)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		delimiter string
+		want      rune
+		wantErr   bool
+	}{
+		{delimiter: ",", want: ','},
+		{delimiter: "tab", want: '\t'},
+		{delimiter: `\t`, want: '\t'},
+		{delimiter: ";", want: ';'},
+		{delimiter: `"`, wantErr: true},
+		{delimiter: "||", wantErr: true},
+		{delimiter: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.delimiter, func(t *testing.T) {
+			got, err := ParseDelimiter(tt.delimiter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDelimiter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseDelimiter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
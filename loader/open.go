@@ -0,0 +1,105 @@
+package loader
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Stdin is the path standing for the standard input.
+const Stdin = "-"
+
+// HTTPClient fetches the query files given as URLs.
+var HTTPClient = http.DefaultClient
+
+// Open opens the query file at the given path, which may be:
+//   - Stdin, so generated queries can be piped into the benchmark.
+//   - An http:// or https:// URL.
+//   - An s3://<bucket>/<key> or gs://<bucket>/<key> URL of an object fetched anonymously over
+//     HTTPS, in the region of the AWS_REGION environment variable for S3. The Google Cloud Storage
+//     objects are fetched with the token of the GOOGLE_OAUTH_ACCESS_TOKEN environment variable, if
+//     set (e.g. to `gcloud auth print-access-token`).
+//   - A local file.
+//
+// Gzip compressed files are decompressed transparently.
+func Open(path string) (io.ReadCloser, error) {
+	rc, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(rc)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("unable to decompress %s. err=%w", path, err)
+		}
+		return readCloser{zr, rc}, nil
+	}
+	return readCloser{br, rc}, nil
+}
+
+// readCloser reads from a Reader wrapping the ReadCloser it closes.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func open(path string) (io.ReadCloser, error) {
+	if path == Stdin {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	u, err := url.Parse(path)
+	if err != nil || u.Host == "" {
+		return os.Open(path)
+	}
+	var header http.Header
+	switch u.Scheme {
+	case "http", "https":
+	case "s3":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		u = &url.URL{Scheme: "https", Host: u.Host + ".s3." + region + ".amazonaws.com", Path: u.Path}
+	case "gs":
+		u = &url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + u.Host + u.Path}
+		if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+			header = http.Header{"Authorization": {"Bearer " + token}}
+		}
+	default:
+		return os.Open(path)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s. err=%w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unable to fetch %s, status %s", path, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// name returns the file name of a path or URL, without its query string nor the .gz extension of
+// compressed files.
+func name(p string) string {
+	if u, err := url.Parse(p); err == nil && u.Host != "" {
+		p = u.Path
+	}
+	return strings.TrimSuffix(path.Base(p), ".gz")
+}
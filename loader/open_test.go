@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// URLClientMock answers every request with the URL and authorization header requested.
+type URLClientMock struct{}
+
+func (URLClientMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := req.URL.String() + " " + req.Header.Get("Authorization")
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/queries.csv", []byte("file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/queries.csv.gz", gzipped(t, "compressed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdin, err := os.Open(dir + "/queries.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(orig *os.File) { os.Stdin = orig }(os.Stdin)
+	os.Stdin = stdin
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/queries.csv":
+			w.Write([]byte("remote"))
+		case "/queries.csv.gz":
+			w.Write(gzipped(t, "remote compressed"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "file", path: dir + "/queries.csv", want: "file"},
+		{name: "compressed file", path: dir + "/queries.csv.gz", want: "compressed"},
+		{name: "stdin", path: Stdin, want: "file"},
+		{name: "missing file", path: dir + "/missing.csv", wantErr: true},
+		{name: "url", path: srv.URL + "/queries.csv", want: "remote"},
+		{name: "compressed url", path: srv.URL + "/queries.csv.gz", want: "remote compressed"},
+		{name: "missing url", path: srv.URL + "/missing.csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Open(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer f.Close()
+			if got, _ := io.ReadAll(f); string(got) != tt.want {
+				t.Errorf("Open() read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_objectStorage(t *testing.T) {
+	defer func(orig *http.Client) { HTTPClient = orig }(HTTPClient)
+	HTTPClient = &http.Client{Transport: URLClientMock{}}
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "token")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "s3://corpora/promql/queries.csv", want: "https://corpora.s3.eu-west-1.amazonaws.com/promql/queries.csv "},
+		{path: "gs://corpora/promql/queries.yaml", want: "https://storage.googleapis.com/corpora/promql/queries.yaml Bearer token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f, err := Open(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if got, _ := io.ReadAll(f); string(got) != tt.want {
+				t.Errorf("Open() requested %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
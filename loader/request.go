@@ -0,0 +1,77 @@
+package loader
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+// endpointQuery is the endpoint of the instant queries of the HTTP API.
+const endpointQuery = "query"
+
+// ParseRequest builds a Query from the path and parameters of a request to the HTTP API, e.g. a
+// target of a vegeta targets file or a request recorded by the record subcommand. The query, start,
+// end and step (or match[] for the metadata endpoints) are taken from its parameters. Instant
+// queries are turned into range queries of a single point at their time, with a step of a second.
+func ParseRequest(path string, params url.Values) (query.Query, error) {
+	i := strings.Index(path, endpointPrefix)
+	if i < 0 {
+		return query.Query{}, fmt.Errorf("path %q doesn't target the HTTP API", path)
+	}
+	endpoint := path[i+len(endpointPrefix):]
+
+	var q query.Query
+	var err error
+	switch endpoint {
+	case endpointQuery:
+		q.Query, q.Step = params.Get("query"), 1
+		if q.Start, err = parseAPITime(params.Get("time")); err != nil {
+			return query.Query{}, err
+		}
+		q.End = q.Start
+		return q, nil
+	case query.EndpointQueryRange:
+		q.Query = params.Get("query")
+		if q.Step, err = ParseStep(params.Get("step")); err != nil {
+			return query.Query{}, err
+		}
+	case query.EndpointQueryExemplars:
+		q.Endpoint, q.Query = endpoint, params.Get("query")
+	default:
+		if err := query.ValidateEndpoint(endpoint); err != nil {
+			return query.Query{}, err
+		}
+		if len(params["match[]"]) > 1 {
+			return query.Query{}, fmt.Errorf("request to %s matches several series selectors, want at most one", path)
+		}
+		q.Endpoint, q.Query = endpoint, params.Get("match[]")
+	}
+	if q.Start, err = parseAPITime(params.Get("start")); err != nil {
+		return query.Query{}, err
+	}
+	if q.End, err = parseAPITime(params.Get("end")); err != nil {
+		return query.Query{}, err
+	}
+	return q, nil
+}
+
+// parseAPITime parses a time given to the HTTP API, either as RFC 3339 or as a unix timestamp in
+// seconds with an optional decimal part, returning it in milliseconds.
+func parseAPITime(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing time, want the start and end parameters or the time of instant queries")
+	}
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return int64(math.Round(seconds * 1000)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want RFC 3339 or a unix timestamp", s)
+	}
+	return t.UnixMilli(), nil
+}
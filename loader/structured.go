@@ -0,0 +1,128 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"gopkg.in/yaml.v3"
+)
+
+// record is a query of a JSON or YAML query file. Times and steps may be given as numbers or in
+// any of the forms accepted in CSV files, e.g. `now-1h` or `15s`.
+type record struct {
+	Query string   `json:"query" yaml:"query"`
+	Start any      `json:"start" yaml:"start"`
+	End   any      `json:"end" yaml:"end"`
+	Step  any      `json:"step" yaml:"step"`
+	SQL   string   `json:"sql" yaml:"sql"`
+	Tags  []string `json:"tags" yaml:"tags"`
+
+	MinSeries      any `json:"min_series" yaml:"min_series"`
+	ExpectNonEmpty any `json:"expect_nonempty" yaml:"expect_nonempty"`
+	At             any `json:"at" yaml:"at"`
+	Timeout        any `json:"timeout" yaml:"timeout"`
+
+	Dedup               any `json:"dedup" yaml:"dedup"`
+	PartialResponse     any `json:"partial_response" yaml:"partial_response"`
+	MaxSourceResolution any `json:"max_source_resolution" yaml:"max_source_resolution"`
+}
+
+// readStructured reads a JSON or YAML query file holding an array of objects with the query, start,
+// end and step, and optionally the sql, tags and assertions of every query, e.g.:
+//
+//   - query: rate(http_requests_total[5m])
+//     start: now-1h
+//     end: now
+//     step: 15s
+//     tags: [dashboard]
+//     min_series: 10
+//
+// Unknown fields are rejected, so misspelled ones don't go unnoticed.
+func readStructured(file io.Reader, typ string) ([]query.Query, error) {
+	var records []record
+	var err error
+	if typ == TypeJSON {
+		dec := json.NewDecoder(file)
+		dec.UseNumber()
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&records)
+	} else {
+		dec := yaml.NewDecoder(file)
+		dec.KnownFields(true)
+		if err = dec.Decode(&records); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse provided file as %s. err=%w", strings.ToUpper(typ), err)
+	}
+
+	now := time.Now()
+	queries := make([]query.Query, len(records))
+	for i, r := range records {
+		for _, c := range requiredColumns {
+			if r.field(c) == "" {
+				return nil, fmt.Errorf("query %d: missing the %s field", i+1, c)
+			}
+		}
+		if queries[i], err = parseQuery(r.field, now); err != nil {
+			return nil, fmt.Errorf("query %d: %w", i+1, err)
+		}
+	}
+	return queries, nil
+}
+
+// field returns the value of the field of the named column, as it would be given in a CSV file.
+func (r record) field(name string) string {
+	switch name {
+	case ColumnQuery:
+		return r.Query
+	case ColumnStart:
+		return scalar(r.Start)
+	case ColumnEnd:
+		return scalar(r.End)
+	case ColumnStep:
+		return scalar(r.Step)
+	case ColumnSQL:
+		return r.SQL
+	case ColumnTag:
+		return strings.Join(r.Tags, ",")
+	case ColumnMinSeries:
+		return scalar(r.MinSeries)
+	case ColumnExpectNonEmpty:
+		return scalar(r.ExpectNonEmpty)
+	case ColumnAt:
+		return scalar(r.At)
+	case ColumnTimeout:
+		return scalar(r.Timeout)
+	case ColumnDedup:
+		return scalar(r.Dedup)
+	case ColumnPartialResponse:
+		return scalar(r.PartialResponse)
+	case ColumnMaxSourceResolution:
+		return scalar(r.MaxSourceResolution)
+	}
+	return ""
+}
+
+// scalar formats a number or string decoded from JSON or YAML, empty if missing.
+func scalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,123 @@
+package loader
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestReadFormat_structured(t *testing.T) {
+	want := []query.Query{
+		{Query: `sum(rate(http_requests_total{code=~"5.."}[5m]))`, Start: 1597056698698, End: 1597059548699, Step: 15, Tags: []string{"dashboard", "slo"}},
+		{Query: `{job="api"}`, Endpoint: query.EndpointSeries, Start: 1597057698698, End: 1597058548699, Step: 60, SQL: "SELECT 1"},
+	}
+	tests := []struct {
+		name         string
+		typ          string
+		fileContents string
+		want         []query.Query
+		wantErr      bool
+	}{
+		{
+			name: "json",
+			typ:  TypeJSON,
+			fileContents: `[
+				{"query": "sum(rate(http_requests_total{code=~\"5..\"}[5m]))", "start": 1597056698698, "end": "1597059548699", "step": "15s", "tags": ["dashboard", "slo"]},
+				{"query": "/api/v1/series {job=\"api\"}", "start": 1597057698698, "end": 1597058548699, "step": 60, "sql": "SELECT 1"}
+			]`,
+			want: want,
+		},
+		{
+			name: "yaml",
+			typ:  TypeYAML,
+			fileContents: `
+- query: sum(rate(http_requests_total{code=~"5.."}[5m]))
+  start: 1597056698698
+  end: "1597059548699"
+  step: 15s
+  tags: [dashboard, slo]
+- query: /api/v1/series {job="api"}
+  start: 1597057698698
+  end: 1597058548699
+  step: 1m
+  sql: SELECT 1
+`,
+			want: want,
+		},
+		{
+			name:         "assertions",
+			typ:          TypeYAML,
+			fileContents: "- {query: up, start: 1, end: 2, step: 15, min_series: 2}\n- {query: up, start: 1, end: 2, step: 15, expect_nonempty: true}",
+			want: []query.Query{
+				{Query: "up", Start: 1, End: 2, Step: 15, MinSeries: 2},
+				{Query: "up", Start: 1, End: 2, Step: 15, ExpectNonEmpty: true},
+			},
+		},
+		{
+			name:         "empty yaml",
+			typ:          TypeYAML,
+			fileContents: "",
+			want:         []query.Query{},
+		},
+		{
+			name:         "unknown field",
+			typ:          TypeJSON,
+			fileContents: `[{"query": "up", "start": 1, "end": 2, "step": 15, "tag": "dashboard"}]`,
+			wantErr:      true,
+		},
+		{
+			name:         "missing step",
+			typ:          TypeYAML,
+			fileContents: "- {query: up, start: 1, end: 2}",
+			wantErr:      true,
+		},
+		{
+			name:         "not an array",
+			typ:          TypeJSON,
+			fileContents: `{"query": "up"}`,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFormat(strings.NewReader(tt.fileContents), Format{Type: tt.typ})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFormat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFormat_structuredRelative(t *testing.T) {
+	got, err := ReadFormat(strings.NewReader(`[{"query": "up", "start": "now-1h", "end": "now", "step": 15}]`), Format{Type: TypeJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].StartAgo == nil || *got[0].StartAgo != time.Hour || got[0].EndAgo == nil || *got[0].EndAgo != 0 {
+		t.Errorf("ReadFormat() relative times = %v-%v, want 1h-0s", got[0].StartAgo, got[0].EndAgo)
+	}
+}
+
+func TestTypeFromPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"queries.csv":  TypeCSV,
+		"queries":      TypeCSV,
+		"queries.JSON": TypeJSON,
+		"queries.yaml": TypeYAML,
+		"q/set.yml":    TypeYAML,
+		"q.json.gz":    TypeJSON,
+		"https://example.com/queries.yaml.gz?version=2": TypeYAML,
+		"gs://corpora/queries.json":                     TypeJSON,
+	} {
+		if got := TypeFromPath(path); got != want {
+			t.Errorf("TypeFromPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package loader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+// vegetaTarget is a target of a vegeta targets file in the JSON format.
+type vegetaTarget struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// readVegeta reads a vegeta targets file, in either the HTTP format, i.e. a method and a URL per
+// target, optionally followed by its headers, e.g.:
+//
+//	GET http://localhost:9090/api/v1/query_range?query=up&start=1597056698&end=1597059548&step=15
+//	X-Scope-OrgID: tenant
+//
+// or the JSON format, i.e. a JSON object with the method and URL per line. Queries are built from
+// the path and parameters of the URLs, see ParseRequest, whose host is ignored in favor of the
+// target benchmarked. Header lines are ignored too, as headers are given by the flags of the
+// benchmark, and body files aren't supported.
+func readVegeta(file io.Reader) ([]query.Query, error) {
+	queries := []query.Query{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	target := false
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		var method, rawURL string
+		switch {
+		case line == "":
+			target = false
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "{"):
+			var t vegetaTarget
+			if err := json.Unmarshal([]byte(line), &t); err != nil {
+				return nil, fmt.Errorf("line %d: invalid JSON target. err=%w", n, err)
+			}
+			method, rawURL = t.Method, t.URL
+		case target && strings.HasPrefix(line, "@"):
+			return nil, fmt.Errorf("line %d: body files are not supported, give the parameters in the URL", n)
+		case target && isVegetaHeader(line):
+			continue
+		default:
+			var ok bool
+			if method, rawURL, ok = strings.Cut(line, " "); !ok {
+				return nil, fmt.Errorf("line %d: invalid target %q, want a method and a URL", n, line)
+			}
+			target = true
+		}
+
+		if method != http.MethodGet && method != http.MethodPost {
+			return nil, fmt.Errorf("line %d: unsupported method %q, want GET or POST", n, method)
+		}
+		q, err := parseVegetaURL(strings.TrimSpace(rawURL))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		queries = append(queries, q)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read vegeta targets. err=%w", err)
+	}
+	return queries, nil
+}
+
+// isVegetaHeader reports whether the line following a target is one of its headers, named like
+// `Name:`, rather than the next target.
+func isVegetaHeader(line string) bool {
+	name, _, _ := strings.Cut(line, " ")
+	return strings.HasSuffix(name, ":")
+}
+
+// parseVegetaURL builds a Query from the URL of an HTTP API request, see ParseRequest.
+func parseVegetaURL(rawURL string) (query.Query, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return query.Query{}, fmt.Errorf("invalid URL %q. err=%w", rawURL, err)
+	}
+	return ParseRequest(u.Path, u.Query())
+}
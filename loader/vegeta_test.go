@@ -0,0 +1,82 @@
+package loader
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestReadFormat_vegeta(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileContents string
+		want         []query.Query
+		wantErr      bool
+	}{
+		{
+			name: "http",
+			fileContents: `# dashboards
+GET http://prometheus:9090/api/v1/query_range?query=sum(rate(http_requests_total%5B5m%5D))&start=1597056698.698&end=1597059548.699&step=15s
+X-Scope-OrgID: tenant
+Authorization: Bearer token
+POST http://prometheus:9090/prefix/api/v1/series?match%5B%5D=%7Bjob%3D%22api%22%7D&start=2020-08-10T10:54:58Z&end=2020-08-10T11:41:58Z
+
+GET http://prometheus:9090/api/v1/label/job/values?start=1&end=2
+`,
+			want: []query.Query{
+				{Query: "sum(rate(http_requests_total[5m]))", Start: 1597056698698, End: 1597059548699, Step: 15},
+				{Query: `{job="api"}`, Endpoint: query.EndpointSeries, Start: 1597056898000, End: 1597059718000},
+				{Endpoint: "label/job/values", Start: 1000, End: 2000},
+			},
+		},
+		{
+			name:         "json",
+			fileContents: `{"method": "GET", "url": "http://prometheus:9090/api/v1/query_exemplars?query=up&start=1&end=2", "header": {"X-Scope-OrgID": ["tenant"]}}`,
+			want:         []query.Query{{Query: "up", Endpoint: query.EndpointQueryExemplars, Start: 1000, End: 2000}},
+		},
+		{
+			name:         "empty",
+			fileContents: "",
+			want:         []query.Query{},
+		},
+		{
+			name:         "instant query",
+			fileContents: "GET http://prometheus:9090/api/v1/query?query=up&time=1",
+			want:         []query.Query{{Query: "up", Start: 1000, End: 1000, Step: 1}},
+		},
+		{
+			name:         "unknown endpoint",
+			fileContents: "GET http://prometheus:9090/api/v1/status/buildinfo",
+			wantErr:      true,
+		},
+		{
+			name:         "missing start",
+			fileContents: "GET http://prometheus:9090/api/v1/query_range?query=up&end=2&step=15",
+			wantErr:      true,
+		},
+		{
+			name:         "body file",
+			fileContents: "POST http://prometheus:9090/api/v1/query_range?query=up&start=1&end=2&step=15\n@query.txt",
+			wantErr:      true,
+		},
+		{
+			name:         "unsupported method",
+			fileContents: "DELETE http://prometheus:9090/api/v1/admin/tsdb/delete_series",
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFormat(strings.NewReader(tt.fileContents), Format{Type: TypeVegeta})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadFormat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadFormat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Formats of the log records.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newLogger returns the logger writing the records of the given level (debug, info, warn or
+// error) and above to w, formatted as logfmt text or as JSON.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q, want debug, info, warn or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want %s or %s", format, logFormatText, logFormatJSON)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_newLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{name: "text", level: "info", format: "text", want: `level=WARN msg="unable to annotate the run" err=refused`},
+		{name: "json", level: "info", format: "json", want: `"level":"WARN","msg":"unable to annotate the run","err":"refused"}`},
+		{name: "level above", level: "error", format: "text"},
+		{name: "unknown level", level: "verbose", format: "text", wantErr: true},
+		{name: "unknown format", level: "info", format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := newLogger(&buf, tt.level, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogger() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			logger.Debug("sending query")
+			logger.Warn("unable to annotate the run", "err", "refused")
+			if got := strings.TrimSpace(buf.String()); !strings.HasSuffix(got, tt.want) || (tt.want == "") != (got == "") {
+				t.Errorf("newLogger() logged %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
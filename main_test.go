@@ -1,57 +1,23 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"net/http"
-	"net/url"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"reflect"
 	"strings"
 	"testing"
-)
+	"time"
 
-func Test_getQueriesStats(t *testing.T) {
-	tests := []struct {
-		name      string
-		queryList []Query
-		want      *Stats
-	}{
-		{
-			name: "OK",
-			queryList: []Query{
-				{Query: "", Start: 0, End: 2}, // 2
-				{Query: "", Start: 0, End: 2}, // 2
-				{Query: "", Start: 0, End: 2}, // 2
-			},
-			want: &Stats{Average: 2, Fastest: 2, Median: 2, Slowest: 2},
-		},
-		{
-			name: "Odd number of queries",
-			queryList: []Query{
-				{Query: "", Start: 0, End: 1}, // 1
-				{Query: "", Start: 0, End: 2}, // 2
-				{Query: "", Start: 0, End: 3}, // 3
-			},
-			want: &Stats{Average: 2, Fastest: 1, Median: 2, Slowest: 3},
-		},
-		{
-			name: "Even number of queries",
-			queryList: []Query{
-				{Query: "", Start: 0, End: 1}, // 1
-				{Query: "", Start: 0, End: 2}, // 2
-				{Query: "", Start: 0, End: 3}, // 3
-				{Query: "", Start: 0, End: 4}, // 4
-			},
-			want: &Stats{Average: 2.5, Fastest: 1, Median: 2.5, Slowest: 4},
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := getQueriesStats(tt.queryList); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getQueriesStats() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/loader"
+	"github.com/noelruault/pqlbench/notify"
+	"github.com/noelruault/pqlbench/scrape"
+)
 
 func Test_parseFlags(t *testing.T) {
 	tests := []struct {
@@ -65,10 +31,44 @@ func Test_parseFlags(t *testing.T) {
 			args:    []string{"benchmark"},
 			wantErr: true,
 		},
+		{
+			name:    "Authentication distributed to agents",
+			args:    []string{"benchmark", "--filepath=promql_queries.csv", "--agents=localhost:9300", "--auth.oauth2.token-url=http://localhost:8080/token"},
+			wantErr: true,
+		},
 		{
 			name: "OK",
 			args: []string{"benchmark", "--filepath=promql_queries.csv", "--workers=100", "--promscale.url=http://localhost:9201"},
-			want: &Config{Filepath: "promql_queries.csv", Workers: 100, URL: "http://localhost:9201"},
+			want: &Config{
+				Filepath:             "promql_queries.csv",
+				Format:               loader.Format{Type: loader.TypeCSV, Delimiter: '|'},
+				Workers:              100,
+				Timeout:              time.Second,
+				HealthCheck:          true,
+				Gzip:                 true,
+				Body:                 "count",
+				Transport:            client.TransportOptions{MaxIdleConnsPerHost: 100, IdleConnTimeout: 90 * time.Second},
+				URL:                  "http://localhost:9201",
+				CalibrationQuery:     "vector(1)",
+				OutputFormat:         "json",
+				Arrival:              "closed",
+				Speed:                1,
+				CheckpointInterval:   time.Minute,
+				BreakerProbeInterval: 5 * time.Second,
+				Balance:              "round-robin",
+				SplitMinRange:        24 * time.Hour,
+				Repeat:               1,
+				PerQuerySort:         "median",
+				Mode:                 "promql",
+				SQLDSN:               "postgres://postgres@localhost:5432/postgres",
+				StatsDPrefix:         "pqlbench.",
+				ScrapeInterval:       5 * time.Second,
+				ScrapeMetrics:        scrape.DefaultMetrics,
+				LogLevel:             "info",
+				LogFormat:            "text",
+				Percentiles:          []float64{50, 90, 95, 99},
+				Runs:                 1,
+			},
 		},
 	}
 	for _, tt := range tests {
@@ -90,151 +90,264 @@ func Test_parseFlags(t *testing.T) {
 	}
 }
 
-func Test_getScheme(t *testing.T) {
-	var newStringPtr = func(s string) *string {
-		return &s
-	}
-
+func Test_applyOverrides(t *testing.T) {
 	tests := []struct {
-		name string
-		text string
-		want *string
+		name     string
+		file     string
+		contents string
+		args     []string
+		env      map[string]string
+		want     map[string]string
+		wantErr  bool
 	}{
 		{
-			name: "https scheme",
-			text: "https://example.xyz",
-			want: newStringPtr("https://"),
+			name:     "yaml",
+			file:     "bench.yaml",
+			contents: "workers: 10\npromscale:\n  url: http://yaml:9201\n",
+			want:     map[string]string{"workers": "10", "promscale.url": "http://yaml:9201"},
 		},
 		{
-			name: "ftp scheme",
-			text: "ftp://example.xyz",
-			want: newStringPtr("ftp://"),
+			name:     "toml",
+			file:     "bench.toml",
+			contents: "workers = 10\n[promscale]\nurl = \"http://toml:9201\"\n",
+			want:     map[string]string{"workers": "10", "promscale.url": "http://toml:9201"},
 		},
 		{
-			name: "http scheme",
-			text: "http://example.xyz",
-			want: newStringPtr("http://"),
+			name:     "environment overrides file, flags override both",
+			file:     "bench.yaml",
+			contents: "workers: 10\npromscale:\n  url: http://yaml:9201\n",
+			args:     []string{"--workers=20"},
+			env:      map[string]string{"PQLBENCH_WORKERS": "30", "PQLBENCH_PROMSCALE_URL": "http://env:9201"},
+			want:     map[string]string{"workers": "20", "promscale.url": "http://env:9201"},
 		},
 		{
-			name: "wrong scheme",
-			text: "://example.xyz",
-			want: nil,
+			name:     "unknown key",
+			file:     "bench.yaml",
+			contents: "wrokers: 10\n",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid value",
+			file:     "bench.yaml",
+			contents: "workers: many\n",
+			wantErr:  true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := getScheme(tt.text); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getScheme() = %v, want %v", got, tt.want)
+			path := t.TempDir() + "/" + tt.file
+			if err := os.WriteFile(path, []byte(tt.contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Int("workers", 1, "")
+			fs.String("promscale.url", "", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			values, err := readConfigFile(path)
+			if err == nil {
+				err = applyOverrides(fs, values, func(key string) (string, bool) {
+					v, ok := tt.env[key]
+					return v, ok
+				})
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyOverrides() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("applyOverrides() %s = %v, want %v", name, got, want)
+				}
 			}
 		})
 	}
 }
 
-type ClientMock struct{}
-
-func (c *ClientMock) Get(url string) (resp *http.Response, err error) {
-	return &http.Response{StatusCode: 200}, nil
+func Test_parseFlags_header(t *testing.T) {
+	os.Args = []string{os.Args[0], "benchmark", "--filepath=promql_queries.csv", "--header=X-Scope-OrgID: ignored", "--header=X-Priority: low",
+		"--tenant=team-a", "--cortex.no-store", "--mimir.shards=1"}
+	got, err := parseFlags()
+	if err != nil {
+		t.Fatalf("parseFlags() error = %v", err)
+	}
+	want := http.Header{"X-Scope-Orgid": {"team-a"}, "X-Priority": {"low"}, "Cache-Control": {"no-store"}, "Sharding-Control": {"1"}}
+	if !reflect.DeepEqual(got.Header, want) {
+		t.Errorf("parseFlags() header = %v, want %v", got.Header, want)
+	}
 }
 
-// TestURL_getHTTPQuery checks the integrity of the url constructed by the method getHTTPQuery
-func TestURL_getHTTPQuery(t *testing.T) {
+func Test_parseFlags_targets(t *testing.T) {
 	tests := []struct {
 		name    string
-		query   *Query
-		url     *url.URL
-		version string
-		want    *url.URL
+		args    []string
+		url     string
+		targets []string
+		wantErr bool
 	}{
-		{
-			url: &url.URL{Scheme: "https", Host: "promscale.xyz"},
-			query: &Query{
-				Query: "some query",
-				Start: 100000,
-				End:   999999,
-				Step:  50,
-			},
-			version: "v1",
-			want: &url.URL{
-				Scheme:   "https",
-				Host:     "promscale.xyz",
-				Path:     "/api/v1/query_range",
-				RawQuery: "end=1970-01-01T01%3A16%3A39%2B01%3A00&query=some+query&start=1970-01-01T01%3A01%3A40%2B01%3A00&step=50",
-			},
-		},
+		{name: "single target", args: []string{"--promscale.url=http://a:9090"}, url: "http://a:9090"},
+		{name: "several targets", args: []string{"--promscale.url=http://a:9090, http://b:9090", "--balance=random"}, url: "http://a:9090", targets: []string{"http://a:9090", "http://b:9090"}},
+		{name: "unknown balance", args: []string{"--balance=least-loaded"}, wantErr: true},
+		{name: "weights without several targets", args: []string{"--balance.weights=90,10"}, wantErr: true},
+		{name: "weights of every target", args: []string{"--promscale.url=http://a:9090,http://b:9090", "--balance.weights=90"}, wantErr: true},
+		{name: "several targets with affinity", args: []string{"--promscale.url=http://a:9090,http://b:9090", "--affinity"}, wantErr: true},
+		{name: "several targets with sockets", args: []string{"--promscale.url=unix:///a.sock,http://b:9090"}, wantErr: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := &Client{
-				Client:  &ClientMock{},
-				URL:     tt.url,
-				Version: "v1",
+			os.Args = append([]string{os.Args[0], "benchmark", "--filepath=promql_queries.csv"}, tt.args...)
+			got, err := parseFlags()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFlags() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			got, err := c.getHTTPQuery(tt.query)
 			if err != nil {
-				t.Errorf("Client.getHTTPQuery() error = %v", err)
 				return
 			}
-			if !reflect.DeepEqual(c.URL, tt.want) {
-				t.Errorf("Client.getHTTPQuery() = %v, want %v", got, tt.want)
+			if got.URL != tt.url || !reflect.DeepEqual(got.Targets, tt.targets) {
+				t.Errorf("parseFlags() url, targets = %s, %v, want %s, %v", got.URL, got.Targets, tt.url, tt.targets)
 			}
 		})
 	}
 }
 
-func Test_readFile(t *testing.T) {
+func Test_parseFlags_transport(t *testing.T) {
 	tests := []struct {
-		name         string
-		fileContents string
-		want         []Query
-		wantErr      bool
+		name    string
+		args    []string
+		want    client.TransportOptions
+		wantErr bool
 	}{
 		{
-			name:         "empty file",
-			fileContents: ``,
-			want:         []Query{},
+			name: "dns options",
+			args: []string{"--dns.server=10.0.0.2", "--dns.cache-ttl=1m", "--ip-family=4", "--resolve=promscale.internal:10.0.0.5"},
+			want: client.TransportOptions{DNSServer: "10.0.0.2", DNSCacheTTL: time.Minute, IPFamily: "4", Resolve: map[string]string{"promscale.internal": "10.0.0.5"}},
 		},
+		{name: "unknown ip family", args: []string{"--ip-family=5"}, wantErr: true},
+		{name: "negative cache ttl", args: []string{"--dns.cache-ttl=-1s"}, wantErr: true},
+		{name: "dns options over http3", args: []string{"--ip-family=6", "--http-version=3"}, wantErr: true},
+		{name: "proxy over http3", args: []string{"--proxy=http://proxy:3128", "--http-version=3"}, wantErr: true},
+		{name: "pool size over http3", args: []string{"--http.max-conns-per-host=4", "--http-version=3"}, wantErr: true},
 		{
-			name:         "one row",
-			fileContents: `demo_cpu_usage_seconds_total{mode="idle"}|1597056698698|1597059548699|15000`,
-			want: []Query{
-				{
-					Query: `demo_cpu_usage_seconds_total{mode="idle"}`,
-					Start: 1597056698698,
-					End:   1597059548699,
-					Step:  15000,
-				},
-			},
-		},
-		{
-			name: "multiple rows",
-			fileContents: `demo_cpu_usage_seconds_total{mode="idle"}|1597056698698|1597059548699|15000
-avg by(instance) (demo_cpu_usage_seconds_total)|1597057698698|1597058548699|60000`,
-			want: []Query{
-				{
-					Query: `demo_cpu_usage_seconds_total{mode="idle"}`,
-					Start: 1597056698698,
-					End:   1597059548699,
-					Step:  15000,
-				},
-				{
-					Query: `avg by(instance) (demo_cpu_usage_seconds_total)`,
-					Start: 1597057698698,
-					End:   1597058548699,
-					Step:  60000,
-				},
-			},
+			name: "http3",
+			args: []string{"--http-version=3", "--disable-keepalive", "--tls.session-cache", "--http.idle-conn-timeout=1m"},
+			want: client.TransportOptions{HTTPVersion: client.HTTP3, DisableKeepAlives: true, TLSSessionCache: true, IdleConnTimeout: time.Minute},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := readFile(strings.NewReader(tt.fileContents))
+			os.Args = append([]string{os.Args[0], "benchmark", "--filepath=promql_queries.csv"}, tt.args...)
+			got, err := parseFlags()
 			if (err != nil) != tt.wantErr {
-				t.Errorf("readFile() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("parseFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("readFile() = %v, want %v", got, tt.want)
+			if got.Transport.DNSServer != tt.want.DNSServer || got.Transport.DNSCacheTTL != tt.want.DNSCacheTTL ||
+				got.Transport.IPFamily != tt.want.IPFamily || !reflect.DeepEqual(got.Transport.Resolve, tt.want.Resolve) ||
+				got.Transport.HTTPVersion != tt.want.HTTPVersion || got.Transport.DisableKeepAlives != tt.want.DisableKeepAlives ||
+				got.Transport.TLSSessionCache != tt.want.TLSSessionCache {
+				t.Errorf("parseFlags() transport = %+v, want %+v", got.Transport, tt.want)
 			}
 		})
 	}
 }
+
+func Test_parseFlags_estimate(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantProbes int
+		wantErr    bool
+	}{
+		{name: "estimate", args: []string{"estimate", "--probes=5"}, wantProbes: 5},
+		{name: "estimate without probes", args: []string{"estimate"}},
+		{name: "probes without estimate", args: []string{"benchmark", "--probes=5"}, wantErr: true},
+		{name: "negative probes", args: []string{"estimate", "--probes=-1"}, wantErr: true},
+		{name: "estimate find-max", args: []string{"estimate", "--find-max=rps"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = append([]string{os.Args[0], tt.args[0], "--filepath=promql_queries.csv"}, tt.args[1:]...)
+			got, err := parseFlags()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !got.Estimate || got.Probes != tt.wantProbes {
+				t.Errorf("parseFlags() estimate = %v, probes = %d, want true, %d", got.Estimate, got.Probes, tt.wantProbes)
+			}
+		})
+	}
+}
+
+func Test_redactHeader(t *testing.T) {
+	header := http.Header{"Authorization": {"Bearer secret"}, "X-Api-Key": {"secret"}, "X-Scope-Orgid": {"tenant"}}
+	want := http.Header{"Authorization": {"redacted"}, "X-Api-Key": {"redacted"}, "X-Scope-Orgid": {"tenant"}}
+	if got := redactHeader(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("redactHeader() = %v, want %v", got, want)
+	}
+	if header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("redactHeader() modified the headers %v", header)
+	}
+}
+
+func Test_diffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+		diff string
+	}{
+		{name: "equal", got: "a\nb\n", want: "a\nb\n", diff: ""},
+		{name: "changed line", got: "a\nc\n", want: "a\nb\n", diff: "line 2:\n- b\n+ c"},
+		{name: "missing line", got: "a\n", want: "a\nb\n", diff: "line 2:\n- b\n+ "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.got, tt.want); got != tt.diff {
+				t.Errorf("diffLines() = %q, want %q", got, tt.diff)
+			}
+		})
+	}
+}
+
+func Test_main_notifyNotReady(t *testing.T) {
+	// The tool is run in a process of its own, as it exits
+	if args := os.Getenv("PQLBENCH_TEST_ARGS"); args != "" {
+		os.Args = append(os.Args[:1], strings.Split(args, " ")...)
+		main()
+		return
+	}
+
+	notifications := make(chan notify.Notification, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var n notify.Notification
+		json.NewDecoder(req.Body).Decode(&n)
+		notifications <- n
+	}))
+	defer webhook.Close()
+	target := httptest.NewServer(http.NotFoundHandler())
+	target.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^Test_main_notifyNotReady$")
+	cmd.Env = append(os.Environ(), "PQLBENCH_TEST_ARGS=benchmark -filepath=promql_queries.csv -promscale.url="+target.URL+" -notify.webhook-url="+webhook.URL)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != ExitConnectivity {
+		t.Fatalf("benchmark against a target not ready error = %v, want exit code %d", err, ExitConnectivity)
+	}
+	select {
+	case n := <-notifications:
+		if n.Status != notify.StatusFailed || !strings.Contains(n.Error, "target is not ready") || n.Target != target.URL {
+			t.Errorf("benchmark against a target not ready notified %+v, want a failure", n)
+		}
+	default:
+		t.Errorf("benchmark against a target not ready didn't notify")
+	}
+}
@@ -0,0 +1,245 @@
+// Package mock serves a synthetic Prometheus HTTP API, answering range queries after a random
+// latency with generated series, so the benchmark pipeline can be tested and demoed without a real
+// Promscale.
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Distribution draws the latencies of the responses.
+type Distribution interface {
+	Sample(rnd *rand.Rand) time.Duration
+	ToString() string
+}
+
+// Constant is a Distribution always drawing the same latency.
+type Constant struct{ Latency time.Duration }
+
+func (d Constant) Sample(*rand.Rand) time.Duration { return d.Latency }
+
+func (d Constant) ToString() string { return "constant:" + d.Latency.String() }
+
+// Uniform is a Distribution drawing latencies uniformly between Min and Max.
+type Uniform struct{ Min, Max time.Duration }
+
+func (d Uniform) Sample(rnd *rand.Rand) time.Duration {
+	return d.Min + time.Duration(rnd.Int63n(int64(d.Max-d.Min)+1))
+}
+
+func (d Uniform) ToString() string { return "uniform:" + d.Min.String() + "-" + d.Max.String() }
+
+// Normal is a Distribution drawing normally distributed latencies, never negative.
+type Normal struct{ Mean, StdDev time.Duration }
+
+func (d Normal) Sample(rnd *rand.Rand) time.Duration {
+	return max(0, d.Mean+time.Duration(rnd.NormFloat64()*float64(d.StdDev)))
+}
+
+func (d Normal) ToString() string { return "normal:" + d.Mean.String() + "," + d.StdDev.String() }
+
+// LogNormal is a Distribution drawing log-normally distributed latencies of the given Median,
+// whose tail grows longer with Sigma, as the latencies of real servers usually are.
+type LogNormal struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+func (d LogNormal) Sample(rnd *rand.Rand) time.Duration {
+	return time.Duration(float64(d.Median) * math.Exp(rnd.NormFloat64()*d.Sigma))
+}
+
+func (d LogNormal) ToString() string {
+	return "lognormal:" + d.Median.String() + "," + strconv.FormatFloat(d.Sigma, 'f', -1, 64)
+}
+
+// Exponential is a Distribution drawing exponentially distributed latencies of the given Mean.
+type Exponential struct{ Mean time.Duration }
+
+func (d Exponential) Sample(rnd *rand.Rand) time.Duration {
+	return time.Duration(rnd.ExpFloat64() * float64(d.Mean))
+}
+
+func (d Exponential) ToString() string { return "exponential:" + d.Mean.String() }
+
+// ParseDistribution parses a latency distribution given as constant:<latency>,
+// uniform:<min>-<max>, normal:<mean>,<stddev>, lognormal:<median>,<sigma> or
+// exponential:<mean>, e.g. lognormal:20ms,0.5.
+func ParseDistribution(spec string) (Distribution, error) {
+	kind, params, _ := strings.Cut(spec, ":")
+	invalid := func(want string) error {
+		return fmt.Errorf("invalid %s latency distribution %q, want %s:%s", kind, spec, kind, want)
+	}
+	switch kind {
+	case "constant":
+		d, err := time.ParseDuration(params)
+		if err != nil || d < 0 {
+			return nil, invalid("<latency>")
+		}
+		return Constant{d}, nil
+	case "uniform":
+		lo, hi, _ := strings.Cut(params, "-")
+		minimum, err1 := time.ParseDuration(lo)
+		maximum, err2 := time.ParseDuration(hi)
+		if err1 != nil || err2 != nil || minimum < 0 || maximum < minimum {
+			return nil, invalid("<min>-<max>")
+		}
+		return Uniform{minimum, maximum}, nil
+	case "normal":
+		m, s, _ := strings.Cut(params, ",")
+		mean, err1 := time.ParseDuration(m)
+		stddev, err2 := time.ParseDuration(s)
+		if err1 != nil || err2 != nil || mean < 0 || stddev < 0 {
+			return nil, invalid("<mean>,<stddev>")
+		}
+		return Normal{mean, stddev}, nil
+	case "lognormal":
+		m, s, _ := strings.Cut(params, ",")
+		median, err1 := time.ParseDuration(m)
+		sigma, err2 := strconv.ParseFloat(s, 64)
+		if err1 != nil || err2 != nil || median < 0 || sigma < 0 {
+			return nil, invalid("<median>,<sigma>")
+		}
+		return LogNormal{median, sigma}, nil
+	case "exponential":
+		mean, err := time.ParseDuration(params)
+		if err != nil || mean < 0 {
+			return nil, invalid("<mean>")
+		}
+		return Exponential{mean}, nil
+	}
+	return nil, fmt.Errorf("unknown latency distribution %q, want constant, uniform, normal, lognormal or exponential", spec)
+}
+
+// MaxPoints is the maximum number of points per series Prometheus answers a range query with.
+const MaxPoints = 11000
+
+// Server answers the range queries of the Prometheus HTTP API with Series generated series of a
+// point every step, after a latency drawn from Latency. A fraction ErrorRate of the queries fail
+// with a 503 instead.
+type Server struct {
+	Latency   Distribution
+	Series    int
+	ErrorRate float64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// New returns a Server whose latencies and errors are drawn from the given seed.
+func New(latency Distribution, series int, errorRate float64, seed int64) *Server {
+	return &Server{Latency: latency, Series: series, ErrorRate: errorRate, rnd: rand.New(rand.NewSource(seed))}
+}
+
+type apiResponse struct {
+	Status    string `json:"status"`
+	Data      any    `json:"data,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type matrixSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]any          `json:"values"`
+}
+
+// Handler serves /api/v1/query_range, and the /-/ready and buildinfo endpoints describing the
+// mock server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/ready", func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintln(w, "Mock Prometheus is Ready.")
+	})
+	mux.HandleFunc("/api/v1/status/buildinfo", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(apiResponse{Status: "success", Data: map[string]string{"version": "mock"}})
+	})
+	mux.HandleFunc("/api/v1/query_range", s.queryRange)
+	return mux
+}
+
+func (s *Server) queryRange(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	if req.Form.Get("query") == "" {
+		writeError(w, http.StatusBadRequest, "bad_data", "missing the query parameter")
+		return
+	}
+	start, err := parseTime(req.Form.Get("start"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_data", "invalid start: "+err.Error())
+		return
+	}
+	end, err := parseTime(req.Form.Get("end"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_data", "invalid end: "+err.Error())
+		return
+	}
+	step, err := strconv.ParseFloat(req.Form.Get("step"), 64)
+	if err != nil || step <= 0 {
+		writeError(w, http.StatusBadRequest, "bad_data", "invalid step, want a positive number of seconds")
+		return
+	}
+	if end < start {
+		writeError(w, http.StatusBadRequest, "bad_data", "end timestamp must not be before start time")
+		return
+	}
+	points := int((end-start)/step) + 1
+	if points > MaxPoints {
+		writeError(w, http.StatusBadRequest, "bad_data", "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)")
+		return
+	}
+
+	s.mu.Lock()
+	latency, fail := s.Latency.Sample(s.rnd), s.rnd.Float64() < s.ErrorRate
+	s.mu.Unlock()
+	select {
+	case <-time.After(latency):
+	case <-req.Context().Done():
+		return
+	}
+	if fail {
+		writeError(w, http.StatusServiceUnavailable, "unavailable", "mock failure")
+		return
+	}
+
+	result := make([]matrixSeries, s.Series)
+	for i := range result {
+		values := make([][2]any, points)
+		for j := range values {
+			values[j] = [2]any{start + float64(j)*step, strconv.Itoa(i + j)}
+		}
+		result[i] = matrixSeries{Metric: map[string]string{"__name__": "mock", "series": strconv.Itoa(i)}, Values: values}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiResponse{Status: "success", Data: map[string]any{"resultType": "matrix", "result": result}})
+}
+
+func writeError(w http.ResponseWriter, status int, errorType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiResponse{Status: "error", ErrorType: errorType, Error: message})
+}
+
+// parseTime parses a time given to the HTTP API as a unix timestamp in seconds or as RFC 3339,
+// returning it in seconds.
+func parseTime(s string) (float64, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want RFC 3339 or a unix timestamp", s)
+	}
+	return float64(t.UnixNano()) / 1e9, nil
+}
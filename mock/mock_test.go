@@ -0,0 +1,118 @@
+package mock
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Distribution
+		wantErr bool
+	}{
+		{spec: "constant:10ms", want: Constant{10 * time.Millisecond}},
+		{spec: "uniform:5ms-50ms", want: Uniform{5 * time.Millisecond, 50 * time.Millisecond}},
+		{spec: "normal:20ms,5ms", want: Normal{20 * time.Millisecond, 5 * time.Millisecond}},
+		{spec: "lognormal:20ms,0.5", want: LogNormal{20 * time.Millisecond, 0.5}},
+		{spec: "exponential:20ms", want: Exponential{20 * time.Millisecond}},
+		{spec: "uniform:50ms-5ms", wantErr: true},
+		{spec: "constant:fast", wantErr: true},
+		{spec: "lognormal:20ms", wantErr: true},
+		{spec: "pareto:1ms", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseDistribution(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDistribution() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDistribution() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && got.ToString() != tt.spec {
+				t.Errorf("ToString() = %s, want %s", got.ToString(), tt.spec)
+			}
+		})
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if l := (Uniform{5 * time.Millisecond, 50 * time.Millisecond}).Sample(rnd); l < 5*time.Millisecond || l > 50*time.Millisecond {
+			t.Fatalf("Uniform.Sample() = %v, want within 5ms-50ms", l)
+		}
+		if l := (Normal{time.Millisecond, time.Second}).Sample(rnd); l < 0 {
+			t.Fatalf("Normal.Sample() = %v, want non-negative", l)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(New(Constant{0}, 2, 0, 1).Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		params     url.Values
+		wantStatus int
+		wantPoints int
+	}{
+		{name: "range query", params: url.Values{"query": {"up"}, "start": {"100"}, "end": {"160"}, "step": {"15"}}, wantStatus: http.StatusOK, wantPoints: 5},
+		{name: "rfc3339", params: url.Values{"query": {"up"}, "start": {"1970-01-01T00:01:40Z"}, "end": {"1970-01-01T00:01:40Z"}, "step": {"15"}}, wantStatus: http.StatusOK, wantPoints: 1},
+		{name: "missing query", params: url.Values{"start": {"100"}, "end": {"160"}, "step": {"15"}}, wantStatus: http.StatusBadRequest},
+		{name: "end before start", params: url.Values{"query": {"up"}, "start": {"160"}, "end": {"100"}, "step": {"15"}}, wantStatus: http.StatusBadRequest},
+		{name: "too many points", params: url.Values{"query": {"up"}, "start": {"0"}, "end": {"11000"}, "step": {"1"}}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/api/v1/query_range?" + tt.params.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data struct {
+					Result []struct {
+						Values [][2]any `json:"values"`
+					} `json:"result"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Data.Result) != 2 {
+				t.Fatalf("got %d series, want 2", len(body.Data.Result))
+			}
+			if n := len(body.Data.Result[0].Values); n != tt.wantPoints {
+				t.Errorf("got %d points, want %d", n, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestServer_errorRate(t *testing.T) {
+	srv := httptest.NewServer(New(Constant{0}, 1, 1, 1).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/query_range?query=up&start=1&end=2&step=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
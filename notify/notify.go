@@ -0,0 +1,116 @@
+// Package notify posts the outcome of benchmark runs to webhooks, e.g. of Slack, so long runs
+// don't need babysitting.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/report"
+)
+
+// Statuses of a run.
+const (
+	StatusPassed = "passed"
+	// StatusFailed is set when any query failed or failed its assertions, or the run was aborted
+	StatusFailed = "failed"
+)
+
+// Notification is the outcome of a run, posted as is to generic webhooks.
+type Notification struct {
+	Status string `json:"status"`
+	Target string `json:"target"`
+	// Error is why the run was aborted, if it was
+	Error             string `json:"error,omitempty"`
+	Errors            int    `json:"errors"`
+	AssertionFailures int    `json:"assertion_failures"`
+	// ReportURL links to the report of the run, if any
+	ReportURL string          `json:"report_url,omitempty"`
+	Summary   *report.Summary `json:"summary"`
+}
+
+// NewNotification describes the outcome of the run of the given summary, aborted with err if not
+// nil.
+func NewNotification(summary *report.Summary, err error, reportURL string) *Notification {
+	n := &Notification{
+		Status:            StatusPassed,
+		Target:            summary.Target,
+		Errors:            summary.Stats.Errors.Total(),
+		AssertionFailures: summary.Stats.AssertionFailures,
+		ReportURL:         reportURL,
+		Summary:           summary,
+	}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	if err != nil || n.Errors > 0 || n.AssertionFailures > 0 {
+		n.Status = StatusFailed
+	}
+	return n
+}
+
+// Text describes the outcome in a sentence or two.
+func (n *Notification) Text() string {
+	s := n.Summary.Stats
+	text := fmt.Sprintf("pqlbench run against %s %s: %d queries processed, median %.2fms, average %.2fms, slowest %dms, %d errors, %d assertion failures",
+		n.Target, n.Status, s.Processed, s.Median, s.Average, s.Slowest, n.Errors, n.AssertionFailures)
+	if n.Error != "" {
+		text += fmt.Sprintf(". Aborted: %s", n.Error)
+	}
+	if n.ReportURL != "" {
+		text += fmt.Sprintf(". Report: %s", n.ReportURL)
+	}
+	return text
+}
+
+// Notifier posts Notifications to a webhook.
+type Notifier struct {
+	Client client.HttpClient
+	URL    string
+	// Slack posts the Text of the notifications as a Slack message instead of the Notification
+	Slack bool
+}
+
+func New(url string, slack bool) *Notifier {
+	return &Notifier{Client: &http.Client{Timeout: 10 * time.Second}, URL: url, Slack: slack}
+}
+
+// slackMessage is a message posted to a Slack incoming webhook.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Send posts the given notification.
+func (n *Notifier) Send(notification *Notification) error {
+	var body any = notification
+	if n.Slack {
+		icon := ":white_check_mark:"
+		if notification.Status == StatusFailed {
+			icon = ":x:"
+		}
+		body = slackMessage{Text: icon + " " + notification.Text()}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to post notification. err=%w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unable to post notification. err=%w", &client.StatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noelruault/pqlbench/report"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func TestNewNotification(t *testing.T) {
+	tests := []struct {
+		name       string
+		stats      *stats.Stats
+		err        error
+		wantStatus string
+	}{
+		{name: "Passed", stats: &stats.Stats{Processed: 10}, wantStatus: StatusPassed},
+		{name: "Assertion failures", stats: &stats.Stats{Processed: 10, AssertionFailures: 1}, wantStatus: StatusFailed},
+		{name: "Aborted", stats: &stats.Stats{Processed: 1}, err: errors.New("query=up, error=timeout"), wantStatus: StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNotification(&report.Summary{Target: "http://localhost:9201", Stats: tt.stats}, tt.err, "")
+			if n.Status != tt.wantStatus {
+				t.Errorf("NewNotification() status = %s, want %s", n.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestNotifier_Send(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	summary := &report.Summary{Target: "http://localhost:9201", Stats: &stats.Stats{Processed: 10, Median: 12, Average: 15, Slowest: 40}}
+	n := NewNotification(summary, nil, "https://ci.example.com/42")
+
+	if err := New(srv.URL, false).Send(n); err != nil {
+		t.Fatalf("Notifier.Send() error = %v", err)
+	}
+	var got Notification
+	if err := json.Unmarshal(body, &got); err != nil || got.Status != StatusPassed || got.Summary.Stats.Processed != 10 {
+		t.Errorf("Notifier.Send() posted %s, want the notification", body)
+	}
+
+	if err := New(srv.URL, true).Send(n); err != nil {
+		t.Fatalf("Notifier.Send() to Slack error = %v", err)
+	}
+	want := `{"text":":white_check_mark: pqlbench run against http://localhost:9201 passed: 10 queries processed, median 12.00ms, average 15.00ms, slowest 40ms, 0 errors, 0 assertion failures. Report: https://ci.example.com/42"}`
+	if string(body) != want {
+		t.Errorf("Notifier.Send() to Slack posted %s, want %s", body, want)
+	}
+
+	if err := New(srv.URL+"/broken", false).Send(n); err == nil {
+		t.Errorf("Notifier.Send() to a failing webhook error = nil, want one")
+	}
+}
@@ -0,0 +1,218 @@
+// Package otlp exports a span per benchmarked request to an OpenTelemetry collector through the
+// OTLP/HTTP protocol, encoded in JSON.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// TracesPath is the path of the OTLP/HTTP traces endpoint.
+const TracesPath = "/v1/traces"
+
+// Span kind and status codes of the OTLP protocol.
+const (
+	spanKindClient  = 3
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+// Exporter is a runner.Recorder exporting a span per Result in batches. Close must be called once
+// the run ends to export the remaining spans.
+type Exporter struct {
+	Client client.HttpClient
+	// URL is the traces endpoint of the collector, e.g. http://localhost:4318/v1/traces
+	URL string
+	// BatchSize is the number of spans exported per request
+	BatchSize int
+	// Service is the service.name of the exported spans
+	Service string
+
+	mu      sync.Mutex
+	batch   []span
+	wg      sync.WaitGroup
+	errs    int
+	lastErr error
+}
+
+// New instantiates an Exporter of the collector at the given endpoint, e.g.
+// http://localhost:4318, whose traces path is appended if missing.
+func New(endpoint string) *Exporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, TracesPath) {
+		endpoint += TracesPath
+	}
+	return &Exporter{
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		URL:       endpoint,
+		BatchSize: 512,
+		Service:   "pqlbench",
+	}
+}
+
+type span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes"`
+	Status            status      `json:"status"`
+}
+
+type attribute struct {
+	Key   string `json:"key"`
+	Value value  `json:"value"`
+}
+
+// value is an OTLP AnyValue, of which a single field is set. Integers are encoded as strings.
+type value struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttribute(key, v string) attribute {
+	return attribute{Key: key, Value: value{StringValue: &v}}
+}
+
+func intAttribute(key string, v int64) attribute {
+	s := strconv.FormatInt(v, 10)
+	return attribute{Key: key, Value: value{IntValue: &s}}
+}
+
+func doubleAttribute(key string, v float64) attribute {
+	return attribute{Key: key, Value: value{DoubleValue: &v}}
+}
+
+// newSpan builds the span of a Result, in the trace the query was sent with if any.
+func newSpan(r *runner.Result) span {
+	traceID, spanID := r.TraceID, r.SpanID
+	if traceID == "" {
+		traceID, spanID = client.NewTraceContext()
+	}
+	endpoint := r.Query.Endpoint
+	if r.Query.RangeQuery() {
+		endpoint = "query_range"
+	}
+
+	s := span{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		Name:              endpoint,
+		Kind:              spanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(r.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(r.End.UnixNano(), 10),
+		Attributes: []attribute{
+			stringAttribute("db.query.text", r.Query.Query),
+			stringAttribute("pqlbench.endpoint", endpoint),
+			intAttribute("pqlbench.start", r.Query.Start),
+			intAttribute("pqlbench.end", r.Query.End),
+			intAttribute("pqlbench.step", int64(r.Query.Step)),
+			intAttribute("pqlbench.worker", int64(r.Worker)),
+			doubleAttribute("pqlbench.latency_ms", float64(r.End.Sub(r.Start))/float64(time.Millisecond)),
+			intAttribute("http.response.status_code", int64(r.Status)),
+			intAttribute("http.response.body.size", r.Bytes),
+		},
+		Status: status{Code: statusCodeOK},
+	}
+	if r.RequestID != "" {
+		s.Attributes = append(s.Attributes, stringAttribute("http.request.header.x-request-id", r.RequestID))
+	}
+	if r.Err != nil {
+		s.Status = status{Code: statusCodeError, Message: r.Err.Error()}
+		s.Attributes = append(s.Attributes, stringAttribute("error.type", string(client.Classify(r.Err))))
+	}
+	return s
+}
+
+func (e *Exporter) Record(r *runner.Result) error {
+	s := newSpan(r)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batch = append(e.batch, s)
+	if len(e.batch) >= e.BatchSize {
+		e.flush()
+	}
+	return nil
+}
+
+// flush exports the current batch in the background. It must be called holding the lock.
+func (e *Exporter) flush() {
+	batch := e.batch
+	e.batch = nil
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if err := e.export(batch); err != nil {
+			e.mu.Lock()
+			e.errs++
+			e.lastErr = err
+			e.mu.Unlock()
+		}
+	}()
+}
+
+// Close exports the remaining spans and waits for every export to finish, returning the last
+// error if any export failed.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	if len(e.batch) > 0 {
+		e.flush()
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+
+	if e.errs > 0 {
+		return fmt.Errorf("%d span exports failed, last err=%w", e.errs, e.lastErr)
+	}
+	return nil
+}
+
+// export sends a batch of spans in an OTLP ExportTraceServiceRequest.
+func (e *Exporter) export(spans []span) error {
+	body := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []attribute{stringAttribute("service.name", e.Service)}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/noelruault/pqlbench"},
+				"spans": spans,
+			}},
+		}},
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to export spans. err=%w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to export spans. err=%w", &client.StatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package otlp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestExporter(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	spans := map[string]span{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != TracesPath || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		for _, s := range body.ResourceSpans[0].ScopeSpans[0].Spans {
+			spans[s.SpanID] = s
+		}
+	}))
+	defer srv.Close()
+
+	e := New(srv.URL + "/")
+	e.BatchSize = 2
+	start := time.Unix(1609459200, 0)
+	results := []runner.Result{
+		{Query: query.Query{Query: "up"}, Start: start, End: start.Add(time.Millisecond), Status: 200, TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331"},
+		{Query: query.Query{Endpoint: query.EndpointLabels}, Start: start, End: start.Add(time.Millisecond), Status: 200},
+		{Query: query.Query{Query: "broken"}, Start: start, End: start.Add(time.Millisecond), Err: errors.New("failed")},
+	}
+	for i := range results {
+		if err := e.Record(&results[i]); err != nil {
+			t.Fatalf("Exporter.Record() error = %v", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Exporter.Close() error = %v", err)
+	}
+
+	if requests != 2 || len(spans) != 3 {
+		t.Fatalf("Exporter exported %d spans in %d requests, want 3 in 2", len(spans), requests)
+	}
+	propagated, ok := spans["b7ad6b7169203331"]
+	if !ok || propagated.TraceID != "0af7651916cd43dd8448eb211c80319c" || propagated.Name != "query_range" || propagated.StartTimeUnixNano != "1609459200000000000" {
+		t.Errorf("Exporter exported %+v for the traced query, want its trace context", propagated)
+	}
+	var failed int
+	for _, s := range spans {
+		if s.Status.Code == statusCodeError {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("Exporter exported %d failed spans, want 1", failed)
+	}
+}
+
+func TestExporter_Close_error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e := New(srv.URL)
+	e.Record(&runner.Result{Query: query.Query{Query: "up"}})
+	if err := e.Close(); err == nil {
+		t.Errorf("Exporter.Close() error = nil, want an error")
+	}
+}
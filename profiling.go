@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	rpprof "runtime/pprof"
+)
+
+// startProfiling profiles the tool itself, e.g. to rule out client-side bottlenecks in runs of
+// very high throughput: it serves the pprof endpoints on addr and writes a CPU profile to cpuPath,
+// if given. The returned function stops the CPU profile and writes a heap profile to memPath, if
+// given, and must be called once the run is over.
+func startProfiling(addr, cpuPath, memPath string) (stop func(), err error) {
+	var srv *http.Server
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		srv = &http.Server{Addr: addr, Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Warn("unable to serve pprof", "err", err)
+			}
+		}()
+		slog.Info("pprof listening", "addr", addr)
+	}
+
+	var cpu *os.File
+	if cpuPath != "" {
+		if cpu, err = os.Create(cpuPath); err != nil {
+			return nil, fmt.Errorf("unable to create CPU profile. err=%w", err)
+		}
+		if err := rpprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, fmt.Errorf("unable to start CPU profile. err=%w", err)
+		}
+	}
+
+	return func() {
+		if srv != nil {
+			srv.Close()
+		}
+		if cpu != nil {
+			rpprof.StopCPUProfile()
+			cpu.Close()
+		}
+		if memPath != "" {
+			if err := writeHeapProfile(memPath); err != nil {
+				slog.Warn("unable to write heap profile", "err", err)
+			}
+		}
+	}, nil
+}
+
+// writeHeapProfile writes a profile of the live objects of the heap to path.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Objects freed since the last garbage collection would otherwise still count as live
+	runtime.GC()
+	return rpprof.WriteHeapProfile(f)
+}
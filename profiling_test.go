@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiling(t *testing.T) {
+	dir := t.TempDir()
+	cpu, mem := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+	stop, err := startProfiling("", cpu, mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	for _, path := range []string{cpu, mem} {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("startProfiling() wrote %s: %v, want a profile", path, err)
+		}
+	}
+	if _, err := startProfiling("", filepath.Join(dir, "missing", "cpu.pprof"), ""); err == nil {
+		t.Errorf("startProfiling() to a missing directory error = nil, want an error")
+	}
+}
@@ -0,0 +1,21 @@
+package query
+
+import "fmt"
+
+// Asserts reports whether the response of the Query is checked, i.e. whether it has assertions.
+func (q Query) Asserts() bool {
+	return q.MinSeries > 0 || q.ExpectNonEmpty
+}
+
+// Check returns an error if a response holding the given number of series fails the assertions
+// of the Query. The failure of an assertion is not a query error: the target answered, but
+// wrongly.
+func (q Query) Check(series int) error {
+	switch {
+	case q.MinSeries > 0 && series < q.MinSeries:
+		return fmt.Errorf("got %d series, want at least %d", series, q.MinSeries)
+	case q.ExpectNonEmpty && series == 0:
+		return fmt.Errorf("got an empty result, want a non-empty one")
+	}
+	return nil
+}
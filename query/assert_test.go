@@ -0,0 +1,29 @@
+package query
+
+import "testing"
+
+func TestQuery_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   Query
+		series  int
+		asserts bool
+		wantErr bool
+	}{
+		{name: "no assertions", series: 0},
+		{name: "enough series", query: Query{MinSeries: 3}, series: 3, asserts: true},
+		{name: "too few series", query: Query{MinSeries: 3}, series: 2, asserts: true, wantErr: true},
+		{name: "non-empty", query: Query{ExpectNonEmpty: true}, series: 1, asserts: true},
+		{name: "empty", query: Query{ExpectNonEmpty: true}, series: 0, asserts: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Asserts(); got != tt.asserts {
+				t.Errorf("Query.Asserts() = %v, want %v", got, tt.asserts)
+			}
+			if err := tt.query.Check(tt.series); (err != nil) != tt.wantErr {
+				t.Errorf("Query.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
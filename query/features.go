@@ -0,0 +1,96 @@
+package query
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// PromQL features a query expression may use, as classified by Features.
+const (
+	FeatureSelector    = "selector"
+	FeatureRegex       = "regex"
+	FeatureOffset      = "offset"
+	FeatureRate        = "rate"
+	FeatureFunction    = "function"
+	FeatureAggregation = "aggregation"
+	FeatureBinary      = "binary"
+	FeatureSubquery    = "subquery"
+	FeatureLiteral     = "literal"
+	FeatureInvalid     = "invalid"
+)
+
+// classPriority lists the features a query is classified by, from the most to the least costly
+// to evaluate.
+var classPriority = []string{
+	FeatureSubquery, FeatureAggregation, FeatureBinary, FeatureRate, FeatureFunction, FeatureSelector, FeatureLiteral,
+}
+
+var rateFunctions = map[string]bool{
+	"rate": true, "irate": true, "increase": true, "delta": true, "idelta": true, "deriv": true,
+}
+
+var promqlParser = parser.NewParser(parser.Options{})
+
+// Features parses the expression with the upstream PromQL parser and returns the features it uses,
+// in the order of the Feature constants. Only raw selectors, i.e. queries consisting of a single
+// series selector, have the "selector" feature. Expressions which can't be parsed have the
+// "invalid" feature only.
+func Features(expr string) []string {
+	node, err := promqlParser.ParseExpr(expr)
+	if err != nil {
+		return []string{FeatureInvalid}
+	}
+
+	found := map[string]bool{}
+	top := node
+	for {
+		p, ok := top.(*parser.ParenExpr)
+		if !ok {
+			break
+		}
+		top = p.Expr
+	}
+	switch top.(type) {
+	case *parser.VectorSelector, *parser.MatrixSelector:
+		found[FeatureSelector] = true
+	}
+	parser.Inspect(node, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			for _, m := range n.LabelMatchers {
+				if m.Type == labels.MatchRegexp || m.Type == labels.MatchNotRegexp {
+					found[FeatureRegex] = true
+				}
+			}
+			if n.OriginalOffset != 0 || n.OriginalOffsetExpr != nil || n.Timestamp != nil || n.StartOrEnd != 0 {
+				found[FeatureOffset] = true
+			}
+		case *parser.Call:
+			if rateFunctions[n.Func.Name] {
+				found[FeatureRate] = true
+			} else {
+				found[FeatureFunction] = true
+			}
+		case *parser.AggregateExpr:
+			found[FeatureAggregation] = true
+		case *parser.BinaryExpr:
+			found[FeatureBinary] = true
+		case *parser.SubqueryExpr:
+			found[FeatureSubquery] = true
+		}
+		return nil
+	})
+
+	var features []string
+	for _, f := range []string{
+		FeatureSelector, FeatureRegex, FeatureOffset, FeatureRate, FeatureFunction, FeatureAggregation, FeatureBinary, FeatureSubquery,
+	} {
+		if found[f] {
+			features = append(features, f)
+		}
+	}
+	if len(features) == 0 {
+		features = []string{FeatureLiteral}
+	}
+	return features
+}
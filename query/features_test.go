@@ -0,0 +1,32 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{name: "raw selector", expr: `demo_cpu_usage_seconds_total{mode="idle"}`, want: []string{FeatureSelector}},
+		{name: "parenthesized selector", expr: `(up)`, want: []string{FeatureSelector}},
+		{name: "regex matcher", expr: `up{job=~"api|web"}`, want: []string{FeatureSelector, FeatureRegex}},
+		{name: "offset", expr: `up offset 1h`, want: []string{FeatureSelector, FeatureOffset}},
+		{name: "rate", expr: `rate(http_requests_total[5m])`, want: []string{FeatureRate}},
+		{name: "aggregation", expr: `sum by(code) (rate(http_requests_total{code!~"2.."}[5m]))`, want: []string{FeatureRegex, FeatureRate, FeatureAggregation}},
+		{name: "binary", expr: `up / up`, want: []string{FeatureBinary}},
+		{name: "subquery", expr: `max_over_time(rate(http_requests_total[5m])[1h:1m])`, want: []string{FeatureRate, FeatureFunction, FeatureSubquery}},
+		{name: "literal", expr: `1`, want: []string{FeatureLiteral}},
+		{name: "invalid", expr: `sum(`, want: []string{FeatureInvalid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Features(tt.expr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Features() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package query
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// NonceLabel is the label of the no-op matcher added by WithNonce. No series has it, so a negative
+// matcher on it matches every series.
+const NonceLabel = "__pqlbench_nonce__"
+
+// NewNonce returns a random nonce for WithNonce.
+func NewNonce() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithNonce returns the Query with a no-op matcher on the NonceLabel with the given value added to
+// every series selector of its expression, so its text, hence the key of any response cache in
+// front of the target, is unique to the nonce. Expressions which can't be parsed are not changed.
+func (q Query) WithNonce(nonce string) Query {
+	node, err := promqlParser.ParseExpr(q.Query)
+	if err != nil {
+		return q
+	}
+	matcher := &labels.Matcher{Type: labels.MatchNotEqual, Name: NonceLabel, Value: nonce}
+	parser.Inspect(node, func(node parser.Node, _ []parser.Node) error {
+		if vs, ok := node.(*parser.VectorSelector); ok {
+			vs.LabelMatchers = append(vs.LabelMatchers, matcher)
+		}
+		return nil
+	})
+	q.Query = node.String()
+	return q
+}
@@ -0,0 +1,31 @@
+package query
+
+import "testing"
+
+func TestQuery_WithNonce(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "selector", query: "up", want: `up{__pqlbench_nonce__!="abc"}`},
+		{
+			name:  "every selector",
+			query: `sum(rate(http_requests_total{job="api"}[5m])) / sum(rate(http_requests_total[5m]))`,
+			want:  `sum(rate(http_requests_total{__pqlbench_nonce__!="abc",job="api"}[5m])) / sum(rate(http_requests_total{__pqlbench_nonce__!="abc"}[5m]))`,
+		},
+		{name: "literal", query: "vector(1)", want: "vector(1)"},
+		{name: "invalid", query: "sum(", want: "sum("},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Query{Query: tt.query}).WithNonce("abc").Query; got != tt.want {
+				t.Errorf("Query.WithNonce() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if a, b := NewNonce(), NewNonce(); len(a) != 16 || a == b {
+		t.Errorf("NewNonce() = %s, %s, want distinct 8 bytes hex encoded", a, b)
+	}
+}
@@ -0,0 +1,195 @@
+// Package query holds the PromQL queries benchmarked by pqlbench and the helpers used to group them.
+package query
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Query contains an individual row resulting from reading the csv file.
+type Query struct {
+	Query string `json:"query"`
+	// Start holds the staring time in unix format in milliseconds
+	Start int64 `json:"start"`
+	// Start holds the end time in unix format in milliseconds
+	End  int64 `json:"end"`
+	Step int   `json:"step"`
+	// SQL is the statement equivalent to the PromQL query, if given
+	SQL string `json:"sql,omitempty"`
+	// Endpoint is the HTTP API endpoint targeted, relative to /api/<version>/ (e.g. "series").
+	// Query holds the series selector of the metadata endpoints, if any. Empty targets query_range
+	Endpoint string `json:"endpoint,omitempty"`
+	// StartAgo and EndAgo hold how long before the query is sent its Start and End are, if given
+	// relative to the current time. Resolve sets the Start and End accordingly
+	StartAgo *time.Duration `json:"start_ago,omitempty"`
+	EndAgo   *time.Duration `json:"end_ago,omitempty"`
+	// Tags are the categories of the query (e.g. "dashboard" or "alerting"), if given
+	Tags []string `json:"tags,omitempty"`
+	// MinSeries is the minimum number of series (or label names and values) the response must
+	// hold, if given. ExpectNonEmpty requires at least one. See Check
+	MinSeries      int  `json:"min_series,omitempty"`
+	ExpectNonEmpty bool `json:"expect_nonempty,omitempty"`
+	// At is when the query was sent since the first one of recorded traffic, if recorded, so the
+	// traffic can be replayed with its original pacing
+	At *time.Duration `json:"at,omitempty"`
+	// Weight is the number of identical rows of the corpus the query stands for once deduplicated,
+	// zero standing for one, see loader.Dedup
+	Weight int `json:"weight,omitempty"`
+	// Timeout is the deadline of the query, if given, overriding the default one of the client so
+	// heavy queries can be given longer than cheap ones
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Thanos holds the parameters of the query specific to the Thanos Querier, overriding those of
+	// the client
+	Thanos Thanos `json:"thanos,omitzero"`
+}
+
+// Endpoints of the HTTP API a Query can target. Label values are requested from the
+// "label/<name>/values" endpoint.
+const (
+	EndpointQueryRange     = "query_range"
+	EndpointQueryExemplars = "query_exemplars"
+	EndpointLabels         = "labels"
+	EndpointSeries         = "series"
+)
+
+var labelValuesRegex = regexp.MustCompile(`^label/[a-zA-Z_][a-zA-Z0-9_]*/values$`)
+
+// ValidateEndpoint returns an error if the given endpoint can't be targeted by a Query.
+func ValidateEndpoint(endpoint string) error {
+	switch endpoint {
+	case "", EndpointQueryRange, EndpointQueryExemplars, EndpointLabels, EndpointSeries:
+		return nil
+	}
+	if labelValuesRegex.MatchString(endpoint) {
+		return nil
+	}
+	return fmt.Errorf("unsupported endpoint %q, want one of %s, %s, %s, %s or label/<name>/values",
+		endpoint, EndpointQueryRange, EndpointQueryExemplars, EndpointLabels, EndpointSeries)
+}
+
+// RangeQuery reports whether the Query targets the query_range endpoint.
+func (q Query) RangeQuery() bool {
+	return q.Endpoint == "" || q.Endpoint == EndpointQueryRange
+}
+
+// RangeBuckets are the upper bounds (inclusive) of the time range buckets queries are grouped in.
+var RangeBuckets = []struct {
+	Name  string
+	Upper time.Duration
+}{
+	{"<=1h", time.Hour},
+	{"1h-6h", 6 * time.Hour},
+	{"6h-24h", 24 * time.Hour},
+	{">24h", math.MaxInt64},
+}
+
+// RangeBucket returns the name of the bucket the time range requested by the Query falls in.
+func (q Query) RangeBucket() string {
+	window := time.Duration(q.End-q.Start) * time.Millisecond
+	for _, b := range RangeBuckets {
+		if window <= b.Upper {
+			return b.Name
+		}
+	}
+	return RangeBuckets[len(RangeBuckets)-1].Name
+}
+
+// StepBuckets are the upper bounds (inclusive) of the step buckets range queries are grouped in.
+var StepBuckets = []struct {
+	Name  string
+	Upper time.Duration
+}{
+	{"<=15s", 15 * time.Second},
+	{"15s-1m", time.Minute},
+	{"1m-5m", 5 * time.Minute},
+	{"5m-1h", time.Hour},
+	{">1h", math.MaxInt64},
+}
+
+// StepBucket returns the name of the bucket the step of the Query falls in, empty for queries
+// without a step, i.e. not targeting query_range.
+func (q Query) StepBucket() string {
+	if !q.RangeQuery() || q.Step <= 0 {
+		return ""
+	}
+	step := time.Duration(q.Step) * time.Second
+	for _, b := range StepBuckets {
+		if step <= b.Upper {
+			return b.Name
+		}
+	}
+	return StepBuckets[len(StepBuckets)-1].Name
+}
+
+// Class returns a coarse classification of the Query expression, i.e. the most costly of the
+// Features it uses. Queries not targeting query_range are classified by their endpoint instead.
+func (q Query) Class() string {
+	switch {
+	case labelValuesRegex.MatchString(q.Endpoint):
+		return "label_values"
+	case !q.RangeQuery():
+		return q.Endpoint
+	}
+	features := Features(q.Query)
+	for _, class := range classPriority {
+		for _, f := range features {
+			if f == class {
+				return class
+			}
+		}
+	}
+	return features[0]
+}
+
+// Resolve returns the Query with its relative Start and End, if any, resolved against now.
+func (q Query) Resolve(now time.Time) Query {
+	if q.StartAgo != nil {
+		q.Start = now.Add(-*q.StartAgo).UnixMilli()
+	}
+	if q.EndAgo != nil {
+		q.End = now.Add(-*q.EndAgo).UnixMilli()
+	}
+	return q
+}
+
+// AlignStep returns the Query with its Start and End snapped down to a multiple of its Step, as
+// Grafana aligns the queries of its dashboards, so successive queries evaluate the same points and
+// hit the response caches of the target like dashboards do. Queries without a Step are unchanged.
+func (q Query) AlignStep() Query {
+	if step := int64(q.Step) * 1000; step > 0 {
+		q.Start = floorMultiple(q.Start, step)
+		q.End = floorMultiple(q.End, step)
+	}
+	return q
+}
+
+// floorMultiple returns the greatest multiple of m not greater than v.
+func floorMultiple(v, m int64) int64 {
+	r := v % m
+	if r < 0 {
+		r += m
+	}
+	return v - r
+}
+
+// Key identifies the Query within a corpus. Relative times are identified by their expression
+// rather than their resolved value, so the Key doesn't change over time.
+func (q Query) Key() string {
+	start, end := strconv.FormatInt(q.Start, 10), strconv.FormatInt(q.End, 10)
+	if q.StartAgo != nil {
+		start = "now-" + q.StartAgo.String()
+	}
+	if q.EndAgo != nil {
+		end = "now-" + q.EndAgo.String()
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%s|%d", q.Query, start, end, q.Step)
+	if !q.RangeQuery() {
+		fmt.Fprintf(h, "|%s", q.Endpoint)
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
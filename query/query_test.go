@@ -0,0 +1,138 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQuery_RangeBucket(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{name: "one hour", query: Query{Start: 0, End: time.Hour.Milliseconds()}, want: "<=1h"},
+		{name: "two hours", query: Query{Start: 0, End: 2 * time.Hour.Milliseconds()}, want: "1h-6h"},
+		{name: "twelve hours", query: Query{Start: 0, End: 12 * time.Hour.Milliseconds()}, want: "6h-24h"},
+		{name: "one week", query: Query{Start: 0, End: 7 * 24 * time.Hour.Milliseconds()}, want: ">24h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.RangeBucket(); got != tt.want {
+				t.Errorf("Query.RangeBucket() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_StepBucket(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{name: "fifteen seconds", query: Query{Step: 15}, want: "<=15s"},
+		{name: "thirty seconds", query: Query{Step: 30}, want: "15s-1m"},
+		{name: "five minutes", query: Query{Step: 300}, want: "1m-5m"},
+		{name: "one hour", query: Query{Step: 3600}, want: "5m-1h"},
+		{name: "one day", query: Query{Step: 86400}, want: ">1h"},
+		{name: "metadata endpoint", query: Query{Endpoint: EndpointSeries}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.StepBucket(); got != tt.want {
+				t.Errorf("Query.StepBucket() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_Class(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{name: "selector", query: Query{Query: `demo_cpu_usage_seconds_total{mode="idle"}`}, want: "selector"},
+		{name: "rate", query: Query{Query: `rate(demo_cpu_usage_seconds_total[5m])`}, want: "rate"},
+		{name: "aggregation", query: Query{Query: `avg without(instance, mode) (demo_cpu_usage_seconds_total)`}, want: "aggregation"},
+		{name: "subquery", query: Query{Query: `sum(max_over_time(up[1h:1m]))`}, want: "subquery"},
+		{name: "binary", query: Query{Query: `rate(errors_total[5m]) / rate(requests_total[5m])`}, want: "binary"},
+		{name: "regex selector", query: Query{Query: `up{job=~"api|web"}`}, want: "selector"},
+		{name: "invalid", query: Query{Query: `sum(`}, want: "invalid"},
+		{name: "series", query: Query{Query: `{job="api"}`, Endpoint: EndpointSeries}, want: "series"},
+		{name: "label values", query: Query{Endpoint: "label/job/values"}, want: "label_values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Class(); got != tt.want {
+				t.Errorf("Query.Class() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  bool
+	}{
+		{endpoint: ""},
+		{endpoint: EndpointQueryRange},
+		{endpoint: EndpointLabels},
+		{endpoint: EndpointSeries},
+		{endpoint: "label/job/values"},
+		{endpoint: "label/job", wantErr: true},
+		{endpoint: "query", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			if err := ValidateEndpoint(tt.endpoint); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuery_AlignStep(t *testing.T) {
+	tests := []struct {
+		name      string
+		q         Query
+		wantStart int64
+		wantEnd   int64
+	}{
+		{name: "unaligned", q: Query{Start: 1597056698698, End: 1597059548699, Step: 60}, wantStart: 1597056660000, wantEnd: 1597059540000},
+		{name: "aligned", q: Query{Start: 1597056660000, End: 1597059540000, Step: 60}, wantStart: 1597056660000, wantEnd: 1597059540000},
+		{name: "no step", q: Query{Start: 1597056698698, End: 1597059548699}, wantStart: 1597056698698, wantEnd: 1597059548699},
+		{name: "before the epoch", q: Query{Start: -1500, End: 1500, Step: 1}, wantStart: -2000, wantEnd: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.q.AlignStep()
+			if got.Start != tt.wantStart || got.End != tt.wantEnd {
+				t.Errorf("Query.AlignStep() = %d-%d, want %d-%d", got.Start, got.End, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestQuery_Resolve(t *testing.T) {
+	hour, zero := time.Hour, time.Duration(0)
+	q := Query{Query: "up", Start: 1, End: 2, StartAgo: &hour, EndAgo: &zero}
+
+	first := q.Resolve(time.UnixMilli(10 * time.Hour.Milliseconds()))
+	if first.Start != 9*time.Hour.Milliseconds() || first.End != 10*time.Hour.Milliseconds() {
+		t.Errorf("Query.Resolve() = %d-%d, want %d-%d", first.Start, first.End, 9*time.Hour.Milliseconds(), 10*time.Hour.Milliseconds())
+	}
+	later := q.Resolve(time.UnixMilli(11 * time.Hour.Milliseconds()))
+	if later.Start != 10*time.Hour.Milliseconds() {
+		t.Errorf("Query.Resolve() start = %d, want %d", later.Start, 10*time.Hour.Milliseconds())
+	}
+	if first.Key() != later.Key() {
+		t.Errorf("Query.Key() of relative queries changed once resolved again: %v != %v", first.Key(), later.Key())
+	}
+	if absolute := (Query{Query: "up", Start: 1, End: 2}); !reflect.DeepEqual(absolute.Resolve(time.Now()), absolute) {
+		t.Errorf("Query.Resolve() changed an absolute query")
+	}
+}
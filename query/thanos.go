@@ -0,0 +1,92 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Thanos holds the query parameters specific to the Thanos Querier, left to its defaults when
+// unset. See https://thanos.io/tip/components/query.md
+type Thanos struct {
+	// Dedup deduplicates the series of the replicas of a source, and PartialResponse answers with
+	// the data of the stores available rather than failing when some are not
+	Dedup           *bool `json:"dedup,omitempty"`
+	PartialResponse *bool `json:"partial_response,omitempty"`
+	// MaxSourceResolution is the coarsest resolution of the downsampled data used, e.g. 5m, 1h or
+	// auto, see ValidateMaxSourceResolution
+	MaxSourceResolution string `json:"max_source_resolution,omitempty"`
+}
+
+// ParseThanos parses the parameters given as text, e.g. by the columns of a query file or by flags,
+// leaving those empty unset.
+func ParseThanos(dedup, partialResponse, maxSourceResolution string) (Thanos, error) {
+	var t Thanos
+	var err error
+	if t.Dedup, err = parseOptionalBool(dedup, "dedup"); err != nil {
+		return Thanos{}, err
+	}
+	if t.PartialResponse, err = parseOptionalBool(partialResponse, "partial_response"); err != nil {
+		return Thanos{}, err
+	}
+	if t.MaxSourceResolution = strings.TrimSpace(maxSourceResolution); t.MaxSourceResolution != "" {
+		if err := ValidateMaxSourceResolution(t.MaxSourceResolution); err != nil {
+			return Thanos{}, err
+		}
+	}
+	return t, nil
+}
+
+// parseOptionalBool parses the named boolean, nil if empty.
+func parseOptionalBool(v, name string) (*bool, error) {
+	if v = strings.TrimSpace(v); v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, want true or false", name, v)
+	}
+	return &b, nil
+}
+
+// Or returns the parameters set, falling back to those of other for the unset ones, e.g. to the
+// parameters of the run for a query setting only some of them.
+func (t Thanos) Or(other Thanos) Thanos {
+	if t.Dedup == nil {
+		t.Dedup = other.Dedup
+	}
+	if t.PartialResponse == nil {
+		t.PartialResponse = other.PartialResponse
+	}
+	if t.MaxSourceResolution == "" {
+		t.MaxSourceResolution = other.MaxSourceResolution
+	}
+	return t
+}
+
+// Encode adds the parameters set to the parameters of a request.
+func (t Thanos) Encode(params url.Values) {
+	if t.Dedup != nil {
+		params.Set("dedup", strconv.FormatBool(*t.Dedup))
+	}
+	if t.PartialResponse != nil {
+		params.Set("partial_response", strconv.FormatBool(*t.PartialResponse))
+	}
+	if t.MaxSourceResolution != "" {
+		params.Set("max_source_resolution", t.MaxSourceResolution)
+	}
+}
+
+// ValidateMaxSourceResolution returns an error if the given resolution is neither auto nor a
+// non-negative duration, 0s standing for the raw data.
+func ValidateMaxSourceResolution(resolution string) error {
+	if resolution == "auto" {
+		return nil
+	}
+	if d, err := time.ParseDuration(resolution); err != nil || d < 0 {
+		return fmt.Errorf("invalid max_source_resolution %q, want auto or a duration, e.g. 0s, 5m or 1h", resolution)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestThanos_Encode(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name  string
+		query Thanos
+		run   Thanos
+		want  string
+	}{
+		{name: "unset", want: ""},
+		{name: "run", run: Thanos{Dedup: &no, MaxSourceResolution: "5m"}, want: "dedup=false&max_source_resolution=5m"},
+		{
+			name:  "query overrides run",
+			query: Thanos{Dedup: &yes, PartialResponse: &no},
+			run:   Thanos{Dedup: &no, MaxSourceResolution: "auto"},
+			want:  "dedup=true&max_source_resolution=auto&partial_response=false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{}
+			tt.query.Or(tt.run).Encode(params)
+			if got := params.Encode(); got != tt.want {
+				t.Errorf("Thanos.Encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMaxSourceResolution(t *testing.T) {
+	tests := []struct {
+		resolution string
+		wantErr    bool
+	}{
+		{resolution: "auto"},
+		{resolution: "0s"},
+		{resolution: "1h"},
+		{resolution: "-5m", wantErr: true},
+		{resolution: "raw", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resolution, func(t *testing.T) {
+			if err := ValidateMaxSourceResolution(tt.resolution); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMaxSourceResolution() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
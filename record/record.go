@@ -0,0 +1,117 @@
+// Package record captures the queries sent to the HTTP API of a Prometheus compatible server
+// through a reverse proxy, writing them to a query file so live traffic can be benchmarked later.
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/loader"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Columns are the columns of the query files written by a Proxy, named by their header.
+var Columns = []string{loader.ColumnQuery, loader.ColumnStart, loader.ColumnEnd, loader.ColumnStep, loader.ColumnAt}
+
+// Proxy is a reverse proxy to the target recording every query it forwards, with when it was
+// received since the first one, see loader.ParseRequest. Requests that aren't queries, e.g. to the
+// buildinfo endpoint, are forwarded without being recorded.
+type Proxy struct {
+	// Relative records the times of the queries relative to when they were received, e.g. now-1h,
+	// so the query file doesn't go stale. Times are rounded to the second
+	Relative bool
+
+	proxy *httputil.ReverseProxy
+	mu    sync.Mutex
+	w     io.Writer
+	first time.Time
+	count int
+}
+
+// New returns a Proxy to the given target writing the queries to w, after a header naming the
+// Columns.
+func New(target *url.URL, w io.Writer) (*Proxy, error) {
+	if _, err := io.WriteString(w, strings.Join(Columns, "|")+"\n"); err != nil {
+		return nil, fmt.Errorf("unable to write the header of the query file. err=%w", err)
+	}
+	return &Proxy{proxy: httputil.NewSingleHostReverseProxy(target), w: w}, nil
+}
+
+// Count returns the number of queries recorded.
+func (p *Proxy) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.count
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	received := time.Now()
+	if params, err := requestParams(req); err != nil {
+		slog.Debug("request not recorded", "path", req.URL.Path, "err", err)
+	} else if q, err := loader.ParseRequest(req.URL.Path, params); err != nil {
+		slog.Debug("request not recorded", "path", req.URL.Path, "err", err)
+	} else if err := p.record(q, received); err != nil {
+		slog.Warn("unable to record query", "err", err)
+	}
+	p.proxy.ServeHTTP(w, req)
+}
+
+// requestParams returns the parameters of the URL and of the form sent in the body of the request,
+// restoring the body so it can still be forwarded. The time of instant queries defaults to now, as
+// it does on the target.
+func requestParams(req *http.Request) (url.Values, error) {
+	params := req.URL.Query()
+	if req.Method == http.MethodPost && req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); ct == "application/x-www-form-urlencoded" {
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range form {
+				params[k] = append(params[k], v...)
+			}
+		}
+	}
+	if strings.HasSuffix(req.URL.Path, "/api/v1/query") && params.Get("time") == "" {
+		params.Set("time", strconv.FormatFloat(float64(time.Now().UnixMilli())/1000, 'f', 3, 64))
+	}
+	return params, nil
+}
+
+// record writes the query received at the given time.
+func (p *Proxy) record(q query.Query, received time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.count == 0 {
+		p.first = received
+	}
+	at := received.Sub(p.first).Round(time.Millisecond)
+	q.At = &at
+	if p.Relative {
+		startAgo := max(0, received.Sub(time.UnixMilli(q.Start)).Round(time.Second))
+		endAgo := max(0, received.Sub(time.UnixMilli(q.End)).Round(time.Second))
+		q.StartAgo, q.EndAgo = &startAgo, &endAgo
+	}
+	if err := loader.Write(p.w, []query.Query{q}, loader.Format{Columns: Columns}); err != nil {
+		return err
+	}
+	p.count++
+	return nil
+}
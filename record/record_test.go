@@ -0,0 +1,98 @@
+package record
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/loader"
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestProxy(t *testing.T) {
+	var forwarded []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		forwarded = append(forwarded, req.URL.Path+" "+req.Form.Get("query"))
+		io.WriteString(w, `{"status":"success"}`)
+	}))
+	defer target.Close()
+
+	u, _ := url.Parse(target.URL)
+	var out strings.Builder
+	p, err := New(u, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	if _, err := http.Get(proxy.URL + "/api/v1/query_range?query=up&start=100&end=160&step=15"); err != nil {
+		t.Fatal(err)
+	}
+	form := url.Values{"query": {`rate(x{a="b|c"}[5m])`}, "time": {"100"}}
+	if _, err := http.PostForm(proxy.URL+"/api/v1/query", form); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(proxy.URL + "/api/v1/status/buildinfo"); err != nil {
+		t.Fatal(err)
+	}
+
+	wantForwarded := []string{"/api/v1/query_range up", `/api/v1/query rate(x{a="b|c"}[5m])`, "/api/v1/status/buildinfo "}
+	if strings.Join(forwarded, "\n") != strings.Join(wantForwarded, "\n") {
+		t.Errorf("forwarded %q, want %q", forwarded, wantForwarded)
+	}
+	if p.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", p.Count())
+	}
+
+	got, err := loader.ReadFormat(strings.NewReader(out.String()), loader.Format{})
+	if err != nil {
+		t.Fatalf("ReadFormat() error = %v reading:\n%s", err, out.String())
+	}
+	want := []query.Query{
+		{Query: "up", Start: 100000, End: 160000, Step: 15},
+		{Query: `rate(x{a="b|c"}[5m])`, Start: 100000, End: 100000, Step: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("recorded %d queries, want %d:\n%s", len(got), len(want), out.String())
+	}
+	if got[0].At == nil || *got[0].At != 0 || got[1].At == nil || *got[1].At < 0 {
+		t.Errorf("recorded queries at %v and %v, want 0 and after", got[0].At, got[1].At)
+	}
+	for i := range want {
+		if got[i].Query != want[i].Query || got[i].Start != want[i].Start || got[i].End != want[i].End || got[i].Step != want[i].Step {
+			t.Errorf("recorded query %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestProxy_relative(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer target.Close()
+
+	u, _ := url.Parse(target.URL)
+	var out strings.Builder
+	p, _ := New(u, &out)
+	p.Relative = true
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	now := time.Now()
+	params := url.Values{
+		"query": {"up"},
+		"start": {now.Add(-time.Hour).Format(time.RFC3339Nano)},
+		"end":   {now.Format(time.RFC3339Nano)},
+		"step":  {"15"},
+	}
+	if _, err := http.Get(proxy.URL + "/api/v1/query_range?" + params.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if want := "query|start|end|step|at\nup|now-1h|now|15|0s\n"; out.String() != want {
+		t.Errorf("recorded %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,152 @@
+// Package remoteread benchmarks queries through the Prometheus remote read protocol, i.e. snappy
+// compressed protobuf requests to the /api/v1/read endpoint, which Promscale serves next to the
+// PromQL HTTP API with very different performance characteristics.
+package remoteread
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Path of the remote read endpoint.
+const Path = "/api/v1/read"
+
+// Client executes queries as remote read requests. It implements the runner.Querier interface,
+// measuring the latency up to the response headers like the PromQL HTTP client, while the time
+// spent decoding the response is reported separately.
+type Client struct {
+	Client client.HttpClient
+	URL    *url.URL
+}
+
+// New instantiates a new Client given a host url, following the same rules as client.New.
+func New(host string) *Client {
+	c := client.New(host)
+	// Read requests aren't sent through client.Client.Query, so its deadline is left to the HTTP
+	// client
+	if hc, ok := c.Client.(*http.Client); ok {
+		hc.Timeout = c.Timeout
+	}
+	return &Client{Client: c.Client, URL: c.URL}
+}
+
+// NewReadRequest converts a query, which must be a series selector, into a ReadRequest.
+func NewReadRequest(q *query.Query) (*prompb.ReadRequest, error) {
+	if !q.RangeQuery() {
+		return nil, fmt.Errorf("the %s endpoint can't be requested through remote read", q.Endpoint)
+	}
+
+	matchers, err := parser.NewParser(parser.Options{}).ParseMetricSelector(q.Query)
+	if err != nil {
+		return nil, fmt.Errorf("remote read only supports series selectors. err=%w", err)
+	}
+
+	rq := &prompb.Query{
+		StartTimestampMs: q.Start,
+		EndTimestampMs:   q.End,
+		Hints:            &prompb.ReadHints{StepMs: int64(q.Step) * 1000, StartMs: q.Start, EndMs: q.End},
+	}
+	for _, m := range matchers {
+		rq.Matchers = append(rq.Matchers, &prompb.LabelMatcher{Type: matchTypes[m.Type], Name: m.Name, Value: m.Value})
+	}
+	return &prompb.ReadRequest{Queries: []*prompb.Query{rq}}, nil
+}
+
+var matchTypes = map[labels.MatchType]prompb.LabelMatcher_Type{
+	labels.MatchEqual:     prompb.LabelMatcher_EQ,
+	labels.MatchNotEqual:  prompb.LabelMatcher_NEQ,
+	labels.MatchRegexp:    prompb.LabelMatcher_RE,
+	labels.MatchNotRegexp: prompb.LabelMatcher_NRE,
+}
+
+// NewRequest builds the HTTP request sent to the target server for a given query.
+func (c *Client) NewRequest(q *query.Query) (*http.Request, error) {
+	rr, err := NewReadRequest(q)
+	if err != nil {
+		return nil, err
+	}
+	b, err := rr.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode read request. err=%w", err)
+	}
+
+	u := *c.URL
+	u.Path = client.PathPrefix(u.Path) + Path
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(snappy.Encode(nil, b)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+	return req, nil
+}
+
+// Query sends the remote read request for a given query and decodes its response.
+func (c *Client) Query(q *query.Query) (*client.Response, error) {
+	req, err := c.NewRequest(q)
+	if err != nil {
+		return nil, fmt.Errorf("Query() building request. error=%w", err)
+	}
+
+	start := time.Now()
+	resp, err := c.Client.Do(req)
+	end := time.Now()
+
+	if err != nil {
+		return nil, fmt.Errorf("Query() sending request to server. error=%w", err)
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Query() reading response. error=%w", err)
+		}
+	}
+
+	response := &client.Response{Response: resp, Bytes: int64(len(body))}
+	response.Timestamp.Start, response.Timestamp.End = start, end
+	if resp.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("Query() unexpected response. error=%w", &client.StatusError{StatusCode: resp.StatusCode})
+	}
+
+	decodeStart := time.Now()
+	rr, err := Decode(body)
+	response.Decode = time.Since(decodeStart)
+	if err != nil {
+		return response, fmt.Errorf("Query() decoding response. error=%w", &client.ClassError{Class: client.ErrorBodyDecode, Message: err.Error()})
+	}
+	for _, result := range rr.Results {
+		response.Series += len(result.Timeseries)
+	}
+
+	return response, nil
+}
+
+// Decode decompresses and unmarshals the body of a remote read response.
+func Decode(body []byte) (*prompb.ReadResponse, error) {
+	b, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress response. err=%w", err)
+	}
+
+	var rr prompb.ReadResponse
+	if err := rr.Unmarshal(b); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal response. err=%w", err)
+	}
+	return &rr, nil
+}
@@ -0,0 +1,114 @@
+package remoteread
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestNewReadRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []*prompb.LabelMatcher
+		wantErr bool
+	}{
+		{
+			name:  "selector",
+			query: `demo_cpu_usage_seconds_total{mode="idle", instance=~"a|b"}`,
+			want: []*prompb.LabelMatcher{
+				{Type: prompb.LabelMatcher_EQ, Name: "mode", Value: "idle"},
+				{Type: prompb.LabelMatcher_RE, Name: "instance", Value: "a|b"},
+				{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "demo_cpu_usage_seconds_total"},
+			},
+		},
+		{
+			name:    "PromQL expression",
+			query:   `avg by(instance) (demo_cpu_usage_seconds_total)`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewReadRequest(&query.Query{Query: tt.query, Start: 1000, End: 2000, Step: 15})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewReadRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			rq := got.Queries[0]
+			if rq.StartTimestampMs != 1000 || rq.EndTimestampMs != 2000 || rq.Hints.StepMs != 15000 {
+				t.Errorf("NewReadRequest() query = %v, want range [1000, 2000] with a 15000ms step", rq)
+			}
+			if len(rq.Matchers) != len(tt.want) {
+				t.Fatalf("NewReadRequest() matchers = %v, want %v", rq.Matchers, tt.want)
+			}
+			for i := range tt.want {
+				if rq.Matchers[i].String() != tt.want[i].String() {
+					t.Errorf("NewReadRequest() matcher %d = %v, want %v", i, rq.Matchers[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClient_Query(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      []byte
+		wantClass client.ErrorClass
+	}{
+		{name: "decoded", body: encode(t, &prompb.ReadResponse{Results: []*prompb.QueryResult{{}}})},
+		{name: "corrupt body", body: []byte("not snappy"), wantClass: client.ErrorBodyDecode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got prompb.ReadRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				b, err := snappy.Decode(nil, b)
+				if err != nil || got.Unmarshal(b) != nil || r.URL.Path != Path {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			u, _ := url.Parse(srv.URL)
+			c := &Client{Client: srv.Client(), URL: u}
+			resp, err := c.Query(&query.Query{Query: "up", Start: 1000, End: 2000, Step: 15})
+			if tt.wantClass == "" && err != nil {
+				t.Fatalf("Client.Query() error = %v", err)
+			}
+			if tt.wantClass != "" && client.Classify(err) != tt.wantClass {
+				t.Fatalf("Client.Query() error = %v, want class %v", err, tt.wantClass)
+			}
+			if len(got.Queries) != 1 {
+				t.Errorf("Client.Query() sent %v, want a single query", got.Queries)
+			}
+			if resp.Bytes != int64(len(tt.body)) || resp.Decode <= 0 {
+				t.Errorf("Client.Query() bytes = %d, decode = %v, want %d bytes decoded", resp.Bytes, resp.Decode, len(tt.body))
+			}
+		})
+	}
+}
+
+func encode(t *testing.T, rr *prompb.ReadResponse) []byte {
+	b, err := rr.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snappy.Encode(nil, b)
+}
@@ -0,0 +1,195 @@
+// Package remotewrite generates synthetic samples and pushes them through the Prometheus remote
+// write protocol, so read benchmarks can be run against a target under concurrent ingest.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// Path of the remote write endpoint.
+const Path = "/api/v1/write"
+
+// Writer pushes Series synthetic series at a constant Rate of samples per second. Every series
+// gets a new sample at the same time, like in a scrape, so a WriteRequest holding one sample per
+// series is sent every Series/Rate seconds.
+type Writer struct {
+	Client client.HttpClient
+	URL    *url.URL
+	// Metric is the name of the synthetic series, told apart by their "series" label
+	Metric string
+	Series int
+	// Rate is the number of samples pushed per second
+	Rate float64
+	// Workers is the number of concurrent requests in flight
+	Workers int
+}
+
+// New instantiates a new Writer given a host url, following the same rules as client.New.
+func New(host string) *Writer {
+	c := client.New(host)
+	// Write requests aren't sent through client.Client.Query, so its deadline is left to the HTTP
+	// client
+	if hc, ok := c.Client.(*http.Client); ok {
+		hc.Timeout = c.Timeout
+	}
+	return &Writer{Client: c.Client, URL: c.URL, Metric: "pqlbench_synthetic", Series: 100, Rate: 1000, Workers: 1}
+}
+
+// Stats of the samples pushed by a Writer.
+type Stats struct {
+	// Dropped is the number of requests not sent because every worker was busy
+	Dropped int `json:"dropped"`
+	// Elapsed is the duration of the run in milliseconds
+	Elapsed int64 `json:"elapsed_ms"`
+	// Failed is the number of requests that encountered an error
+	Failed int `json:"failed"`
+	// Requests is the number of requests sent
+	Requests int `json:"requests"`
+	// Samples is the number of samples accepted by the target
+	Samples int64 `json:"samples"`
+}
+
+func (s *Stats) ToString() (output string) {
+	output += fmt.Sprintf("Number of write requests sent: %d\n", s.Requests)
+	output += fmt.Sprintf("Number of samples written: %d\n", s.Samples)
+	if s.Elapsed > 0 {
+		output += fmt.Sprintf("Achieved rate: %.2f samples/s\n", float64(s.Samples)/(float64(s.Elapsed)/1000))
+	}
+	if s.Failed > 0 {
+		output += fmt.Sprintf("Failed write requests: %d\n", s.Failed)
+	}
+	if s.Dropped > 0 {
+		output += fmt.Sprintf("Write requests dropped because the target did not keep up: %d\n", s.Dropped)
+	}
+	return
+}
+
+// Run pushes samples until the context is done.
+func (w *Writer) Run(ctx context.Context) *Stats {
+	interval := time.Duration(float64(w.Series) / w.Rate * float64(time.Second))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// mu guards the stats updated by the concurrent workers
+	var mu sync.Mutex
+	s := &Stats{}
+
+	wg := sync.WaitGroup{}
+	wg.Add(w.Workers)
+	batches := make(chan *prompb.WriteRequest)
+	for i := 0; i < w.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for wr := range batches {
+				err := w.Write(wr)
+
+				mu.Lock()
+				s.Requests++
+				if err != nil {
+					s.Failed++
+				} else {
+					s.Samples += int64(len(wr.Timeseries))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	g := NewGenerator(w.Metric, w.Series, rand.New(rand.NewSource(start.UnixNano())))
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case now := <-ticker.C:
+			select {
+			case batches <- g.Next(now):
+			default:
+				mu.Lock()
+				s.Dropped++
+				mu.Unlock()
+			}
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	s.Elapsed = time.Since(start).Milliseconds()
+	return s
+}
+
+// Write sends a single WriteRequest.
+func (w *Writer) Write(wr *prompb.WriteRequest) error {
+	b, err := wr.Marshal()
+	if err != nil {
+		return fmt.Errorf("unable to encode write request. err=%w", err)
+	}
+
+	u := *w.URL
+	u.Path = client.PathPrefix(u.Path) + Path
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(snappy.Encode(nil, b)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Write() sending request to server. error=%w", err)
+	}
+	if resp.Body != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Write() unexpected response. error=%w", &client.StatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
+
+// Generator produces the samples of synthetic series, each following a random walk.
+type Generator struct {
+	labels [][]prompb.Label
+	values []float64
+	rnd    *rand.Rand
+}
+
+// NewGenerator returns a Generator of the given number of series of a metric.
+func NewGenerator(metric string, series int, rnd *rand.Rand) *Generator {
+	g := &Generator{labels: make([][]prompb.Label, series), values: make([]float64, series), rnd: rnd}
+	for i := range g.labels {
+		g.labels[i] = []prompb.Label{{Name: "__name__", Value: metric}, {Name: "series", Value: strconv.Itoa(i)}}
+		g.values[i] = rnd.Float64() * 100
+	}
+	return g
+}
+
+// Next returns a WriteRequest holding a new sample of every series at the given time.
+func (g *Generator) Next(now time.Time) *prompb.WriteRequest {
+	wr := &prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, len(g.labels))}
+	for i := range g.labels {
+		g.values[i] += g.rnd.NormFloat64()
+		wr.Timeseries[i] = prompb.TimeSeries{
+			Labels:  g.labels[i],
+			Samples: []prompb.Sample{{Value: g.values[i], Timestamp: now.UnixMilli()}},
+		}
+	}
+	return wr
+}
@@ -0,0 +1,66 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestGenerator_Next(t *testing.T) {
+	g := NewGenerator("synthetic", 3, rand.New(rand.NewSource(1)))
+	now := time.UnixMilli(1000)
+
+	wr := g.Next(now)
+	if len(wr.Timeseries) != 3 {
+		t.Fatalf("Generator.Next() = %d series, want 3", len(wr.Timeseries))
+	}
+	for i, ts := range wr.Timeseries {
+		if ts.Labels[0].Value != "synthetic" || ts.Labels[1].Value != string(rune('0'+i)) {
+			t.Errorf("Generator.Next() series %d labels = %v", i, ts.Labels)
+		}
+		if len(ts.Samples) != 1 || ts.Samples[0].Timestamp != 1000 {
+			t.Errorf("Generator.Next() series %d samples = %v, want one at 1000", i, ts.Samples)
+		}
+	}
+}
+
+func TestWriter_Run(t *testing.T) {
+	var mu sync.Mutex
+	var samples int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		b, err := snappy.Decode(nil, b)
+		var wr prompb.WriteRequest
+		if err != nil || wr.Unmarshal(b) != nil || r.URL.Path != Path {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		samples += len(wr.Timeseries)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	w := &Writer{Client: srv.Client(), URL: u, Metric: "synthetic", Series: 10, Rate: 1000, Workers: 2}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	s := w.Run(ctx)
+	if s.Failed != 0 || s.Requests == 0 {
+		t.Errorf("Writer.Run() requests = %d, failed = %d, want every request to succeed", s.Requests, s.Failed)
+	}
+	if s.Samples != int64(samples) || s.Samples > 120 {
+		t.Errorf("Writer.Run() samples = %d (received %d), want about 100", s.Samples, samples)
+	}
+}
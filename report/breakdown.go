@@ -0,0 +1,118 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// GroupStats are the stats of the queries of a group, e.g. sharing a tag.
+type GroupStats struct {
+	Name  string       `json:"name"`
+	Stats *stats.Stats `json:"stats"`
+}
+
+func (g GroupStats) toString(kind string) string {
+	return fmt.Sprintf("%s %s: %d queries processed, median %fms, average %fms, slowest %dms, %d errors\n",
+		kind, g.Name, g.Stats.Processed, g.Stats.Median, g.Stats.Average, g.Stats.Slowest, g.Stats.Errors.Total())
+}
+
+// Breakdown is a runner.Recorder grouping the Results by the keys of their query, so the stats of
+// every group can be told apart from those of the whole run. A Result is in the group of every key
+// of its query.
+type Breakdown struct {
+	keys func(q query.Query) []string
+	// buckets is set if the groups are known in advance, in which case they are held in order
+	buckets bool
+
+	mu      sync.Mutex
+	results map[string][]runner.Result
+	order   []string
+}
+
+func NewBreakdown(keys func(q query.Query) []string) *Breakdown {
+	return &Breakdown{keys: keys, results: map[string][]runner.Result{}}
+}
+
+// NewBucketBreakdown returns a Breakdown grouping the Results into the given buckets, reported in
+// their order rather than in the order they were first recorded. Empty buckets are left out.
+func NewBucketBreakdown(keys func(q query.Query) []string, buckets []string) *Breakdown {
+	return &Breakdown{keys: keys, buckets: true, results: map[string][]runner.Result{}, order: buckets}
+}
+
+// NewRangeBreakdown returns a Breakdown grouping the Results by the length of the time range of
+// their query, the main driver of its cost, see query.Query.RangeBucket.
+func NewRangeBreakdown() *Breakdown {
+	buckets := make([]string, len(query.RangeBuckets))
+	for i, b := range query.RangeBuckets {
+		buckets[i] = b.Name
+	}
+	return NewBucketBreakdown(func(q query.Query) []string { return []string{q.RangeBucket()} }, buckets)
+}
+
+// NewStepBreakdown returns a Breakdown grouping the Results by the step of their query, i.e. the
+// resolution requested, see query.Query.StepBucket. Queries without a step are left out.
+func NewStepBreakdown() *Breakdown {
+	buckets := make([]string, len(query.StepBuckets))
+	for i, b := range query.StepBuckets {
+		buckets[i] = b.Name
+	}
+	return NewBucketBreakdown(func(q query.Query) []string {
+		if bucket := q.StepBucket(); bucket != "" {
+			return []string{bucket}
+		}
+		return nil
+	}, buckets)
+}
+
+func (b *Breakdown) Record(r *runner.Result) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, name := range b.keys(r.Query) {
+		if _, ok := b.results[name]; !ok && !b.buckets {
+			b.order = append(b.order, name)
+		}
+		b.results[name] = append(b.results[name], *r)
+	}
+	return nil
+}
+
+// Stats returns the stats of every group, in the order they were first recorded.
+func (b *Breakdown) Stats() []GroupStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	groups := make([]GroupStats, 0, len(b.order))
+	for _, name := range b.order {
+		results, ok := b.results[name]
+		if !ok {
+			continue
+		}
+		s := runner.Aggregate(results, Span(results))
+		// Workers run queries of every group, so their stats only make sense for the whole run
+		s.Workers = nil
+		groups = append(groups, GroupStats{Name: name, Stats: s})
+	}
+	return groups
+}
+
+// TagKeys groups queries by their tags.
+func TagKeys(q query.Query) []string {
+	if len(q.Tags) == 0 {
+		return []string{"untagged"}
+	}
+	return q.Tags
+}
+
+// FeatureKeys groups queries by the PromQL features they use, or by their endpoint if not
+// targeting query_range.
+func FeatureKeys(q query.Query) []string {
+	if !q.RangeQuery() {
+		return []string{q.Class()}
+	}
+	return query.Features(q.Query)
+}
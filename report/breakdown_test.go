@@ -0,0 +1,108 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestBreakdown_Stats(t *testing.T) {
+	start := time.UnixMilli(0)
+	result := func(ms int, err error, tags ...string) *runner.Result {
+		return &runner.Result{Query: query.Query{Query: "up", Tags: tags}, Start: start, End: start.Add(time.Duration(ms) * time.Millisecond), Err: err}
+	}
+
+	b := NewBreakdown(TagKeys)
+	for _, r := range []*runner.Result{
+		result(10, nil, "dashboard"), result(100, nil), result(30, nil, "dashboard", "slo"), result(5, errors.New("failed"), "alerting", "slo"),
+	} {
+		b.Record(r)
+	}
+
+	got := b.Stats()
+	want := []struct {
+		name      string
+		processed int
+		errors    int
+		slowest   int64
+	}{
+		{"dashboard", 2, 0, 30},
+		{"untagged", 1, 0, 100},
+		{"slo", 1, 1, 30},
+		{"alerting", 0, 1, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Breakdown.Stats() = %d groups, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Name != w.name || g.Stats.Processed != w.processed || g.Stats.Errors.Total() != w.errors || g.Stats.Slowest != w.slowest {
+			t.Errorf("Breakdown.Stats()[%d] = %s %+v, want %+v", i, g.Name, g.Stats, w)
+		}
+		if g.Stats.Workers != nil {
+			t.Errorf("Breakdown.Stats()[%d] workers = %v, want none", i, g.Stats.Workers)
+		}
+	}
+}
+
+func TestBreakdown_ranges(t *testing.T) {
+	start := time.UnixMilli(0)
+	b := NewRangeBreakdown()
+	for _, window := range []time.Duration{48 * time.Hour, 30 * time.Minute, time.Hour, 48 * time.Hour} {
+		b.Record(&runner.Result{Query: query.Query{Query: "up", End: window.Milliseconds()}, Start: start, End: start.Add(time.Millisecond)})
+	}
+
+	var got []string
+	var processed []int
+	for _, g := range b.Stats() {
+		got, processed = append(got, g.Name), append(processed, g.Stats.Processed)
+	}
+	if want := []string{"<=1h", ">24h"}; !reflect.DeepEqual(got, want) || !reflect.DeepEqual(processed, []int{2, 2}) {
+		t.Errorf("Breakdown.Stats() groups = %v of %v queries, want %v of 2 queries each", got, processed, want)
+	}
+}
+
+func TestBreakdown_steps(t *testing.T) {
+	start := time.UnixMilli(0)
+	b := NewStepBreakdown()
+	for _, q := range []query.Query{
+		{Query: "up", Step: 300},
+		{Query: "up", Step: 15},
+		{Endpoint: query.EndpointSeries, Query: "up"},
+	} {
+		b.Record(&runner.Result{Query: q, Start: start, End: start.Add(time.Millisecond)})
+	}
+
+	var got []string
+	for _, g := range b.Stats() {
+		got = append(got, g.Name)
+	}
+	if want := []string{"<=15s", "1m-5m"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Breakdown.Stats() groups = %v, want %v", got, want)
+	}
+}
+
+func TestBreakdown_features(t *testing.T) {
+	start := time.UnixMilli(0)
+	b := NewBreakdown(FeatureKeys)
+	for _, q := range []query.Query{
+		{Query: `sum(rate(http_requests_total{code=~"5.."}[5m]))`},
+		{Query: `http_requests_total`},
+		{Endpoint: query.EndpointSeries, Query: `{job="api"}`},
+	} {
+		b.Record(&runner.Result{Query: q, Start: start, End: start.Add(time.Millisecond)})
+	}
+
+	var got []string
+	for _, g := range b.Stats() {
+		got = append(got, g.Name)
+	}
+	want := []string{query.FeatureRegex, query.FeatureRate, query.FeatureAggregation, query.FeatureSelector, query.EndpointSeries}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Breakdown.Stats() groups = %v, want %v", got, want)
+	}
+}
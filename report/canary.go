@@ -0,0 +1,66 @@
+package report
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// Canary compares the query times of the canary among several targets, given a fraction of the
+// queries by its weight, to those of the stable targets of the same run.
+type Canary struct {
+	Target string `json:"target"`
+	// Share is the fraction of the queries sent to the canary
+	Share        float64       `json:"share"`
+	Significance *Significance `json:"significance"`
+}
+
+// NewCanary compares the results of the canary, the only target given the smallest weight, to
+// those of the other targets, see NewSignificance. It returns nil if the targets aren't weighted or
+// no single one has the smallest weight.
+func NewCanary(targets []runner.Target, results [][]runner.Result, level float64, resamples int, rnd *rand.Rand) *Canary {
+	if len(targets) < 2 {
+		return nil
+	}
+	canary := -1
+	for i, t := range targets {
+		switch {
+		case t.Weight <= 0:
+			return nil
+		case canary < 0 || t.Weight < targets[canary].Weight:
+			canary = i
+		}
+	}
+	if canary < 0 {
+		return nil
+	}
+	var stable []runner.Result
+	total := len(results[canary])
+	for i, t := range targets {
+		if i == canary {
+			continue
+		}
+		if t.Weight == targets[canary].Weight {
+			return nil
+		}
+		stable = append(stable, results[i]...)
+		total += len(results[i])
+	}
+	if total == 0 {
+		return nil
+	}
+	return &Canary{
+		Target:       targets[canary].Name,
+		Share:        float64(len(results[canary])) / float64(total),
+		Significance: NewSignificance(stable, results[canary], level, resamples, rnd),
+	}
+}
+
+func (c *Canary) ToString() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Canary %s, sent %.1f%% of the queries, the candidate compared to the stable targets as the baseline:\n", c.Target, c.Share*100)
+	c.Significance.Render(&b)
+	return b.String()
+}
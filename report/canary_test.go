@@ -0,0 +1,54 @@
+package report
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestNewCanary(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := func(n, base int) []runner.Result {
+		var results []runner.Result
+		for i := range n {
+			results = append(results, runner.Result{Start: start, End: start.Add(time.Duration(base+i%10) * time.Millisecond)})
+		}
+		return results
+	}
+	tests := []struct {
+		name    string
+		weights []int
+		want    string
+	}{
+		{name: "canary", weights: []int{45, 45, 10}, want: "c"},
+		{name: "unweighted", weights: []int{0, 0, 0}},
+		{name: "no single lightest target", weights: []int{80, 10, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var targets []runner.Target
+			for i, w := range tt.weights {
+				targets = append(targets, runner.Target{Name: string(rune('a' + i)), Weight: w})
+			}
+			canary := NewCanary(targets, [][]runner.Result{results(45, 10), results(45, 10), results(10, 30)}, 0.95, 200, rand.New(rand.NewSource(1)))
+			if tt.want == "" {
+				if canary != nil {
+					t.Errorf("NewCanary() = %+v, want nil", canary)
+				}
+				return
+			}
+			if canary == nil || canary.Target != tt.want || canary.Share != 0.1 {
+				t.Fatalf("NewCanary() = %+v, want target %s with a share of 0.1", canary, tt.want)
+			}
+			if canary.Significance.P >= 0.05 {
+				t.Errorf("NewCanary() p = %v, want below 0.05 for a slower canary", canary.Significance.P)
+			}
+			if out := canary.ToString(); !strings.HasPrefix(out, "Canary c, sent 10.0% of the queries") {
+				t.Errorf("Canary.ToString() = %q", out)
+			}
+		})
+	}
+}
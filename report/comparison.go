@@ -0,0 +1,91 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// Winners of a QueryComparison.
+const (
+	WinnerPromQL = "promql"
+	WinnerSQL    = "sql"
+	WinnerTie    = "tie"
+	// WinnerNone is set when both paths failed
+	WinnerNone = "none"
+)
+
+// QueryComparison is the latency of a query over the HTTP PromQL API next to the latency of its
+// equivalent SQL statement.
+type QueryComparison struct {
+	Query       string  `json:"query"`
+	SQL         string  `json:"sql,omitempty"`
+	PromQL      float64 `json:"promql_ms"`
+	PromQLError string  `json:"promql_error,omitempty"`
+	SQLLatency  float64 `json:"sql_ms"`
+	SQLError    string  `json:"sql_error,omitempty"`
+	// Winner is the fastest path. A failed path never wins
+	Winner string `json:"winner"`
+}
+
+// NewQueryComparison builds the QueryComparison of a comparison whose Result ran over PromQL and
+// whose Other ran over SQL.
+func NewQueryComparison(c runner.Comparison) QueryComparison {
+	qc := QueryComparison{
+		Query:      c.Query.Query,
+		SQL:        c.Query.SQL,
+		PromQL:     latency(c.Result),
+		SQLLatency: latency(c.Other),
+	}
+	if c.Result.Err != nil {
+		qc.PromQLError = c.Result.Err.Error()
+	}
+	if c.Other.Err != nil {
+		qc.SQLError = c.Other.Err.Error()
+	}
+
+	switch {
+	case c.Result.Err != nil && c.Other.Err != nil:
+		qc.Winner = WinnerNone
+	case c.Other.Err != nil, c.Result.Err == nil && qc.PromQL < qc.SQLLatency:
+		qc.Winner = WinnerPromQL
+	case c.Result.Err != nil, qc.SQLLatency < qc.PromQL:
+		qc.Winner = WinnerSQL
+	default:
+		qc.Winner = WinnerTie
+	}
+	return qc
+}
+
+// latency returns the latency of a Result in milliseconds.
+func latency(r runner.Result) float64 {
+	return float64(r.End.Sub(r.Start).Microseconds()) / 1000
+}
+
+// Compare renders a table with the latency of every query over both paths and the winner, followed
+// by the number of wins of each path.
+func Compare(w io.Writer, comparisons []QueryComparison) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUERY\tPROMQL\tSQL\tWINNER")
+
+	wins := map[string]int{}
+	for _, c := range comparisons {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Query,
+			formatLatency(c.PromQL, c.PromQLError), formatLatency(c.SQLLatency, c.SQLError), c.Winner)
+		wins[c.Winner]++
+	}
+	fmt.Fprintf(tw, "wins\t%d\t%d\t%d tie, %d none\n",
+		wins[WinnerPromQL], wins[WinnerSQL], wins[WinnerTie], wins[WinnerNone])
+
+	return tw.Flush()
+}
+
+// formatLatency renders a latency, or "error" if the query failed.
+func formatLatency(ms float64, err string) string {
+	if err != "" {
+		return "error"
+	}
+	return fmt.Sprintf("%.2fms", ms)
+}
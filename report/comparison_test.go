@@ -0,0 +1,60 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestNewQueryComparison(t *testing.T) {
+	start := time.Unix(0, 0)
+	result := func(ms int, err error) runner.Result {
+		return runner.Result{Start: start, End: start.Add(time.Duration(ms) * time.Millisecond), Err: err}
+	}
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name       string
+		comparison runner.Comparison
+		want       string
+	}{
+		{name: "promql faster", comparison: runner.Comparison{Result: result(10, nil), Other: result(20, nil)}, want: WinnerPromQL},
+		{name: "sql faster", comparison: runner.Comparison{Result: result(20, nil), Other: result(10, nil)}, want: WinnerSQL},
+		{name: "tie", comparison: runner.Comparison{Result: result(10, nil), Other: result(10, nil)}, want: WinnerTie},
+		{name: "sql failed", comparison: runner.Comparison{Result: result(20, nil), Other: result(10, failed)}, want: WinnerPromQL},
+		{name: "promql failed", comparison: runner.Comparison{Result: result(10, failed), Other: result(20, nil)}, want: WinnerSQL},
+		{name: "both failed", comparison: runner.Comparison{Result: result(10, failed), Other: result(20, failed)}, want: WinnerNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewQueryComparison(tt.comparison).Winner; got != tt.want {
+				t.Errorf("NewQueryComparison() winner = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	comparisons := []QueryComparison{
+		NewQueryComparison(runner.Comparison{
+			Query:  query.Query{Query: "up", SQL: "SELECT 1"},
+			Result: runner.Result{End: time.Unix(0, 0).Add(1500 * time.Microsecond), Start: time.Unix(0, 0)},
+			Other:  runner.Result{Err: errors.New("failed")},
+		}),
+	}
+
+	var buf bytes.Buffer
+	if err := Compare(&buf, comparisons); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"up", "1.50ms", "error", WinnerPromQL, "wins"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Compare() = %v, want it to contain %v", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// Formats of a Document.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Document is the report of the raw results of a run rendered offline, as Markdown or HTML, so the
+// tables, histograms and rankings of a large run are generated away from the process that ran it.
+type Document struct {
+	Title   string
+	Summary *Summary
+	// Slowest holds the queries with the highest p95 query time and Failing those with the most
+	// errors, the first of the ranking only
+	Slowest []QueryStats
+	Failing []QueryStats
+}
+
+// NewDocument returns the Document of the given summary and stats of every query, ranking the top
+// queries by p95 query time and by errors.
+func NewDocument(title string, summary *Summary, queries []QueryStats, top int) *Document {
+	d := &Document{Title: title, Summary: summary}
+	d.Slowest = slices.Clone(queries)
+	sort.SliceStable(d.Slowest, func(i, j int) bool { return QueryStatsSorts["p95"](d.Slowest[i], d.Slowest[j]) })
+	for _, q := range queries {
+		if q.Errors > 0 {
+			d.Failing = append(d.Failing, q)
+		}
+	}
+	sort.SliceStable(d.Failing, func(i, j int) bool { return QueryStatsSorts["errors"](d.Failing[i], d.Failing[j]) })
+	d.Slowest, d.Failing = d.Slowest[:min(top, len(d.Slowest))], d.Failing[:min(top, len(d.Failing))]
+	return d
+}
+
+// ValidateFormat returns an error if the format is neither markdown nor html.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, want %s or %s", format, FormatMarkdown, FormatHTML)
+}
+
+// Render writes the Document in the given format.
+func (d *Document) Render(w io.Writer, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return d.markdown(w)
+	case FormatHTML:
+		return documentTemplate.Execute(w, struct {
+			Title  string
+			Tables []table
+		}{d.Title, d.tables()})
+	}
+	return ValidateFormat(format)
+}
+
+// table is a section of a Document, laid out the same in every format.
+type table struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+}
+
+// histogramBar is the width of the bar of the largest bucket of the histogram.
+const histogramBar = 30
+
+// tables returns the sections of the Document, leaving out those without rows.
+func (d *Document) tables() []table {
+	s := d.Summary.Stats
+	overview := table{Title: "Overview", Header: []string{"Statistic", "Value"}, Rows: [][]string{
+		{"Queries processed", fmt.Sprint(s.Processed)},
+		{"Errors", fmt.Sprint(s.Errors.Total())},
+		{"Success rate", fmt.Sprintf("%.2f%%", s.SuccessRate*100)},
+		{"Throughput", fmt.Sprintf("%.2f queries/s", s.Throughput)},
+		{"Fastest", fmt.Sprintf("%dms", s.Fastest)},
+		{"Median", millis(s.Median)},
+		{"Average", millis(s.Average)},
+		{"Standard deviation", millis(s.StdDev)},
+		{"Slowest", fmt.Sprintf("%dms", s.Slowest)},
+	}}
+	for _, p := range s.Percentiles {
+		overview.Rows = append(overview.Rows, []string{fmt.Sprintf("p%g", p.Percentile), millis(p.Value)})
+	}
+	tables := []table{overview}
+
+	histogram := table{Title: "Latency histogram", Header: []string{"Query time", "Queries", "Distribution"}}
+	var largest int
+	for _, b := range s.Histogram {
+		largest = max(largest, b.Count)
+	}
+	for _, b := range s.Histogram {
+		bar := strings.Repeat("█", (b.Count*histogramBar+largest-1)/largest)
+		histogram.Rows = append(histogram.Rows, []string{b.Label(), fmt.Sprint(b.Count), bar})
+	}
+
+	errs := table{Title: "Errors", Header: []string{"Class", "Errors", "Sample"}}
+	for _, class := range client.ErrorClasses {
+		if count := s.Errors.Counts[class]; count > 0 {
+			var sample string
+			if err := s.Errors.Samples[class]; err != nil {
+				sample = err.Error()
+			}
+			errs.Rows = append(errs.Rows, []string{string(class), fmt.Sprint(count), sample})
+		}
+	}
+
+	tables = append(tables, histogram, errs,
+		queriesTable("Slowest queries, by p95", d.Slowest),
+		queriesTable("Most failing queries", d.Failing),
+		groupsTable("PromQL features", d.Summary.Features),
+		groupsTable("Time ranges", d.Summary.Ranges),
+		groupsTable("Steps", d.Summary.Steps),
+		groupsTable("Tags", d.Summary.Tags),
+	)
+	return slices.DeleteFunc(tables, func(t table) bool { return len(t.Rows) == 0 })
+}
+
+func queriesTable(title string, qs []QueryStats) table {
+	t := table{Title: title, Header: []string{"Query", "Count", "Errors", "Min", "Median", "p95", "Max"}}
+	for _, q := range qs {
+		t.Rows = append(t.Rows, []string{q.Query, fmt.Sprint(q.Count), fmt.Sprint(q.Errors), millis(q.Min), millis(q.Median), millis(q.P95), millis(q.Max)})
+	}
+	return t
+}
+
+func groupsTable(title string, groups []GroupStats) table {
+	t := table{Title: title, Header: []string{"Group", "Queries", "Median", "Average", "Slowest", "Errors"}}
+	for _, g := range groups {
+		t.Rows = append(t.Rows, []string{g.Name, fmt.Sprint(g.Stats.Processed), millis(g.Stats.Median), millis(g.Stats.Average),
+			fmt.Sprintf("%dms", g.Stats.Slowest), fmt.Sprint(g.Stats.Errors.Total())})
+	}
+	return t
+}
+
+// millis formats a time in milliseconds.
+func millis(ms float64) string {
+	return fmt.Sprintf("%.2fms", ms)
+}
+
+// markdownCell escapes the text of a cell of a Markdown table, which can't span lines.
+var markdownCell = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+
+func (d *Document) markdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", d.Title)
+	for _, t := range d.tables() {
+		fmt.Fprintf(&b, "\n## %s\n\n", t.Title)
+		fmt.Fprintf(&b, "| %s |\n|", strings.Join(t.Header, " | "))
+		b.WriteString(strings.Repeat(" --- |", len(t.Header)) + "\n")
+		for _, row := range t.Rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = markdownCell.Replace(cell)
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var documentTemplate = template.Must(template.New("document").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- range .Tables}}
+<h2>{{.Title}}</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func TestNewDocument(t *testing.T) {
+	queries := []QueryStats{
+		{Query: "a", Count: 2, P95: 10},
+		{Query: "b", Count: 2, Errors: 1, P95: 30},
+		{Query: "c", Count: 2, Errors: 2, P95: 20},
+	}
+	d := NewDocument("run", &Summary{Stats: &stats.Stats{}}, queries, 2)
+	if len(d.Slowest) != 2 || d.Slowest[0].Query != "b" || d.Slowest[1].Query != "c" {
+		t.Errorf("NewDocument() slowest = %+v, want b, c", d.Slowest)
+	}
+	if len(d.Failing) != 2 || d.Failing[0].Query != "c" || d.Failing[1].Query != "b" {
+		t.Errorf("NewDocument() failing = %+v, want c, b", d.Failing)
+	}
+	if queries[0].Query != "a" {
+		t.Errorf("NewDocument() reordered the queries %+v", queries)
+	}
+}
+
+func TestDocument_Render(t *testing.T) {
+	var errs stats.ErrorSummary
+	errs.Add(errors.New("no such host"))
+	summary := &Summary{
+		Stats: &stats.Stats{
+			Processed:   3,
+			Errors:      errs,
+			Histogram:   stats.NewHistogram([]int64{5, 40, 40}),
+			Percentiles: stats.Percentiles{{Percentile: 99, Value: 40}},
+		},
+		Tags: []GroupStats{{Name: "team=a", Stats: &stats.Stats{Processed: 3}}},
+	}
+	queries := []QueryStats{{Query: `sum(rate(x{job="a|b"}[5m])) < 1`, Count: 4, Errors: 1, P95: 40}}
+	d := NewDocument("run <1>", summary, queries, 10)
+
+	tests := []struct {
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			format: FormatMarkdown,
+			want: []string{
+				"# run <1>\n", "| p99 | 40.00ms |", "| <=5ms | 1 | ███████████████ |", "| <=50ms | 2 | ██████████████████████████████ |",
+				"## Most failing queries", `| sum(rate(x{job="a\|b"}[5m])) < 1 | 4 | 1 |`, "## Tags", "| team=a | 3 |",
+			},
+		},
+		{
+			format: FormatHTML,
+			want: []string{
+				"<title>run &lt;1&gt;</title>", "<h2>Errors</h2>", "<td>p99</td><td>40.00ms</td>",
+				"<td>sum(rate(x{job=&#34;a|b&#34;}[5m])) &lt; 1</td>",
+			},
+		},
+		{format: "pdf", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b strings.Builder
+			err := d.Render(&b, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Document.Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("Document.Render() misses %q in\n%s", want, b.String())
+				}
+			}
+			if strings.Contains(b.String(), "## Steps") || strings.Contains(b.String(), "<h2>Steps</h2>") {
+				t.Errorf("Document.Render() rendered the empty steps table")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// NondeterministicQuery is a query whose executions returned different results.
+type NondeterministicQuery struct {
+	Query    string `json:"query"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	// Fingerprints counts the executions that returned every distinct result
+	Fingerprints map[string]int `json:"fingerprints"`
+}
+
+func (q NondeterministicQuery) ToString() string {
+	var executions int
+	for _, n := range q.Fingerprints {
+		executions += n
+	}
+	return fmt.Sprintf("Nondeterministic query %s: %d distinct results in %d executions\n", q.Query, len(q.Fingerprints), executions)
+}
+
+// Fingerprints is a runner.Recorder collecting the fingerprints of the results of every query, so
+// the queries returning different results across their executions can be told apart, e.g. because
+// of a nondeterministic target or, reading the request logs of several runs, across targets. Only
+// executions over the same time range are compared, so queries with relative times are compared
+// only if resolved to the same millisecond.
+type Fingerprints struct {
+	mu      sync.Mutex
+	queries map[string]*NondeterministicQuery
+	order   []string
+}
+
+func NewFingerprints() *Fingerprints {
+	return &Fingerprints{queries: map[string]*NondeterministicQuery{}}
+}
+
+func (f *Fingerprints) Record(r *runner.Result) error {
+	if r.Fingerprint == "" {
+		return nil
+	}
+	q := query.Query{Query: r.Query.Query, Endpoint: r.Query.Endpoint, Start: r.Query.Start, End: r.Query.End, Step: r.Query.Step}
+	key := q.Key()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fq, ok := f.queries[key]
+	if !ok {
+		fq = &NondeterministicQuery{Query: q.Query, Endpoint: q.Endpoint, Start: q.Start, End: q.End, Fingerprints: map[string]int{}}
+		f.queries[key] = fq
+		f.order = append(f.order, key)
+	}
+	fq.Fingerprints[r.Fingerprint]++
+	return nil
+}
+
+// Nondeterministic returns the queries whose executions returned different results, in the order
+// they were first recorded.
+func (f *Fingerprints) Nondeterministic() []NondeterministicQuery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var queries []NondeterministicQuery
+	for _, key := range f.order {
+		if q := f.queries[key]; len(q.Fingerprints) > 1 {
+			queries = append(queries, *q)
+		}
+	}
+	return queries
+}
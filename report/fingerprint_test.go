@@ -0,0 +1,36 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestFingerprints_Nondeterministic(t *testing.T) {
+	results := []runner.Result{
+		{Query: query.Query{Query: "up", Start: 1, End: 2}, Fingerprint: "a"},
+		{Query: query.Query{Query: "rand()", Start: 1, End: 2}, Fingerprint: "b"},
+		{Query: query.Query{Query: "up", Start: 1, End: 2}, Fingerprint: "a"},
+		{Query: query.Query{Query: "rand()", Start: 1, End: 2}, Fingerprint: "c"},
+		{Query: query.Query{Query: "rand()", Start: 1, End: 2}, Fingerprint: "c"},
+		// Over another time range, or failed
+		{Query: query.Query{Query: "up", Start: 1, End: 3}, Fingerprint: "d"},
+		{Query: query.Query{Query: "up", Start: 1, End: 2}},
+	}
+	f := NewFingerprints()
+	for i := range results {
+		if err := f.Record(&results[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []NondeterministicQuery{{Query: "rand()", Start: 1, End: 2, Fingerprints: map[string]int{"b": 1, "c": 2}}}
+	if got := f.Nondeterministic(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fingerprints.Nondeterministic() = %+v, want %+v", got, want)
+	}
+	if got, want := want[0].ToString(), "Nondeterministic query rand(): 2 distinct results in 3 executions\n"; got != want {
+		t.Errorf("NondeterministicQuery.ToString() = %q, want %q", got, want)
+	}
+}
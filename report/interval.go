@@ -0,0 +1,151 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// IntervalReporter is a runner.Recorder writing to W, every Interval between Start and Stop, the
+// stats of the queries completed within the last interval and since Start, so long runs (e.g. soak
+// tests) can be monitored while running. Only the results of the current interval are kept, those
+// of the previous ones are merged into running aggregates.
+type IntervalReporter struct {
+	W        io.Writer
+	Interval time.Duration
+
+	mu    sync.Mutex
+	start time.Time
+	last  time.Time
+	// window holds the results completed within the current interval
+	window []runner.Result
+	total  cumulative
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// cumulative aggregates the results of the intervals reported. It counts the results of every
+// latency in milliseconds rather than keeping them, so its size is bounded by the spread of the
+// latencies instead of growing with the run, and still gives their exact median.
+type cumulative struct {
+	results   int
+	processed int
+	sum       int64
+	slowest   int64
+	errors    map[client.ErrorClass]int
+	latencies map[int64]int
+}
+
+// add merges the results of an interval, whose stats are given.
+func (c *cumulative) add(results []runner.Result, s *stats.Stats) {
+	if c.latencies == nil {
+		c.errors, c.latencies = map[client.ErrorClass]int{}, map[int64]int{}
+	}
+	c.results += len(results)
+	for class, count := range s.Errors.Counts {
+		c.errors[class] += count
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		// Latencies are measured in milliseconds as by runner.Aggregate
+		latency := r.End.UnixMilli() - r.Start.UnixMilli()
+		c.latencies[latency]++
+		c.processed++
+		c.sum += latency
+		c.slowest = max(c.slowest, latency)
+	}
+}
+
+// stats returns the stats of the results merged so far, which took elapsed.
+func (c *cumulative) stats(elapsed time.Duration) *stats.Stats {
+	s := &stats.Stats{Processed: c.processed, Slowest: c.slowest, Errors: stats.ErrorSummary{Counts: c.errors}}
+	if elapsed > 0 {
+		s.Throughput = float64(c.results) / elapsed.Seconds()
+	}
+	if c.processed == 0 {
+		return s
+	}
+	s.Average = float64(c.sum) / float64(c.processed)
+
+	latencies := make([]int64, 0, len(c.latencies))
+	for latency := range c.latencies {
+		latencies = append(latencies, latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	// nth returns the latency of the nth result by latency
+	nth := func(n int) int64 {
+		for _, latency := range latencies {
+			if n -= c.latencies[latency]; n < 0 {
+				return latency
+			}
+		}
+		return c.slowest
+	}
+	if half := c.processed / 2; c.processed%2 != 0 {
+		s.Median = float64(nth(half))
+	} else {
+		s.Median = float64(nth(half-1)+nth(half)) / 2
+	}
+	return s
+}
+
+func NewIntervalReporter(w io.Writer, interval time.Duration) *IntervalReporter {
+	return &IntervalReporter{W: w, Interval: interval}
+}
+
+func (i *IntervalReporter) Record(r *runner.Result) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.window = append(i.window, *r)
+	return nil
+}
+
+// Start keeps reporting every Interval in the background.
+func (i *IntervalReporter) Start() {
+	i.start, i.last = time.Now(), time.Now()
+	i.stop, i.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(i.done)
+		ticker := time.NewTicker(i.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-i.stop:
+				return
+			case now := <-ticker.C:
+				i.report(now)
+			}
+		}
+	}()
+}
+
+// Stop stops reporting. The queries completed since the last report are left to the summary.
+func (i *IntervalReporter) Stop() {
+	close(i.stop)
+	<-i.done
+}
+
+func (i *IntervalReporter) report(now time.Time) {
+	i.mu.Lock()
+	window := runner.Aggregate(i.window, now.Sub(i.last))
+	i.total.add(i.window, window)
+	cumulative := i.total.stats(now.Sub(i.start))
+	from, to := i.last.Sub(i.start).Round(time.Second), now.Sub(i.start).Round(time.Second)
+	i.window, i.last = nil, now
+	i.mu.Unlock()
+
+	fmt.Fprintf(i.W, "Interval %s-%s: %s; cumulative: %s\n", from, to, intervalString(window), intervalString(cumulative))
+}
+
+func intervalString(s *stats.Stats) string {
+	return fmt.Sprintf("%d queries processed, %f queries/s, median %fms, average %fms, slowest %dms, %d errors",
+		s.Processed, s.Throughput, s.Median, s.Average, s.Slowest, s.Errors.Total())
+}
@@ -0,0 +1,54 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestIntervalReporter_report(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.UnixMilli(0)
+	i := NewIntervalReporter(&buf, time.Second)
+	i.start, i.last = start, start
+
+	i.Record(&runner.Result{Start: start, End: start.Add(10 * time.Millisecond)})
+	i.Record(&runner.Result{Start: start, End: start.Add(30 * time.Millisecond)})
+	i.report(start.Add(time.Second))
+	i.Record(&runner.Result{Start: start.Add(time.Second), End: start.Add(time.Second + 50*time.Millisecond)})
+	i.Record(&runner.Result{Start: start.Add(time.Second), End: start.Add(time.Second + 50*time.Millisecond), Err: errors.New("timeout")})
+	i.report(start.Add(2 * time.Second))
+
+	want := "Interval 0s-1s: 2 queries processed, 2.000000 queries/s, median 20.000000ms, average 20.000000ms, slowest 30ms, 0 errors; " +
+		"cumulative: 2 queries processed, 2.000000 queries/s, median 20.000000ms, average 20.000000ms, slowest 30ms, 0 errors\n" +
+		"Interval 1s-2s: 1 queries processed, 2.000000 queries/s, median 50.000000ms, average 50.000000ms, slowest 50ms, 1 errors; " +
+		"cumulative: 3 queries processed, 2.000000 queries/s, median 30.000000ms, average 30.000000ms, slowest 50ms, 1 errors\n"
+	if buf.String() != want {
+		t.Errorf("IntervalReporter.report() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestIntervalReporter_report_cumulative(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.UnixMilli(0)
+	i := NewIntervalReporter(&buf, time.Second)
+	i.start, i.last = start, start
+
+	for n, latencies := range [][]time.Duration{{10, 40}, {20}, {80}} {
+		for _, latency := range latencies {
+			i.Record(&runner.Result{Start: start, End: start.Add(latency * time.Millisecond)})
+		}
+		i.report(start.Add(time.Duration(n+1) * time.Second))
+		if len(i.window) != 0 {
+			t.Fatalf("IntervalReporter.report() kept %d results, want the window reset", len(i.window))
+		}
+	}
+
+	s := i.total.stats(3 * time.Second)
+	if s.Processed != 4 || s.Median != 30 || s.Average != 37.5 || s.Slowest != 80 {
+		t.Errorf("cumulative.stats() = %d processed, median %v, average %v, slowest %d, want 4, 30, 37.5 and 80", s.Processed, s.Median, s.Average, s.Slowest)
+	}
+}
@@ -0,0 +1,153 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// JUnitSuite is the single test suite of a JUnit XML report, whose test cases are the queries.
+type JUnitSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is a query of a JUnit XML report. Its Time is the total time of its executions in
+// seconds.
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Error     *JUnitMessage `xml:"error,omitempty"`
+}
+
+// JUnitMessage is the failure of the assertions of a query, or the error of a query.
+type JUnitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit is a runner.Recorder collecting every query as a test case of a JUnit XML report, so CI
+// systems (e.g. Jenkins or GitLab) render the outcome of the run natively. A query fails if any of
+// its executions failed its assertions, and is in error if any of them errored.
+type JUnit struct {
+	mu    sync.Mutex
+	start time.Time
+	cases map[string]*junitCase
+	order []string
+}
+
+type junitCase struct {
+	name, class string
+	executions  int
+	elapsed     time.Duration
+	failures    int
+	failure     string
+	errors      int
+	err         string
+}
+
+func NewJUnit() *JUnit {
+	return &JUnit{cases: map[string]*junitCase{}}
+}
+
+func (j *JUnit) Record(r *runner.Result) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// Failures logged by older versions may lack their times
+	if !r.Start.IsZero() && (j.start.IsZero() || r.Start.Before(j.start)) {
+		j.start = r.Start
+	}
+	key := r.Query.Key()
+	c, ok := j.cases[key]
+	if !ok {
+		c = &junitCase{name: r.Query.Query, class: "pqlbench." + r.Query.Class()}
+		if !r.Query.RangeQuery() {
+			c.name = "/api/v1/" + r.Query.Endpoint + " " + r.Query.Query
+		}
+		j.cases[key] = c
+		j.order = append(j.order, key)
+	}
+	c.executions++
+	c.elapsed += r.End.Sub(r.Start)
+	if r.Err != nil {
+		if c.errors++; c.errors == 1 {
+			c.err = r.Err.Error()
+		}
+	} else if r.Assertion != "" {
+		if c.failures++; c.failures == 1 {
+			c.failure = r.Assertion
+		}
+	}
+	return nil
+}
+
+// Suite returns the test suite of the queries recorded, in the order they were first recorded.
+func (j *JUnit) Suite() JUnitSuite {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	suite := JUnitSuite{Name: "pqlbench", Tests: len(j.order)}
+	if !j.start.IsZero() {
+		suite.Timestamp = j.start.UTC().Format(time.RFC3339)
+	}
+	for _, key := range j.order {
+		c := j.cases[key]
+		tc := JUnitTestCase{Name: c.name, ClassName: c.class, Time: c.elapsed.Seconds()}
+		if c.failures > 0 {
+			suite.Failures++
+			tc.Failure = &JUnitMessage{Message: c.failure, Type: "assertion", Text: fmt.Sprintf("%d of %d executions failed their assertions, first: %s", c.failures, c.executions, c.failure)}
+		}
+		if c.errors > 0 {
+			suite.Errors++
+			tc.Error = &JUnitMessage{Message: c.err, Type: "error", Text: fmt.Sprintf("%d of %d executions errored, first: %s", c.errors, c.executions, c.err)}
+		}
+		suite.Time += tc.Time
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return suite
+}
+
+// WriteJUnit writes the given suite as a JUnit XML document.
+func WriteJUnit(w io.Writer, suite JUnitSuite) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Write writes the JUnit XML report of the queries recorded to the given path.
+func (j *JUnit) Write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WriteJUnit(f, j.Suite())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write JUnit report %s. err=%w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestJUnit(t *testing.T) {
+	start := time.Unix(100, 0)
+	up := query.Query{Query: "up", Start: 1, End: 2, Step: 1}
+	rate := query.Query{Query: "rate(x[5m])", Start: 1, End: 2, Step: 1}
+	labels := query.Query{Endpoint: query.EndpointLabels}
+	results := []runner.Result{
+		{Query: up, Start: start, End: start.Add(100 * time.Millisecond)},
+		{Query: up, Start: start, End: start.Add(300 * time.Millisecond), Assertion: "got 0 series, want at least 1"},
+		{Query: rate, Start: start, End: start.Add(time.Second), Err: errors.New("timeout")},
+		{Query: labels, Start: start, End: start.Add(500 * time.Millisecond)},
+	}
+
+	j := NewJUnit()
+	for i := range results {
+		j.Record(&results[i])
+	}
+
+	got := j.Suite()
+	want := JUnitSuite{
+		Name: "pqlbench", Tests: 3, Failures: 1, Errors: 1, Time: 1.9, Timestamp: "1970-01-01T00:01:40Z",
+		Cases: []JUnitTestCase{
+			{Name: "up", ClassName: "pqlbench.selector", Time: 0.4, Failure: &JUnitMessage{
+				Message: "got 0 series, want at least 1", Type: "assertion",
+				Text: "1 of 2 executions failed their assertions, first: got 0 series, want at least 1",
+			}},
+			{Name: "rate(x[5m])", ClassName: "pqlbench.rate", Time: 1, Error: &JUnitMessage{
+				Message: "timeout", Type: "error", Text: "1 of 1 executions errored, first: timeout",
+			}},
+			{Name: "/api/v1/labels ", ClassName: "pqlbench.labels", Time: 0.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("JUnit.Suite() = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, JUnitSuite{Name: "pqlbench", Tests: 1, Time: 0.5, Cases: []JUnitTestCase{{Name: `a{b="c"}`, ClassName: "pqlbench.selector", Time: 0.5}}}); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+	wantXML := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="pqlbench" tests="1" failures="0" errors="0" time="0.5">
+  <testcase name="a{b=&#34;c&#34;}" classname="pqlbench.selector" time="0.5"></testcase>
+</testsuite>
+`
+	if buf.String() != wantXML {
+		t.Errorf("WriteJUnit() = %q, want %q", buf.String(), wantXML)
+	}
+}
+
+func TestJUnit_untimedFailure(t *testing.T) {
+	start := time.Unix(100, 0)
+	up := query.Query{Query: "up", Start: 1, End: 2, Step: 1}
+	j := NewJUnit()
+	j.Record(&runner.Result{Query: up, Err: errors.New("connection refused")})
+	if got := j.Suite().Timestamp; got != "" {
+		t.Errorf("JUnit.Suite().Timestamp = %q, want none", got)
+	}
+	j.Record(&runner.Result{Query: up, Start: start, End: start.Add(time.Second)})
+	if got := j.Suite().Timestamp; got != "1970-01-01T00:01:40Z" {
+		t.Errorf("JUnit.Suite().Timestamp = %q, want 1970-01-01T00:01:40Z", got)
+	}
+}
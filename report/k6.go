@@ -0,0 +1,138 @@
+package report
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// K6Metric is a metric of a K6Summary: a counter, trend or rate holding the values k6 summarizes it
+// with, e.g. count and rate for counters.
+type K6Metric struct {
+	Type     string             `json:"type"`
+	Contains string             `json:"contains"`
+	Values   map[string]float64 `json:"values"`
+}
+
+// K6Check is a check of a K6Group, counting the executions passing and failing it.
+type K6Check struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	ID     string `json:"id"`
+	Passes int    `json:"passes"`
+	Fails  int    `json:"fails"`
+}
+
+// K6Group is a group of a K6Summary. Queries are all in the root group.
+type K6Group struct {
+	Name   string    `json:"name"`
+	Path   string    `json:"path"`
+	ID     string    `json:"id"`
+	Groups []K6Group `json:"groups"`
+	Checks []K6Check `json:"checks"`
+}
+
+// K6Summary is the summary of a run encoded like the end-of-test summary k6 hands to handleSummary
+// (and writes with --summary-export=...json in its newer versions), so the dashboards and tooling
+// built around k6 consume it unchanged. Every query is an http_reqs request and an iteration, and
+// the assertions of the queries are the "assertions" check.
+type K6Summary struct {
+	RootGroup K6Group             `json:"root_group"`
+	Options   map[string]any      `json:"options"`
+	State     map[string]any      `json:"state"`
+	Metrics   map[string]K6Metric `json:"metrics"`
+}
+
+// k6TrendStats are the stats k6 summarizes the trends with by default.
+var k6TrendStats = []string{"avg", "min", "med", "max", "p(90)", "p(95)"}
+
+// k6ID is the ID k6 gives to the groups and checks of the given path.
+func k6ID(path string) string {
+	sum := md5.Sum([]byte(path))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewK6Summary builds the K6Summary of the given results. Like k6, the durations of the requests
+// include those of the failed queries.
+func NewK6Summary(results []runner.Result) *K6Summary {
+	var first, last time.Time
+	var failed, received int64
+	var passes, fails int
+	durations := make([]float64, len(results))
+	for i, r := range results {
+		// Failures logged by older versions may lack their times
+		if !r.Start.IsZero() && (first.IsZero() || r.Start.Before(first)) {
+			first = r.Start
+		}
+		if r.End.After(last) {
+			last = r.End
+		}
+		durations[i] = float64(r.End.Sub(r.Start)) / float64(time.Millisecond)
+		received += r.Bytes
+		if r.Err != nil {
+			failed++
+			continue
+		}
+		if r.Query.MinSeries > 0 || r.Query.ExpectNonEmpty {
+			if r.Assertion == "" {
+				passes++
+			} else {
+				fails++
+			}
+		}
+	}
+	elapsed := last.Sub(first)
+	rate := func(count float64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return count / elapsed.Seconds()
+	}
+
+	requests := float64(len(results))
+	var average, failedRate float64
+	if len(results) > 0 {
+		for _, d := range durations {
+			average += d
+		}
+		average /= requests
+		failedRate = float64(failed) / requests
+	}
+	duration := K6Metric{Type: "trend", Contains: "time", Values: map[string]float64{
+		"avg":   average,
+		"min":   stats.Quantile(durations, 0),
+		"med":   stats.Quantile(durations, 0.5),
+		"max":   stats.Quantile(durations, 1),
+		"p(90)": stats.Quantile(durations, 0.9),
+		"p(95)": stats.Quantile(durations, 0.95),
+	}}
+
+	summary := &K6Summary{
+		RootGroup: K6Group{ID: k6ID(""), Groups: []K6Group{}, Checks: []K6Check{}},
+		Options:   map[string]any{"summaryTrendStats": k6TrendStats, "summaryTimeUnit": "", "noColor": false},
+		State:     map[string]any{"isStdOutTTY": false, "isStdErrTTY": false, "testRunDurationMs": float64(elapsed) / float64(time.Millisecond)},
+		Metrics: map[string]K6Metric{
+			"http_reqs":         {Type: "counter", Contains: "default", Values: map[string]float64{"count": requests, "rate": rate(requests)}},
+			"iterations":        {Type: "counter", Contains: "default", Values: map[string]float64{"count": requests, "rate": rate(requests)}},
+			"http_req_duration": duration,
+			// A rate counts the requests for which it holds as passes, here those failed
+			"http_req_failed": {Type: "rate", Contains: "default", Values: map[string]float64{"rate": failedRate, "passes": float64(failed), "fails": requests - float64(failed)}},
+			"data_received":   {Type: "counter", Contains: "data", Values: map[string]float64{"count": float64(received), "rate": rate(float64(received))}},
+		},
+	}
+	if passes+fails > 0 {
+		summary.RootGroup.Checks = append(summary.RootGroup.Checks, K6Check{Name: "assertions", Path: "::assertions", ID: k6ID("::assertions"), Passes: passes, Fails: fails})
+		summary.Metrics["checks"] = K6Metric{Type: "rate", Contains: "default", Values: map[string]float64{
+			"rate": float64(passes) / float64(passes+fails), "passes": float64(passes), "fails": float64(fails),
+		}}
+	}
+	return summary
+}
+
+// Write writes the summary to the given path as JSON.
+func (s *K6Summary) Write(path string) error {
+	return writeJSON(path, s)
+}
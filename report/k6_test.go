@@ -0,0 +1,55 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestNewK6Summary(t *testing.T) {
+	start := time.Unix(100, 0)
+	asserted := query.Query{Query: "up", MinSeries: 1}
+	results := []runner.Result{
+		{Query: asserted, Start: start, End: start.Add(10 * time.Millisecond), Bytes: 100},
+		{Query: asserted, Start: start.Add(time.Second), End: start.Add(time.Second + 30*time.Millisecond), Bytes: 300, Assertion: "got 0 series, want at least 1"},
+		{Query: query.Query{Query: "rate(x[5m])"}, Start: start.Add(1980 * time.Millisecond), End: start.Add(2 * time.Second), Err: errors.New("timeout")},
+	}
+
+	got := NewK6Summary(results)
+	wantMetrics := map[string]K6Metric{
+		"http_reqs":         {Type: "counter", Contains: "default", Values: map[string]float64{"count": 3, "rate": 1.5}},
+		"iterations":        {Type: "counter", Contains: "default", Values: map[string]float64{"count": 3, "rate": 1.5}},
+		"http_req_duration": {Type: "trend", Contains: "time", Values: map[string]float64{"avg": 20, "min": 10, "med": 20, "max": 30, "p(90)": 30, "p(95)": 30}},
+		"http_req_failed":   {Type: "rate", Contains: "default", Values: map[string]float64{"rate": 1.0 / 3, "passes": 1, "fails": 2}},
+		"data_received":     {Type: "counter", Contains: "data", Values: map[string]float64{"count": 400, "rate": 200}},
+		"checks":            {Type: "rate", Contains: "default", Values: map[string]float64{"rate": 0.5, "passes": 1, "fails": 1}},
+	}
+	if !reflect.DeepEqual(got.Metrics, wantMetrics) {
+		t.Errorf("NewK6Summary().Metrics = %+v, want %+v", got.Metrics, wantMetrics)
+	}
+	wantChecks := []K6Check{{Name: "assertions", Path: "::assertions", ID: k6ID("::assertions"), Passes: 1, Fails: 1}}
+	if !reflect.DeepEqual(got.RootGroup.Checks, wantChecks) {
+		t.Errorf("NewK6Summary().RootGroup.Checks = %+v, want %+v", got.RootGroup.Checks, wantChecks)
+	}
+	if got.RootGroup.ID != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("NewK6Summary().RootGroup.ID = %s, want the MD5 of the empty path", got.RootGroup.ID)
+	}
+	if d := got.State["testRunDurationMs"]; d != 2000.0 {
+		t.Errorf("NewK6Summary().State[testRunDurationMs] = %v, want 2000", d)
+	}
+}
+
+func TestNewK6Summary_untimedFailure(t *testing.T) {
+	start := time.Unix(100, 0)
+	results := []runner.Result{
+		{Err: errors.New("connection refused")},
+		{Start: start, End: start.Add(time.Second)},
+	}
+	if d := NewK6Summary(results).State["testRunDurationMs"]; d != 1000.0 {
+		t.Errorf("NewK6Summary().State[testRunDurationMs] = %v, want 1000", d)
+	}
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+// Metadata describes how a run was produced, so its artifacts are self-describing and the run can
+// be reproduced.
+type Metadata struct {
+	// Config is the configuration of the run, without credentials
+	Config json.RawMessage `json:"config,omitempty"`
+	// Started is the time the run started
+	Started time.Time `json:"started"`
+	// Target is the build reported by the target, if available
+	Target *client.BuildInfo `json:"target,omitempty"`
+	// Tool is the version of pqlbench the run was produced by, and ToolBuild its build
+	Tool      string `json:"tool"`
+	ToolBuild *Build `json:"tool_build,omitempty"`
+}
+
+// NewMetadata returns the Metadata of a run started at the given time with the running binary.
+func NewMetadata(started time.Time) *Metadata {
+	build := ToolBuild()
+	return &Metadata{Started: started, Tool: ToolVersion(), ToolBuild: &build}
+}
+
+func (m *Metadata) ToString() (output string) {
+	if m.ToolBuild != nil {
+		output += fmt.Sprintf("Run started at %s with %s\n", m.Started.Format(time.RFC3339), m.ToolBuild.ToString())
+	} else {
+		output += fmt.Sprintf("Run started at %s with pqlbench %s\n", m.Started.Format(time.RFC3339), m.Tool)
+	}
+	if m.Target != nil && m.Target.Version != "" {
+		output += fmt.Sprintf("Target version: %s\n", m.Target.Version)
+	}
+	return
+}
+
+// Version, Commit and Date describe the build of the pqlbench binary, set at build time with
+// -ldflags, e.g. -X github.com/noelruault/pqlbench/report.Version=v1.2.0 (see the Makefile). The
+// build info embedded by go build is used for those left unset.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Build describes the build of the pqlbench binary.
+type Build struct {
+	// Version is the semantic version of the release, or the version of the module if installed
+	// with go install, (devel) otherwise
+	Version string `json:"version"`
+	// Commit is the VCS revision built, suffixed with +dirty if the tree had local changes
+	Commit string `json:"commit,omitempty"`
+	// Date is the time of the build, or of the commit if not set at build time, in RFC 3339
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+func (b Build) ToString() string {
+	output := "pqlbench " + b.Version
+	if b.Commit != "" {
+		output += ", commit " + b.Commit
+	}
+	if b.Date != "" {
+		output += ", built " + b.Date
+	}
+	return output + ", " + b.GoVersion
+}
+
+// ToolBuild returns the build of the running pqlbench binary.
+func ToolBuild() Build {
+	b := Build{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		if b.Version == "" {
+			b.Version = "unknown"
+		}
+		return b
+	}
+	if b.Version == "" {
+		b.Version = info.Main.Version
+	}
+	if b.Version == "" {
+		b.Version = "(devel)"
+	}
+
+	var revision, modified, date string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = "+dirty"
+			}
+		case "vcs.time":
+			date = s.Value
+		}
+	}
+	if b.Commit == "" && revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		b.Commit = revision + modified
+	}
+	b.Date = cmp.Or(b.Date, date)
+	return b
+}
+
+// ToolVersion returns the version of the running pqlbench binary: its version if released or
+// installed with go install, or the VCS revision it was built from otherwise.
+func ToolVersion() string {
+	b := ToolBuild()
+	if b.Version != "(devel)" || b.Commit == "" {
+		return b.Version
+	}
+	return b.Commit
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuild_ToString(t *testing.T) {
+	tests := []struct {
+		name  string
+		build Build
+		want  string
+	}{
+		{
+			name:  "release",
+			build: Build{Version: "v1.2.0", Commit: "0123456789ab", Date: "2026-01-02T03:04:05Z", GoVersion: "go1.26.0"},
+			want:  "pqlbench v1.2.0, commit 0123456789ab, built 2026-01-02T03:04:05Z, go1.26.0",
+		},
+		{name: "development build", build: Build{Version: "(devel)", GoVersion: "go1.26.0"}, want: "pqlbench (devel), go1.26.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.build.ToString(); got != tt.want {
+				t.Errorf("Build.ToString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolBuild(t *testing.T) {
+	Version, Commit, Date = "v1.2.0", "0123456789ab", "2026-01-02T03:04:05Z"
+	defer func() { Version, Commit, Date = "", "", "" }()
+
+	want := Build{Version: "v1.2.0", Commit: "0123456789ab", Date: "2026-01-02T03:04:05Z", GoVersion: runtime.Version()}
+	if got := ToolBuild(); got != want {
+		t.Errorf("ToolBuild() = %+v, want %+v", got, want)
+	}
+	if got := ToolVersion(); got != "v1.2.0" {
+		t.Errorf("ToolVersion() = %s, want v1.2.0", got)
+	}
+
+	m := NewMetadata(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if out := m.ToString(); !strings.HasPrefix(out, "Run started at 2026-01-02T03:04:05Z with pqlbench v1.2.0, commit 0123456789ab") {
+		t.Errorf("Metadata.ToString() = %q", out)
+	}
+}
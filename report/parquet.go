@@ -0,0 +1,170 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/parquet-go/parquet-go"
+)
+
+// ParquetEvent is a row of the Parquet request log, holding the same fields as a RequestEvent.
+// Times are stored as milliseconds since the epoch.
+type ParquetEvent struct {
+	Timestamp    int64    `parquet:"timestamp,timestamp(millisecond)"`
+	Scheduled    int64    `parquet:"scheduled,optional,timestamp(millisecond)"`
+	Query        string   `parquet:"query,dict"`
+	Endpoint     string   `parquet:"endpoint,optional,dict"`
+	Tags         []string `parquet:"tags,list"`
+	Start        int64    `parquet:"start,timestamp(millisecond)"`
+	End          int64    `parquet:"end,timestamp(millisecond)"`
+	Step         int64    `parquet:"step"`
+	LatencyMs    float64  `parquet:"latency_ms"`
+	FirstByteMs  float64  `parquet:"first_byte_ms"`
+	TransferMs   float64  `parquet:"transfer_ms"`
+	DecodeMs     float64  `parquet:"decode_ms"`
+	Status       int32    `parquet:"status"`
+	Bytes        int64    `parquet:"bytes"`
+	Compressed   int64    `parquet:"compressed_bytes"`
+	DecompressMs float64  `parquet:"decompress_ms"`
+	Exemplars    int64    `parquet:"exemplars"`
+	Series       int64    `parquet:"series"`
+	Fingerprint  string   `parquet:"fingerprint,optional"`
+	RequestID    string   `parquet:"request_id,optional"`
+	TraceID      string   `parquet:"trace_id,optional"`
+	SpanID       string   `parquet:"span_id,optional"`
+	Worker       int32    `parquet:"worker"`
+	Error        string   `parquet:"error,optional"`
+	ErrorClass   string   `parquet:"error_class,optional,dict"`
+	Assertion    string   `parquet:"assertion,optional"`
+}
+
+func newParquetEvent(e *RequestEvent) ParquetEvent {
+	p := ParquetEvent{
+		Timestamp:    e.Timestamp.UnixMilli(),
+		Query:        e.Query,
+		Endpoint:     e.Endpoint,
+		Tags:         e.Tags,
+		Start:        e.Start,
+		End:          e.End,
+		Step:         int64(e.Step),
+		LatencyMs:    e.LatencyMs,
+		FirstByteMs:  e.FirstByteMs,
+		TransferMs:   e.TransferMs,
+		DecodeMs:     e.DecodeMs,
+		Status:       int32(e.Status),
+		Bytes:        e.Bytes,
+		Compressed:   e.Compressed,
+		DecompressMs: e.DecompressMs,
+		Exemplars:    int64(e.Exemplars),
+		Series:       int64(e.Series),
+		Fingerprint:  e.Fingerprint,
+		RequestID:    e.RequestID,
+		TraceID:      e.TraceID,
+		SpanID:       e.SpanID,
+		Worker:       int32(e.Worker),
+		Error:        e.Error,
+		ErrorClass:   e.ErrorClass,
+		Assertion:    e.Assertion,
+	}
+	if !e.Scheduled.IsZero() {
+		p.Scheduled = e.Scheduled.UnixMilli()
+	}
+	return p
+}
+
+func (p *ParquetEvent) requestEvent() *RequestEvent {
+	e := &RequestEvent{
+		Timestamp:    time.UnixMilli(p.Timestamp),
+		Query:        p.Query,
+		Endpoint:     p.Endpoint,
+		Tags:         p.Tags,
+		Start:        p.Start,
+		End:          p.End,
+		Step:         int(p.Step),
+		LatencyMs:    p.LatencyMs,
+		FirstByteMs:  p.FirstByteMs,
+		TransferMs:   p.TransferMs,
+		DecodeMs:     p.DecodeMs,
+		Status:       int(p.Status),
+		Bytes:        p.Bytes,
+		Compressed:   p.Compressed,
+		DecompressMs: p.DecompressMs,
+		Exemplars:    int(p.Exemplars),
+		Series:       int(p.Series),
+		Fingerprint:  p.Fingerprint,
+		RequestID:    p.RequestID,
+		TraceID:      p.TraceID,
+		SpanID:       p.SpanID,
+		Worker:       int(p.Worker),
+		Error:        p.Error,
+		ErrorClass:   p.ErrorClass,
+		Assertion:    p.Assertion,
+	}
+	if p.Scheduled != 0 {
+		e.Scheduled = time.UnixMilli(p.Scheduled)
+	}
+	if len(p.Tags) == 0 {
+		e.Tags = nil
+	}
+	return e
+}
+
+// ParquetLogger is a runner.Recorder writing every request as a row of a Parquet file, which loads
+// efficiently into analytical engines such as DuckDB or Athena. Close must be called once the run
+// ends to write the footer of the file.
+type ParquetLogger struct {
+	mu sync.Mutex
+	w  *parquet.GenericWriter[ParquetEvent]
+}
+
+func NewParquetLogger(w io.Writer) *ParquetLogger {
+	return &ParquetLogger{w: parquet.NewGenericWriter[ParquetEvent](w, parquet.Compression(&parquet.Zstd))}
+}
+
+func (l *ParquetLogger) Record(r *runner.Result) error {
+	event := newParquetEvent(NewRequestEvent(r))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.w.Write([]ParquetEvent{event})
+	return err
+}
+
+// MetadataKey is the key of the Parquet file metadata holding the JSON encoded Metadata of the run.
+const MetadataKey = "pqlbench.metadata"
+
+// SetMetadata stores the metadata of the run in the footer of the file.
+func (l *ParquetLogger) SetMetadata(m *Metadata) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.SetKeyValueMetadata(MetadataKey, string(b))
+	return nil
+}
+
+func (l *ParquetLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Close()
+}
+
+// ReadParquetLog reads the results logged by a ParquetLogger to a file of the given size.
+func ReadParquetLog(r io.ReaderAt, size int64) ([]runner.Result, error) {
+	events, err := parquet.Read[ParquetEvent](r, size)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Parquet request log. err=%w", err)
+	}
+	results := make([]runner.Result, len(events))
+	for i := range events {
+		results[i] = *events[i].requestEvent().Result()
+	}
+	return results, nil
+}
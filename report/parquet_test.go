@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestReadParquetLog(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []runner.Result{
+		{Query: query.Query{Query: "up", Start: 1, End: 2, Step: 3, Tags: []string{"dashboard", "slo"}}, Worker: 1, Start: start, End: start.Add(2 * time.Millisecond), Status: 200, Bytes: 10},
+		{Query: query.Query{Query: `{job="api"}`, Endpoint: query.EndpointSeries, Start: 1, End: 2}, Scheduled: start, Start: start.Add(time.Millisecond), End: start.Add(5 * time.Millisecond), Status: 503, Err: &client.StatusError{StatusCode: 503}},
+	}
+
+	var buf bytes.Buffer
+	logger := NewParquetLogger(&buf)
+	for i := range want {
+		if err := logger.Record(&want[i]); err != nil {
+			t.Fatalf("ParquetLogger.Record() error = %v", err)
+		}
+	}
+	if err := logger.SetMetadata(&Metadata{Started: start, Tool: "v1.0.0"}); err != nil {
+		t.Fatalf("ParquetLogger.SetMetadata() error = %v", err)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("ParquetLogger.Close() error = %v", err)
+	}
+
+	got, err := ReadParquetLog(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ReadParquetLog() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadParquetLog() read %d results, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !reflect.DeepEqual(g.Query, w.Query) || g.Worker != w.Worker || !g.Start.Equal(w.Start) || !g.End.Equal(w.End) ||
+			!g.Scheduled.Equal(w.Scheduled) || g.Status != w.Status || g.Bytes != w.Bytes {
+			t.Errorf("ReadParquetLog()[%d] = %+v, want %+v", i, g, w)
+		}
+	}
+	if client.Classify(got[1].Err) != client.ErrorServerStatus || got[1].Err.Error() != want[1].Err.Error() {
+		t.Errorf("ReadParquetLog() error = %v, want %v", got[1].Err, want[1].Err)
+	}
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("parquet.OpenFile() error = %v", err)
+	}
+	if metadata, _ := f.Lookup(MetadataKey); metadata != `{"started":"2021-01-01T00:00:00Z","tool":"v1.0.0"}` {
+		t.Errorf("ParquetLogger metadata = %s", metadata)
+	}
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// QueryStats are the stats of every run of a single query.
+type QueryStats struct {
+	Query  string  `json:"query"`
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	Min    float64 `json:"min_ms"`
+	Median float64 `json:"median_ms"`
+	P95    float64 `json:"p95_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+// QueryStatsSorts are the columns a QueryStats table can be sorted by, in descending order.
+var QueryStatsSorts = map[string]func(a, b QueryStats) bool{
+	"count":  func(a, b QueryStats) bool { return a.Count > b.Count },
+	"errors": func(a, b QueryStats) bool { return a.Errors > b.Errors },
+	"min":    func(a, b QueryStats) bool { return a.Min > b.Min },
+	"median": func(a, b QueryStats) bool { return a.Median > b.Median },
+	"p95":    func(a, b QueryStats) bool { return a.P95 > b.P95 },
+	"max":    func(a, b QueryStats) bool { return a.Max > b.Max },
+}
+
+// QueryTable is a runner.Recorder collecting the latencies of every query, so queries run
+// several times (e.g. with --repeat) can be ranked.
+type QueryTable struct {
+	mu sync.Mutex
+	// rows holds the latencies (in milliseconds) and errors of every query by its query.Query Key
+	rows  map[string]*queryRow
+	order []string
+}
+
+type queryRow struct {
+	query     string
+	latencies []float64
+	errors    int
+}
+
+func NewQueryTable() *QueryTable {
+	return &QueryTable{rows: map[string]*queryRow{}}
+}
+
+func (t *QueryTable) Record(r *runner.Result) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := r.Query.Key()
+	row, ok := t.rows[key]
+	if !ok {
+		row = &queryRow{query: r.Query.Query}
+		if !r.Query.RangeQuery() {
+			row.query = "/api/v1/" + r.Query.Endpoint + " " + r.Query.Query
+		}
+		t.rows[key] = row
+		t.order = append(t.order, key)
+	}
+	if r.Err != nil {
+		row.errors++
+	} else {
+		row.latencies = append(row.latencies, float64(r.End.Sub(r.Start))/float64(time.Millisecond))
+	}
+	return nil
+}
+
+// Repeated reports whether any query was recorded more than once.
+func (t *QueryTable) Repeated() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, row := range t.rows {
+		if len(row.latencies)+row.errors > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the stats of every query sorted by the given column of QueryStatsSorts, or in the
+// order they were first recorded if empty.
+func (t *QueryTable) Stats(sortBy string) ([]QueryStats, error) {
+	less, ok := QueryStatsSorts[sortBy]
+	if sortBy != "" && !ok {
+		return nil, fmt.Errorf("unknown sort column %q", sortBy)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	qs := make([]QueryStats, len(t.order))
+	for i, key := range t.order {
+		row := t.rows[key]
+		latencies := append([]float64(nil), row.latencies...)
+		qs[i] = QueryStats{
+			Query:  row.query,
+			Count:  len(row.latencies) + row.errors,
+			Errors: row.errors,
+			Min:    stats.Quantile(latencies, 0),
+			Median: stats.Quantile(latencies, 0.5),
+			P95:    stats.Quantile(latencies, 0.95),
+			Max:    stats.Quantile(latencies, 1),
+		}
+	}
+	if less != nil {
+		sort.SliceStable(qs, func(i, j int) bool { return less(qs[i], qs[j]) })
+	}
+	return qs, nil
+}
+
+// RenderQueryStats renders the stats of every query as a table.
+func RenderQueryStats(w io.Writer, qs []QueryStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUERY\tCOUNT\tMIN\tMEDIAN\tP95\tMAX\tERRORS")
+	for _, q := range qs {
+		fmt.Fprintf(tw, "%s\t%d\t%.2fms\t%.2fms\t%.2fms\t%.2fms\t%d\n", q.Query, q.Count, q.Min, q.Median, q.P95, q.Max, q.Errors)
+	}
+	return tw.Flush()
+}
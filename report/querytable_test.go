@@ -0,0 +1,58 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestQueryTable_Stats(t *testing.T) {
+	start := time.UnixMilli(0)
+	result := func(expr string, ms int, err error) *runner.Result {
+		return &runner.Result{Query: query.Query{Query: expr}, Start: start, End: start.Add(time.Duration(ms) * time.Millisecond), Err: err}
+	}
+
+	table := NewQueryTable()
+	table.Record(result("fast", 1, nil))
+	if table.Repeated() {
+		t.Errorf("QueryTable.Repeated() = true after a single run")
+	}
+	for _, r := range []*runner.Result{
+		result("slow", 100, nil), result("fast", 3, nil), result("slow", 300, nil),
+		result("fast", 2, nil), result("slow", 0, errors.New("failed")),
+	} {
+		table.Record(r)
+	}
+	if !table.Repeated() {
+		t.Errorf("QueryTable.Repeated() = false after several runs")
+	}
+
+	got, err := table.Stats("median")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []QueryStats{
+		{Query: "slow", Count: 3, Errors: 1, Min: 100, Median: 100, P95: 300, Max: 300},
+		{Query: "fast", Count: 3, Min: 1, Median: 2, P95: 3, Max: 3},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("QueryTable.Stats() = %+v, want %+v", got, want)
+	}
+
+	if _, err := table.Stats("slowness"); err == nil {
+		t.Errorf("QueryTable.Stats() of an unknown column error = nil, want an error")
+	}
+
+	var buf bytes.Buffer
+	if err := RenderQueryStats(&buf, got); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[1], "slow") {
+		t.Errorf("RenderQueryStats() = %v, want a header and the slow query first", buf.String())
+	}
+}
@@ -0,0 +1,218 @@
+// Package report renders and persists the outcome of benchmark runs.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/noelruault/pqlbench/loader"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/scrape"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// Summary is the machine readable outcome of a benchmark run.
+type Summary struct {
+	// Target is the URL of the benchmarked server
+	Target string `json:"target"`
+	// Calibration is the median latency in milliseconds of the calibration query, used as a
+	// baseline to normalize results across different hardware/targets
+	Calibration float64 `json:"calibration_ms,omitempty"`
+	// Cache holds the stats of the cold and warm runs of the queries, if run in cache-compare mode
+	Cache []GroupStats `json:"cache,omitempty"`
+	// Canary compares the canary to the stable targets, if the queries were split across targets
+	// by weight
+	Canary *Canary `json:"canary,omitempty"`
+	// Comparison of every query over PromQL and SQL, if run in compare mode
+	Comparison []QueryComparison `json:"comparison,omitempty"`
+	// Consumption of the corpus, if the run was stopped early or resumed
+	Consumption *runner.Consumption `json:"consumption,omitempty"`
+	// Coverage of the corpus across sampled runs, if sampling is enabled
+	Coverage *loader.Coverage `json:"coverage,omitempty"`
+	// Features holds the stats of the queries using every PromQL feature, e.g. subqueries
+	Features []GroupStats `json:"features,omitempty"`
+	// FindMax holds the load levels run while searching the maximum load sustained under the SLO
+	FindMax *runner.SearchResult `json:"find_max,omitempty"`
+	// Metadata describes the tool, target and configuration of the run
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Nondeterministic holds the queries whose executions returned different results, if fingerprinted
+	Nondeterministic []NondeterministicQuery `json:"nondeterministic,omitempty"`
+	// Outages holds the times the target was down and the queries paused, if a circuit breaker
+	// was set
+	Outages []runner.Outage `json:"outages,omitempty"`
+	// Queries holds the stats of every query, if any ran more than once
+	Queries []QueryStats `json:"queries,omitempty"`
+	// Ranges holds the stats of the queries of every bucket of time range length, see
+	// query.RangeBuckets
+	Ranges []GroupStats `json:"ranges,omitempty"`
+	// Retries tells the transient failures from the persistent ones, if the failed queries were
+	// retried
+	Retries *runner.Retries `json:"retries,omitempty"`
+	// Runs holds the stats of every run, if the benchmark was repeated, and RunStatistics the mean
+	// and standard deviation of their statistics
+	Runs          []*stats.Stats `json:"runs,omitempty"`
+	RunStatistics []RunStatistic `json:"run_statistics,omitempty"`
+	// Server holds the metrics sampled from the target during the run, if enabled
+	Server []scrape.Sample `json:"server,omitempty"`
+	// Split holds the stats of the chunks of the queries split and of those queries reassembled, if
+	// split
+	Split []GroupStats `json:"split,omitempty"`
+	// Stabilization is the time spent warming up the target before measuring, if enabled
+	Stabilization time.Duration `json:"stabilization_ns,omitempty"`
+	// Stabilized is false when the target did not stabilize before the warm-up timed out
+	Stabilized bool `json:"stabilized,omitempty"`
+	// Stages holds the stats of every stage of the load profile, if any
+	Stages []runner.StageStats `json:"stages,omitempty"`
+	Stats  *stats.Stats        `json:"stats"`
+	// Steps holds the stats of the range queries of every bucket of step, see query.StepBuckets
+	Steps []GroupStats `json:"steps,omitempty"`
+	// Tags holds the stats of the queries of every tag, if any query is tagged
+	Tags []GroupStats `json:"tags,omitempty"`
+	// Targets holds the stats of the queries sent to every target, if distributed across several
+	Targets []GroupStats `json:"targets,omitempty"`
+}
+
+func (s *Summary) ToString() (output string) {
+	if s.Metadata != nil {
+		output += s.Metadata.ToString()
+	}
+	output += s.Stats.ToString()
+	if len(s.RunStatistics) > 0 {
+		output += fmt.Sprintf("Across %d runs:\n", len(s.Runs))
+		for _, statistic := range s.RunStatistics {
+			output += statistic.ToString()
+		}
+	}
+	if s.Stabilization > 0 {
+		if s.Stabilized {
+			output += fmt.Sprintf("Target stabilized after: %dms\n", s.Stabilization.Milliseconds())
+		} else {
+			output += fmt.Sprintf("Target did not stabilize, measured after: %dms\n", s.Stabilization.Milliseconds())
+		}
+	}
+	for _, stage := range s.Stages {
+		output += fmt.Sprintf("Stage %s: %d queries processed, median %fms, average %fms, %d errors\n",
+			stage.Name, stage.Stats.Processed, stage.Stats.Median, stage.Stats.Average, stage.Stats.Errors.Total())
+	}
+	for _, run := range s.Cache {
+		output += run.toString("Cache")
+	}
+	for _, target := range s.Targets {
+		output += target.toString("Target")
+	}
+	if s.Canary != nil {
+		output += s.Canary.ToString()
+	}
+	for _, group := range s.Split {
+		output += group.toString("Split")
+	}
+	for _, tag := range s.Tags {
+		output += tag.toString("Tag")
+	}
+	for _, feature := range s.Features {
+		output += feature.toString("Feature")
+	}
+	for _, bucket := range s.Ranges {
+		output += bucket.toString("Range")
+	}
+	for _, bucket := range s.Steps {
+		output += bucket.toString("Step")
+	}
+	for _, sample := range s.Server {
+		output += sample.ToString()
+	}
+	if s.FindMax != nil {
+		output += s.FindMax.ToString()
+	}
+	if s.Consumption != nil {
+		output += s.Consumption.ToString()
+	}
+	if s.Coverage != nil {
+		output += s.Coverage.ToString()
+	}
+	for _, outage := range s.Outages {
+		output += outage.ToString()
+	}
+	if s.Retries != nil {
+		output += s.Retries.ToString()
+	}
+	for _, q := range s.Nondeterministic {
+		output += q.ToString()
+	}
+	if len(s.Queries) > 0 {
+		var b strings.Builder
+		RenderQueryStats(&b, s.Queries)
+		output += b.String()
+	}
+	if len(s.Comparison) > 0 {
+		var b strings.Builder
+		Compare(&b, s.Comparison)
+		output += b.String()
+	}
+	return
+}
+
+func (s *Summary) Write(path string) error {
+	return writeJSON(path, s)
+}
+
+// writeJSON writes the given value to the given path as indented JSON.
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+func ReadSummary(path string) (*Summary, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Summary
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("unable to decode summary %s. err=%w", path, err)
+	}
+	if s.Stats == nil {
+		return nil, fmt.Errorf("summary %s holds no stats", path)
+	}
+	return &s, nil
+}
+
+// Merge renders the given summaries side by side. Latencies are also shown normalized by the
+// calibration score of every run (i.e. as multiples of its baseline latency), so runs against
+// different hardware/targets can be compared.
+func Merge(w io.Writer, paths []string, summaries []*Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN\tTARGET\tPROCESSED\tCALIBRATION\tMEDIAN\tAVERAGE\tSLOWEST\tNORM MEDIAN\tNORM AVERAGE\tNORM SLOWEST")
+
+	var processed int
+	var normMedian, normAverage float64
+	for i, s := range summaries {
+		if s.Calibration <= 0 {
+			return fmt.Errorf("summary %s has no calibration score, run the benchmark with --calibrate", paths[i])
+		}
+
+		st := s.Stats
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.2fms\t%.2fms\t%.2fms\t%dms\t%.2fx\t%.2fx\t%.2fx\n",
+			paths[i], s.Target, st.Processed, s.Calibration, st.Median, st.Average, st.Slowest,
+			st.Median/s.Calibration, st.Average/s.Calibration, float64(st.Slowest)/s.Calibration)
+
+		processed += st.Processed
+		normMedian += st.Median / s.Calibration * float64(st.Processed)
+		normAverage += st.Average / s.Calibration * float64(st.Processed)
+	}
+	if processed > 0 {
+		fmt.Fprintf(tw, "merged\t\t%d\t\t\t\t\t%.2fx\t%.2fx\t\n", processed,
+			normMedian/float64(processed), normAverage/float64(processed))
+	}
+
+	return tw.Flush()
+}
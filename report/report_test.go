@@ -0,0 +1,78 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		summaries []*Summary
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "normalized by calibration",
+			summaries: []*Summary{
+				{Target: "http://a", Calibration: 2, Stats: &stats.Stats{Processed: 10, Median: 20, Average: 30, Slowest: 40}},
+				{Target: "http://b", Calibration: 4, Stats: &stats.Stats{Processed: 30, Median: 20, Average: 40, Slowest: 40}},
+			},
+			want: []string{"10.00x", "15.00x", "20.00x", "5.00x", "10.00x", "6.25x", "11.25x"},
+		},
+		{
+			name: "missing calibration",
+			summaries: []*Summary{
+				{Target: "http://a", Stats: &stats.Stats{Processed: 10}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := make([]string, len(tt.summaries))
+			for i := range paths {
+				paths[i] = fmt.Sprintf("run%d.json", i)
+			}
+
+			var buf bytes.Buffer
+			err := Merge(&buf, paths, tt.summaries)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Merge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Merge() = %v, want it to contain %v", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestSummary_roundTrip(t *testing.T) {
+	path := t.TempDir() + "/summary.json"
+	want := &Summary{Target: "http://a", Calibration: 1.5, Stats: &stats.Stats{Processed: 3, Median: 2}}
+	want.Stats.Errors.Add(&client.StatusError{StatusCode: 500})
+
+	if err := want.Write(path); err != nil {
+		t.Fatalf("Summary.Write() error = %v", err)
+	}
+	got, err := ReadSummary(path)
+	if err != nil {
+		t.Fatalf("ReadSummary() error = %v", err)
+	}
+	if got.Stats.Errors.Counts[client.ErrorServerStatus] != 1 || got.Stats.Errors.Samples[client.ErrorServerStatus].Error() != want.Stats.Errors.Samples[client.ErrorServerStatus].Error() {
+		t.Errorf("ReadSummary() errors = %v, want %v", got.Stats.Errors, want.Stats.Errors)
+	}
+	got.Stats.Errors, want.Stats.Errors = stats.ErrorSummary{}, stats.ErrorSummary{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSummary() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// RequestEvent is a single line of the NDJSON request log.
+type RequestEvent struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	Scheduled    time.Time      `json:"scheduled,omitzero"`
+	Query        string         `json:"query"`
+	Endpoint     string         `json:"endpoint,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	Start        int64          `json:"start"`
+	End          int64          `json:"end"`
+	StartAgo     *time.Duration `json:"start_ago,omitempty"`
+	EndAgo       *time.Duration `json:"end_ago,omitempty"`
+	Step         int            `json:"step"`
+	LatencyMs    float64        `json:"latency_ms"`
+	FirstByteMs  float64        `json:"first_byte_ms,omitempty"`
+	TransferMs   float64        `json:"transfer_ms,omitempty"`
+	DecodeMs     float64        `json:"decode_ms,omitempty"`
+	Status       int            `json:"status"`
+	Bytes        int64          `json:"bytes"`
+	Compressed   int64          `json:"compressed_bytes,omitempty"`
+	DecompressMs float64        `json:"decompress_ms,omitempty"`
+	Exemplars    int            `json:"exemplars,omitempty"`
+	Series       int            `json:"series,omitempty"`
+	Fingerprint  string         `json:"fingerprint,omitempty"`
+	RequestID    string         `json:"request_id,omitempty"`
+	TraceID      string         `json:"trace_id,omitempty"`
+	SpanID       string         `json:"span_id,omitempty"`
+	Worker       int            `json:"worker"`
+	Error        string         `json:"error,omitempty"`
+	ErrorClass   string         `json:"error_class,omitempty"`
+	Assertion    string         `json:"assertion,omitempty"`
+}
+
+// NewRequestEvent builds the RequestEvent logged for a Result.
+func NewRequestEvent(r *runner.Result) *RequestEvent {
+	event := &RequestEvent{
+		Timestamp:    r.Start,
+		Scheduled:    r.Scheduled,
+		Query:        r.Query.Query,
+		Endpoint:     r.Query.Endpoint,
+		Tags:         r.Query.Tags,
+		Start:        r.Query.Start,
+		End:          r.Query.End,
+		StartAgo:     r.Query.StartAgo,
+		EndAgo:       r.Query.EndAgo,
+		Step:         r.Query.Step,
+		LatencyMs:    float64(r.End.Sub(r.Start)) / float64(time.Millisecond),
+		FirstByteMs:  float64(r.FirstByte) / float64(time.Millisecond),
+		TransferMs:   float64(r.Transfer) / float64(time.Millisecond),
+		DecodeMs:     float64(r.Decode) / float64(time.Millisecond),
+		Status:       r.Status,
+		Bytes:        r.Bytes,
+		Compressed:   r.Compressed,
+		DecompressMs: float64(r.Decompress) / float64(time.Millisecond),
+		Exemplars:    r.Exemplars,
+		Series:       r.Series,
+		Worker:       r.Worker,
+		Fingerprint:  r.Fingerprint,
+		RequestID:    r.RequestID,
+		TraceID:      r.TraceID,
+		SpanID:       r.SpanID,
+		Assertion:    r.Assertion,
+	}
+	if r.Err != nil {
+		event.Error = r.Err.Error()
+		event.ErrorClass = string(client.Classify(r.Err))
+	}
+	return event
+}
+
+// Result rebuilds the Result a RequestEvent was logged for.
+func (e *RequestEvent) Result() *runner.Result {
+	r := &runner.Result{
+		Query:       query.Query{Query: e.Query, Start: e.Start, End: e.End, StartAgo: e.StartAgo, EndAgo: e.EndAgo, Step: e.Step, Endpoint: e.Endpoint, Tags: e.Tags},
+		Worker:      e.Worker,
+		Scheduled:   e.Scheduled,
+		Start:       e.Timestamp,
+		End:         e.Timestamp.Add(time.Duration(e.LatencyMs * float64(time.Millisecond))),
+		FirstByte:   time.Duration(e.FirstByteMs * float64(time.Millisecond)),
+		Transfer:    time.Duration(e.TransferMs * float64(time.Millisecond)),
+		Status:      e.Status,
+		Bytes:       e.Bytes,
+		Compressed:  e.Compressed,
+		Decode:      time.Duration(e.DecodeMs * float64(time.Millisecond)),
+		Decompress:  time.Duration(e.DecompressMs * float64(time.Millisecond)),
+		Exemplars:   e.Exemplars,
+		Series:      e.Series,
+		Assertion:   e.Assertion,
+		Fingerprint: e.Fingerprint,
+		RequestID:   e.RequestID,
+		TraceID:     e.TraceID,
+		SpanID:      e.SpanID,
+	}
+	if e.Error != "" {
+		r.Err = &client.ClassError{Class: client.ErrorClass(e.ErrorClass), Message: e.Error}
+	}
+	return r
+}
+
+// RequestLogger is a runner.Recorder writing one JSON object per request (NDJSON), so results can
+// be streamed into other tools while the benchmark is still running.
+type RequestLogger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewRequestLogger(w io.Writer) *RequestLogger {
+	return &RequestLogger{enc: json.NewEncoder(w)}
+}
+
+func (l *RequestLogger) Record(r *runner.Result) error {
+	event := NewRequestEvent(r)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(event)
+}
+
+// WriteMetadata logs the metadata of the run, as a line holding a single metadata object that
+// ReadRequestLog skips.
+func (l *RequestLogger) WriteMetadata(m *Metadata) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(struct {
+		Metadata *Metadata `json:"metadata"`
+	}{m})
+}
+
+// ReadRequestLog reads the results logged by a RequestLogger.
+func ReadRequestLog(r io.Reader) ([]runner.Result, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var results []runner.Result
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event struct {
+			Metadata *Metadata `json:"metadata"`
+			RequestEvent
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("unable to decode request event at line %d. err=%w", line, err)
+		}
+		if event.Metadata != nil {
+			continue
+		}
+		results = append(results, *event.Result())
+	}
+	return results, scanner.Err()
+}
+
+// Span returns the wall clock time elapsed from the first request to the end of the last one.
+// Requests without a Start or End, e.g. failures logged by older versions, are left out.
+func Span(results []runner.Result) time.Duration {
+	var first, last time.Time
+	for _, r := range results {
+		if !r.Start.IsZero() && (first.IsZero() || r.Start.Before(first)) {
+			first = r.Start
+		}
+		if r.End.After(last) {
+			last = r.End
+		}
+	}
+	if first.IsZero() || last.IsZero() {
+		return 0
+	}
+	return last.Sub(first)
+}
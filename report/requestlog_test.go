@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestRequestLogger_Record(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		result *runner.Result
+		want   string
+	}{
+		{
+			name: "OK",
+			result: &runner.Result{
+				Query:  query.Query{Query: "up", Start: 1, End: 2, Step: 3},
+				Worker: 4,
+				Start:  start,
+				End:    start.Add(1500 * time.Microsecond),
+				Status: 200,
+				Bytes:  42,
+			},
+			want: `{"timestamp":"2021-01-01T00:00:00Z","query":"up","start":1,"end":2,"step":3,"latency_ms":1.5,"status":200,"bytes":42,"worker":4}` + "\n",
+		},
+		{
+			name: "error",
+			result: &runner.Result{
+				Query:  query.Query{Query: "up", Start: 1, End: 2, Step: 3},
+				Start:  start,
+				End:    start.Add(time.Millisecond),
+				Status: 503,
+				Err:    &client.StatusError{StatusCode: 503},
+			},
+			want: `{"timestamp":"2021-01-01T00:00:00Z","query":"up","start":1,"end":2,"step":3,"latency_ms":1,"status":503,"bytes":0,"worker":0,"error":"unexpected response status code: 503","error_class":"5xx"}` + "\n",
+		},
+		{
+			name: "assertion failure",
+			result: &runner.Result{
+				Query:     query.Query{Query: "up", Start: 1, End: 2, Step: 3, MinSeries: 2},
+				Start:     start,
+				End:       start.Add(time.Millisecond),
+				Status:    200,
+				Series:    1,
+				Assertion: "got 1 series, want at least 2",
+			},
+			want: `{"timestamp":"2021-01-01T00:00:00Z","query":"up","start":1,"end":2,"step":3,"latency_ms":1,"status":200,"bytes":0,"series":1,"worker":0,"assertion":"got 1 series, want at least 2"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := NewRequestLogger(&buf).Record(tt.result); err != nil {
+				t.Errorf("RequestLogger.Record() error = %v", err)
+				return
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("RequestLogger.Record() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// RefusedQuerierMock fails every query after Delay as if the target refused the connection.
+type RefusedQuerierMock struct {
+	Delay time.Duration
+}
+
+func (m *RefusedQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	time.Sleep(m.Delay)
+	return nil, fmt.Errorf("dial. err=%w", syscall.ECONNREFUSED)
+}
+
+func TestRequestLogger_Record_refused(t *testing.T) {
+	var buf bytes.Buffer
+	before := time.Now()
+	r := &runner.Runner{Client: &RefusedQuerierMock{Delay: 2 * time.Millisecond}, Workers: 1, Recorders: []runner.Recorder{NewRequestLogger(&buf)}}
+	r.Run([]query.Query{{Query: "up"}})
+
+	var event RequestEvent
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Timestamp.Before(before) || event.LatencyMs < 2 || event.ErrorClass != string(client.ErrorConnectionRefused) {
+		t.Errorf("RequestLogger.Record() = %s, want the time and latency of the refused request", buf.String())
+	}
+}
+
+func TestSpan(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		results []runner.Result
+		want    time.Duration
+	}{
+		{name: "no results", want: 0},
+		{name: "results", results: []runner.Result{{Start: start.Add(time.Second), End: start.Add(3 * time.Second)}, {Start: start, End: start.Add(time.Second)}}, want: 3 * time.Second},
+		{name: "untimed failure", results: []runner.Result{{Start: start, End: start.Add(time.Second)}, {Err: errors.New("connection refused")}}, want: time.Second},
+		{name: "only untimed failures", results: []runner.Result{{Err: errors.New("connection refused")}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Span(tt.results); got != tt.want {
+				t.Errorf("Span() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRequestLog(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	ago := time.Hour
+	want := []runner.Result{
+		{Query: query.Query{Query: "up", Start: 1, End: 2, Step: 3}, Worker: 1, Start: start, End: start.Add(2 * time.Millisecond), Status: 200, Bytes: 10, RequestID: "c0ffee00-0000-4000-8000-000000000000", TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331"},
+		{Query: query.Query{Query: "up", Start: 1, End: 2, StartAgo: &ago, EndAgo: new(time.Duration), Step: 3}, Worker: 2, Start: start.Add(time.Millisecond), End: start.Add(5 * time.Millisecond), Status: 503, Err: &client.StatusError{StatusCode: 503}},
+	}
+
+	var buf bytes.Buffer
+	logger := NewRequestLogger(&buf)
+	if err := logger.WriteMetadata(&Metadata{Started: start, Tool: "v1.0.0"}); err != nil {
+		t.Fatalf("RequestLogger.WriteMetadata() error = %v", err)
+	}
+	for i := range want {
+		logger.Record(&want[i])
+	}
+
+	got, err := ReadRequestLog(&buf)
+	if err != nil {
+		t.Fatalf("ReadRequestLog() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadRequestLog() read %d results, want %d", len(got), len(want))
+	}
+	if client.Classify(got[1].Err) != client.ErrorServerStatus || got[1].Err.Error() != want[1].Err.Error() {
+		t.Errorf("ReadRequestLog() error = %v, want %v", got[1].Err, want[1].Err)
+	}
+	if !got[1].Start.Equal(want[1].Start) || !got[1].End.Equal(want[1].End) || got[0].Worker != 1 || got[0].Bytes != 10 {
+		t.Errorf("ReadRequestLog() = %v, want %v", got, want)
+	}
+	if got[0].RequestID != want[0].RequestID || got[0].TraceID != want[0].TraceID || got[0].SpanID != want[0].SpanID {
+		t.Errorf("ReadRequestLog() trace context = %q %q %q, want %q %q %q", got[0].RequestID, got[0].TraceID, got[0].SpanID, want[0].RequestID, want[0].TraceID, want[0].SpanID)
+	}
+	if got[1].Query.Key() != want[1].Query.Key() {
+		t.Errorf("ReadRequestLog() query = %v, want %v", got[1].Query, want[1].Query)
+	}
+	if span := Span(got); span != 5*time.Millisecond {
+		t.Errorf("Span() = %v, want 5ms", span)
+	}
+}
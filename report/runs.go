@@ -0,0 +1,62 @@
+package report
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// RunStatistic is the mean and the (sample) standard deviation of a statistic across the runs of a
+// benchmark repeated several times.
+type RunStatistic struct {
+	Name   string  `json:"name"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+}
+
+func (s RunStatistic) ToString() string {
+	var cv float64
+	if s.Mean != 0 {
+		cv = s.StdDev / math.Abs(s.Mean)
+	}
+	return fmt.Sprintf("  %s: mean %f, standard deviation %f (%.2f%%)\n", s.Name, s.Mean, s.StdDev, cv*100)
+}
+
+// runStatistics are the statistics of every run compared across runs, named like their JSON field.
+var runStatistics = []struct {
+	name  string
+	value func(s *stats.Stats) float64
+}{
+	{"processed", func(s *stats.Stats) float64 { return float64(s.Processed) }},
+	{"fastest_ms", func(s *stats.Stats) float64 { return float64(s.Fastest) }},
+	{"median_ms", func(s *stats.Stats) float64 { return s.Median }},
+	{"average_ms", func(s *stats.Stats) float64 { return s.Average }},
+	{"trimmed_mean_ms", func(s *stats.Stats) float64 { return s.TrimmedMean }},
+	{"stddev_ms", func(s *stats.Stats) float64 { return s.StdDev }},
+	{"slowest_ms", func(s *stats.Stats) float64 { return float64(s.Slowest) }},
+	{"throughput_qps", func(s *stats.Stats) float64 { return s.Throughput }},
+	{"success_rate", func(s *stats.Stats) float64 { return s.SuccessRate }},
+}
+
+// NewRunStatistics returns the mean and standard deviation of the statistics of the given runs.
+func NewRunStatistics(runs []*stats.Stats) []RunStatistic {
+	if len(runs) < 2 {
+		return nil
+	}
+
+	statistics := make([]RunStatistic, len(runStatistics))
+	for i, rs := range runStatistics {
+		var mean, variance float64
+		for _, run := range runs {
+			mean += rs.value(run)
+		}
+		mean /= float64(len(runs))
+		for _, run := range runs {
+			variance += math.Pow(rs.value(run)-mean, 2)
+		}
+		variance /= float64(len(runs) - 1)
+		statistics[i] = RunStatistic{Name: rs.name, Mean: mean, StdDev: math.Sqrt(variance)}
+	}
+	return statistics
+}
@@ -0,0 +1,37 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func TestNewRunStatistics(t *testing.T) {
+	runs := []*stats.Stats{
+		{Processed: 10, Median: 10, Slowest: 30},
+		{Processed: 10, Median: 12, Slowest: 30},
+		{Processed: 10, Median: 14, Slowest: 30},
+	}
+
+	got := map[string]RunStatistic{}
+	for _, s := range NewRunStatistics(runs) {
+		got[s.Name] = s
+	}
+	want := map[string]RunStatistic{
+		"processed":  {Name: "processed", Mean: 10},
+		"median_ms":  {Name: "median_ms", Mean: 12, StdDev: 2},
+		"slowest_ms": {Name: "slowest_ms", Mean: 30},
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("NewRunStatistics() %s = %+v, want %+v", name, got[name], w)
+		}
+	}
+	if want := "  median_ms: mean 12.000000, standard deviation 2.000000 (16.67%)\n"; got["median_ms"].ToString() != want {
+		t.Errorf("RunStatistic.ToString() = %q, want %q", got["median_ms"].ToString(), want)
+	}
+
+	if got := NewRunStatistics(runs[:1]); got != nil {
+		t.Errorf("NewRunStatistics() of a single run = %v, want none", got)
+	}
+}
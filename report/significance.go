@@ -0,0 +1,105 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"text/tabwriter"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// StatisticDelta is the difference of a statistic of the query times between a baseline and a
+// candidate run, along with its confidence interval and p-value estimated by bootstrapping.
+type StatisticDelta struct {
+	Name      string  `json:"name"`
+	Baseline  float64 `json:"baseline_ms"`
+	Candidate float64 `json:"candidate_ms"`
+	// Low and High bound the confidence interval of the difference in milliseconds
+	Low  float64 `json:"low_ms"`
+	High float64 `json:"high_ms"`
+	P    float64 `json:"p_value"`
+}
+
+// Significance tells whether the query times of a candidate run differ from those of a baseline
+// beyond the noise: the Mann-Whitney U test compares their whole distributions, and every statistic
+// is given with the confidence interval of its difference.
+type Significance struct {
+	// Level is the confidence level, e.g. 0.95
+	Level      float64          `json:"level"`
+	U          float64          `json:"u"`
+	P          float64          `json:"p_value"`
+	Statistics []StatisticDelta `json:"statistics"`
+}
+
+// significanceStatistics are the statistics of the query times compared.
+var significanceStatistics = []struct {
+	name  string
+	value func(values []float64) float64
+}{
+	{"median", func(values []float64) float64 { return stats.Quantile(values, 0.5) }},
+	{"average", stats.Mean},
+	{"p95", func(values []float64) float64 { return stats.Quantile(values, 0.95) }},
+	{"p99", func(values []float64) float64 { return stats.Quantile(values, 0.99) }},
+}
+
+// NewSignificance compares the query times of the successful results of the candidate run to those
+// of the baseline at the given confidence level, bootstrapping the statistics over the given
+// number of resamples drawn from rnd.
+func NewSignificance(baseline, candidate []runner.Result, level float64, resamples int, rnd *rand.Rand) *Significance {
+	a, b := successLatencies(baseline), successLatencies(candidate)
+	s := &Significance{Level: level}
+	s.U, s.P = stats.MannWhitney(a, b)
+	for _, statistic := range significanceStatistics {
+		low, high, p := stats.Bootstrap(a, b, statistic.value, resamples, level, rnd)
+		s.Statistics = append(s.Statistics, StatisticDelta{
+			Name:      statistic.name,
+			Baseline:  statistic.value(a),
+			Candidate: statistic.value(b),
+			Low:       low,
+			High:      high,
+			P:         p,
+		})
+	}
+	return s
+}
+
+// successLatencies returns the query times in milliseconds of the successful results.
+func successLatencies(results []runner.Result) []float64 {
+	var latencies []float64
+	for _, r := range results {
+		if r.Err == nil {
+			latencies = append(latencies, float64(r.End.Sub(r.Start).Microseconds())/1000)
+		}
+	}
+	return latencies
+}
+
+// Render writes the outcome of the tests, marking the differences that are not significant, i.e.
+// could be noise, with a ~.
+func (s *Significance) Render(w io.Writer) error {
+	verdict := "not significantly different"
+	if s.P < 1-s.Level {
+		verdict = "significantly different"
+	}
+	fmt.Fprintf(w, "Mann-Whitney U test of the query times: U=%.1f, p=%.4f, %s at the %g%% level\n", s.U, s.P, verdict, s.Level*100)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STATISTIC\tBASELINE\tCANDIDATE\tDELTA\t%g%% CI\tP-VALUE\n", s.Level*100)
+	for _, d := range s.Statistics {
+		var delta string
+		if d.Baseline != 0 {
+			delta = fmt.Sprintf("%+.2f%%", (d.Candidate-d.Baseline)/d.Baseline*100)
+		}
+		if d.P >= 1-s.Level {
+			delta = "~" + delta
+		}
+		fmt.Fprintf(tw, "%s\t%.2fms\t%.2fms\t%s\t[%+.2fms, %+.2fms]\t%.4f\n", d.Name, d.Baseline, d.Candidate, delta, d.Low, d.High, d.P)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "~ marks the differences that are not significant at the %g%% level\n", s.Level*100)
+	return err
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestNewSignificance(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := func(base int) []runner.Result {
+		var results []runner.Result
+		for i := range 50 {
+			results = append(results, runner.Result{Start: start, End: start.Add(time.Duration(base+i%10) * time.Millisecond)})
+		}
+		// Failed queries are left out
+		return append(results, runner.Result{Start: start, End: start.Add(time.Second), Err: errors.New("timeout")})
+	}
+	baseline := results(10)
+
+	slower := NewSignificance(baseline, results(20), 0.95, 500, rand.New(rand.NewSource(1)))
+	if slower.P >= 0.05 {
+		t.Errorf("NewSignificance() of a slower candidate p = %v, want below 0.05", slower.P)
+	}
+	median := slower.Statistics[0]
+	if median.Name != "median" || median.Baseline != 14 || median.Candidate != 24 || median.P >= 0.05 {
+		t.Errorf("NewSignificance() of a slower candidate median = %+v, want 14ms and 24ms, significant", median)
+	}
+
+	same := NewSignificance(baseline, results(10), 0.95, 500, rand.New(rand.NewSource(1)))
+	var buf bytes.Buffer
+	if err := same.Render(&buf); err != nil {
+		t.Fatalf("Significance.Render() error = %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "not significantly different at the 95% level") || !strings.Contains(out, "~+0.00%") {
+		t.Errorf("Significance.Render() of the same run = %q, want no significant difference", out)
+	}
+}
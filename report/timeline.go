@@ -0,0 +1,150 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// TimelinePoint aggregates the queries completed within a second of a run. Latencies are those of
+// the successful queries in milliseconds.
+type TimelinePoint struct {
+	Time     time.Time `json:"time"`
+	Requests int       `json:"requests"`
+	Errors   int       `json:"errors"`
+	Average  float64   `json:"average_ms"`
+	Median   float64   `json:"median_ms"`
+	P99      float64   `json:"p99_ms"`
+	Slowest  float64   `json:"slowest_ms"`
+}
+
+// Timeline is a runner.Recorder aggregating the Results by the second they completed in, so the
+// evolution of the latency, throughput and errors over the run can be told apart from the stats of
+// the whole run, e.g. a target slowing down as its caches fill.
+type Timeline struct {
+	mu      sync.Mutex
+	seconds map[int64]*timelineSecond
+}
+
+type timelineSecond struct {
+	latencies []float64
+	errors    int
+}
+
+func NewTimeline() *Timeline {
+	return &Timeline{seconds: map[int64]*timelineSecond{}}
+}
+
+func (t *Timeline) Record(r *runner.Result) error {
+	// Results rebuilt from logs of older versions lack the End of the queries failing without a
+	// response, which are accounted when they were due instead, or left out if not known
+	end := r.End
+	if end.IsZero() {
+		end = r.Scheduled
+	}
+	if end.IsZero() {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	second, ok := t.seconds[end.Unix()]
+	if !ok {
+		second = &timelineSecond{}
+		t.seconds[end.Unix()] = second
+	}
+	if r.Err != nil {
+		second.errors++
+	} else {
+		second.latencies = append(second.latencies, float64(r.End.Sub(r.Start).Milliseconds()))
+	}
+	return nil
+}
+
+// Points returns a point for every second from the first to the last one a query completed in,
+// including those without any, e.g. while the target stalled.
+func (t *Timeline) Points() []TimelinePoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.seconds) == 0 {
+		return nil
+	}
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for s := range t.seconds {
+		first, last = min(first, s), max(last, s)
+	}
+
+	points := make([]TimelinePoint, 0, last-first+1)
+	for s := first; s <= last; s++ {
+		point := TimelinePoint{Time: time.Unix(s, 0).UTC()}
+		if second, ok := t.seconds[s]; ok {
+			point.Requests = len(second.latencies) + second.errors
+			point.Errors = second.errors
+			if len(second.latencies) > 0 {
+				var sum float64
+				for _, l := range second.latencies {
+					sum += l
+				}
+				point.Average = sum / float64(len(second.latencies))
+				point.Median = stats.Quantile(second.latencies, 0.5)
+				point.P99 = stats.Quantile(second.latencies, 0.99)
+				point.Slowest = stats.Quantile(second.latencies, 1)
+			}
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+// Write writes the points of the timeline to the given path, as a JSON array if its extension is
+// .json or as CSV with a header otherwise.
+func (t *Timeline) Write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(path, ".json") {
+		err = json.NewEncoder(f).Encode(t.Points())
+	} else {
+		err = WriteTimelineCSV(f, t.Points())
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write timeline %s. err=%w", path, err)
+	}
+	return nil
+}
+
+// WriteTimelineCSV writes the given points as CSV, with a header naming the columns like the JSON
+// fields of a TimelinePoint.
+func WriteTimelineCSV(w io.Writer, points []TimelinePoint) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "requests", "errors", "average_ms", "median_ms", "p99_ms", "slowest_ms"})
+	for _, p := range points {
+		cw.Write([]string{
+			p.Time.Format(time.RFC3339),
+			strconv.Itoa(p.Requests),
+			strconv.Itoa(p.Errors),
+			strconv.FormatFloat(p.Average, 'f', -1, 64),
+			strconv.FormatFloat(p.Median, 'f', -1, 64),
+			strconv.FormatFloat(p.P99, 'f', -1, 64),
+			strconv.FormatFloat(p.Slowest, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
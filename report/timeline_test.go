@@ -0,0 +1,88 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestTimeline(t *testing.T) {
+	start := time.Unix(100, 0)
+	results := []runner.Result{
+		{Start: start, End: start.Add(10 * time.Millisecond)},
+		{Start: start, End: start.Add(30 * time.Millisecond)},
+		{Start: start, End: start.Add(20 * time.Millisecond), Err: errors.New("timeout")},
+		// Nothing completed in the second 101
+		{Start: start.Add(2 * time.Second), End: start.Add(2*time.Second + 50*time.Millisecond)},
+	}
+
+	tl := NewTimeline()
+	for i := range results {
+		tl.Record(&results[i])
+	}
+
+	got := tl.Points()
+	want := []TimelinePoint{
+		{Time: time.Unix(100, 0).UTC(), Requests: 3, Errors: 1, Average: 20, Median: 10, P99: 30, Slowest: 30},
+		{Time: time.Unix(101, 0).UTC()},
+		{Time: time.Unix(102, 0).UTC(), Requests: 1, Average: 50, Median: 50, P99: 50, Slowest: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Timeline.Points() = %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTimelineCSV(&buf, got); err != nil {
+		t.Fatalf("WriteTimelineCSV() error = %v", err)
+	}
+	wantCSV := "time,requests,errors,average_ms,median_ms,p99_ms,slowest_ms\n" +
+		"1970-01-01T00:01:40Z,3,1,20,10,30,30\n" +
+		"1970-01-01T00:01:41Z,0,0,0,0,0,0\n" +
+		"1970-01-01T00:01:42Z,1,0,50,50,50,50\n"
+	if buf.String() != wantCSV {
+		t.Errorf("WriteTimelineCSV() = %q, want %q", buf.String(), wantCSV)
+	}
+
+	if got := NewTimeline().Points(); got != nil {
+		t.Errorf("Timeline.Points() of no results = %v, want none", got)
+	}
+}
+
+func TestTimeline_untimedErrors(t *testing.T) {
+	start := time.Unix(100, 0)
+	results := []runner.Result{
+		{Start: start, End: start.Add(10 * time.Millisecond)},
+		// Failures without a response, due in an open loop or not
+		{Scheduled: start.Add(time.Second), Err: errors.New("connection refused")},
+		{Err: errors.New("connection refused")},
+	}
+
+	tl := NewTimeline()
+	for i := range results {
+		tl.Record(&results[i])
+	}
+
+	want := []TimelinePoint{
+		{Time: time.Unix(100, 0).UTC(), Requests: 1, Average: 10, Median: 10, P99: 10, Slowest: 10},
+		{Time: time.Unix(101, 0).UTC(), Requests: 1, Errors: 1},
+	}
+	if got := tl.Points(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Timeline.Points() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTimeline_refused(t *testing.T) {
+	tl := NewTimeline()
+	r := &runner.Runner{Client: &RefusedQuerierMock{}, Workers: 1, Recorders: []runner.Recorder{tl}}
+	r.Run([]query.Query{{Query: "up"}})
+
+	got := tl.Points()
+	if len(got) != 1 || got[0].Requests != 1 || got[0].Errors != 1 || time.Since(got[0].Time) > time.Minute {
+		t.Errorf("Timeline.Points() = %+v, want the refused query in the current second", got)
+	}
+}
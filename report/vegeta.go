@@ -0,0 +1,114 @@
+package report
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// VegetaLatencies are the latencies of a VegetaReport in nanoseconds.
+type VegetaLatencies struct {
+	Total time.Duration `json:"total"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"50th"`
+	P90   time.Duration `json:"90th"`
+	P95   time.Duration `json:"95th"`
+	P99   time.Duration `json:"99th"`
+	Max   time.Duration `json:"max"`
+	Min   time.Duration `json:"min"`
+}
+
+// VegetaBytes are the sizes of the bodies of a VegetaReport.
+type VegetaBytes struct {
+	Total int64   `json:"total"`
+	Mean  float64 `json:"mean"`
+}
+
+// VegetaReport is the summary of a run encoded like the JSON report of vegeta (vegeta report
+// -type=json), so the dashboards and tooling built around vegeta consume it unchanged. Durations
+// are in nanoseconds, Earliest and Latest are the times the first and last queries were sent and End
+// the time the last one completed. A query succeeds if it didn't error, as vegeta only looks at
+// the status code.
+type VegetaReport struct {
+	Latencies   VegetaLatencies `json:"latencies"`
+	BytesIn     VegetaBytes     `json:"bytes_in"`
+	BytesOut    VegetaBytes     `json:"bytes_out"`
+	Earliest    time.Time       `json:"earliest"`
+	Latest      time.Time       `json:"latest"`
+	End         time.Time       `json:"end"`
+	Duration    time.Duration   `json:"duration"`
+	Wait        time.Duration   `json:"wait"`
+	Requests    int             `json:"requests"`
+	Rate        float64         `json:"rate"`
+	Throughput  float64         `json:"throughput"`
+	Success     float64         `json:"success"`
+	StatusCodes map[string]int  `json:"status_codes"`
+	Errors      []string        `json:"errors"`
+}
+
+// NewVegetaReport builds the VegetaReport of the given results. Like vegeta, latencies include
+// those of the failed queries.
+func NewVegetaReport(results []runner.Result) *VegetaReport {
+	report := &VegetaReport{StatusCodes: map[string]int{}, Errors: []string{}, Requests: len(results)}
+	if len(results) == 0 {
+		return report
+	}
+
+	latencies := make([]float64, len(results))
+	errors := map[string]bool{}
+	var successes int
+	for i, r := range results {
+		latency := r.End.Sub(r.Start)
+		latencies[i] = float64(latency)
+		report.Latencies.Total += latency
+		report.BytesIn.Total += r.Bytes
+		report.StatusCodes[strconv.Itoa(r.Status)]++
+		// Failures logged by older versions may lack their times
+		if !r.Start.IsZero() && (report.Earliest.IsZero() || r.Start.Before(report.Earliest)) {
+			report.Earliest = r.Start
+		}
+		if r.Start.After(report.Latest) {
+			report.Latest = r.Start
+		}
+		if r.End.After(report.End) {
+			report.End = r.End
+		}
+		if r.Err != nil {
+			errors[r.Err.Error()] = true
+		} else {
+			successes++
+		}
+	}
+	for err := range errors {
+		report.Errors = append(report.Errors, err)
+	}
+	sort.Strings(report.Errors)
+
+	report.Latencies.Mean = report.Latencies.Total / time.Duration(len(results))
+	report.Latencies.P50 = time.Duration(stats.Quantile(latencies, 0.5))
+	report.Latencies.P90 = time.Duration(stats.Quantile(latencies, 0.9))
+	report.Latencies.P95 = time.Duration(stats.Quantile(latencies, 0.95))
+	report.Latencies.P99 = time.Duration(stats.Quantile(latencies, 0.99))
+	report.Latencies.Max = time.Duration(stats.Quantile(latencies, 1))
+	report.Latencies.Min = time.Duration(stats.Quantile(latencies, 0))
+	report.BytesIn.Mean = float64(report.BytesIn.Total) / float64(len(results))
+
+	report.Duration = report.Latest.Sub(report.Earliest)
+	report.Wait = report.End.Sub(report.Latest)
+	report.Success = float64(successes) / float64(len(results))
+	if report.Duration > 0 {
+		report.Rate = float64(len(results)) / report.Duration.Seconds()
+	}
+	if elapsed := report.Duration + report.Wait; elapsed > 0 {
+		report.Throughput = float64(successes) / elapsed.Seconds()
+	}
+	return report
+}
+
+// Write writes the report to the given path as JSON.
+func (r *VegetaReport) Write(path string) error {
+	return writeJSON(path, r)
+}
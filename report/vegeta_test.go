@@ -0,0 +1,61 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestNewVegetaReport(t *testing.T) {
+	start := time.Unix(100, 0)
+	results := []runner.Result{
+		{Start: start, End: start.Add(10 * time.Millisecond), Status: 200, Bytes: 100},
+		{Start: start.Add(time.Second), End: start.Add(time.Second + 30*time.Millisecond), Status: 200, Bytes: 300},
+		{Start: start.Add(2 * time.Second), End: start.Add(2*time.Second + 20*time.Millisecond), Status: 503, Err: errors.New("unexpected status 503")},
+	}
+
+	got := NewVegetaReport(results)
+	want := &VegetaReport{
+		Latencies: VegetaLatencies{
+			Total: 60 * time.Millisecond, Mean: 20 * time.Millisecond,
+			P50: 20 * time.Millisecond, P90: 30 * time.Millisecond, P95: 30 * time.Millisecond, P99: 30 * time.Millisecond,
+			Max: 30 * time.Millisecond, Min: 10 * time.Millisecond,
+		},
+		BytesIn:     VegetaBytes{Total: 400, Mean: 400.0 / 3},
+		Earliest:    start,
+		Latest:      start.Add(2 * time.Second),
+		End:         start.Add(2*time.Second + 20*time.Millisecond),
+		Duration:    2 * time.Second,
+		Wait:        20 * time.Millisecond,
+		Requests:    3,
+		Rate:        1.5,
+		Throughput:  2 / 2.02,
+		Success:     2.0 / 3,
+		StatusCodes: map[string]int{"200": 2, "503": 1},
+		Errors:      []string{"unexpected status 503"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewVegetaReport() = %+v, want %+v", got, want)
+	}
+
+	empty := NewVegetaReport(nil)
+	if empty.Requests != 0 || empty.Errors == nil || empty.StatusCodes == nil {
+		t.Errorf("NewVegetaReport() of no results = %+v, want empty errors and status codes", empty)
+	}
+}
+
+func TestNewVegetaReport_untimedFailure(t *testing.T) {
+	start := time.Unix(100, 0)
+	results := []runner.Result{
+		{Err: errors.New("connection refused")},
+		{Start: start, End: start.Add(10 * time.Millisecond), Status: 200},
+		{Start: start.Add(time.Second), End: start.Add(time.Second + 10*time.Millisecond), Status: 200},
+	}
+	got := NewVegetaReport(results)
+	if !got.Earliest.Equal(start) || got.Duration != time.Second {
+		t.Errorf("NewVegetaReport() earliest = %v, duration = %v, want %v and 1s", got.Earliest, got.Duration, start)
+	}
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// Apdex is a Recorder scoring the query times of a run against the thresholds of a stats.Apdex.
+// Queries that failed or failed their assertions count as frustrated.
+type Apdex struct {
+	mu    sync.Mutex
+	apdex *stats.Apdex
+}
+
+// NewApdex returns an Apdex recorder with the given thresholds, see stats.NewApdex.
+func NewApdex(satisfied, tolerating time.Duration) *Apdex {
+	return &Apdex{apdex: stats.NewApdex(satisfied, tolerating)}
+}
+
+func (a *Apdex) Record(r *Result) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.apdex.Add(r.End.Sub(r.Start).Milliseconds(), r.Err != nil || r.Assertion != "")
+	return nil
+}
+
+// Stats returns the score of the results recorded so far.
+func (a *Apdex) Stats() *stats.Apdex {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	apdex := *a.apdex
+	return &apdex
+}
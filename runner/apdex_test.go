@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApdex_Record(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Start: start, End: start.Add(50 * time.Millisecond)},
+		{Start: start, End: start.Add(150 * time.Millisecond)},
+		{Start: start, End: start.Add(10 * time.Millisecond), Err: errors.New("timeout")},
+		{Start: start, End: start.Add(10 * time.Millisecond), Assertion: "got 0 series, want at least 1"},
+	}
+
+	a := NewApdex(100*time.Millisecond, 0)
+	for i := range results {
+		if err := a.Record(&results[i]); err != nil {
+			t.Fatalf("Apdex.Record() error = %v", err)
+		}
+	}
+	got := a.Stats()
+	if got.SatisfiedCount != 1 || got.ToleratingCount != 1 || got.Frustrated != 2 || got.Score != 0.375 {
+		t.Errorf("Apdex.Stats() = %+v, want 1 satisfied, 1 tolerating and 2 frustrated scoring 0.375", got)
+	}
+}
@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Balance strategies of a Balancer.
+const (
+	BalanceRoundRobin = "round-robin"
+	BalanceRandom     = "random"
+)
+
+// Target is a named Querier of a Balancer, e.g. a client of one of the replicas of a target.
+type Target struct {
+	Name string
+	Querier
+	// Weight is the share of the queries sent to the target relative to the others, e.g. 90 and 10
+	// to send a canary a tenth of them. Targets without a weight are given 1
+	Weight int
+}
+
+// weight returns the Weight of the target, 1 if unset.
+func (t Target) weight() int {
+	return max(1, t.Weight)
+}
+
+// Balancer is a Querier distributing the queries across several Targets, e.g. replicas serving the
+// same data or a whole fleet, in proportion to their weight. The BalanceRoundRobin Strategy sends
+// them in turn, interleaving the targets as evenly as their weights allow, while BalanceRandom picks
+// one at random. The results of every target are collected so they can be reported apart.
+type Balancer struct {
+	Targets  []Target
+	Strategy string
+	// Rand picks the targets with BalanceRandom, seeded with the time if nil
+	Rand *rand.Rand
+
+	mu sync.Mutex
+	// current holds the running weights of the smooth weighted round-robin, as nginx does
+	current []int
+	results [][]Result
+}
+
+// ParseWeights parses the comma-separated weights of the given number of targets, e.g. 90,10, nil
+// if empty.
+func ParseWeights(s string, targets int) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ",")
+	if len(fields) != targets {
+		return nil, fmt.Errorf("got %d weights for %d targets", len(fields), targets)
+	}
+	weights := make([]int, len(fields))
+	for i, field := range fields {
+		w, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid weight %q, want a positive integer", field)
+		}
+		weights[i] = w
+	}
+	return weights, nil
+}
+
+// ValidateBalance returns an error if the strategy is neither round-robin nor random.
+func ValidateBalance(strategy string) error {
+	switch strategy {
+	case BalanceRoundRobin, BalanceRandom:
+		return nil
+	}
+	return fmt.Errorf("unknown balance %q, want %s or %s", strategy, BalanceRoundRobin, BalanceRandom)
+}
+
+func (b *Balancer) Query(q *query.Query) (*client.Response, error) {
+	i := b.pick()
+	res, resp, err := send(b.Targets[i], *q)
+
+	b.mu.Lock()
+	b.results[i] = append(b.results[i], res)
+	b.mu.Unlock()
+	return resp, err
+}
+
+// pick returns the index of the target the next query is sent to.
+func (b *Balancer) pick() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.results == nil {
+		b.results, b.current = make([][]Result, len(b.Targets)), make([]int, len(b.Targets))
+	}
+	var total int
+	for _, t := range b.Targets {
+		total += t.weight()
+	}
+	if b.Strategy == BalanceRandom {
+		if b.Rand == nil {
+			b.Rand = rand.New(rand.NewSource(rand.Int63()))
+		}
+		n := b.Rand.Intn(total)
+		for i, t := range b.Targets {
+			if n -= t.weight(); n < 0 {
+				return i
+			}
+		}
+	}
+	// Every target gains its weight and the one ahead is picked, losing the total, so a target of
+	// weight w is picked w times every total queries
+	best := 0
+	for i, t := range b.Targets {
+		b.current[i] += t.weight()
+		if b.current[i] > b.current[best] {
+			best = i
+		}
+	}
+	b.current[best] -= total
+	return best
+}
+
+// Results returns the results of the queries sent to every target, in the order of the Targets.
+func (b *Balancer) Results() [][]Result {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	results := make([][]Result, len(b.Targets))
+	copy(results, b.results)
+	return results
+}
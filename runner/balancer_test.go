@@ -0,0 +1,130 @@
+package runner
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// CountQuerierMock answers every query successfully, counting them.
+type CountQuerierMock struct {
+	Calls int
+}
+
+func (m *CountQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	m.Calls++
+	return &client.Response{Bytes: 10, FirstByte: time.Millisecond, Transfer: 2 * time.Millisecond}, nil
+}
+
+func TestBalancer_Query(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		weights  []int
+		queries  int
+		want     func(calls []int) bool
+	}{
+		{
+			name:     "round-robin",
+			strategy: BalanceRoundRobin,
+			queries:  7,
+			want:     func(calls []int) bool { return calls[0] == 3 && calls[1] == 2 && calls[2] == 2 },
+		},
+		{
+			name:     "weighted round-robin",
+			strategy: BalanceRoundRobin,
+			weights:  []int{6, 3, 1},
+			queries:  20,
+			want:     func(calls []int) bool { return calls[0] == 12 && calls[1] == 6 && calls[2] == 2 },
+		},
+		{
+			name:     "weighted random",
+			strategy: BalanceRandom,
+			weights:  []int{90, 9, 1},
+			queries:  1000,
+			want: func(calls []int) bool {
+				return calls[0]+calls[1]+calls[2] == 1000 && calls[0] > 850 && calls[2] < 30
+			},
+		},
+		{
+			name:     "random",
+			strategy: BalanceRandom,
+			queries:  300,
+			want: func(calls []int) bool {
+				return calls[0]+calls[1]+calls[2] == 300 && calls[0] > 0 && calls[1] > 0 && calls[2] > 0
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mocks := []*CountQuerierMock{{}, {}, {}}
+			b := &Balancer{Strategy: tt.strategy, Rand: rand.New(rand.NewSource(1))}
+			for i, m := range mocks {
+				target := Target{Name: string(rune('a' + i)), Querier: m}
+				if tt.weights != nil {
+					target.Weight = tt.weights[i]
+				}
+				b.Targets = append(b.Targets, target)
+			}
+			for range tt.queries {
+				if _, err := b.Query(&query.Query{Query: "up"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			calls := []int{mocks[0].Calls, mocks[1].Calls, mocks[2].Calls}
+			if !tt.want(calls) {
+				t.Errorf("Balancer.Query() calls = %v", calls)
+			}
+			for i, results := range b.Results() {
+				if len(results) != calls[i] {
+					t.Errorf("Balancer.Results() of target %d = %d results, want %d", i, len(results), calls[i])
+				}
+				for _, res := range results {
+					if res.Bytes != 10 || res.FirstByte != time.Millisecond || res.Transfer != 2*time.Millisecond {
+						t.Errorf("Balancer.Results() of target %d = %+v, want the timings of the response", i, res)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBalance(t *testing.T) {
+	for strategy, wantErr := range map[string]bool{BalanceRoundRobin: false, BalanceRandom: false, "least-loaded": true} {
+		if err := ValidateBalance(strategy); (err != nil) != wantErr {
+			t.Errorf("ValidateBalance(%q) error = %v, wantErr %v", strategy, err, wantErr)
+		}
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		targets int
+		want    []int
+		wantErr bool
+	}{
+		{name: "empty", s: "", targets: 2, want: nil},
+		{name: "weights", s: "90, 10", targets: 2, want: []int{90, 10}},
+		{name: "too few weights", s: "90", targets: 2, wantErr: true},
+		{name: "zero weight", s: "90,0", targets: 2, wantErr: true},
+		{name: "invalid weight", s: "90,ten", targets: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWeights(tt.s, tt.targets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWeights() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWeights() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
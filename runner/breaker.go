@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// ErrCircuitOpen is the error of the queries a Breaker held back until the end of the run, which
+// were never sent.
+var ErrCircuitOpen = errors.New("circuit open, query not sent")
+
+// Breaker is a Querier pausing the queries once Threshold consecutive ones failed because of the
+// target, i.e. with any error but a 4xx status, as there's no point measuring a target that is
+// down. While the circuit is open a single query is sent every ProbeInterval as a probe, the others
+// waiting, and the queries resume as soon as a probe succeeds. Every outage is recorded so it can
+// be reported as such, rather than buried in thousands of errors. The queries still waiting once
+// the Context is done fail with ErrCircuitOpen.
+type Breaker struct {
+	Querier
+	Threshold     int
+	ProbeInterval time.Duration
+	Context       context.Context
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	probing  bool
+	// probed is closed after every probe, waking up the queries waiting for the circuit to close
+	probed  chan struct{}
+	outages []Outage
+}
+
+// Outage is a time the circuit of a Breaker was open.
+type Outage struct {
+	Start time.Time `json:"start"`
+	// End is when the first successful probe was sent, zero if the run ended during the outage
+	End time.Time `json:"end,omitzero"`
+	// Failures is the number of consecutive failures the circuit was opened after
+	Failures int `json:"failures"`
+	// Probes is the number of queries sent as probes, including the successful one
+	Probes int `json:"probes"`
+}
+
+func (o Outage) ToString() string {
+	if o.End.IsZero() {
+		return fmt.Sprintf("Outage from %s until the end of the run: circuit opened after %d consecutive failures, %d probes failed\n",
+			o.Start.Format(time.RFC3339), o.Failures, o.Probes)
+	}
+	return fmt.Sprintf("Outage from %s for %s: circuit opened after %d consecutive failures, closed after %d probes\n",
+		o.Start.Format(time.RFC3339), o.End.Sub(o.Start).Round(time.Millisecond), o.Failures, o.Probes)
+}
+
+func (b *Breaker) Query(q *query.Query) (*client.Response, error) {
+	for {
+		b.mu.Lock()
+		if !b.open {
+			b.mu.Unlock()
+			break
+		}
+		if !b.probing {
+			b.probing = true
+			b.mu.Unlock()
+			return b.probe(q)
+		}
+		probed := b.probed
+		b.mu.Unlock()
+
+		select {
+		case <-probed:
+		case <-b.done():
+			return nil, ErrCircuitOpen
+		}
+	}
+
+	resp, err := b.Querier.Query(q)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !targetFailed(err) {
+		b.failures = 0
+	} else if b.failures++; b.failures >= b.Threshold && !b.open {
+		b.open, b.probed = true, make(chan struct{})
+		b.outages = append(b.outages, Outage{Start: time.Now(), Failures: b.failures})
+	}
+	return resp, err
+}
+
+// probe sends the query as a probe once the ProbeInterval elapsed, closing the circuit if it
+// succeeds.
+func (b *Breaker) probe(q *query.Query) (*client.Response, error) {
+	wait := time.NewTimer(b.ProbeInterval)
+	defer wait.Stop()
+	var resp *client.Response
+	var err error
+	select {
+	case <-wait.C:
+		resp, err = b.Querier.Query(q)
+	case <-b.done():
+		return nil, ErrCircuitOpen
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	outage := &b.outages[len(b.outages)-1]
+	outage.Probes++
+	if !targetFailed(err) {
+		outage.End = time.Now()
+		b.open, b.failures = false, 0
+	}
+	b.probing = false
+	close(b.probed)
+	b.probed = make(chan struct{})
+	return resp, err
+}
+
+// done returns the channel closed once the Context is done, nil if there is none.
+func (b *Breaker) done() <-chan struct{} {
+	if b.Context == nil {
+		return nil
+	}
+	return b.Context.Done()
+}
+
+// Outages returns the times the circuit was open, in order.
+func (b *Breaker) Outages() []Outage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Outage(nil), b.outages...)
+}
+
+// targetFailed reports whether the error tells the target failed, rather than the query being wrong.
+func targetFailed(err error) bool {
+	return err != nil && client.Classify(err) != client.ErrorClientStatus
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// DownQuerierMock fails the first Down queries, or every query if Down is negative.
+type DownQuerierMock struct {
+	Down int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *DownQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	resp := &client.Response{}
+	resp.Timestamp.Start, resp.Timestamp.End = time.Now(), time.Now()
+	if m.Down < 0 || m.calls <= m.Down {
+		return resp, &client.StatusError{StatusCode: 503}
+	}
+	return resp, nil
+}
+
+func TestBreaker_Query(t *testing.T) {
+	mock := &DownQuerierMock{Down: 5}
+	b := &Breaker{Querier: mock, Threshold: 3, ProbeInterval: time.Millisecond}
+	r := &Runner{Client: b, Workers: 1}
+	s := r.Run(make([]query.Query, 10))
+
+	// The circuit opens after 3 failures, then closes with the third probe
+	outages := b.Outages()
+	if len(outages) != 1 || outages[0].Failures != 3 || outages[0].Probes != 3 || outages[0].End.IsZero() {
+		t.Fatalf("Breaker.Outages() = %+v, want a closed outage of 3 failures and 3 probes", outages)
+	}
+	if mock.calls != 10 || s.Processed != 5 {
+		t.Errorf("Runner.Run() sent %d queries, %d processed, want 10 sent and 5 processed", mock.calls, s.Processed)
+	}
+}
+
+func TestBreaker_Query_notRecovered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	mock := &DownQuerierMock{Down: -1}
+	b := &Breaker{Querier: mock, Threshold: 2, ProbeInterval: 5 * time.Millisecond, Context: ctx}
+	recorder := &resultsRecorder{}
+	r := &Runner{Client: b, Workers: 4, Recorders: []Recorder{recorder}}
+	r.Run(make([]query.Query, 100))
+
+	outages := b.Outages()
+	if len(outages) != 1 || !outages[0].End.IsZero() || outages[0].Probes == 0 {
+		t.Fatalf("Breaker.Outages() = %+v, want an outage until the end of the run", outages)
+	}
+	var held int
+	for _, res := range recorder.results {
+		if errors.Is(res.Err, ErrCircuitOpen) {
+			held++
+		}
+	}
+	if held == 0 || mock.calls+held != 100 {
+		t.Errorf("Runner.Run() sent %d queries and held back %d, want the others held back", mock.calls, held)
+	}
+}
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Estimator predicts the number of requests a run sends and how long it takes before committing to
+// it, from the queries and the settings of the run, and from the latency of a few Probes if given.
+type Estimator struct {
+	// Client sends the probes
+	Client Querier
+	// Probes is the number of queries, spread across the set, sent one at a time to sample their
+	// latency. Without probes, the duration of a closed loop is unknown
+	Probes  int
+	Workers int
+	// Arrival schedules the queries in an open loop, nil for a closed loop
+	Arrival   Arrival
+	ThinkTime time.Duration
+	// Sends is the number of times every query is sent within a run, e.g. 2 to compare it cold and
+	// warm, 1 if zero
+	Sends int
+	// Splitter splits the queries into the chunks they're sent as, if any
+	Splitter *Splitter
+	// Runs is the number of times the queries are run, 1 if zero, pausing for CoolDown between them
+	Runs     int
+	CoolDown time.Duration
+	// Limit stops every run once elapsed, none if zero
+	Limit time.Duration
+}
+
+// Estimate is the prediction of an Estimator.
+type Estimate struct {
+	// Queries is the number of queries run and Requests the number of requests they're sent as,
+	// every run included
+	Queries  int `json:"queries"`
+	Requests int `json:"requests"`
+	// Probes is the number of probes sent, Errors those that failed, and Latency the mean latency
+	// of the others, zero if none succeeded
+	Probes  int           `json:"probes"`
+	Errors  int           `json:"errors"`
+	Latency time.Duration `json:"latency"`
+	// Duration is the predicted duration of the whole run, zero if unknown
+	Duration time.Duration `json:"duration"`
+	// Limited is whether the runs are stopped by the Limit before every query ran, the counts being
+	// those sent until then
+	Limited bool          `json:"limited"`
+	Limit   time.Duration `json:"limit,omitempty"`
+}
+
+// Estimate predicts the run of the given queries, as they would be dispatched by a Runner.
+func (e *Estimator) Estimate(queries []query.Query) *Estimate {
+	sends, runs := max(1, e.Sends), max(1, e.Runs)
+	est := &Estimate{Queries: len(queries) * sends, Limit: e.Limit}
+	for _, q := range queries {
+		if e.Splitter != nil {
+			est.Requests += len(e.Splitter.Split(q)) * sends
+			continue
+		}
+		est.Requests += sends
+	}
+
+	if n := min(e.Probes, len(queries)); n > 0 {
+		var total time.Duration
+		for i := range n {
+			q := queries[i*len(queries)/n].Resolve(time.Now())
+			resp, err := e.Client.Query(&q)
+			est.Probes++
+			if err != nil {
+				est.Errors++
+				continue
+			}
+			total += resp.Timestamp.End.Sub(resp.Timestamp.Start)
+		}
+		if ok := est.Probes - est.Errors; ok > 0 {
+			est.Latency = total / time.Duration(ok)
+		}
+	}
+
+	// The duration of a single run, the queries sent in turn by every worker in a closed loop, or
+	// as scheduled by the Arrival in an open one and answered a latency later
+	var run time.Duration
+	switch {
+	case e.Arrival != nil:
+		for range est.Queries {
+			run += e.Arrival.Next()
+		}
+		run += est.Latency
+	case est.Latency > 0 && len(queries) > 0:
+		perWorker := (est.Queries + max(1, e.Workers) - 1) / max(1, e.Workers)
+		run = time.Duration(perWorker)*est.Latency + time.Duration(perWorker-1)*e.ThinkTime
+	}
+	if e.Limit > 0 && run > e.Limit {
+		est.Queries = int(float64(est.Queries) * float64(e.Limit) / float64(run))
+		est.Requests = int(float64(est.Requests) * float64(e.Limit) / float64(run))
+		run, est.Limited = e.Limit, true
+	}
+	est.Queries, est.Requests = est.Queries*runs, est.Requests*runs
+	if run > 0 {
+		est.Duration = time.Duration(runs)*run + time.Duration(runs-1)*e.CoolDown
+	}
+	return est
+}
+
+func (e *Estimate) ToString() (output string) {
+	output += fmt.Sprintf("Estimated requests: %d (%d queries)\n", e.Requests, e.Queries)
+	if e.Probes > 0 {
+		output += fmt.Sprintf("Probes: %d sent, %d failed, mean latency %s\n", e.Probes, e.Errors, e.Latency.Round(time.Microsecond))
+	}
+	switch {
+	case e.Limited:
+		output += fmt.Sprintf("Estimated duration: %s, stopped by the duration limit before every query ran\n", e.Duration.Round(time.Millisecond))
+	case e.Duration > 0:
+		output += fmt.Sprintf("Estimated duration: %s\n", e.Duration.Round(time.Millisecond))
+	case e.Limit > 0:
+		output += fmt.Sprintf("Estimated duration: unknown, at most %s per run, send probes to estimate it\n", e.Limit)
+	default:
+		output += "Estimated duration: unknown, send probes to estimate it\n"
+	}
+	return
+}
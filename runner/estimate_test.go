@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// LatencyQuerierMock answers every query after the given Latency, without waiting for it, failing
+// those of the Broken expression.
+type LatencyQuerierMock struct {
+	Latency time.Duration
+	Broken  string
+	Calls   int
+}
+
+func (m *LatencyQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	m.Calls++
+	if q.Query == m.Broken {
+		return nil, errors.New("broken")
+	}
+	resp := &client.Response{}
+	resp.Timestamp.Start = time.Now()
+	resp.Timestamp.End = resp.Timestamp.Start.Add(m.Latency)
+	return resp, nil
+}
+
+func TestEstimator_Estimate(t *testing.T) {
+	queries := make([]query.Query, 10)
+	for i := range queries {
+		queries[i] = query.Query{Query: "up", Start: 0, End: 3600_000, Step: 60}
+	}
+	queries[0].Query = "broken"
+
+	tests := []struct {
+		name      string
+		estimator Estimator
+		want      Estimate
+		wantCalls int
+	}{
+		{
+			name:      "without probes",
+			estimator: Estimator{Workers: 2},
+			want:      Estimate{Queries: 10, Requests: 10},
+		},
+		{
+			name:      "closed loop",
+			estimator: Estimator{Probes: 5, Workers: 2, ThinkTime: time.Second},
+			want:      Estimate{Queries: 10, Requests: 10, Probes: 5, Errors: 1, Latency: 100 * time.Millisecond, Duration: 4500 * time.Millisecond},
+			wantCalls: 5,
+		},
+		{
+			name:      "more probes than queries",
+			estimator: Estimator{Probes: 50, Workers: 10},
+			want:      Estimate{Queries: 10, Requests: 10, Probes: 10, Errors: 1, Latency: 100 * time.Millisecond, Duration: 100 * time.Millisecond},
+			wantCalls: 10,
+		},
+		{
+			name:      "open loop",
+			estimator: Estimator{Arrival: &ConstantArrival{Rate: 10}, Sends: 2},
+			want:      Estimate{Queries: 20, Requests: 20, Duration: 2 * time.Second},
+		},
+		{
+			name:      "split runs",
+			estimator: Estimator{Probes: 2, Workers: 5, Splitter: &Splitter{Chunks: 4}, Runs: 3, CoolDown: time.Second},
+			want:      Estimate{Queries: 30, Requests: 120, Probes: 2, Errors: 1, Latency: 100 * time.Millisecond, Duration: 2600 * time.Millisecond},
+			wantCalls: 2,
+		},
+		{
+			name:      "limited",
+			estimator: Estimator{Arrival: &ConstantArrival{Rate: 2}, Limit: time.Second, Runs: 2},
+			want:      Estimate{Queries: 4, Requests: 4, Duration: 2 * time.Second, Limited: true, Limit: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &LatencyQuerierMock{Latency: 100 * time.Millisecond, Broken: "broken"}
+			tt.estimator.Client = m
+			got := tt.estimator.Estimate(queries)
+			if *got != tt.want {
+				t.Errorf("Estimator.Estimate() = %+v, want %+v", *got, tt.want)
+			}
+			if m.Calls != tt.wantCalls {
+				t.Errorf("Estimator.Estimate() probes sent = %d, want %d", m.Calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FailFast is a Recorder calling Cancel on the first Result of a query that failed or failed its
+// assertions, e.g. to cancel the Context of a Runner so the run is aborted. Queries in flight still
+// complete.
+type FailFast struct {
+	Cancel context.CancelFunc
+
+	mu    sync.Mutex
+	first *Result
+}
+
+func (f *FailFast) Record(r *Result) error {
+	if r.Err == nil && r.Assertion == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.first == nil {
+		first := *r
+		f.first = &first
+		f.Cancel()
+	}
+	return nil
+}
+
+// Err returns the failure of the first query that failed, nil if none did.
+func (f *FailFast) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case f.first == nil:
+		return nil
+	case f.first.Err != nil:
+		return fmt.Errorf("query=%v, error=%w", f.first.Query.Query, f.first.Err)
+	}
+	return fmt.Errorf("query=%v, assertion=%s", f.first.Query.Query, f.first.Assertion)
+}
@@ -0,0 +1,52 @@
+package runner
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestFailFast(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries []query.Query
+		wantErr bool
+	}{
+		{name: "no failure", queries: []query.Query{{Query: "up"}, {Query: "up"}}},
+		{name: "error", queries: []query.Query{{Query: "up"}, {Query: "broken"}}, wantErr: true},
+		{name: "assertion failure", queries: []query.Query{{Query: "up"}, {Query: "up", ExpectNonEmpty: true}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			failFast := &FailFast{Cancel: cancel}
+			r := &Runner{
+				Client: &client.Client{
+					Client:  &StatusClientMock{Status: map[string]int{"broken": 500}},
+					URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+					Version: "v1",
+				},
+				Workers:   1,
+				Recorders: []Recorder{failFast},
+				Context:   ctx,
+			}
+
+			// The queries after the failure are not run
+			queries := append(tt.queries, make([]query.Query, 100)...)
+			s := r.Run(queries)
+			if err := failFast.Err(); (err != nil) != tt.wantErr {
+				t.Fatalf("FailFast.Err() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && s.Processed+s.Errors.Total() >= len(queries)/2 {
+				t.Errorf("Runner.Run() ran %d queries, want the run aborted", s.Processed+s.Errors.Total())
+			}
+			if !tt.wantErr && s.Processed != len(queries) {
+				t.Errorf("Runner.Run() processed = %d, want %d", s.Processed, len(queries))
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// SLO is the service level objective the load of a Search must sustain.
+type SLO struct {
+	// Latency is the maximum latency tolerated at the given Quantile, e.g. 0.99
+	Latency  time.Duration
+	Quantile float64
+	// ErrorRate is the maximum fraction of failed queries tolerated
+	ErrorRate float64
+}
+
+// Search looks for the maximum load, either in queries per second sent in an open loop or in
+// concurrent workers, the target sustains without violating the SLO. The load is doubled from
+// Start until the SLO is violated (or Max is reached), then binary searched between the last
+// level that met it and the first one that did not.
+type Search struct {
+	Client    Querier
+	Recorders []Recorder
+	// Workers searches the number of concurrent workers instead of the rate
+	Workers bool
+	SLO     SLO
+	// Step is the time every load level is run for, cycling through the queries
+	Step       time.Duration
+	Start, Max float64
+	// Precision stops the binary search once the relative difference between the levels that
+	// met and violated the SLO falls below it
+	Precision float64
+}
+
+// Level is the outcome of running a Search at a given load.
+type Level struct {
+	Load float64 `json:"load"`
+	// Latency at the quantile of the SLO in milliseconds
+	Latency    float64      `json:"latency_ms"`
+	ErrorRate  float64      `json:"error_rate"`
+	Throughput float64      `json:"throughput"`
+	OK         bool         `json:"ok"`
+	Stats      *stats.Stats `json:"stats"`
+}
+
+// SearchResult holds every Level run by a Search and the maximum load that met the SLO.
+type SearchResult struct {
+	Levels []Level `json:"levels"`
+	// Max is the highest Level that met the SLO, nil if none did
+	Max *Level `json:"max,omitempty"`
+}
+
+func (s *SearchResult) ToString() (output string) {
+	for _, l := range s.Levels {
+		verdict := "met"
+		if !l.OK {
+			verdict = "violated"
+		}
+		output += fmt.Sprintf("Load %g: latency %.2fms, error rate %.4f, throughput %.2f queries/s, SLO %s\n",
+			l.Load, l.Latency, l.ErrorRate, l.Throughput, verdict)
+	}
+	if s.Max == nil {
+		output += "No load met the SLO\n"
+	} else {
+		output += fmt.Sprintf("Maximum sustainable load: %g (%.2f queries/s)\n", s.Max.Load, s.Max.Throughput)
+	}
+	return
+}
+
+// Run searches the maximum load sustained with the given queries.
+func (s *Search) Run(queries []query.Query) *SearchResult {
+	result := &SearchResult{}
+	run := func(load float64) bool {
+		l := s.level(queries, load)
+		result.Levels = append(result.Levels, l)
+		return l.OK
+	}
+
+	lower, upper := 0.0, 0.0
+	for load := s.Start; ; load = math.Min(load*2, s.Max) {
+		if !run(load) {
+			upper = load
+			break
+		}
+		lower = load
+		if load >= s.Max {
+			return result.withMax()
+		}
+	}
+
+	for {
+		mid := (lower + upper) / 2
+		if s.Workers {
+			mid = math.Floor(mid)
+		}
+		if mid <= lower || (upper-lower)/upper <= s.Precision {
+			break
+		}
+		if run(mid) {
+			lower = mid
+		} else {
+			upper = mid
+		}
+	}
+	return result.withMax()
+}
+
+// withMax sets the Max of the result to the highest of its Levels that met the SLO.
+func (s *SearchResult) withMax() *SearchResult {
+	for i, l := range s.Levels {
+		if l.OK && (s.Max == nil || l.Load > s.Max.Load) {
+			s.Max = &s.Levels[i]
+		}
+	}
+	return s
+}
+
+// level runs the queries at the given load for a Step.
+func (s *Search) level(queries []query.Query, load float64) Level {
+	rec := &collector{}
+	r := &Runner{Client: s.Client, Workers: 1, Recorders: append([]Recorder{rec}, s.Recorders...), Duration: s.Step, Cycle: true}
+	if s.Workers {
+		r.Workers = int(load)
+	} else {
+		r.Arrival = &ConstantArrival{Rate: load}
+	}
+
+	start := time.Now()
+	st := r.Run(queries)
+	elapsed := time.Since(start)
+
+	l := Level{Load: load, Stats: st, Throughput: float64(st.Processed) / elapsed.Seconds()}
+	var latencies []float64
+	for _, res := range rec.results {
+		if res.Err == nil {
+			latencies = append(latencies, float64(res.End.Sub(res.Start))/float64(time.Millisecond))
+		}
+	}
+	if total := len(rec.results); total > 0 {
+		l.ErrorRate = float64(total-len(latencies)) / float64(total)
+	}
+	l.Latency = stats.Quantile(latencies, s.SLO.Quantile)
+	l.OK = len(latencies) > 0 && l.Latency <= float64(s.SLO.Latency)/float64(time.Millisecond) && l.ErrorRate <= s.SLO.ErrorRate
+	return l
+}
+
+// collector is a Recorder keeping every Result.
+type collector struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+func (c *collector) Record(r *Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, *r)
+	return nil
+}
@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// LoadQuerierMock answers after 5ms per query in flight, so its latency grows with the load.
+type LoadQuerierMock struct {
+	inflight atomic.Int64
+}
+
+func (m *LoadQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	n := m.inflight.Add(1)
+	defer m.inflight.Add(-1)
+
+	resp := &client.Response{}
+	resp.Timestamp.Start = time.Now()
+	time.Sleep(time.Duration(n) * 5 * time.Millisecond)
+	resp.Timestamp.End = time.Now()
+	return resp, nil
+}
+
+func TestSearch_Run(t *testing.T) {
+	s := &Search{
+		Client:    &LoadQuerierMock{},
+		Workers:   true,
+		SLO:       SLO{Latency: 23 * time.Millisecond, Quantile: 0.9, ErrorRate: 0},
+		Step:      100 * time.Millisecond,
+		Start:     1,
+		Max:       64,
+		Precision: 0.05,
+	}
+
+	got := s.Run([]query.Query{{Query: "up"}})
+	if got.Max == nil || got.Max.Load != 4 {
+		t.Fatalf("Search.Run() = %v, want 4 workers sustained", got.ToString())
+	}
+	var loads []float64
+	for _, l := range got.Levels {
+		loads = append(loads, l.Load)
+	}
+	if want := []float64{1, 2, 4, 8, 6, 5}; len(loads) != len(want) {
+		t.Errorf("Search.Run() levels = %v, want %v", loads, want)
+	}
+}
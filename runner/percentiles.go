@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// Percentiles is a Recorder computing the chosen percentiles of the query times of a run, of the
+// times until the first byte and the whole body of the responses were received, if measured, and of
+// the TLS handshakes of the new connections.
+// Like the rest of the stats, they leave out the queries that failed.
+type Percentiles struct {
+	percentiles []float64
+
+	mu        sync.Mutex
+	latencies []float64
+	firstByte []float64
+	transfer  []float64
+	tls       []float64
+}
+
+// NewPercentiles returns a Percentiles recorder computing the given percentiles (0-100].
+func NewPercentiles(percentiles []float64) *Percentiles {
+	return &Percentiles{percentiles: percentiles}
+}
+
+func (p *Percentiles) Record(r *Result) error {
+	if r.Err != nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latencies = append(p.latencies, float64(r.End.Sub(r.Start).Milliseconds()))
+	if r.FirstByte > 0 {
+		p.firstByte = append(p.firstByte, float64(r.FirstByte)/float64(time.Millisecond))
+	}
+	if r.Transfer > 0 {
+		p.transfer = append(p.transfer, float64(r.Transfer)/float64(time.Millisecond))
+	}
+	if r.TLSHandshake > 0 {
+		p.tls = append(p.tls, float64(r.TLSHandshake)/float64(time.Millisecond))
+	}
+	return nil
+}
+
+// Stats returns the percentiles of the results recorded so far.
+func (p *Percentiles) Stats() stats.Percentiles {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return stats.ComputePercentiles(p.latencies, p.percentiles)
+}
+
+// Timings returns the percentiles of the times until the first byte and until the whole body of
+// the responses recorded so far were received.
+func (p *Percentiles) Timings() (firstByte, transfer stats.Percentiles) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return stats.ComputePercentiles(p.firstByte, p.percentiles), stats.ComputePercentiles(p.transfer, p.percentiles)
+}
+
+// TLSHandshakes returns the percentiles of the times of the TLS handshakes recorded so far.
+func (p *Percentiles) TLSHandshakes() stats.Percentiles {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return stats.ComputePercentiles(p.tls, p.percentiles)
+}
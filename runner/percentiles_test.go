@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func TestPercentiles_Record(t *testing.T) {
+	start := time.UnixMilli(0)
+	p := NewPercentiles([]float64{50, 100})
+	for _, ms := range []int{30, 10, 20} {
+		p.Record(&Result{Start: start, End: start.Add(time.Duration(ms) * time.Millisecond)})
+	}
+	p.Record(&Result{Start: start, End: start.Add(time.Second), Err: errors.New("timeout")})
+
+	want := stats.Percentiles{{Percentile: 50, Value: 20}, {Percentile: 100, Value: 30}}
+	if got := p.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Percentiles.Stats() = %v, want %v", got, want)
+	}
+}
+
+func TestPercentiles_Timings(t *testing.T) {
+	start := time.UnixMilli(0)
+	p := NewPercentiles([]float64{50, 100})
+	for _, ms := range []int{30, 10, 20} {
+		d := time.Duration(ms) * time.Millisecond
+		p.Record(&Result{Start: start, End: start.Add(d), FirstByte: d, Transfer: 2 * d})
+	}
+	// Results that weren't timed, e.g. over SQL, are left out
+	p.Record(&Result{Start: start, End: start.Add(time.Second)})
+
+	firstByte, transfer := p.Timings()
+	if want := (stats.Percentiles{{Percentile: 50, Value: 20}, {Percentile: 100, Value: 30}}); !reflect.DeepEqual(firstByte, want) {
+		t.Errorf("Percentiles.Timings() first byte = %v, want %v", firstByte, want)
+	}
+	if want := (stats.Percentiles{{Percentile: 50, Value: 40}, {Percentile: 100, Value: 60}}); !reflect.DeepEqual(transfer, want) {
+		t.Errorf("Percentiles.Timings() transfer = %v, want %v", transfer, want)
+	}
+}
+
+func TestPercentiles_TLSHandshakes(t *testing.T) {
+	start := time.UnixMilli(0)
+	p := NewPercentiles([]float64{50, 100})
+	for _, ms := range []int{30, 10, 20, 0} {
+		p.Record(&Result{Start: start, End: start.Add(time.Second), TLSHandshake: time.Duration(ms) * time.Millisecond})
+	}
+
+	if got, want := p.TLSHandshakes(), (stats.Percentiles{{Percentile: 50, Value: 20}, {Percentile: 100, Value: 30}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Percentiles.TLSHandshakes() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,263 @@
+package runner
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// Stage is a part of a Profile whose results are reported on their own.
+type Stage struct {
+	Name     string
+	Duration time.Duration
+	// Rate returns the queries per second sent at the given time since the start of the stage
+	Rate func(t time.Duration) float64
+}
+
+// Profile is an Arrival varying the rate queries are sent at over the run, e.g. to capture the
+// behavior of the target during surges. It is also a Recorder, collecting the results of every
+// Stage so they can be reported apart.
+type Profile struct {
+	Stages []Stage
+
+	mu sync.Mutex
+	// begin is the time the first query was scheduled at, and elapsed the schedule of the last
+	// one since then
+	begin   time.Time
+	elapsed time.Duration
+	// due is the fraction of a query accumulated since the last one was scheduled
+	due     float64
+	results [][]Result
+}
+
+// ParseProfile parses a Profile given in one of the following forms, where rates are given in
+// queries per second:
+//
+//   - ramp:<from>-<to>rps:<duration>, raising the rate linearly. Reported in 10 stages.
+//   - steps:<rate>,<rate>,...[:<duration>], holding each rate for the duration (1m by default).
+//   - sine:<min>-<max>rps:<period>[:<duration>], oscillating between both rates for the duration
+//     (a single period by default). Reported by quarter periods.
+func ParseProfile(spec string) (*Profile, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	parts := strings.Split(args, ":")
+
+	var stages []Stage
+	switch kind {
+	case "ramp":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid ramp profile %q, want ramp:<from>-<to>rps:<duration>", spec)
+		}
+		from, to, err := parseRateRange(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		n := 10
+		for i := 0; i < n; i++ {
+			lower := from + (to-from)*float64(i)/float64(n)
+			upper := from + (to-from)*float64(i+1)/float64(n)
+			stageDuration := d / time.Duration(n)
+			stages = append(stages, Stage{
+				Name:     fmt.Sprintf("%g-%grps", lower, upper),
+				Duration: stageDuration,
+				Rate: func(t time.Duration) float64 {
+					return lower + (upper-lower)*float64(t)/float64(stageDuration)
+				},
+			})
+		}
+	case "steps":
+		if len(parts) < 1 || len(parts) > 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid steps profile %q, want steps:<rate>,<rate>,...[:<duration>]", spec)
+		}
+		d := time.Minute
+		if len(parts) == 2 {
+			var err error
+			if d, err = time.ParseDuration(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, r := range strings.Split(parts[0], ",") {
+			rate, err := strconv.ParseFloat(strings.TrimSuffix(r, "rps"), 64)
+			if err != nil || rate < 0 {
+				return nil, fmt.Errorf("invalid rate %q", r)
+			}
+			stages = append(stages, Stage{
+				Name:     fmt.Sprintf("%grps", rate),
+				Duration: d,
+				Rate:     func(time.Duration) float64 { return rate },
+			})
+		}
+	case "sine":
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid sine profile %q, want sine:<min>-<max>rps:<period>[:<duration>]", spec)
+		}
+		min, max, err := parseRateRange(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		period, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		d := period
+		if len(parts) == 3 {
+			if d, err = time.ParseDuration(parts[2]); err != nil {
+				return nil, err
+			}
+		}
+
+		quarter := period / 4
+		for offset := time.Duration(0); offset < d; offset += quarter {
+			stageOffset, stageDuration := offset, quarter
+			if offset+quarter > d {
+				stageDuration = d - offset
+			}
+			stages = append(stages, Stage{
+				Name:     fmt.Sprintf("%v-%v", stageOffset, stageOffset+stageDuration),
+				Duration: stageDuration,
+				Rate: func(t time.Duration) float64 {
+					phase := 2 * math.Pi * float64(stageOffset+t) / float64(period)
+					return (min+max)/2 + (max-min)/2*math.Sin(phase)
+				},
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unknown profile %q, want ramp, steps or sine", kind)
+	}
+
+	for _, s := range stages {
+		if s.Duration <= 0 {
+			return nil, fmt.Errorf("profile %q lasts no time", spec)
+		}
+	}
+	return &Profile{Stages: stages}, nil
+}
+
+// parseRateRange parses a range of rates such as `100-500rps`.
+func parseRateRange(s string) (float64, float64, error) {
+	from, to, ok := strings.Cut(strings.TrimSuffix(s, "rps"), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate range %q, want <from>-<to>rps", s)
+	}
+	lower, err := strconv.ParseFloat(from, 64)
+	if err != nil || lower < 0 {
+		return 0, 0, fmt.Errorf("invalid rate range %q, want <from>-<to>rps", s)
+	}
+	upper, err := strconv.ParseFloat(to, 64)
+	if err != nil || upper < 0 {
+		return 0, 0, fmt.Errorf("invalid rate range %q, want <from>-<to>rps", s)
+	}
+	return lower, upper, nil
+}
+
+// Duration returns the time it takes to go through every Stage.
+func (p *Profile) Duration() (d time.Duration) {
+	for _, s := range p.Stages {
+		d += s.Duration
+	}
+	return
+}
+
+// Queries returns the number of queries sent over the whole Profile.
+func (p *Profile) Queries() int {
+	var total float64
+	for _, s := range p.Stages {
+		for t := time.Duration(0); t < s.Duration; t += time.Millisecond {
+			total += s.Rate(t) / 1000
+		}
+	}
+	return int(math.Ceil(total))
+}
+
+// stageAt returns the index of the Stage running at the given time since the start of the
+// Profile and the time since the start of that Stage. It returns -1 once the Profile is over.
+func (p *Profile) stageAt(t time.Duration) (int, time.Duration) {
+	for i, s := range p.Stages {
+		if t < s.Duration {
+			return i, t
+		}
+		t -= s.Duration
+	}
+	return -1, t
+}
+
+// Next integrates the rate of the Profile from the last scheduled query until the next one is due.
+func (p *Profile) Next() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.begin.IsZero() {
+		p.begin = time.Now()
+	}
+
+	last := p.elapsed
+	for p.due < 1 {
+		i, t := p.stageAt(p.elapsed)
+		if i < 0 { // no more queries are due, the run is expected to stop after the Duration
+			return time.Hour
+		}
+
+		rate := p.Stages[i].Rate(t)
+		step := time.Millisecond
+		if rate > 0 {
+			step = time.Duration(math.Max(float64(time.Microsecond), math.Min(float64(time.Millisecond), 0.1/rate*float64(time.Second))))
+		}
+		p.due += rate * step.Seconds()
+		p.elapsed += step
+	}
+	p.due--
+	return p.elapsed - last
+}
+
+// Record keeps a Result within the Stage running when its query was scheduled.
+func (p *Profile) Record(r *Result) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.results == nil {
+		p.results = make([][]Result, len(p.Stages))
+	}
+
+	// The queries are dispatched by the Profile, so they are due in the Stage they were scheduled
+	// in, even if they failed without being sent
+	at := r.Scheduled
+	if at.IsZero() {
+		at = r.Start
+	}
+	i, _ := p.stageAt(at.Sub(p.begin))
+	if i < 0 || at.IsZero() {
+		i = len(p.Stages) - 1
+	}
+	p.results[i] = append(p.results[i], *r)
+	return nil
+}
+
+// StageStats are the stats of the queries sent during a Stage.
+type StageStats struct {
+	Name  string       `json:"name"`
+	Stats *stats.Stats `json:"stats"`
+}
+
+// StageStats returns the stats of every Stage, computed from the Results recorded so far.
+func (p *Profile) StageStats() []StageStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := make([]StageStats, len(p.Stages))
+	for i, stage := range p.Stages {
+		var results []Result
+		if p.results != nil {
+			results = p.results[i]
+		}
+		s[i] = StageStats{Name: stage.Name, Stats: Aggregate(results, stage.Duration)}
+	}
+	return s
+}
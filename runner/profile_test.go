@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		spec         string
+		wantStages   []string
+		wantDuration time.Duration
+		wantQueries  int
+		wantErr      bool
+	}{
+		{
+			spec:         "ramp:0-100rps:10s",
+			wantStages:   []string{"0-10rps", "10-20rps", "20-30rps", "30-40rps", "40-50rps", "50-60rps", "60-70rps", "70-80rps", "80-90rps", "90-100rps"},
+			wantDuration: 10 * time.Second,
+			wantQueries:  500,
+		},
+		{
+			spec:         "steps:100,200,400:2s",
+			wantStages:   []string{"100rps", "200rps", "400rps"},
+			wantDuration: 6 * time.Second,
+			wantQueries:  1400,
+		},
+		{
+			spec:         "steps:10rps",
+			wantStages:   []string{"10rps"},
+			wantDuration: time.Minute,
+			wantQueries:  600,
+		},
+		{
+			spec:         "sine:0-100rps:4s:6s",
+			wantStages:   []string{"0s-1s", "1s-2s", "2s-3s", "3s-4s", "4s-5s", "5s-6s"},
+			wantDuration: 6 * time.Second,
+			wantQueries:  364, // 50rps on average plus the extra positive half period
+		},
+		{spec: "ramp:0-100rps", wantErr: true},
+		{spec: "steps:fast", wantErr: true},
+		{spec: "sine", wantErr: true},
+		{spec: "square:0-100rps:1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := ParseProfile(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var names []string
+			for _, s := range p.Stages {
+				names = append(names, s.Name)
+			}
+			if len(names) != len(tt.wantStages) {
+				t.Fatalf("ParseProfile() stages = %v, want %v", names, tt.wantStages)
+			}
+			for i := range names {
+				if names[i] != tt.wantStages[i] {
+					t.Errorf("ParseProfile() stage %d = %v, want %v", i, names[i], tt.wantStages[i])
+				}
+			}
+			if d := p.Duration(); d != tt.wantDuration {
+				t.Errorf("Profile.Duration() = %v, want %v", d, tt.wantDuration)
+			}
+			if q := p.Queries(); q < tt.wantQueries*99/100 || q > tt.wantQueries*101/100 {
+				t.Errorf("Profile.Queries() = %v, want about %v", q, tt.wantQueries)
+			}
+		})
+	}
+}
+
+func TestProfile_Next(t *testing.T) {
+	p, err := ParseProfile("steps:1000,100:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var elapsed time.Duration
+	var gaps []time.Duration
+	for elapsed < p.Duration() {
+		gap := p.Next()
+		elapsed += gap
+		gaps = append(gaps, gap)
+	}
+	// The last gap lands after the end of the profile
+	if n := len(gaps) - 1; n < 1090 || n > 1110 {
+		t.Errorf("Profile.Next() scheduled %d queries, want about 1100", n)
+	}
+	var first time.Duration
+	for _, gap := range gaps[:1000] {
+		first += gap
+	}
+	if first < 980*time.Millisecond || first > 1020*time.Millisecond {
+		t.Errorf("Profile.Next() scheduled the first 1000 queries over %v, want about 1s", first)
+	}
+}
+
+func TestProfile_Record(t *testing.T) {
+	p, err := ParseProfile("steps:10,20:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Next() // starts the profile
+
+	for _, offset := range []time.Duration{100 * time.Millisecond, 1500 * time.Millisecond, 1800 * time.Millisecond} {
+		start := p.begin.Add(offset)
+		p.Record(&Result{Start: start, End: start.Add(time.Millisecond)})
+	}
+
+	// A query failing without a response is accounted in the Stage it was scheduled in
+	p.Record(&Result{Scheduled: p.begin.Add(200 * time.Millisecond), Err: errors.New("connection refused")})
+
+	s := p.StageStats()
+	if s[0].Stats.Processed != 1 || s[1].Stats.Processed != 2 {
+		t.Errorf("Profile.StageStats() processed = %d and %d, want 1 and 2", s[0].Stats.Processed, s[1].Stats.Processed)
+	}
+	if s[0].Stats.Errors.Total() != 1 || s[1].Stats.Errors.Total() != 0 {
+		t.Errorf("Profile.StageStats() errors = %d and %d, want 1 and 0", s[0].Stats.Errors.Total(), s[1].Stats.Errors.Total())
+	}
+}
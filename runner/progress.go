@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Progress is a Recorder tracking which queries of a corpus completed, so a run stopped before
+// consuming all of it (e.g. by its Duration) can report its coverage and be resumed later.
+type Progress struct {
+	mu sync.Mutex
+	// Completed counts the executions of every query by its query.Query Key
+	Completed map[string]int `json:"completed"`
+}
+
+func NewProgress() *Progress {
+	return &Progress{Completed: map[string]int{}}
+}
+
+// ReadProgress loads the progress written by a previous run, returning an empty one if the file
+// does not exist yet.
+func ReadProgress(path string) (*Progress, error) {
+	p := NewProgress()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("unable to decode progress file %s. err=%w", path, err)
+	}
+	if p.Completed == nil {
+		p.Completed = map[string]int{}
+	}
+	return p, nil
+}
+
+// Write writes the progress to the given file, replacing it atomically so a crash while writing
+// doesn't leave a truncated checkpoint behind.
+func (p *Progress) Write(path string) error {
+	p.mu.Lock()
+	b, err := json.Marshal(p)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Checkpoint writes the progress to the given file every interval until stopped, so a run
+// interrupted by a crash or a reboot can be resumed from its last checkpoint.
+func (p *Progress) Checkpoint(path string, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.Write(path); err != nil {
+					slog.Warn("unable to write checkpoint", "path", path, "err", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
+
+func (p *Progress) Record(r *Result) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Completed[r.Query.Key()]++
+	return nil
+}
+
+// Remaining returns the queries not completed yet. Duplicated queries are accounted once per
+// execution, so only as many copies as were completed are skipped.
+func (p *Progress) Remaining(queries []query.Query) []query.Query {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	skipped := map[string]int{}
+	var remaining []query.Query
+	for _, q := range queries {
+		key := q.Key()
+		if skipped[key] < p.Completed[key] {
+			skipped[key]++
+			continue
+		}
+		remaining = append(remaining, q)
+	}
+	return remaining
+}
+
+// Consumption returns which fraction of the corpus, and of every group of queries (by class and
+// time range), has been completed.
+func (p *Progress) Consumption(queries []query.Query) *Consumption {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := &Consumption{Total: len(queries)}
+	groups := map[string]*GroupConsumption{}
+	counted := map[string]int{}
+	for _, q := range queries {
+		name := q.Class() + "/" + q.RangeBucket()
+		g, ok := groups[name]
+		if !ok {
+			g = &GroupConsumption{Name: name}
+			groups[name] = g
+		}
+		g.Total++
+
+		key := q.Key()
+		if counted[key] < p.Completed[key] {
+			counted[key]++
+			g.Completed++
+			c.Completed++
+		}
+	}
+
+	for _, g := range groups {
+		c.Groups = append(c.Groups, *g)
+	}
+	sort.Slice(c.Groups, func(i, j int) bool { return c.Groups[i].Name < c.Groups[j].Name })
+	return c
+}
+
+// Consumption describes how much of a corpus has been run.
+type Consumption struct {
+	Completed int                `json:"completed"`
+	Total     int                `json:"total"`
+	Groups    []GroupConsumption `json:"groups"`
+}
+
+// GroupConsumption describes how much of a group of queries of a corpus has been run.
+type GroupConsumption struct {
+	Name      string `json:"name"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+}
+
+func (c *Consumption) ToString() (output string) {
+	output += fmt.Sprintf("Corpus consumed: %d/%d queries (%.1f%%)\n", c.Completed, c.Total, percent(c.Completed, c.Total))
+	for _, g := range c.Groups {
+		output += fmt.Sprintf("  %s: %d/%d (%.1f%%)\n", g.Name, g.Completed, g.Total, percent(g.Completed, g.Total))
+	}
+	return
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
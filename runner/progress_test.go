@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestProgress_Remaining(t *testing.T) {
+	up := query.Query{Query: "up", Start: 0, End: 1000, Step: 1}
+	rate := query.Query{Query: "rate(up[5m])", Start: 0, End: 1000, Step: 1}
+	corpus := []query.Query{up, rate, up, up}
+
+	p := NewProgress()
+	p.Record(&Result{Query: up})
+	p.Record(&Result{Query: up})
+
+	if got, want := p.Remaining(corpus), []query.Query{rate, up}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Progress.Remaining() = %v, want %v", got, want)
+	}
+}
+
+func TestProgress_Consumption(t *testing.T) {
+	up := query.Query{Query: "up", Start: 0, End: 1000, Step: 1}
+	long := query.Query{Query: "up", Start: 0, End: 48 * time.Hour.Milliseconds(), Step: 1}
+	corpus := []query.Query{up, up, long}
+
+	p := NewProgress()
+	p.Record(&Result{Query: up})
+
+	want := &Consumption{
+		Completed: 1,
+		Total:     3,
+		Groups: []GroupConsumption{
+			{Name: "selector/<=1h", Completed: 1, Total: 2},
+			{Name: "selector/>24h", Completed: 0, Total: 1},
+		},
+	}
+	if got := p.Consumption(corpus); !reflect.DeepEqual(got, want) {
+		t.Errorf("Progress.Consumption() = %v, want %v", got, want)
+	}
+}
+
+func TestProgress_roundTrip(t *testing.T) {
+	path := t.TempDir() + "/progress.json"
+	p := NewProgress()
+	p.Record(&Result{Query: query.Query{Query: "up"}})
+	if err := p.Write(path); err != nil {
+		t.Fatalf("Progress.Write() error = %v", err)
+	}
+
+	got, err := ReadProgress(path)
+	if err != nil {
+		t.Fatalf("ReadProgress() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Completed, p.Completed) {
+		t.Errorf("ReadProgress() = %v, want %v", got.Completed, p.Completed)
+	}
+}
+
+func TestProgress_Checkpoint(t *testing.T) {
+	path := t.TempDir() + "/progress.json"
+	p := NewProgress()
+	p.Record(&Result{Query: query.Query{Query: "up"}})
+
+	stop := p.Checkpoint(path, time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, err := ReadProgress(path)
+		if err != nil {
+			t.Fatalf("ReadProgress() error = %v", err)
+		}
+		if reflect.DeepEqual(got.Completed, p.Completed) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Progress.Checkpoint() didn't write %v", p.Completed)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+}
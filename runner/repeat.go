@@ -0,0 +1,31 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// Repeat runs the queries the given number of times, pausing for coolDown between the runs so the
+// target can settle, and returns the stats of all the runs combined and of every run, so the
+// variability across runs can be told apart from a difference between targets. It stops early,
+// without cooling down, once the Context is done.
+func (r *Runner) Repeat(queries []query.Query, runs int, coolDown time.Duration) (*stats.Stats, []*stats.Stats) {
+	var all []Result
+	var elapsed time.Duration
+	var each []*stats.Stats
+	for i := range runs {
+		if i > 0 && coolDown > 0 {
+			time.Sleep(coolDown)
+		}
+		results, d := r.run(queries)
+		all, elapsed = append(all, results...), elapsed+d
+		each = append(each, Aggregate(results, d))
+		if r.Context != nil && r.Context.Err() != nil {
+			break
+		}
+	}
+	// The cool-downs are left out of the elapsed time, like the pauses between the runs
+	return Aggregate(all, elapsed), each
+}
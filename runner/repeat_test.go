@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestRunner_Repeat(t *testing.T) {
+	rec := &resultsRecorder{}
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &StatusClientMock{},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:   1,
+		Recorders: []Recorder{rec},
+	}
+
+	queries := []query.Query{{Query: "up"}, {Query: "down"}}
+	total, each := r.Repeat(queries, 3, 0)
+	if len(each) != 3 || total.Processed != 6 || len(rec.results) != 6 {
+		t.Fatalf("Runner.Repeat() = %d runs and %d processed, %d recorded, want 3, 6 and 6", len(each), total.Processed, len(rec.results))
+	}
+	for i, s := range each {
+		if s.Processed != 2 {
+			t.Errorf("Runner.Repeat() run %d processed = %d, want 2", i, s.Processed)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Context = ctx
+	if _, each := r.Repeat(queries, 3, time.Hour); len(each) != 1 {
+		t.Errorf("Runner.Repeat() with a done context = %d runs, want 1", len(each))
+	}
+}
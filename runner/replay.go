@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+// ReplayArrival sends the queries of recorded traffic with the pacing they were recorded with (see
+// query.Query.At), compressed or stretched by the Speed, e.g. twice as fast with a Speed of 2.
+// Queries recorded out of order are sent right after the previous one, and the traffic is replayed
+// again from its start once done, e.g. with --repeat.
+type ReplayArrival struct {
+	Speed float64
+	// gaps holds the time between every query and the previous one, as recorded
+	gaps []time.Duration
+	i    int
+}
+
+// NewReplayArrival returns a ReplayArrival of the given queries, in the order they are sent, which
+// must all have been recorded with when they were sent.
+func NewReplayArrival(queries []query.Query, speed float64) (*ReplayArrival, error) {
+	a := &ReplayArrival{Speed: speed, gaps: make([]time.Duration, len(queries))}
+	var previous time.Duration
+	for i, q := range queries {
+		if q.At == nil {
+			return nil, fmt.Errorf("query %d (%s) wasn't recorded with when it was sent, the at column", i+1, q.Query)
+		}
+		if i > 0 {
+			a.gaps[i] = max(0, *q.At-previous)
+		}
+		previous = *q.At
+	}
+	return a, nil
+}
+
+func (a *ReplayArrival) Next() time.Duration {
+	if len(a.gaps) == 0 {
+		return 0
+	}
+	gap := a.gaps[a.i%len(a.gaps)]
+	a.i++
+	return time.Duration(float64(gap) / a.Speed)
+}
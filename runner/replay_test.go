@@ -0,0 +1,44 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestReplayArrival(t *testing.T) {
+	at := func(d time.Duration) query.Query { return query.Query{Query: "up", At: &d} }
+	queries := []query.Query{at(time.Second), at(3 * time.Second), at(2 * time.Second), at(6 * time.Second)}
+
+	tests := []struct {
+		name  string
+		speed float64
+		want  []time.Duration
+	}{
+		// Out of order queries are sent right away, and the traffic is replayed again once done
+		{name: "original pacing", speed: 1, want: []time.Duration{0, 2 * time.Second, 0, 4 * time.Second, 0, 2 * time.Second}},
+		{name: "twice as fast", speed: 2, want: []time.Duration{0, time.Second, 0, 2 * time.Second, 0, time.Second}},
+		{name: "half as fast", speed: 0.5, want: []time.Duration{0, 4 * time.Second, 0, 8 * time.Second, 0, 4 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewReplayArrival(queries, tt.speed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []time.Duration
+			for range tt.want {
+				got = append(got, a.Next())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewReplayArrival([]query.Query{at(0), {Query: "up"}}, 1); err == nil {
+		t.Errorf("NewReplayArrival() of a query not recorded error = nil, want an error")
+	}
+}
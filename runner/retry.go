@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Failures is a Recorder collecting the Results of the queries that failed or failed their
+// assertions, so they can be retried once the run is over, see Runner.RetryFailed.
+type Failures struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+func (f *Failures) Record(r *Result) error {
+	if r.Err == nil && r.Assertion == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, *r)
+	return nil
+}
+
+// Results returns the Results of the queries that failed, in the order they were recorded.
+func (f *Failures) Results() []Result {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.results
+}
+
+// RetriedQuery is a query that failed during a run, retried once afterwards.
+type RetriedQuery struct {
+	Query    string `json:"query"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	// Failures is the number of its executions that failed during the run, and Error the failure
+	// of the first one
+	Failures int    `json:"failures"`
+	Error    string `json:"error"`
+	// RetryError is the failure of the retry, empty if it succeeded
+	RetryError string `json:"retry_error,omitempty"`
+}
+
+// Transient reports whether the query succeeded when retried, i.e. its failures were likely caused
+// by the infrastructure rather than by the query itself.
+func (q RetriedQuery) Transient() bool {
+	return q.RetryError == ""
+}
+
+func (q RetriedQuery) ToString() string {
+	if q.Transient() {
+		return fmt.Sprintf("  Transient failure of query %s (%d failed): %s\n", q.Query, q.Failures, q.Error)
+	}
+	return fmt.Sprintf("  Persistent failure of query %s (%d failed): %s\n", q.Query, q.Failures, q.RetryError)
+}
+
+// Retries describes the retry of the queries that failed during a run.
+type Retries struct {
+	Persistent int            `json:"persistent"`
+	Queries    []RetriedQuery `json:"queries"`
+	Transient  int            `json:"transient"`
+}
+
+func (r *Retries) ToString() (output string) {
+	output += fmt.Sprintf("Retried %d failed queries: %d transient failures, %d persistent failures\n", len(r.Queries), r.Transient, r.Persistent)
+	for _, q := range r.Queries {
+		output += q.ToString()
+	}
+	return
+}
+
+// RetryFailed re-executes once, serially with the Client, every query of the given failed
+// Results, e.g. collected by Failures, telling the failures that were transient from the
+// persistent ones. Queries failing several times are retried once, and relative times are resolved
+// anew. The retries aren't recorded, and stop once the Context of the Runner is done.
+func (r *Runner) RetryFailed(failed []Result) *Retries {
+	retries := &Retries{}
+	var queries []query.Query
+	retried := map[string]int{}
+	for _, res := range failed {
+		key := res.Query.Key()
+		if i, ok := retried[key]; ok {
+			retries.Queries[i].Failures++
+			continue
+		}
+		retried[key] = len(retries.Queries)
+		queries = append(queries, res.Query)
+		retries.Queries = append(retries.Queries, RetriedQuery{
+			Query:    res.Query.Query,
+			Endpoint: res.Query.Endpoint,
+			Start:    res.Query.Start,
+			End:      res.Query.End,
+			Failures: 1,
+			Error:    failure(res),
+		})
+	}
+
+	for i, q := range queries {
+		if r.Context != nil && r.Context.Err() != nil {
+			retries.Queries = retries.Queries[:i]
+			break
+		}
+		res := r.execute(r.Client, job{q: q})
+		if retries.Queries[i].RetryError = failure(res); retries.Queries[i].Transient() {
+			retries.Transient++
+		} else {
+			retries.Persistent++
+		}
+	}
+	return retries
+}
+
+// failure describes why the query of a Result failed, empty if it succeeded.
+func failure(res Result) string {
+	if res.Err != nil {
+		return res.Err.Error()
+	}
+	return res.Assertion
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// FlakyQuerierMock fails the first Flaky executions of every query, and every execution of the
+// Broken queries.
+type FlakyQuerierMock struct {
+	Flaky  int
+	Broken map[string]bool
+	calls  map[string]int
+}
+
+func (m *FlakyQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+	m.calls[q.Query]++
+	if m.Broken[q.Query] {
+		return nil, errors.New("broken")
+	}
+	if m.calls[q.Query] <= m.Flaky {
+		return nil, errors.New("flaky")
+	}
+	return &client.Response{}, nil
+}
+
+func TestRunner_RetryFailed(t *testing.T) {
+	mock := &FlakyQuerierMock{Flaky: 2, Broken: map[string]bool{"broken": true}}
+	failures := &Failures{}
+	r := &Runner{Client: mock, Workers: 1, Recorders: []Recorder{failures}}
+	r.Run([]query.Query{{Query: "up"}, {Query: "broken"}, {Query: "up"}, {Query: "rate(up[5m])"}, {Query: "rate(up[5m])"}, {Query: "rate(up[5m])"}})
+
+	want := &Retries{
+		Persistent: 1,
+		Queries: []RetriedQuery{
+			{Query: "up", Failures: 2, Error: "flaky"},
+			{Query: "broken", Failures: 1, Error: "broken", RetryError: "broken"},
+			{Query: "rate(up[5m])", Failures: 2, Error: "flaky"},
+		},
+		Transient: 2,
+	}
+	if got := r.RetryFailed(failures.Results()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Runner.RetryFailed() = %+v, want %+v", got, want)
+	}
+	if got := mock.calls["up"]; got != 3 {
+		t.Errorf("Runner.RetryFailed() ran up %d times, want it retried once", got-2)
+	}
+}
@@ -0,0 +1,700 @@
+// Package runner executes the benchmark, dispatching the queries to a pool of concurrent workers.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// Result holds the outcome of a single query execution.
+type Result struct {
+	Query query.Query
+	// Worker is the index of the worker that executed the query
+	Worker int
+	// Scheduled is the time the query was due in an open loop, zero otherwise. Measuring the
+	// latency from it rather than from the Start accounts for the time the query was held back
+	// (i.e. coordinated omission)
+	Scheduled time.Time
+	// Start and End are when the query was sent and answered, or failed
+	Start time.Time
+	End   time.Time
+	// Status is the HTTP status code of the response, zero if none was received
+	Status int
+	// Proto is the protocol the response was received over, e.g. HTTP/2.0, empty if none was
+	Proto string
+	// FirstByte is the time from the Start until the first byte of the response was received, and
+	// Transfer until its whole body was, if measured, see client.Response
+	FirstByte time.Duration
+	Transfer  time.Duration
+	// Bytes is the size of the response body, once decompressed
+	Bytes int64
+	// Compressed is the size of the response body as received, if gzip compressed, and Decompress
+	// the time spent decompressing it, not included in the latency
+	Compressed int64
+	Decompress time.Duration
+	// Connect is the time spent opening a new connection for the query, zero if one was reused
+	Connect time.Duration
+	// TLSHandshake is the time spent on the TLS handshake of the new connection, part of the
+	// Connect time, zero if none was made, and TLSResumed whether it resumed a previous session
+	TLSHandshake time.Duration
+	TLSResumed   bool
+	// Decode is the time spent decoding the response body, not included in the latency
+	Decode time.Duration
+	// Exemplars is the number of exemplars returned by a query_exemplars request
+	Exemplars int
+	// Series is the number of series returned, if counted, see client.Response
+	Series int
+	// Fingerprint is the hash of the result returned, if fingerprinted, see client.Fingerprint
+	Fingerprint string
+	// Assertion is the failure of the assertions of the query by its response, empty if they held
+	// or the query has none. Only checked over HTTP, as the rows of SQL queries are not series
+	Assertion string
+	// TraceID and SpanID are the W3C trace context the query was sent with, and RequestID its
+	// X-Request-ID header, if traced
+	TraceID   string
+	SpanID    string
+	RequestID string
+	Err       error
+}
+
+// Recorder is notified of every Result as soon as its query finishes. Record may be called
+// concurrently from multiple workers.
+type Recorder interface {
+	Record(r *Result) error
+}
+
+// Querier executes a single query against the benchmarked target. client.Client queries over
+// HTTP, while other implementations may use other protocols.
+type Querier interface {
+	Query(q *query.Query) (*client.Response, error)
+}
+
+// Runner runs a benchmark against the target of its Client.
+type Runner struct {
+	Client Querier
+	// Workers is the number of concurrent workers sending queries
+	Workers int
+	// Recorders are notified of every Result
+	Recorders []Recorder
+	// Duration stops dispatching queries once elapsed, even if not all of them ran. Zero runs
+	// every query
+	Duration time.Duration
+	// Cycle goes through the queries again once every one was dispatched, until the Duration
+	// elapses. Ignored without a Duration
+	Cycle bool
+	// Arrival schedules the queries in an open loop, each one sent on its own goroutine as soon as
+	// it is due regardless of the responses still pending, instead of by the Workers. Nil runs
+	// a closed loop
+	Arrival Arrival
+	// ThinkTime is the pause of every worker between its queries, modeling users looking at their
+	// dashboards rather than a tight loop, varied uniformly by up to ThinkJitter either way.
+	// Ignored in an open loop
+	ThinkTime   time.Duration
+	ThinkJitter time.Duration
+	// Affinity dispatches every unique query to the same worker, picked by its hash, rather than to
+	// the first worker idle, e.g. to study the effects of per-connection caches of the target.
+	// Ignored in an open loop
+	Affinity bool
+	// Clients are the Queriers of every worker in place of the Client, if given, e.g. with a
+	// connection of their own
+	Clients []Querier
+	// Context stops dispatching queries once done, e.g. to abort the run, like the Duration does.
+	// Nil never stops
+	Context context.Context
+}
+
+// Arrival is the process generating the times queries are sent at in an open loop.
+type Arrival interface {
+	// Next returns the time to wait before sending the next query
+	Next() time.Duration
+}
+
+// ConstantArrival sends queries at a fixed Rate per second.
+type ConstantArrival struct {
+	Rate float64
+}
+
+func (a *ConstantArrival) Next() time.Duration {
+	return time.Duration(float64(time.Second) / a.Rate)
+}
+
+// PoissonArrival sends queries following a Poisson process of the given Rate per second, i.e.
+// with exponentially distributed gaps between them, like independent users would.
+type PoissonArrival struct {
+	Rate float64
+	Rand *rand.Rand
+}
+
+func (a *PoissonArrival) Next() time.Duration {
+	return time.Duration(a.Rand.ExpFloat64() / a.Rate * float64(time.Second))
+}
+
+// Run executes every query once (or until the Duration elapses or the Context is done) and returns
+// the stats of the run.
+func (r *Runner) Run(queries []query.Query) *stats.Stats {
+	return Aggregate(r.run(queries))
+}
+
+// run executes every query once and returns their results and the time it took.
+func (r *Runner) run(queries []query.Query) ([]Result, time.Duration) {
+	// mu guards the results collected by the concurrent workers
+	var mu sync.Mutex
+	var results []Result
+	start := time.Now()
+	r.dispatch(queries, func(j job) {
+		res := r.execute(r.querier(j.worker), j)
+		r.record(&res)
+
+		mu.Lock()
+		results = append(results, res)
+		mu.Unlock()
+	})
+	return results, time.Since(start)
+}
+
+// Comparison holds the outcome of running a query through two different Queriers.
+type Comparison struct {
+	Query  query.Query
+	Result Result
+	Other  Result
+}
+
+// Compare executes every query through both the Client and other, one right after the other, so
+// both paths are measured under the same conditions. Only the Results of the Client are notified to
+// the Recorders. Comparisons are returned in the order of the queries, skipping those not
+// dispatched before the Duration elapsed. Every query is compared once, even if Cycle is set.
+func (r *Runner) Compare(queries []query.Query, other Querier) []Comparison {
+	comparisons := make([]Comparison, len(queries))
+	dispatched := make([]bool, len(queries))
+	once := *r
+	once.Cycle = false
+	once.dispatch(queries, func(j job) {
+		res := r.execute(r.querier(j.worker), j)
+		r.record(&res)
+		comparisons[j.i] = Comparison{Query: j.q, Result: res, Other: r.execute(other, j)}
+		dispatched[j.i] = true
+	})
+
+	var done []Comparison
+	for i, c := range comparisons {
+		if dispatched[i] {
+			done = append(done, c)
+		}
+	}
+	return done
+}
+
+// ColdWarm runs every query twice in a row by the same worker, made unique to the pair with
+// Query.WithNonce, so the first (cold) run can't be answered from a response cache while the second
+// (warm) one can. It returns the results of the cold and warm runs of the queries dispatched, which
+// hold the queries as given.
+func (r *Runner) ColdWarm(queries []query.Query) (cold, warm []Result) {
+	pairs := make([][2]Result, len(queries))
+	dispatched := make([]bool, len(queries))
+	once := *r
+	once.Cycle = false
+	once.dispatch(queries, func(j job) {
+		unique := j
+		unique.q = j.q.WithNonce(query.NewNonce())
+		for i := range pairs[j.i] {
+			res := r.execute(r.querier(j.worker), unique)
+			res.Query = j.q
+			r.record(&res)
+			pairs[j.i][i] = res
+		}
+		dispatched[j.i] = true
+	})
+
+	for i, pair := range pairs {
+		if dispatched[i] {
+			cold, warm = append(cold, pair[0]), append(warm, pair[1])
+		}
+	}
+	return cold, warm
+}
+
+// job is a query dispatched to a worker.
+type job struct {
+	// i is the index of the query q
+	i      int
+	q      query.Query
+	worker int
+	// scheduled is the time the query is due in an open loop, zero otherwise
+	scheduled time.Time
+}
+
+// dispatch feeds the queries to a fixed pool of workers calling exec for every one, until every
+// query is dispatched or the Duration elapses.
+func (r *Runner) dispatch(queries []query.Query, exec func(j job)) {
+	if r.Arrival != nil {
+		r.dispatchOpen(queries, exec)
+		return
+	}
+	if r.Affinity {
+		r.dispatchAffine(queries, exec)
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(r.Workers)
+	jobs := make(chan job)
+	for w := 0; w < r.Workers; w++ {
+		go func(worker int) {
+			defer wg.Done()
+			first := true
+			for j := range jobs {
+				if !first && r.ThinkTime > 0 {
+					time.Sleep(r.think())
+				}
+				first = false
+				j.worker = worker
+				exec(j)
+			}
+		}(w)
+	}
+
+	ctx, cancel := r.runContext()
+	defer cancel()
+
+dispatch:
+	for i := 0; i < r.count(len(queries)); i++ {
+		j := i % len(queries)
+		select {
+		case jobs <- job{i: j, q: queries[j]}:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+}
+
+// dispatchOpen calls exec on a new goroutine for every query as scheduled by the Arrival, until
+// every query is dispatched or the Duration elapses. The worker index is always zero.
+func (r *Runner) dispatchOpen(queries []query.Query, exec func(j job)) {
+	ctx, cancel := r.runContext()
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	// Queries are scheduled from the start of the run rather than from the previous one, so the
+	// time spent dispatching doesn't slow down the arrival rate
+	next := time.Now()
+dispatch:
+	for i := 0; i < r.count(len(queries)); i++ {
+		next = next.Add(r.Arrival.Next())
+		wait := time.NewTimer(time.Until(next))
+		select {
+		case <-wait.C:
+		case <-ctx.Done():
+			wait.Stop()
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+			exec(j)
+		}(job{i: i % len(queries), q: queries[i%len(queries)], scheduled: next})
+	}
+
+	wg.Wait()
+}
+
+// runContext returns the context of a run, done once the Duration elapses or the Context is done.
+func (r *Runner) runContext() (context.Context, context.CancelFunc) {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.Duration > 0 {
+		return context.WithTimeout(ctx, r.Duration)
+	}
+	return context.WithCancel(ctx)
+}
+
+// think returns the pause before the next query of a worker.
+func (r *Runner) think() time.Duration {
+	d := r.ThinkTime
+	if r.ThinkJitter > 0 {
+		d += time.Duration(rand.Int63n(2*int64(r.ThinkJitter)+1)) - r.ThinkJitter
+	}
+	return max(d, 0)
+}
+
+// dispatchAffine calls exec on every query by the worker it is pinned to, until every query is
+// dispatched or the Duration elapses. Every worker goes through the queries pinned to it on its own,
+// so a worker busy with a slow query doesn't hold back the others.
+func (r *Runner) dispatchAffine(queries []query.Query, exec func(j job)) {
+	pinned := make([][]job, r.Workers)
+	for i, q := range queries {
+		w := pin(q, r.Workers)
+		pinned[w] = append(pinned[w], job{i: i, q: q, worker: w})
+	}
+
+	ctx, cancel := r.runContext()
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	wg.Add(r.Workers)
+	for _, jobs := range pinned {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < r.count(len(jobs)); i++ {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				if i > 0 && r.ThinkTime > 0 {
+					time.Sleep(r.think())
+				}
+				exec(jobs[i%len(jobs)])
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// pin returns the worker a query is pinned to, out of the given number of workers.
+func pin(q query.Query, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(q.Endpoint + "\x00" + q.Query))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// count returns the number of the n queries to dispatch, unbounded when cycling through them.
+func (r *Runner) count(n int) int {
+	if r.Cycle && r.Duration > 0 && n > 0 {
+		return math.MaxInt
+	}
+	return n
+}
+
+// querier returns the Querier of the given worker.
+func (r *Runner) querier(worker int) Querier {
+	if worker < len(r.Clients) {
+		return r.Clients[worker]
+	}
+	return r.Client
+}
+
+// execute runs the query of a job through the given Querier.
+func (r *Runner) execute(c Querier, j job) Result {
+	res, _, _ := send(c, j.q.Resolve(time.Now()))
+	res.Worker, res.Scheduled = j.worker, j.scheduled
+	return res
+}
+
+// send sends the query through the Querier, returning its Result along with the response and error
+// of the Querier. Queries failing without a response, e.g. refused or timed out, are timed from the
+// call, so every Result has a Start and End.
+func send(c Querier, q query.Query) (Result, *client.Response, error) {
+	sent := time.Now()
+	resp, err := c.Query(&q)
+	res := newResult(q, resp, err)
+	if res.Start.IsZero() {
+		res.Start, res.End = sent, time.Now()
+	}
+	return res, resp, err
+}
+
+// newResult returns the Result of the query answered with the response, or failed with the error,
+// checking its assertions.
+func newResult(q query.Query, resp *client.Response, err error) Result {
+	res := Result{Query: q, Err: err}
+	if resp != nil {
+		res.Start, res.End = resp.Timestamp.Start, resp.Timestamp.End
+		res.Bytes, res.Decode, res.Exemplars = resp.Bytes, resp.Decode, resp.Exemplars
+		res.Connect = resp.Connect
+		res.TLSHandshake, res.TLSResumed = resp.TLSHandshake, resp.TLSResumed
+		res.Compressed, res.Decompress = resp.Compressed, resp.Decompress
+		res.FirstByte, res.Transfer = resp.FirstByte, resp.Transfer
+		res.TraceID, res.SpanID, res.RequestID = resp.TraceID, resp.SpanID, resp.RequestID
+		res.Series, res.Fingerprint = resp.Series, resp.Fingerprint
+		if resp.Response != nil {
+			res.Status, res.Proto = resp.StatusCode, resp.Proto
+		}
+	}
+	if err == nil && resp != nil && resp.Response != nil {
+		if err := q.Check(resp.Series); err != nil {
+			res.Assertion = err.Error()
+		}
+	}
+	return res
+}
+
+// record notifies the Recorders of a Result.
+func (r *Runner) record(res *Result) {
+	for _, rec := range r.Recorders {
+		if err := rec.Record(res); err != nil {
+			slog.Warn("unable to record result", "err", err)
+		}
+	}
+}
+
+// Aggregate builds the stats of the given results, collected during a run that lasted elapsed.
+func Aggregate(results []Result, elapsed time.Duration) *stats.Stats {
+	var errs stats.ErrorSummary
+	var queryList, scheduledList []query.Query
+	var connect, decode, decompress, tlsHandshake time.Duration
+	var connections, decoded, decompressed, exemplars, assertionFailures, tlsHandshakes, tlsResumed int
+	var bytes, compressed int64
+	var assertionSample string
+	var protocols map[string]int
+	for _, res := range results {
+		if res.Proto != "" {
+			if protocols == nil {
+				protocols = map[string]int{}
+			}
+			protocols[res.Proto]++
+		}
+		if res.Err != nil {
+			errs.Add(fmt.Errorf("query=%v, error=%w", res.Query, res.Err))
+			continue
+		}
+		exemplars += res.Exemplars
+		if res.Assertion != "" {
+			if assertionFailures == 0 {
+				assertionSample = fmt.Sprintf("query=%v, assertion=%s", res.Query.Query, res.Assertion)
+			}
+			assertionFailures++
+		}
+		if res.Connect > 0 {
+			connect += res.Connect
+			connections++
+		}
+		if res.TLSHandshake > 0 {
+			tlsHandshake += res.TLSHandshake
+			tlsHandshakes++
+			if res.TLSResumed {
+				tlsResumed++
+			}
+		}
+		if res.Decode > 0 {
+			decode += res.Decode
+			decoded++
+		}
+		bytes += res.Bytes
+		if res.Compressed > 0 {
+			compressed += res.Compressed
+			decompress += res.Decompress
+			decompressed++
+		} else {
+			compressed += res.Bytes
+		}
+
+		// This part reuses the query structure obtained from the csv and overwrites its time
+		// values for start and end of execution.
+		q := res.Query
+		q.Start = res.Start.UnixMilli()
+		q.End = res.End.UnixMilli()
+		queryList = append(queryList, q)
+		if !res.Scheduled.IsZero() {
+			q.Start = res.Scheduled.UnixMilli()
+			scheduledList = append(scheduledList, q)
+		}
+	}
+
+	// Build stats using the queries processed
+	s := stats.Compute(queryList)
+	latencies := make([]int64, len(queryList))
+	for i, q := range queryList {
+		latencies[i] = q.End - q.Start
+	}
+	s.Histogram = stats.NewHistogram(latencies)
+	s.Processed = len(results) - errs.Total()
+	s.Total = elapsed.Milliseconds()
+	s.Errors = errs
+	s.Exemplars = exemplars
+	s.AssertionFailures, s.AssertionSample = assertionFailures, assertionSample
+	s.Protocols = protocols
+	if len(scheduledList) > 0 {
+		s.Corrected = stats.Compute(scheduledList)
+		s.Corrected.Processed = len(scheduledList)
+	} else {
+		// Open-loop runs send every query on its own goroutine, so they only have workers otherwise
+		s.Workers = workerStats(results, elapsed)
+		s.Utilization = utilization(results, len(s.Workers), elapsed)
+	}
+	if len(results) > 0 {
+		s.SuccessRate = float64(s.Processed-s.AssertionFailures) / float64(len(results))
+	}
+	if elapsed > 0 {
+		s.Throughput = float64(len(results)) / elapsed.Seconds()
+	}
+	if connections > 0 {
+		s.Connections = connections
+		s.Connect = float64(connect) / float64(connections) / float64(time.Millisecond)
+	}
+	if tlsHandshakes > 0 {
+		s.TLSHandshakes, s.TLSResumed = tlsHandshakes, tlsResumed
+		s.TLSHandshake = float64(tlsHandshake) / float64(tlsHandshakes) / float64(time.Millisecond)
+	}
+	if decoded > 0 {
+		s.Decode = float64(decode) / float64(decoded) / float64(time.Millisecond)
+	}
+	s.Bytes = bytes
+	if decompressed > 0 {
+		s.CompressedBytes = compressed
+		s.Decompress = float64(decompress) / float64(decompressed) / float64(time.Millisecond)
+	}
+
+	return s
+}
+
+// workerStats builds the stats of every worker from the results of a run that lasted elapsed.
+func workerStats(results []Result, elapsed time.Duration) []stats.WorkerStats {
+	busy := map[int]time.Duration{}
+	requests := map[int]int{}
+	for _, res := range results {
+		requests[res.Worker]++
+		busy[res.Worker] += res.End.Sub(res.Start)
+	}
+
+	workers := make([]stats.WorkerStats, 0, len(requests))
+	for w, n := range requests {
+		idle := elapsed - busy[w]
+		if idle < 0 { // e.g. the results of a merged run, whose elapsed time is approximated
+			idle = 0
+		}
+		workers = append(workers, stats.WorkerStats{
+			Average:  float64(busy[w]) / float64(n) / float64(time.Millisecond),
+			Idle:     idle.Milliseconds(),
+			Requests: n,
+			Worker:   w,
+		})
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Worker < workers[j].Worker })
+	return workers
+}
+
+// utilization returns the fraction of the time of the given number of workers spent with a query
+// in flight, over a run that lasted elapsed.
+func utilization(results []Result, workers int, elapsed time.Duration) float64 {
+	if workers == 0 || elapsed <= 0 {
+		return 0
+	}
+	var busy time.Duration
+	for _, res := range results {
+		busy += res.End.Sub(res.Start)
+	}
+	// e.g. the results of a merged run, whose elapsed time is approximated
+	return min(float64(busy)/float64(elapsed)/float64(workers), 1)
+}
+
+// Stabilization configures the warm-up phase run before the benchmark is measured. Some targets
+// (e.g. with cold buffers) respond with sustained high latency right after startup, which would
+// otherwise skew the results.
+type Stabilization struct {
+	// Band is the maximum relative deviation from the window mean tolerated for the latency to be
+	// considered stable, e.g. 0.2 for ±20%
+	Band float64
+	// Window is the number of consecutive requests that must fall within the band
+	Window int
+	// Timeout caps the time spent waiting for the target to stabilize
+	Timeout time.Duration
+}
+
+// Pauses of Stabilize after a failed query, doubling with every consecutive failure, and the number
+// of consecutive refused connections it gives up after, as the target is down rather than warming up.
+const (
+	stabilizeMinBackoff = 50 * time.Millisecond
+	stabilizeMaxBackoff = 2 * time.Second
+	stabilizeMaxRefused = 5
+)
+
+// Stabilize sends the queries one at a time (cycling through the list) until the latencies of the
+// last Window requests fall within Band of their mean or Timeout elapses. Failed queries are
+// retried after a growing pause, and the target is given up on once it refused that many
+// connections in a row. It returns the time it took the target to stabilize and whether it did so
+// before the timeout.
+func Stabilize(c Querier, queries []query.Query, s Stabilization) (time.Duration, bool) {
+	if len(queries) == 0 || s.Window < 1 {
+		return 0, true
+	}
+
+	var window []time.Duration
+	var failures, refused int
+	start := time.Now()
+	for i := 0; time.Since(start) < s.Timeout; i++ {
+		q := queries[i%len(queries)].Resolve(time.Now())
+		resp, err := c.Query(&q)
+		if err != nil {
+			window = window[:0] // errors are never considered stable
+			if client.Classify(err) == client.ErrorConnectionRefused {
+				if refused++; refused >= stabilizeMaxRefused {
+					break
+				}
+			} else {
+				refused = 0
+			}
+			backoff := min(stabilizeMinBackoff<<min(failures, 10), stabilizeMaxBackoff)
+			failures++
+			time.Sleep(min(backoff, max(s.Timeout-time.Since(start), 0)))
+			continue
+		}
+		failures, refused = 0, 0
+
+		window = append(window, resp.Timestamp.End.Sub(resp.Timestamp.Start))
+		if len(window) > s.Window {
+			window = window[1:]
+		}
+		if len(window) == s.Window && withinBand(window, s.Band) {
+			return time.Since(start), true
+		}
+	}
+
+	return time.Since(start), false
+}
+
+// withinBand reports whether all the given latencies deviate at most band (relative) from their mean.
+func withinBand(latencies []time.Duration, band float64) bool {
+	var mean float64
+	for _, l := range latencies {
+		mean += float64(l)
+	}
+	mean /= float64(len(latencies))
+
+	for _, l := range latencies {
+		if math.Abs(float64(l)-mean) > band*mean {
+			return false
+		}
+	}
+	return true
+}
+
+// Calibrate runs the given query serially the given number of times and returns its median latency
+// in milliseconds. The query is requested over the last hour, so it should be cheap (e.g.
+// `vector(1)`) to capture the baseline overhead of the environment rather than the query cost.
+func Calibrate(c Querier, expr string, runs int) (float64, error) {
+	now := time.Now()
+	q := query.Query{Query: expr, Start: now.Add(-time.Hour).UnixMilli(), End: now.UnixMilli(), Step: 60}
+
+	latencies := make([]float64, runs)
+	for i := range latencies {
+		resp, err := c.Query(&q)
+		if err != nil {
+			return 0, fmt.Errorf("calibration query failed. err=%w", err)
+		}
+		latencies[i] = float64(resp.Timestamp.End.Sub(resp.Timestamp.Start)) / float64(time.Millisecond)
+	}
+
+	sort.Float64s(latencies)
+	if runs%2 != 0 {
+		return latencies[runs/2], nil
+	}
+	return (latencies[runs/2-1] + latencies[runs/2]) / 2, nil
+}
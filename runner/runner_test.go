@@ -0,0 +1,567 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// SlowStartClientMock responds slowly to the first Cold requests and fast afterwards.
+type SlowStartClientMock struct {
+	Cold  int
+	calls int
+}
+
+func (c *SlowStartClientMock) Do(req *http.Request) (resp *http.Response, err error) {
+	c.calls++
+	if c.calls <= c.Cold {
+		time.Sleep(time.Duration(c.calls) * 5 * time.Millisecond)
+	} else {
+		time.Sleep(time.Millisecond)
+	}
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func TestStabilize(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     *SlowStartClientMock
+		cfg        Stabilization
+		wantStable bool
+	}{
+		{
+			name:       "stabilizes after cold start",
+			client:     &SlowStartClientMock{Cold: 3},
+			cfg:        Stabilization{Band: 0.3, Window: 3, Timeout: time.Second},
+			wantStable: true,
+		},
+		{
+			name:       "times out",
+			client:     &SlowStartClientMock{Cold: 1000},
+			cfg:        Stabilization{Band: 0.01, Window: 3, Timeout: 50 * time.Millisecond},
+			wantStable: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client.Client{Client: tt.client, URL: &url.URL{Scheme: "http", Host: "promscale.xyz"}, Version: "v1"}
+			_, stable := Stabilize(c, []query.Query{{Query: "up", Start: 0, End: 1, Step: 1}}, tt.cfg)
+			if stable != tt.wantStable {
+				t.Errorf("Stabilize() stable = %v, want %v", stable, tt.wantStable)
+			}
+			if tt.wantStable && tt.client.calls < tt.client.Cold+tt.cfg.Window {
+				t.Errorf("Stabilize() measured after %d calls, want at least %d", tt.client.calls, tt.client.Cold+tt.cfg.Window)
+			}
+		})
+	}
+}
+
+// RefusedQuerierMock fails every query as if the target refused the connection.
+type RefusedQuerierMock struct {
+	calls int
+}
+
+func (m *RefusedQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	m.calls++
+	return nil, fmt.Errorf("dial. err=%w", syscall.ECONNREFUSED)
+}
+
+func TestRunner_Run_refused(t *testing.T) {
+	rec := &resultsRecorder{}
+	r := &Runner{Client: &RefusedQuerierMock{}, Workers: 1, Recorders: []Recorder{rec}}
+	before := time.Now()
+	r.Run([]query.Query{{Query: "up"}})
+	if len(rec.results) != 1 || rec.results[0].Start.Before(before) || rec.results[0].End.Before(rec.results[0].Start) {
+		t.Errorf("Runner.Run() results = %+v, want the refused query timed from its call", rec.results)
+	}
+}
+
+func TestStabilize_failures(t *testing.T) {
+	q := []query.Query{{Query: "up", Start: 0, End: 1, Step: 1}}
+
+	// Failing queries are retried after a pause rather than in a tight loop
+	down := &DownQuerierMock{Down: -1}
+	if _, stable := Stabilize(down, q, Stabilization{Band: 0.3, Window: 3, Timeout: 300 * time.Millisecond}); stable || down.calls > 5 {
+		t.Errorf("Stabilize() stable = %v after %d calls, want unstable after a few", stable, down.calls)
+	}
+
+	// A target refusing connections is given up on before the timeout
+	refused := &RefusedQuerierMock{}
+	if elapsed, stable := Stabilize(refused, q, Stabilization{Band: 0.3, Window: 3, Timeout: time.Minute}); stable || refused.calls != stabilizeMaxRefused || elapsed > 5*time.Second {
+		t.Errorf("Stabilize() stable = %v after %d calls and %s, want unstable after %d", stable, refused.calls, elapsed, stabilizeMaxRefused)
+	}
+}
+
+// StatusClientMock answers every request with the status code set for its query expression.
+type StatusClientMock struct {
+	Status map[string]int
+}
+
+func (c *StatusClientMock) Do(req *http.Request) (resp *http.Response, err error) {
+	status, ok := c.Status[req.URL.Query().Get("query")]
+	if !ok {
+		status = 200
+	}
+	return &http.Response{StatusCode: status}, nil
+}
+
+// resultsRecorder keeps every Result recorded.
+type resultsRecorder struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+func (r *resultsRecorder) Record(res *Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, *res)
+	return nil
+}
+
+func TestRunner_Run(t *testing.T) {
+	rec := &resultsRecorder{}
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &StatusClientMock{Status: map[string]int{"broken": 500}},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:   3,
+		Recorders: []Recorder{rec},
+	}
+
+	queries := []query.Query{{Query: "up"}, {Query: "broken"}, {Query: "up"}, {Query: "up"}}
+	s := r.Run(queries)
+	if s.Processed != 3 || s.Errors.Total() != 1 {
+		t.Errorf("Runner.Run() processed = %d, errors = %d, want 3 and 1", s.Processed, s.Errors.Total())
+	}
+	if len(rec.results) != len(queries) {
+		t.Fatalf("Runner.Run() recorded %d results, want %d", len(rec.results), len(queries))
+	}
+	for _, res := range rec.results {
+		if res.Worker < 0 || res.Worker >= r.Workers {
+			t.Errorf("Runner.Run() recorded worker %d, want one in [0, %d)", res.Worker, r.Workers)
+		}
+	}
+}
+
+// SleepClientMock answers every request successfully after sleeping Latency.
+type SleepClientMock struct {
+	Latency time.Duration
+}
+
+func (c *SleepClientMock) Do(req *http.Request) (resp *http.Response, err error) {
+	time.Sleep(c.Latency)
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func TestRunner_Run_duration(t *testing.T) {
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &SleepClientMock{Latency: 10 * time.Millisecond},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:  1,
+		Duration: 35 * time.Millisecond,
+	}
+
+	queries := make([]query.Query, 100)
+	s := r.Run(queries)
+	if s.Processed == 0 || s.Processed >= len(queries)/2 {
+		t.Errorf("Runner.Run() processed = %d, want the run stopped early", s.Processed)
+	}
+}
+
+func TestRunner_Compare(t *testing.T) {
+	rec := &resultsRecorder{}
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &StatusClientMock{Status: map[string]int{"broken": 500}},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:   2,
+		Recorders: []Recorder{rec},
+	}
+	other := &client.Client{
+		Client:  &StatusClientMock{Status: map[string]int{"up": 500}},
+		URL:     &url.URL{Scheme: "http", Host: "other.xyz"},
+		Version: "v1",
+	}
+
+	queries := []query.Query{{Query: "up"}, {Query: "broken"}, {Query: "rate"}}
+	comparisons := r.Compare(queries, other)
+	if len(comparisons) != len(queries) {
+		t.Fatalf("Runner.Compare() = %d comparisons, want %d", len(comparisons), len(queries))
+	}
+	for i, c := range comparisons {
+		if !reflect.DeepEqual(c.Query, queries[i]) {
+			t.Errorf("Runner.Compare() comparison %d query = %v, want %v", i, c.Query, queries[i])
+		}
+		if c.Result.Worker != c.Other.Worker {
+			t.Errorf("Runner.Compare() ran %v on workers %d and %d, want the same", c.Query, c.Result.Worker, c.Other.Worker)
+		}
+	}
+	if comparisons[0].Result.Err != nil || comparisons[0].Other.Err == nil {
+		t.Errorf("Runner.Compare() errors of up = %v and %v, want only the other failing", comparisons[0].Result.Err, comparisons[0].Other.Err)
+	}
+	if comparisons[1].Result.Err == nil || comparisons[1].Other.Err != nil {
+		t.Errorf("Runner.Compare() errors of broken = %v and %v, want only the client failing", comparisons[1].Result.Err, comparisons[1].Other.Err)
+	}
+	if len(rec.results) != len(queries) {
+		t.Errorf("Runner.Compare() recorded %d results, want only the %d of the client", len(rec.results), len(queries))
+	}
+}
+
+// QueriesClientMock answers every request successfully, keeping the queries requested.
+type QueriesClientMock struct {
+	mu      sync.Mutex
+	Queries []string
+}
+
+func (c *QueriesClientMock) Do(req *http.Request) (resp *http.Response, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Queries = append(c.Queries, req.URL.Query().Get("query"))
+	return &http.Response{StatusCode: 200}, nil
+}
+
+func TestRunner_ColdWarm(t *testing.T) {
+	rec := &resultsRecorder{}
+	mock := &QueriesClientMock{}
+	r := &Runner{
+		Client: &client.Client{
+			Client:  mock,
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:   2,
+		Recorders: []Recorder{rec},
+	}
+
+	queries := []query.Query{{Query: "up"}, {Query: "rate(x[5m])"}, {Query: "sum(y)"}}
+	cold, warm := r.ColdWarm(queries)
+	if len(cold) != len(queries) || len(warm) != len(queries) {
+		t.Fatalf("Runner.ColdWarm() = %d cold and %d warm results, want %d", len(cold), len(warm), len(queries))
+	}
+	for i := range cold {
+		if !reflect.DeepEqual(cold[i].Query, queries[i]) || !reflect.DeepEqual(warm[i].Query, queries[i]) {
+			t.Errorf("Runner.ColdWarm() results of %v hold %v and %v", queries[i], cold[i].Query, warm[i].Query)
+		}
+		if cold[i].Worker != warm[i].Worker || warm[i].Start.Before(cold[i].End) {
+			t.Errorf("Runner.ColdWarm() ran %v on workers %d and %d, want in a row on the same", queries[i], cold[i].Worker, warm[i].Worker)
+		}
+	}
+
+	// Every query is requested twice, unique to the pair
+	requested := map[string]int{}
+	for _, q := range mock.Queries {
+		if !strings.Contains(q, query.NonceLabel) {
+			t.Errorf("Runner.ColdWarm() requested %q, want a nonce matcher", q)
+		}
+		requested[q]++
+	}
+	if len(requested) != len(queries) {
+		t.Errorf("Runner.ColdWarm() requested %d distinct queries, want %d", len(requested), len(queries))
+	}
+	for q, n := range requested {
+		if n != 2 {
+			t.Errorf("Runner.ColdWarm() requested %q %d times, want 2", q, n)
+		}
+	}
+	if len(rec.results) != 2*len(queries) {
+		t.Errorf("Runner.ColdWarm() recorded %d results, want %d", len(rec.results), 2*len(queries))
+	}
+}
+
+func TestRunner_Run_thinkTime(t *testing.T) {
+	rec := &resultsRecorder{}
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &StatusClientMock{},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:   1,
+		Recorders: []Recorder{rec},
+		ThinkTime: 30 * time.Millisecond,
+	}
+
+	r.Run(make([]query.Query, 3))
+	for i := 1; i < len(rec.results); i++ {
+		if pause := rec.results[i].Start.Sub(rec.results[i-1].End); pause < r.ThinkTime {
+			t.Errorf("Runner.Run() paused %v before query %d, want at least %v", pause, i, r.ThinkTime)
+		}
+	}
+}
+
+func TestRunner_think(t *testing.T) {
+	tests := []struct {
+		name     string
+		think    time.Duration
+		jitter   time.Duration
+		min, max time.Duration
+	}{
+		{name: "no jitter", think: 200 * time.Millisecond, min: 200 * time.Millisecond, max: 200 * time.Millisecond},
+		{name: "jitter", think: 200 * time.Millisecond, jitter: 50 * time.Millisecond, min: 150 * time.Millisecond, max: 250 * time.Millisecond},
+		{name: "jitter beyond think time", think: 10 * time.Millisecond, jitter: 50 * time.Millisecond, max: 60 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Runner{ThinkTime: tt.think, ThinkJitter: tt.jitter}
+			for range 100 {
+				if got := r.think(); got < tt.min || got > tt.max {
+					t.Fatalf("Runner.think() = %v, want between %v and %v", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRunner_Run_affinity(t *testing.T) {
+	rec := &resultsRecorder{}
+	mocks := []*QueriesClientMock{{}, {}, {}}
+	r := &Runner{
+		Workers:   len(mocks),
+		Recorders: []Recorder{rec},
+		Affinity:  true,
+	}
+	for _, mock := range mocks {
+		r.Clients = append(r.Clients, &client.Client{Client: mock, URL: &url.URL{Scheme: "http", Host: "promscale.xyz"}, Version: "v1"})
+	}
+
+	var queries []query.Query
+	for range 5 {
+		queries = append(queries, query.Query{Query: "up"}, query.Query{Query: "rate(x[5m])"}, query.Query{Query: "sum(y)"}, query.Query{Query: "z"})
+	}
+	if s := r.Run(queries); s.Processed != len(queries) {
+		t.Fatalf("Runner.Run() processed = %d, want %d", s.Processed, len(queries))
+	}
+
+	workers := map[string]int{}
+	for _, res := range rec.results {
+		if w, ok := workers[res.Query.Query]; ok && w != res.Worker {
+			t.Errorf("Runner.Run() ran %q on workers %d and %d, want it pinned", res.Query.Query, w, res.Worker)
+		}
+		workers[res.Query.Query] = res.Worker
+	}
+	for w, mock := range mocks {
+		for _, q := range mock.Queries {
+			if workers[q] != w {
+				t.Errorf("Runner.Run() sent %q through the client of worker %d, want %d", q, w, workers[q])
+			}
+		}
+	}
+}
+
+func TestRunner_Run_openLoop(t *testing.T) {
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &SleepClientMock{Latency: 50 * time.Millisecond},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers: 1,
+		Arrival: &ConstantArrival{Rate: 200},
+	}
+
+	// A closed loop with a single worker would take a second
+	queries := make([]query.Query, 20)
+	start := time.Now()
+	s := r.Run(queries)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Runner.Run() took %v, want the queries sent regardless of the pending responses", elapsed)
+	}
+	if s.Processed != len(queries) {
+		t.Errorf("Runner.Run() processed = %d, want %d", s.Processed, len(queries))
+	}
+	if s.Corrected == nil || s.Corrected.Processed != len(queries) {
+		t.Errorf("Runner.Run() corrected = %+v, want the stats of every query", s.Corrected)
+	}
+}
+
+func TestPoissonArrival_Next(t *testing.T) {
+	a := &PoissonArrival{Rate: 100, Rand: rand.New(rand.NewSource(1))}
+
+	var total time.Duration
+	n := 10000
+	for i := 0; i < n; i++ {
+		total += a.Next()
+	}
+	if mean := total / time.Duration(n); mean < 9500*time.Microsecond || mean > 10500*time.Microsecond {
+		t.Errorf("PoissonArrival.Next() mean = %v, want about 10ms", mean)
+	}
+}
+
+func TestAggregate_corrected(t *testing.T) {
+	due := time.UnixMilli(0)
+	results := []Result{
+		// Sent on time
+		{Scheduled: due, Start: due, End: due.Add(10 * time.Millisecond)},
+		// Held back for 90ms before being sent
+		{Scheduled: due, Start: due.Add(90 * time.Millisecond), End: due.Add(100 * time.Millisecond)},
+	}
+
+	s := Aggregate(results, time.Second)
+	if s.Slowest != 10 {
+		t.Errorf("Aggregate() slowest = %d, want 10", s.Slowest)
+	}
+	if s.Corrected == nil || s.Corrected.Slowest != 100 || s.Corrected.Average != 55 {
+		t.Errorf("Aggregate() corrected = %+v, want slowest 100 and average 55", s.Corrected)
+	}
+
+	if s := Aggregate(results[:0], time.Second); s.Corrected != nil {
+		t.Errorf("Aggregate() of a closed loop corrected = %+v, want none", s.Corrected)
+	}
+}
+
+func TestAggregate_workers(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Worker: 1, Start: start, End: start.Add(30 * time.Millisecond)},
+		{Worker: 0, Start: start, End: start.Add(20 * time.Millisecond)},
+		{Worker: 0, Start: start.Add(20 * time.Millisecond), End: start.Add(60 * time.Millisecond)},
+	}
+
+	got := Aggregate(results, 100*time.Millisecond).Workers
+	want := []stats.WorkerStats{
+		{Worker: 0, Requests: 2, Average: 30, Idle: 40},
+		{Worker: 1, Requests: 1, Average: 30, Idle: 70},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Aggregate() workers = %+v, want %+v", got, want)
+	}
+}
+
+func TestAggregate_compression(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Start: start, End: start.Add(10 * time.Millisecond), Bytes: 1000, Compressed: 100, Decompress: 2 * time.Millisecond},
+		{Start: start, End: start.Add(10 * time.Millisecond), Bytes: 500, Compressed: 50, Decompress: 4 * time.Millisecond},
+		{Start: start, End: start.Add(10 * time.Millisecond), Bytes: 10},
+	}
+
+	s := Aggregate(results, time.Second)
+	if s.Bytes != 1510 || s.CompressedBytes != 160 || s.Decompress != 3 {
+		t.Errorf("Aggregate() = %d bytes, %d compressed, decompressed in %fms, want 1510, 160 and 3ms", s.Bytes, s.CompressedBytes, s.Decompress)
+	}
+}
+
+func TestAggregate_throughput(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Worker: 0, Start: start, End: start.Add(100 * time.Millisecond)},
+		{Worker: 0, Start: start.Add(100 * time.Millisecond), End: start.Add(400 * time.Millisecond)},
+		{Worker: 1, Start: start, End: start.Add(200 * time.Millisecond), Err: errors.New("timeout")},
+		{Worker: 1, Start: start.Add(200 * time.Millisecond), End: start.Add(400 * time.Millisecond), Assertion: "got 0 series, want at least 1"},
+	}
+
+	s := Aggregate(results, 500*time.Millisecond)
+	if s.Throughput != 8 || s.SuccessRate != 0.5 || s.Utilization != 0.8 {
+		t.Errorf("Aggregate() = %f queries/s, success rate %f and utilization %f, want 8, 0.5 and 0.8", s.Throughput, s.SuccessRate, s.Utilization)
+	}
+
+	results[0].Scheduled = start
+	if s := Aggregate(results, 500*time.Millisecond); s.Utilization != 0 {
+		t.Errorf("Aggregate() of an open loop utilization = %f, want none", s.Utilization)
+	}
+	if s := Aggregate(nil, 0); s.Throughput != 0 || s.SuccessRate != 0 {
+		t.Errorf("Aggregate() of no results = %f queries/s and success rate %f, want none", s.Throughput, s.SuccessRate)
+	}
+}
+
+func TestAggregate_protocols(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Proto: "HTTP/2.0", Start: start, End: start.Add(10 * time.Millisecond)},
+		{Proto: "HTTP/2.0", Start: start, End: start.Add(20 * time.Millisecond), Err: errors.New("timeout")},
+		{Proto: "HTTP/1.1", Start: start, End: start.Add(30 * time.Millisecond)},
+		// Not sent over HTTP, e.g. SQL queries
+		{Start: start, End: start.Add(40 * time.Millisecond)},
+	}
+
+	got := Aggregate(results, time.Second).Protocols
+	if want := map[string]int{"HTTP/1.1": 1, "HTTP/2.0": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Aggregate() protocols = %v, want %v", got, want)
+	}
+}
+
+func TestAggregate_connect(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Connect: 4 * time.Millisecond, Start: start, End: start.Add(10 * time.Millisecond)},
+		{Start: start, End: start.Add(10 * time.Millisecond)},
+		{Connect: 2 * time.Millisecond, Start: start, End: start.Add(10 * time.Millisecond)},
+	}
+
+	s := Aggregate(results, time.Second)
+	if s.Connections != 2 || s.Connect != 3 {
+		t.Errorf("Aggregate() = %d connections opened in %fms, want 2 in 3ms", s.Connections, s.Connect)
+	}
+}
+
+func TestAggregate_tls(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Connect: 4 * time.Millisecond, TLSHandshake: 3 * time.Millisecond, Start: start, End: start.Add(10 * time.Millisecond)},
+		{Start: start, End: start.Add(10 * time.Millisecond)},
+		{Connect: 2 * time.Millisecond, TLSHandshake: time.Millisecond, TLSResumed: true, Start: start, End: start.Add(10 * time.Millisecond)},
+		// Failed queries are left out
+		{Connect: 2 * time.Millisecond, TLSHandshake: time.Millisecond, Start: start, End: start.Add(10 * time.Millisecond), Err: errors.New("timeout")},
+	}
+
+	s := Aggregate(results, time.Second)
+	if s.TLSHandshakes != 2 || s.TLSHandshake != 2 || s.TLSResumed != 1 {
+		t.Errorf("Aggregate() = %d TLS handshakes in %fms, %d resumed, want 2 in 2ms, 1 resumed", s.TLSHandshakes, s.TLSHandshake, s.TLSResumed)
+	}
+}
+
+func TestAggregate_assertions(t *testing.T) {
+	start := time.UnixMilli(0)
+	results := []Result{
+		{Query: query.Query{Query: "up"}, Start: start, End: start.Add(10 * time.Millisecond)},
+		{Query: query.Query{Query: "a"}, Assertion: "got 0 series, want at least 1", Start: start, End: start.Add(10 * time.Millisecond)},
+		{Query: query.Query{Query: "b"}, Assertion: "got an empty result, want a non-empty one", Start: start, End: start.Add(10 * time.Millisecond)},
+		{Query: query.Query{Query: "c"}, Err: errors.New("connection refused")},
+	}
+
+	s := Aggregate(results, time.Second)
+	if s.AssertionFailures != 2 || s.Errors.Total() != 1 || s.Processed != 3 {
+		t.Errorf("Aggregate() = %d assertion failures, %d errors and %d processed, want 2, 1 and 3", s.AssertionFailures, s.Errors.Total(), s.Processed)
+	}
+	if want := "query=a, assertion=got 0 series, want at least 1"; s.AssertionSample != want {
+		t.Errorf("Aggregate() assertion sample = %q, want %q", s.AssertionSample, want)
+	}
+}
+
+func TestRunner_Run_assertions(t *testing.T) {
+	rec := &resultsRecorder{}
+	r := &Runner{
+		Client: &client.Client{
+			Client:  &StatusClientMock{},
+			URL:     &url.URL{Scheme: "http", Host: "promscale.xyz"},
+			Version: "v1",
+		},
+		Workers:   1,
+		Recorders: []Recorder{rec},
+	}
+
+	// The mock answers without a body, which holds no series
+	s := r.Run([]query.Query{{Query: "up"}, {Query: "up", ExpectNonEmpty: true}})
+	if s.AssertionFailures != 1 || rec.results[1].Assertion == "" {
+		t.Errorf("Runner.Run() = %d assertion failures, want the empty result of the query expecting one", s.AssertionFailures)
+	}
+}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Splitter is a Querier sending every range query spanning longer than MinRange as Chunks
+// sequential requests of consecutive sub-ranges, as the query frontends of Thanos, Cortex or Loki
+// split long queries, so whether splitting helps the target can be evaluated. The Response of a
+// split query is reassembled from those of its chunks: it spans from the start of the first one to
+// the end of the last, and holds the size of all of them and the most series any returned. Its
+// result isn't fingerprinted. The results of every chunk and every reassembled query are
+// collected so they can be reported apart.
+type Splitter struct {
+	Querier
+	Chunks   int
+	MinRange time.Duration
+
+	mu          sync.Mutex
+	chunks      []Result
+	reassembled []Result
+}
+
+func (s *Splitter) Query(q *query.Query) (*client.Response, error) {
+	chunks := s.Split(*q)
+	if len(chunks) < 2 {
+		return s.Querier.Query(q)
+	}
+
+	reassembled := &client.Response{}
+	var results []Result
+	var err error
+	start := time.Now()
+	for i := range chunks {
+		var resp *client.Response
+		sent := time.Now()
+		resp, err = s.Querier.Query(&chunks[i])
+		res := Result{Query: chunks[i], Start: sent, End: time.Now(), Err: err}
+		if resp != nil {
+			res.Start, res.End, res.Bytes = resp.Timestamp.Start, resp.Timestamp.End, resp.Bytes
+			if resp.Response != nil {
+				res.Status, res.Proto = resp.StatusCode, resp.Proto
+			}
+			if i == 0 {
+				reassembled.Timestamp.Start = resp.Timestamp.Start
+				reassembled.TraceID, reassembled.SpanID, reassembled.RequestID = resp.TraceID, resp.SpanID, resp.RequestID
+			}
+			reassembled.Response, reassembled.Timestamp.End = resp.Response, resp.Timestamp.End
+			reassembled.Bytes += resp.Bytes
+			reassembled.Connect += resp.Connect
+			reassembled.Decode += resp.Decode
+			reassembled.Series = max(reassembled.Series, resp.Series)
+		}
+		results = append(results, res)
+		// The query failed with its first failing chunk
+		if err != nil {
+			break
+		}
+	}
+
+	whole := Result{Query: *q, Start: reassembled.Timestamp.Start, End: reassembled.Timestamp.End, Bytes: reassembled.Bytes, Err: err}
+	if whole.Start.IsZero() {
+		// No chunk was answered
+		whole.Start, whole.End = start, time.Now()
+	}
+	if reassembled.Response != nil {
+		whole.Status, whole.Proto = reassembled.StatusCode, reassembled.Proto
+	}
+	s.mu.Lock()
+	s.chunks = append(s.chunks, results...)
+	s.reassembled = append(s.reassembled, whole)
+	s.mu.Unlock()
+
+	if reassembled.Timestamp.Start.IsZero() {
+		return nil, err
+	}
+	return reassembled, err
+}
+
+// Split returns the chunks the query is sent as, or the query itself if it isn't split. The
+// sub-ranges are aligned on the step, so no point is evaluated twice, and the last chunk ends with
+// the query.
+func (s *Splitter) Split(q query.Query) []query.Query {
+	span := time.Duration(q.End-q.Start) * time.Millisecond
+	step := int64(q.Step) * 1000
+	if !q.RangeQuery() || s.Chunks < 2 || step <= 0 || span <= s.MinRange {
+		return []query.Query{q}
+	}
+	// Chunks are given a whole number of steps, the last one those left
+	steps := (q.End-q.Start)/step + 1
+	per := (steps + int64(s.Chunks) - 1) / int64(s.Chunks)
+	var chunks []query.Query
+	for start := q.Start; start <= q.End; start += per * step {
+		chunk := q
+		chunk.Start, chunk.End = start, min(start+(per-1)*step, q.End)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// Results returns the results of every chunk sent and of every query split, reassembled from its
+// chunks.
+func (s *Splitter) Results() (chunks, reassembled []Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chunks, s.reassembled
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestSplitter_Split(t *testing.T) {
+	s := &Splitter{Chunks: 3, MinRange: time.Hour}
+	tests := []struct {
+		name string
+		q    query.Query
+		want [][2]int64
+	}{
+		{
+			name: "split on steps",
+			q:    query.Query{Query: "up", Start: 0, End: 3 * 3600_000, Step: 60},
+			want: [][2]int64{{0, 3600_000}, {3660_000, 7260_000}, {7320_000, 3 * 3600_000}},
+		},
+		{
+			name: "short range",
+			q:    query.Query{Query: "up", Start: 0, End: 3600_000, Step: 60},
+			want: [][2]int64{{0, 3600_000}},
+		},
+		{
+			name: "fewer steps than chunks",
+			q:    query.Query{Query: "up", Start: 0, End: 2 * 3600_000, Step: 3600},
+			want: [][2]int64{{0, 0}, {3600_000, 3600_000}, {7200_000, 7200_000}},
+		},
+		{
+			name: "metadata endpoint",
+			q:    query.Query{Query: "up", Endpoint: query.EndpointSeries, Start: 0, End: 3 * 3600_000},
+			want: [][2]int64{{0, 3 * 3600_000}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][2]int64
+			for _, chunk := range s.Split(tt.q) {
+				got = append(got, [2]int64{chunk.Start, chunk.End})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Splitter.Split() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ChunkQuerierMock answers every query after a millisecond, failing those starting at Fail.
+type ChunkQuerierMock struct {
+	Fail    int64
+	Queries []query.Query
+}
+
+func (m *ChunkQuerierMock) Query(q *query.Query) (*client.Response, error) {
+	m.Queries = append(m.Queries, *q)
+	resp := &client.Response{Bytes: 10}
+	resp.Timestamp.Start = time.Now()
+	time.Sleep(time.Millisecond)
+	resp.Timestamp.End = time.Now()
+	if m.Fail > 0 && q.Start == m.Fail {
+		return resp, errors.New("chunk failed")
+	}
+	return resp, nil
+}
+
+func TestSplitter_Query(t *testing.T) {
+	mock := &ChunkQuerierMock{}
+	s := &Splitter{Querier: mock, Chunks: 2}
+	q := query.Query{Query: "up", Start: 0, End: 3600_000, Step: 60}
+	resp, err := s.Query(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.Queries) != 2 {
+		t.Fatalf("Splitter.Query() sent %d requests, want 2", len(mock.Queries))
+	}
+	chunks, reassembled := s.Results()
+	if len(chunks) != 2 || len(reassembled) != 1 {
+		t.Fatalf("Splitter.Results() = %d chunks and %d reassembled, want 2 and 1", len(chunks), len(reassembled))
+	}
+	if resp.Bytes != 20 || resp.Timestamp.Start != chunks[0].Start || resp.Timestamp.End != chunks[1].End {
+		t.Errorf("Splitter.Query() = %d bytes from %v to %v, want both chunks", resp.Bytes, resp.Timestamp.Start, resp.Timestamp.End)
+	}
+	if reassembled[0].Query.Start != 0 || reassembled[0].Query.End != 3600_000 {
+		t.Errorf("Splitter.Results() reassembled %+v, want the whole query", reassembled[0].Query)
+	}
+
+	// The third chunk isn't sent once the second one failed
+	s.Chunks, mock.Queries = 3, nil
+	mock.Fail = s.Split(q)[1].Start
+	if _, err := s.Query(&q); err == nil {
+		t.Errorf("Splitter.Query() with a failed chunk error = nil, want an error")
+	}
+	if len(mock.Queries) != 2 {
+		t.Errorf("Splitter.Query() sent %d requests, want 2 up to the failed chunk", len(mock.Queries))
+	}
+}
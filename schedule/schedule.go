@@ -0,0 +1,124 @@
+// Package schedule parses cron-style schedules of recurring runs.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron-style schedule of five space-separated fields: minute (0-59), hour (0-23), day
+// of the month (1-31), month (1-12) and day of the week (0-6, Sunday being 0 or 7). Every field is
+// a comma-separated list of values, ranges (1-5) or * for every value, optionally with a step
+// (*/15 or 0-30/10). As in cron, when both the day of the month and the day of the week are
+// restricted, a day matching either of them matches.
+type Schedule struct {
+	minutes, hours, days, months, weekdays []bool
+	// anyDay and anyWeekday are set when the day of the month or of the week is *
+	anyDay, anyWeekday bool
+}
+
+// Parse parses a schedule, e.g. "0 2 * * *" every day at 02:00.
+func Parse(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, want 5 fields: minute hour day-of-month month day-of-week", spec)
+	}
+
+	s := &Schedule{anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	var err error
+	if s.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute. err=%w", err)
+	}
+	if s.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour. err=%w", err)
+	}
+	if s.days, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid day of the month. err=%w", err)
+	}
+	if s.months, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid month. err=%w", err)
+	}
+	if s.weekdays, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid day of the week. err=%w", err)
+	}
+	s.weekdays[0] = s.weekdays[0] || s.weekdays[7]
+	return s, nil
+}
+
+// parseField returns the values between min and max (inclusive) matched by a field, indexed by
+// value.
+func parseField(field string, min, max int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			rng = part[:i]
+		}
+
+		from, to := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				// e.g. 5/15 from 5 to the end
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Next returns the first time after t matching the schedule, in the location of t, or the zero
+// time if none does within five years (e.g. on the 31st of February).
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case !s.months[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) day(t time.Time) bool {
+	day, weekday := s.days[t.Day()], s.weekdays[t.Weekday()]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
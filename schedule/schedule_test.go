@@ -0,0 +1,48 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2024, time.May, 1, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "0 2 * * *", want: time.Date(2024, time.May, 2, 2, 0, 0, 0, time.UTC)},
+		{spec: "* * * * *", want: time.Date(2024, time.May, 1, 10, 31, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", want: time.Date(2024, time.May, 1, 10, 45, 0, 0, time.UTC)},
+		{spec: "0-10/5 11 * * *", want: time.Date(2024, time.May, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "30 10 * * *", want: time.Date(2024, time.May, 2, 10, 30, 0, 0, time.UTC)},
+		{spec: "0 0 1 1 *", want: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * 1-5", want: time.Date(2024, time.May, 2, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * 0", want: time.Date(2024, time.May, 5, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * 7", want: time.Date(2024, time.May, 5, 9, 0, 0, 0, time.UTC)},
+		// Either the 10th or a Friday
+		{spec: "0 9 10 * 5", want: time.Date(2024, time.May, 3, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", want: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 31 2 *", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := s.Next(now); !got.Equal(tt.want) {
+				t.Errorf("Schedule.Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, spec := range []string{"", "0 2 * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) error = nil, want one", spec)
+		}
+	}
+}
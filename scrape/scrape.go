@@ -0,0 +1,220 @@
+// Package scrape samples the metrics exposed by the benchmarked servers while the benchmark runs,
+// so client-side latencies can be read against the resources used by the server.
+package scrape
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+// DefaultMetrics are the metrics sampled unless others are given: the CPU and memory used by the
+// server process and the queries running on a Prometheus engine.
+var DefaultMetrics = []string{"process_cpu_seconds_total", "process_resident_memory_bytes", "prometheus_engine_queries"}
+
+// Sample holds the metrics scraped from every target at a point of the run, along with the latency
+// of the queries completed since the previous one.
+type Sample struct {
+	// Elapsed is the time since the start of the run in milliseconds
+	Elapsed int64 `json:"elapsed_ms"`
+	// Errors holds the error scraping every target that failed to be scraped
+	Errors map[string]string `json:"errors,omitempty"`
+	// Median latency in milliseconds of the queries completed since the previous sample
+	Median float64 `json:"median_ms"`
+	// Requests is the number of queries completed since the previous sample
+	Requests int `json:"requests"`
+	// Targets holds the value of every metric scraped from every target, summed across series.
+	// Counters are given as their per second rate since the previous sample
+	Targets map[string]map[string]float64 `json:"targets,omitempty"`
+}
+
+func (s *Sample) ToString() (output string) {
+	output += fmt.Sprintf("Server metrics at %dms: %d queries processed, median %fms\n", s.Elapsed, s.Requests, s.Median)
+	for _, target := range sortedKeys(s.Targets) {
+		var values []string
+		for _, name := range sortedKeys(s.Targets[target]) {
+			values = append(values, fmt.Sprintf("%s %g", name, s.Targets[target][name]))
+		}
+		output += fmt.Sprintf("  %s: %s\n", target, strings.Join(values, ", "))
+	}
+	for _, target := range sortedKeys(s.Errors) {
+		output += fmt.Sprintf("  %s: %s\n", target, s.Errors[target])
+	}
+	return
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Scraper is a runner.Recorder sampling the metrics of the Targets every Interval between Start
+// and Stop.
+type Scraper struct {
+	Client client.HttpClient
+	// Targets are the URLs the metrics are scraped from, e.g. http://localhost:9201/metrics
+	Targets  []string
+	Interval time.Duration
+	// Metrics are the names of the metrics sampled, any other is ignored
+	Metrics []string
+
+	mu        sync.Mutex
+	start     time.Time
+	latencies []float64
+	samples   []Sample
+	// counters holds the last value and time every counter of every target was scraped
+	counters map[string]map[string]counter
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type counter struct {
+	value float64
+	time  time.Time
+}
+
+// New instantiates a Scraper of the given targets, sampling the DefaultMetrics every interval.
+func New(targets []string, interval time.Duration) *Scraper {
+	return &Scraper{
+		Client:   &http.Client{Timeout: interval},
+		Targets:  targets,
+		Interval: interval,
+		Metrics:  DefaultMetrics,
+		counters: map[string]map[string]counter{},
+	}
+}
+
+func (s *Scraper) Record(r *runner.Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, float64(r.End.Sub(r.Start).Microseconds())/1000)
+	return nil
+}
+
+// Start takes a first sample, the baseline of the counters, and keeps sampling in the background.
+func (s *Scraper) Start() {
+	s.start = time.Now()
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	s.sample(s.start)
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.sample(now)
+			}
+		}
+	}()
+}
+
+// Stop takes a last sample and returns every sample taken since Start.
+func (s *Scraper) Stop() []Sample {
+	close(s.stop)
+	<-s.done
+	s.sample(time.Now())
+	return s.samples
+}
+
+func (s *Scraper) sample(now time.Time) {
+	sample := Sample{Elapsed: now.Sub(s.start).Milliseconds()}
+	for _, target := range s.Targets {
+		values, err := s.scrape(target, now)
+		if err != nil {
+			if sample.Errors == nil {
+				sample.Errors = map[string]string{}
+			}
+			sample.Errors[target] = err.Error()
+			continue
+		}
+		if sample.Targets == nil {
+			sample.Targets = map[string]map[string]float64{}
+		}
+		sample.Targets[target] = values
+	}
+
+	s.mu.Lock()
+	sample.Requests = len(s.latencies)
+	sample.Median = stats.Quantile(s.latencies, 0.5)
+	s.latencies = nil
+	s.mu.Unlock()
+	s.samples = append(s.samples, sample)
+}
+
+// scrape returns the sampled metrics of the given target, with counters as their rate since the
+// previous scrape. Counters are left out of the first scrape of a target.
+func (s *Scraper) scrape(target string, now time.Time) (map[string]float64, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &client.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse metrics. err=%w", err)
+	}
+
+	values := map[string]float64{}
+	previous := s.counters[target]
+	current := map[string]counter{}
+	for _, name := range s.Metrics {
+		family, ok := families[name]
+		if !ok {
+			continue
+		}
+		var sum float64
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				sum += m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				sum += m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				sum += m.GetUntyped().GetValue()
+			}
+		}
+		if family.GetType() != dto.MetricType_COUNTER {
+			values[name] = sum
+			continue
+		}
+
+		current[name] = counter{value: sum, time: now}
+		if prev, ok := previous[name]; ok && now.After(prev.time) {
+			delta := sum - prev.value
+			if delta < 0 { // reset, e.g. the target restarted
+				delta = sum
+			}
+			values[name] = delta / now.Sub(prev.time).Seconds()
+		}
+	}
+	s.counters[target] = current
+	return values, nil
+}
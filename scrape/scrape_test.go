@@ -0,0 +1,59 @@
+package scrape
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestScraper_sample(t *testing.T) {
+	var cpu float64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# TYPE process_cpu_seconds_total counter\nprocess_cpu_seconds_total %g\n", cpu)
+		fmt.Fprint(w, "# TYPE process_resident_memory_bytes gauge\nprocess_resident_memory_bytes 1024\n")
+		fmt.Fprint(w, "# TYPE prometheus_engine_queries gauge\nprometheus_engine_queries{shard=\"a\"} 2\nprometheus_engine_queries{shard=\"b\"} 3\n")
+		fmt.Fprint(w, "# TYPE go_goroutines gauge\ngo_goroutines 42\n")
+	}))
+	defer srv.Close()
+
+	s := New([]string{srv.URL, "http://127.0.0.1:1/metrics"}, time.Second)
+	start := time.Unix(1609459200, 0)
+	s.start = start
+	s.sample(start)
+
+	cpu = 3
+	for _, latency := range []time.Duration{10, 30, 20} {
+		s.Record(&runner.Result{Start: start, End: start.Add(latency * time.Millisecond)})
+	}
+	s.sample(start.Add(2 * time.Second))
+
+	if len(s.samples) != 2 {
+		t.Fatalf("Scraper took %d samples, want 2", len(s.samples))
+	}
+	if got, want := s.samples[0].Targets[srv.URL], map[string]float64{
+		"process_resident_memory_bytes": 1024,
+		"prometheus_engine_queries":     5,
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Scraper first sample = %v, want %v", got, want)
+	}
+
+	got := s.samples[1]
+	if want := map[string]float64{
+		"process_cpu_seconds_total":     1.5,
+		"process_resident_memory_bytes": 1024,
+		"prometheus_engine_queries":     5,
+	}; !reflect.DeepEqual(got.Targets[srv.URL], want) {
+		t.Errorf("Scraper second sample = %v, want %v", got.Targets[srv.URL], want)
+	}
+	if got.Elapsed != 2000 || got.Requests != 3 || got.Median != 20 {
+		t.Errorf("Scraper second sample = %+v, want 3 requests with a 20ms median after 2000ms", got)
+	}
+	if _, ok := got.Errors["http://127.0.0.1:1/metrics"]; !ok {
+		t.Errorf("Scraper second sample errors = %v, want the unreachable target", got.Errors)
+	}
+}
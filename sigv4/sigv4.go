@@ -0,0 +1,407 @@
+// Package sigv4 signs the requests sent to AWS services, e.g. Amazon Managed Service for
+// Prometheus, with the AWS Signature Version 4.
+package sigv4
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	// expiryDelta is how long before their expiry temporary credentials are refreshed, so requests
+	// in flight aren't signed with expired credentials.
+	expiryDelta = time.Minute
+)
+
+// metadataEndpoint is the endpoint of the EC2 instance metadata service.
+var metadataEndpoint = "http://169.254.169.254"
+
+// Credentials are the AWS credentials requests are signed with.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials, e.g. those of an assumed role
+	SessionToken string
+	// Expiry is the time temporary credentials expire at, zero if they don't
+	Expiry time.Time
+}
+
+func (c Credentials) valid(now time.Time) bool {
+	return c.AccessKeyID != "" && (c.Expiry.IsZero() || now.Add(expiryDelta).Before(c.Expiry))
+}
+
+// CredentialsFromEnv reads the credentials from the standard AWS environment variables.
+func CredentialsFromEnv() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to sign requests")
+	}
+	return creds, nil
+}
+
+// Signer signs requests to a Service of a Region.
+type Signer struct {
+	Credentials Credentials
+	// Source returns new credentials once the current ones are about to expire, if they do
+	Source func() (Credentials, error)
+	Region string
+	// Service is the signing name of the service, e.g. aps for Amazon Managed Service for Prometheus
+	Service string
+	// Now returns the time requests are signed at, time.Now if nil
+	Now func() time.Time
+
+	mu sync.Mutex
+}
+
+func (s *Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// Refresh asks the Source for new credentials if the current ones are about to expire.
+func (s *Signer) Refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Source == nil || s.Credentials.valid(s.now()) {
+		return nil
+	}
+	creds, err := s.Source()
+	if err != nil {
+		return fmt.Errorf("unable to refresh credentials. err=%w", err)
+	}
+	s.Credentials = creds
+	return nil
+}
+
+// Sign adds the X-Amz-Date and Authorization headers signing the request, whose body is given.
+func (s *Signer) Sign(req *http.Request, body []byte) {
+	s.mu.Lock()
+	creds := s.Credentials
+	s.mu.Unlock()
+	t := s.now().UTC()
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", t.Format(timeFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers, signedHeaders := canonicalHeaders(req)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payload := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), headers, signedHeaders, hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{algorithm, t.Format(timeFormat), scope, hex.EncodeToString(hashedRequest[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalHeaders returns the canonical form of the host and X-Amz-* headers of the request, which
+// are the ones signed, and the list of their names.
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, v := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			values[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery encodes the query parameters sorted by name and value, escaping spaces as %20.
+func canonicalQuery(params url.Values) string {
+	var pairs []string
+	for name, values := range params {
+		for _, v := range values {
+			pairs = append(pairs, escape(name)+"="+escape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// Transport is an http.RoundTripper signing every request before sending it through the Base
+// transport, refreshing the credentials of the Signer as they expire.
+type Transport struct {
+	Base   http.RoundTripper
+	Signer *Signer
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Signer.Refresh(); err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the given request, so the body hashed is a copy of its own
+	req = req.Clone(req.Context())
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			// The body can't be read twice, so the one sent is the copy
+			var err error
+			if body, err = io.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+			req.Body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		} else {
+			copied, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			body, err = io.ReadAll(copied)
+			copied.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	t.Signer.Sign(req, body)
+	return t.Base.RoundTrip(req)
+}
+
+// stsCredentials are the credentials returned by the STS endpoints.
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// AssumeRole returns the temporary credentials of the given role, assumed through the STS endpoint
+// of the region of the Signer, whose credentials are used to sign the request.
+func (s *Signer) AssumeRole(httpClient client.HttpClient, roleARN string) (Credentials, error) {
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleARN},
+		"RoleSessionName": {"pqlbench"},
+	}
+	body := []byte(form.Encode())
+	req, err := http.NewRequest(http.MethodPost, "https://sts."+s.Region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.mu.Lock()
+	sts := &Signer{Credentials: s.Credentials, Region: s.Region, Service: "sts", Now: s.Now}
+	s.mu.Unlock()
+	sts.Sign(req, body)
+
+	var r struct {
+		Credentials stsCredentials `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := requestSTS(httpClient, req, roleARN, &r); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{r.Credentials.AccessKeyID, r.Credentials.SecretAccessKey, r.Credentials.SessionToken, r.Credentials.Expiration}, nil
+}
+
+// WebIdentityCredentials returns the temporary credentials of the given role, assumed through the
+// STS endpoint of the region with the web identity token of the given file, as on EKS with IAM
+// roles for service accounts. The file is read again for every new credentials, as the token is
+// rotated.
+func WebIdentityCredentials(httpClient client.HttpClient, region, roleARN, tokenFile string) (Credentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("unable to read the web identity token. err=%w", err)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {"pqlbench"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://sts."+region+".amazonaws.com/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var r struct {
+		Credentials stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := requestSTS(httpClient, req, roleARN, &r); err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{r.Credentials.AccessKeyID, r.Credentials.SecretAccessKey, r.Credentials.SessionToken, r.Credentials.Expiration}, nil
+}
+
+// requestSTS sends the request assuming the given role to an STS endpoint and decodes its response.
+func requestSTS(httpClient client.HttpClient, req *http.Request, roleARN string, v any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to assume role %s. err=%w", roleARN, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to assume role %s. err=%w", roleARN, &client.StatusError{StatusCode: resp.StatusCode})
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unable to decode the credentials of role %s. err=%w", roleARN, err)
+	}
+	return nil
+}
+
+// InstanceCredentials returns the temporary credentials of the role of the EC2 instance, from its
+// instance metadata service.
+func InstanceCredentials(httpClient client.HttpClient) (Credentials, error) {
+	req, err := http.NewRequest(http.MethodPut, metadataEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "60")
+	token, err := getMetadata(httpClient, req)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodGet, metadataEndpoint+"/latest/meta-data/iam/security-credentials/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+		return getMetadata(httpClient, req)
+	}
+	role, err := get("")
+	if err != nil {
+		return Credentials{}, err
+	}
+	body, err := get(strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0]))
+	if err != nil {
+		return Credentials{}, err
+	}
+	var r struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return Credentials{}, fmt.Errorf("unable to decode the credentials of the instance. err=%w", err)
+	}
+	return Credentials{r.AccessKeyID, r.SecretAccessKey, r.Token, r.Expiration}, nil
+}
+
+// getMetadata sends a request to the instance metadata service and returns the body of its response.
+func getMetadata(httpClient client.HttpClient, req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the credentials of the instance. err=%w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to get the credentials of the instance. err=%w", &client.StatusError{StatusCode: resp.StatusCode})
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Config selects how requests are signed.
+type Config struct {
+	Region string
+	// Service is the signing name of the service, e.g. aps
+	Service string
+	// RoleARN is the role assumed to sign requests, if any
+	RoleARN string
+}
+
+// NewSigner returns the Signer of the given config with the credentials of the environment, or with
+// those of the role of the config if any, assumed with httpClient. The credentials of the
+// environment are those of the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables, those of the
+// role of AWS_ROLE_ARN assumed with the web identity token of AWS_WEB_IDENTITY_TOKEN_FILE, or else
+// those of the role of the EC2 instance. Temporary credentials are refreshed as they expire.
+func NewSigner(cfg Config, httpClient client.HttpClient) (*Signer, error) {
+	source := CredentialsFromEnv
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" && os.Getenv("AWS_SECRET_ACCESS_KEY") == "" {
+		if tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && roleARN != "" {
+			source = func() (Credentials, error) {
+				return WebIdentityCredentials(httpClient, cfg.Region, roleARN, tokenFile)
+			}
+		} else {
+			source = func() (Credentials, error) {
+				return InstanceCredentials(httpClient)
+			}
+		}
+	}
+	signer := &Signer{Source: source, Region: cfg.Region, Service: cfg.Service}
+	if err := signer.Refresh(); err != nil {
+		return nil, err
+	}
+	if cfg.RoleARN != "" {
+		base := signer
+		signer = &Signer{Region: cfg.Region, Service: cfg.Service, Source: func() (Credentials, error) {
+			if err := base.Refresh(); err != nil {
+				return Credentials{}, err
+			}
+			return base.AssumeRole(httpClient, cfg.RoleARN)
+		}}
+		if err := signer.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
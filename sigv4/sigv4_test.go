@@ -0,0 +1,225 @@
+package sigv4
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Requests and signatures of the AWS Signature Version 4 test suite.
+func TestSigner_Sign(t *testing.T) {
+	signer := &Signer{
+		Credentials: Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		Region:      "us-east-1",
+		Service:     "service",
+		Now:         func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "get-vanilla",
+			url:  "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "get-vanilla-query-order-key-case",
+			url:  "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer.Sign(req, nil)
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Signer.Sign() Authorization = %s, want %s", got, tt.want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("Signer.Sign() X-Amz-Date = %s", got)
+			}
+		})
+	}
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	signer := &Signer{Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"}, Region: "eu-west-1", Service: "aps"}
+	c := &http.Client{Transport: &Transport{Base: http.DefaultTransport, Signer: signer}}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/query", strings.NewReader("query=up"))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if body != "query=up" {
+		t.Errorf("Transport.RoundTrip() sent body %q, want query=up", body)
+	}
+	if auth := got.Header.Get("Authorization"); !strings.Contains(auth, "/eu-west-1/aps/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Transport.RoundTrip() Authorization = %s", auth)
+	}
+	if got.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Errorf("Transport.RoundTrip() didn't send the session token")
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("Transport.RoundTrip() modified the given request")
+	}
+	// The body can still be replayed, e.g. on a retry
+	if replayed, _ := req.GetBody(); replayed == nil {
+		t.Errorf("Transport.RoundTrip() removed the body of the given request")
+	} else if b, _ := io.ReadAll(replayed); string(b) != "query=up" {
+		t.Errorf("Transport.RoundTrip() consumed the body of the given request, left %q", b)
+	}
+}
+
+func TestSigner_AssumeRole(t *testing.T) {
+	mock := &roleClientMock{body: `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIA</AccessKeyId>
+      <SecretAccessKey>role-secret</SecretAccessKey>
+      <SessionToken>role-token</SessionToken>
+      <Expiration>2021-01-01T01:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`}
+	signer := &Signer{Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, Region: "eu-west-1", Service: "aps"}
+
+	got, err := signer.AssumeRole(mock, "arn:aws:iam::123456789012:role/bench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Credentials{AccessKeyID: "ASIA", SecretAccessKey: "role-secret", SessionToken: "role-token", Expiry: time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC)}); got != want {
+		t.Errorf("Signer.AssumeRole() = %+v, want %+v", got, want)
+	}
+	if mock.req.URL.String() != "https://sts.eu-west-1.amazonaws.com/" || !strings.Contains(mock.req.Header.Get("Authorization"), "/eu-west-1/sts/aws4_request") {
+		t.Errorf("Signer.AssumeRole() requested %s with %s", mock.req.URL, mock.req.Header.Get("Authorization"))
+	}
+}
+
+func TestWebIdentityCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("jwt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mock := &roleClientMock{body: `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIA</AccessKeyId>
+      <SecretAccessKey>role-secret</SecretAccessKey>
+      <SessionToken>role-token</SessionToken>
+      <Expiration>2021-01-01T01:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`}
+
+	got, err := WebIdentityCredentials(mock, "eu-west-1", "arn:aws:iam::123456789012:role/bench", tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "ASIA" || got.SessionToken != "role-token" || !got.Expiry.Equal(time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("WebIdentityCredentials() = %+v", got)
+	}
+	body, _ := io.ReadAll(mock.req.Body)
+	if form, _ := url.ParseQuery(string(body)); form.Get("Action") != "AssumeRoleWithWebIdentity" || form.Get("WebIdentityToken") != "jwt" || mock.req.Header.Get("Authorization") != "" {
+		t.Errorf("WebIdentityCredentials() requested %s with %s", body, mock.req.Header.Get("Authorization"))
+	}
+}
+
+func TestInstanceCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("imds-token"))
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("bench\n"))
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/bench":
+			w.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIA","SecretAccessKey":"role-secret","Token":"role-token","Expiration":"2021-01-01T01:00:00Z"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer func(endpoint string) { metadataEndpoint = endpoint }(metadataEndpoint)
+	metadataEndpoint = srv.URL
+
+	got, err := InstanceCredentials(http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "ASIA" || got.SecretAccessKey != "role-secret" || got.SessionToken != "role-token" || !got.Expiry.Equal(time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("InstanceCredentials() = %+v", got)
+	}
+}
+
+func TestTransport_RoundTrip_refresh(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var refreshed int
+	signer := &Signer{
+		Source: func() (Credentials, error) {
+			refreshed++
+			return Credentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", Expiry: now.Add(time.Hour)}, nil
+		},
+		Region:  "eu-west-1",
+		Service: "aps",
+		Now:     func() time.Time { return now },
+	}
+	c := &http.Client{Transport: &Transport{Base: http.DefaultTransport, Signer: signer}}
+	tests := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{name: "no credentials", at: now, want: 1},
+		{name: "valid credentials", at: now.Add(30 * time.Minute), want: 1},
+		{name: "about to expire", at: now.Add(time.Hour - time.Second), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = tt.at
+			resp, err := c.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if refreshed != tt.want {
+				t.Errorf("Transport.RoundTrip() refreshed the credentials %d times, want %d", refreshed, tt.want)
+			}
+		})
+	}
+}
+
+type roleClientMock struct {
+	req  *http.Request
+	body string
+}
+
+func (c *roleClientMock) Do(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(c.body))}, nil
+}
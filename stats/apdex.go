@@ -0,0 +1,49 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+)
+
+// Apdex is the Application Performance Index of the queries of a run, from 0 (every query
+// frustrating) to 1 (every query satisfying). Queries answered within the satisfied threshold count
+// fully, those answered within the tolerating threshold count half and the slower or failed ones
+// do not count.
+type Apdex struct {
+	// Satisfied and Tolerating are the thresholds in milliseconds
+	Satisfied  int64 `json:"satisfied_ms"`
+	Tolerating int64 `json:"tolerating_ms"`
+	// SatisfiedCount, ToleratingCount and Frustrated count the queries in every zone
+	SatisfiedCount  int     `json:"satisfied"`
+	ToleratingCount int     `json:"tolerating"`
+	Frustrated      int     `json:"frustrated"`
+	Score           float64 `json:"score"`
+}
+
+// NewApdex returns an empty Apdex with the given thresholds. The tolerating threshold defaults to
+// four times the satisfied one, as in the Apdex specification.
+func NewApdex(satisfied, tolerating time.Duration) *Apdex {
+	if tolerating == 0 {
+		tolerating = 4 * satisfied
+	}
+	return &Apdex{Satisfied: satisfied.Milliseconds(), Tolerating: tolerating.Milliseconds()}
+}
+
+// Add accounts a query that took the given time in milliseconds, or failed, and updates the score.
+func (a *Apdex) Add(latency int64, failed bool) {
+	switch {
+	case failed || latency > a.Tolerating:
+		a.Frustrated++
+	case latency > a.Satisfied:
+		a.ToleratingCount++
+	default:
+		a.SatisfiedCount++
+	}
+	total := a.SatisfiedCount + a.ToleratingCount + a.Frustrated
+	a.Score = (float64(a.SatisfiedCount) + float64(a.ToleratingCount)/2) / float64(total)
+}
+
+func (a *Apdex) ToString() string {
+	return fmt.Sprintf("Apdex score: %.3f (satisfied <=%dms: %d, tolerating <=%dms: %d, frustrated: %d)\n",
+		a.Score, a.Satisfied, a.SatisfiedCount, a.Tolerating, a.ToleratingCount, a.Frustrated)
+}
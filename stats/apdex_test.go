@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApdex_Add(t *testing.T) {
+	tests := []struct {
+		name       string
+		tolerating time.Duration
+		latencies  []int64
+		failed     int
+		want       Apdex
+	}{
+		{
+			name:      "Default tolerating threshold",
+			latencies: []int64{10, 100, 101, 400, 401},
+			want:      Apdex{Satisfied: 100, Tolerating: 400, SatisfiedCount: 2, ToleratingCount: 2, Frustrated: 1, Score: 0.6},
+		},
+		{
+			name:       "Tolerating threshold",
+			tolerating: 200 * time.Millisecond,
+			latencies:  []int64{10, 150, 400},
+			failed:     1,
+			want:       Apdex{Satisfied: 100, Tolerating: 200, SatisfiedCount: 1, ToleratingCount: 1, Frustrated: 2, Score: 0.375},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewApdex(100*time.Millisecond, tt.tolerating)
+			for _, l := range tt.latencies {
+				a.Add(l, false)
+			}
+			for range tt.failed {
+				a.Add(0, true)
+			}
+			if *a != tt.want {
+				t.Errorf("Apdex = %+v, want %+v", *a, tt.want)
+			}
+		})
+	}
+}
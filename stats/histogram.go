@@ -0,0 +1,83 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HistogramBounds are the upper bounds (inclusive) in milliseconds of the buckets of the latency
+// histogram, growing exponentially so both fast queries and a long tail can be told apart.
+var HistogramBounds = []int64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 20000, 50000}
+
+// HistogramBucket counts the latencies up to its upper bound in milliseconds and above the bound of
+// the previous bucket. The last bucket has no upper bound, given as zero.
+type HistogramBucket struct {
+	Count int   `json:"count"`
+	Upper int64 `json:"upper_ms,omitempty"`
+}
+
+// Histogram is the distribution of the latencies of a run over the HistogramBounds, which shows its
+// shape (e.g. bimodal or with a long tail) at a glance. Empty buckets below the fastest latency and
+// above the slowest one are left out.
+type Histogram []HistogramBucket
+
+// NewHistogram builds the Histogram of the given latencies in milliseconds.
+func NewHistogram(latencies []int64) Histogram {
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	buckets := make(Histogram, len(HistogramBounds)+1)
+	for i, upper := range HistogramBounds {
+		buckets[i].Upper = upper
+	}
+	for _, l := range latencies {
+		i := 0
+		for i < len(HistogramBounds) && l > HistogramBounds[i] {
+			i++
+		}
+		buckets[i].Count++
+	}
+
+	first, last := 0, len(buckets)-1
+	for buckets[first].Count == 0 {
+		first++
+	}
+	for buckets[last].Count == 0 {
+		last--
+	}
+	return buckets[first : last+1]
+}
+
+// histogramWidth is the width of the bar of the largest bucket.
+const histogramWidth = 40
+
+func (h Histogram) ToString() (output string) {
+	var largest int
+	for _, b := range h {
+		largest = max(largest, b.Count)
+	}
+
+	output += "Latency histogram:\n"
+	for _, b := range h {
+		bar := strings.Repeat("#", (b.Count*histogramWidth+largest-1)/largest)
+		output += fmt.Sprintf("  %8s | %-*s %d\n", b.Label(), histogramWidth, bar, b.Count)
+	}
+	return
+}
+
+// Label names the latencies counted by the bucket, e.g. <=50ms, or >50s for the last one.
+func (b HistogramBucket) Label() string {
+	if b.Upper == 0 {
+		return ">" + formatMillis(HistogramBounds[len(HistogramBounds)-1])
+	}
+	return "<=" + formatMillis(b.Upper)
+}
+
+// formatMillis formats a bound in milliseconds, in seconds if whole.
+func formatMillis(ms int64) string {
+	if ms >= 1000 && ms%1000 == 0 {
+		return fmt.Sprintf("%ds", ms/1000)
+	}
+	return fmt.Sprintf("%dms", ms)
+}
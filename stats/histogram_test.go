@@ -0,0 +1,48 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewHistogram(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []int64
+		want      Histogram
+	}{
+		{name: "no latencies"},
+		{
+			name:      "bimodal",
+			latencies: []int64{3, 4, 5, 180, 200, 3},
+			want:      Histogram{{Count: 4, Upper: 5}, {Upper: 10}, {Upper: 20}, {Upper: 50}, {Upper: 100}, {Count: 2, Upper: 200}},
+		},
+		{
+			name:      "long tail",
+			latencies: []int64{0, 50001},
+			want: Histogram{
+				{Count: 1, Upper: 1}, {Upper: 2}, {Upper: 5}, {Upper: 10}, {Upper: 20}, {Upper: 50}, {Upper: 100}, {Upper: 200},
+				{Upper: 500}, {Upper: 1000}, {Upper: 2000}, {Upper: 5000}, {Upper: 10000}, {Upper: 20000}, {Upper: 50000}, {Count: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewHistogram(tt.latencies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewHistogram() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogram_ToString(t *testing.T) {
+	h := Histogram{{Count: 4, Upper: 5}, {Upper: 10}, {Count: 1, Upper: 1000}, {Count: 2}}
+	want := "Latency histogram:\n" +
+		"     <=5ms | ######################################## 4\n" +
+		"    <=10ms |                                          0\n" +
+		"      <=1s | ##########                               1\n" +
+		"      >50s | ####################                     2\n"
+	if got := h.ToString(); got != want {
+		t.Errorf("Histogram.ToString() = \n%s, want \n%s", got, want)
+	}
+}
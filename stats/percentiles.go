@@ -0,0 +1,62 @@
+package stats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultPercentiles are the percentiles of the query times reported unless others are chosen.
+const DefaultPercentiles = "50,90,95,99"
+
+// Percentile is the query time in milliseconds below which the given percentage of the queries
+// were answered.
+type Percentile struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"value_ms"`
+}
+
+// Percentiles of the query times of a run, in the order they were chosen.
+type Percentiles []Percentile
+
+// ParsePercentiles parses a comma-separated list of percentiles, e.g. 50,90,99,99.9, each greater
+// than 0 and up to 100.
+func ParsePercentiles(list string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(field, 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q, want a number greater than 0 and up to 100", field)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// ComputePercentiles returns the given percentiles of the latencies in milliseconds, sorting them.
+func ComputePercentiles(latencies []float64, percentiles []float64) Percentiles {
+	if len(latencies) == 0 {
+		return nil
+	}
+	values := make(Percentiles, len(percentiles))
+	for i, p := range percentiles {
+		values[i] = Percentile{Percentile: p, Value: Quantile(latencies, p/100)}
+	}
+	return values
+}
+
+func (p Percentiles) ToString() string {
+	return p.toString("Query time")
+}
+
+// toString renders the percentiles of the named time.
+func (p Percentiles) toString(name string) string {
+	fields := make([]string, len(p))
+	for i, percentile := range p {
+		fields[i] = fmt.Sprintf("p%g %fms", percentile.Percentile, percentile.Value)
+	}
+	return fmt.Sprintf("%s percentiles: %s\n", name, strings.Join(fields, ", "))
+}
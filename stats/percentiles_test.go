@@ -0,0 +1,49 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePercentiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []float64
+		wantErr bool
+	}{
+		{name: "OK", list: "50, 90,99,99.9", want: []float64{50, 90, 99, 99.9}},
+		{name: "Empty", list: ""},
+		{name: "Maximum", list: "100", want: []float64{100}},
+		{name: "Zero", list: "0,50", wantErr: true},
+		{name: "Above 100", list: "50,101", wantErr: true},
+		{name: "Not a number", list: "p99", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePercentiles(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePercentiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePercentiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputePercentiles(t *testing.T) {
+	latencies := []float64{5, 1, 4, 2, 3, 6, 7, 8, 9, 10}
+	got := ComputePercentiles(latencies, []float64{50, 90, 99.9})
+	want := Percentiles{{Percentile: 50, Value: 5}, {Percentile: 90, Value: 9}, {Percentile: 99.9, Value: 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputePercentiles() = %v, want %v", got, want)
+	}
+	if want := "Query time percentiles: p50 5.000000ms, p90 9.000000ms, p99.9 10.000000ms\n"; got.ToString() != want {
+		t.Errorf("Percentiles.ToString() = %q, want %q", got.ToString(), want)
+	}
+
+	if got := ComputePercentiles(nil, []float64{50}); got != nil {
+		t.Errorf("ComputePercentiles() of no latencies = %v, want none", got)
+	}
+}
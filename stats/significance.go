@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// MannWhitney runs a two-sided Mann-Whitney U test of whether the values of a and b come from the
+// same distribution, returning the U statistic of a and the p-value, computed with the normal
+// approximation corrected for ties. Unlike comparing averages, it makes no assumption on the
+// distribution of the values, which for query times is far from normal.
+func MannWhitney(a, b []float64) (u, p float64) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	type value struct {
+		v     float64
+		fromA bool
+	}
+	values := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		values = append(values, value{v, true})
+	}
+	for _, v := range b {
+		values = append(values, value{v, false})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].v < values[j].v })
+
+	// Rank the values, averaging the ranks of ties
+	var rankA, ties float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].v == values[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].fromA {
+				rankA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u = rankA - n1*(n1+1)/2
+	mean := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 { // every value is the same
+		return u, 1
+	}
+	// Continuity correction
+	z := (math.Abs(u-mean) - 0.5) / sigma
+	return u, min(math.Erfc(max(z, 0)/math.Sqrt2), 1)
+}
+
+// Bootstrap estimates the distribution of the difference of the given statistic between b and a
+// by recomputing it over the given number of resamples (with replacement) of both. It returns the
+// bounds of the confidence interval of the difference at the given level (e.g. 0.95) and the
+// two-sided p-value of the difference being zero.
+func Bootstrap(a, b []float64, statistic func(values []float64) float64, resamples int, level float64, rnd *rand.Rand) (low, high, p float64) {
+	if len(a) == 0 || len(b) == 0 || resamples <= 0 {
+		return 0, 0, 1
+	}
+
+	deltas := make([]float64, resamples)
+	var below, above int
+	ra, rb := make([]float64, len(a)), make([]float64, len(b))
+	for i := range deltas {
+		for j := range ra {
+			ra[j] = a[rnd.Intn(len(a))]
+		}
+		for j := range rb {
+			rb[j] = b[rnd.Intn(len(b))]
+		}
+		deltas[i] = statistic(rb) - statistic(ra)
+		if deltas[i] <= 0 {
+			below++
+		}
+		if deltas[i] >= 0 {
+			above++
+		}
+	}
+
+	alpha := (1 - level) / 2
+	low, high = Quantile(deltas, alpha), Quantile(deltas, 1-alpha)
+	p = min(2*float64(min(below, above))/float64(resamples), 1)
+	return low, high, p
+}
+
+// Mean returns the average of the given values.
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  []float64
+		wantU float64
+		// wantP is the p-value rounded to 4 decimals
+		wantP float64
+	}{
+		{
+			name:  "Shifted",
+			a:     []float64{1, 2, 3, 4, 5, 6, 7, 8},
+			b:     []float64{9, 10, 11, 12, 13, 14, 15, 16},
+			wantU: 0,
+			wantP: 0.0009,
+		},
+		{
+			name:  "Same distribution",
+			a:     []float64{1, 3, 5, 7},
+			b:     []float64{2, 4, 6, 8},
+			wantU: 6,
+			wantP: 0.665,
+		},
+		{
+			name:  "Ties only",
+			a:     []float64{5, 5},
+			b:     []float64{5, 5},
+			wantU: 2,
+			wantP: 1,
+		},
+		{
+			name:  "Empty",
+			a:     []float64{1},
+			wantP: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, p := MannWhitney(tt.a, tt.b)
+			if u != tt.wantU || math.Round(p*10000)/10000 != tt.wantP {
+				t.Errorf("MannWhitney() = %v, %v, want %v, %v", u, p, tt.wantU, tt.wantP)
+			}
+		})
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	var a, b []float64
+	for i := range 100 {
+		a = append(a, float64(10+i%10))
+		b = append(b, float64(20+i%10))
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	low, high, p := Bootstrap(a, b, Mean, 1000, 0.95, rnd)
+	if low > 10 || high < 10 || low < 9 || high > 11 || p != 0 {
+		t.Errorf("Bootstrap() of a 10ms shift = [%v, %v], p %v, want an interval around 10 and p 0", low, high, p)
+	}
+
+	low, high, p = Bootstrap(a, a, Mean, 1000, 0.95, rnd)
+	if low > 0 || high < 0 || p < 0.05 {
+		t.Errorf("Bootstrap() of the same values = [%v, %v], p %v, want an interval around 0 and p above 0.05", low, high, p)
+	}
+}
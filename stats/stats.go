@@ -0,0 +1,345 @@
+// Package stats computes the statistics summarizing a benchmark run.
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+// Stats of the resulting from the execution of the command line tool.
+type Stats struct {
+	// Apdex scores the query times against the configured thresholds, if any
+	Apdex *Apdex `json:"apdex,omitempty"`
+	// AssertionFailures counts the queries answered successfully but failing their assertions, e.g.
+	// with fewer series than expected, and AssertionSample describes the first one
+	AssertionFailures int    `json:"assertion_failures,omitempty"`
+	AssertionSample   string `json:"assertion_sample,omitempty"`
+	// Average query time
+	Average float64 `json:"average_ms"`
+	// Bytes is the size of the bodies of the successful responses, once decompressed
+	Bytes int64 `json:"bytes,omitempty"`
+	// CoefficientOfVariation is the standard deviation relative to the average query time, which
+	// tells apart noisy runs regardless of the magnitude of their query times
+	CoefficientOfVariation float64 `json:"cv"`
+	// CompressedBytes is the size of the bodies of the successful responses as received, set if any
+	// was gzip compressed, and Decompress the average time spent decompressing those in
+	// milliseconds
+	CompressedBytes int64   `json:"compressed_bytes,omitempty"`
+	Decompress      float64 `json:"decompress_ms,omitempty"`
+	// Connect is the average time spent opening a new connection in milliseconds, if any was opened
+	Connect float64 `json:"connect_ms,omitempty"`
+	// Connections is the number of new connections opened by successful queries
+	Connections int `json:"connections,omitempty"`
+	// Corrected holds the latencies measured from the time queries were due rather than sent in
+	// open-loop runs, accounting for coordinated omission
+	Corrected *Stats `json:"corrected,omitempty"`
+	// Decode is the average time spent decoding response bodies in milliseconds, if measured
+	Decode float64 `json:"decode_ms,omitempty"`
+	// Errors counts the queries that encountered an error by class
+	Errors ErrorSummary `json:"errors"`
+	// Exemplars is the number of exemplars returned by query_exemplars requests
+	Exemplars int `json:"exemplars,omitempty"`
+	// Fastest is the minimum query time (for a single query) in milliseconds
+	Fastest int64 `json:"fastest_ms"`
+	// FirstBytePercentiles are the percentiles of the times until the first byte of the responses
+	// was received, and TransferPercentiles until their whole body was, if measured. The query time
+	// falls in between, once the headers were received
+	FirstBytePercentiles Percentiles `json:"first_byte_percentiles,omitempty"`
+	// Histogram is the distribution of the query times
+	Histogram Histogram `json:"histogram,omitempty"`
+	// Median query time of all queries
+	Median float64 `json:"median_ms"`
+	// Percentiles of the query times chosen for the run, if any
+	Percentiles Percentiles `json:"percentiles,omitempty"`
+	// Processed is the number of queries processed in milliseconds
+	Processed int `json:"processed"`
+	// Protocols counts the responses received over every protocol, e.g. HTTP/1.1 or HTTP/2.0
+	Protocols map[string]int `json:"protocols,omitempty"`
+	// Slowest is maximum query time (for a single query) in milliseconds
+	Slowest int64 `json:"slowest_ms"`
+	// StdDev is the standard deviation of the query times in milliseconds
+	StdDev float64 `json:"stddev_ms"`
+	// SuccessRate is the fraction of the queries sent that succeeded, without an error or a failed assertion
+	SuccessRate float64 `json:"success_rate"`
+	// TLSHandshakes is the number of TLS handshakes of the new connections opened by successful
+	// queries, TLSHandshake their average time in milliseconds, part of the connection setup time,
+	// and TLSHandshakePercentiles its percentiles. TLSResumed is the number of those that resumed a
+	// previous session, skipping the key exchange
+	TLSHandshake            float64     `json:"tls_handshake_ms,omitempty"`
+	TLSHandshakePercentiles Percentiles `json:"tls_handshake_percentiles,omitempty"`
+	TLSHandshakes           int         `json:"tls_handshakes,omitempty"`
+	TLSResumed              int         `json:"tls_resumed,omitempty"`
+	// Throughput is the number of queries answered per second, successfully or not, over the run
+	Throughput float64 `json:"throughput_qps"`
+	// Total processing time across all queries in milliseconds
+	Total               int64       `json:"total_ms"`
+	TransferPercentiles Percentiles `json:"transfer_percentiles,omitempty"`
+	// TrimmedMean is the average query time in milliseconds leaving out the fastest and slowest
+	// TrimmedFraction of the queries, so it is not skewed by a few outliers like the average
+	TrimmedMean float64 `json:"trimmed_mean_ms"`
+	// Utilization is the fraction of the time of the workers spent with a query in flight in
+	// closed-loop runs. Lower values mean the load delivered was held back by the tool, e.g. by its
+	// think time, rather than by the target
+	Utilization float64 `json:"utilization,omitempty"`
+	// Variance of the query times in squared milliseconds
+	Variance float64 `json:"variance_ms2"`
+	// WeightedAverage is the average query time weighting every query by the number of identical
+	// rows of the corpus it stands for, if deduplicated, see query.Query.Weight
+	WeightedAverage float64 `json:"weighted_average_ms,omitempty"`
+	// Workers holds the stats of every worker of closed-loop runs
+	Workers []WorkerStats `json:"workers,omitempty"`
+}
+
+// WorkerStats are the stats of the queries run by a single worker.
+type WorkerStats struct {
+	// Average query time of the worker in milliseconds
+	Average float64 `json:"average_ms"`
+	// Idle is the time the worker spent without a query in flight in milliseconds
+	Idle     int64 `json:"idle_ms"`
+	Requests int   `json:"requests"`
+	Worker   int   `json:"worker"`
+}
+
+func (s *Stats) ToString() (output string) {
+	output += fmt.Sprintf("Number of queries processed: %d\n", s.Processed)
+	output += fmt.Sprintf("Total processing time across all queries: %dms\n", s.Total)
+	output += fmt.Sprintf("Minimum query time (for a single query): %dms\n", s.Fastest)
+	output += fmt.Sprintf("Maximum query time (for a single query): %dms\n", s.Slowest)
+	output += fmt.Sprintf("Median query time: %fms\n", s.Median)
+	output += fmt.Sprintf("Average query time: %fms\n", s.Average)
+	output += fmt.Sprintf("Trimmed mean query time (%g%% trimmed): %fms\n", TrimmedFraction*100, s.TrimmedMean)
+	if s.WeightedAverage > 0 {
+		output += fmt.Sprintf("Average query time weighted by duplicates: %fms\n", s.WeightedAverage)
+	}
+	output += fmt.Sprintf("Standard deviation of the query time: %fms (variance %fms², coefficient of variation %f)\n", s.StdDev, s.Variance, s.CoefficientOfVariation)
+	if len(s.Percentiles) > 0 {
+		output += s.Percentiles.ToString()
+	}
+	if len(s.FirstBytePercentiles) > 0 {
+		output += s.FirstBytePercentiles.toString("Time to first byte")
+	}
+	if len(s.TransferPercentiles) > 0 {
+		output += s.TransferPercentiles.toString("Transfer time")
+	}
+	if s.Apdex != nil {
+		output += s.Apdex.ToString()
+	}
+	output += fmt.Sprintf("Throughput: %f queries/s, success rate: %.2f%%\n", s.Throughput, s.SuccessRate*100)
+	if s.Utilization > 0 {
+		output += fmt.Sprintf("Worker utilization: %.2f%%\n", s.Utilization*100)
+	}
+	if s.Corrected != nil {
+		output += fmt.Sprintf("Corrected for coordinated omission, median query time: %fms\n", s.Corrected.Median)
+		output += fmt.Sprintf("Corrected for coordinated omission, average query time: %fms\n", s.Corrected.Average)
+		output += fmt.Sprintf("Corrected for coordinated omission, maximum query time: %dms\n", s.Corrected.Slowest)
+	}
+	if s.Exemplars > 0 {
+		output += fmt.Sprintf("Number of exemplars returned: %d\n", s.Exemplars)
+	}
+	if s.Connections > 0 {
+		output += fmt.Sprintf("Connections opened: %d, average connection setup time: %fms\n", s.Connections, s.Connect)
+	}
+	if s.TLSHandshakes > 0 {
+		output += fmt.Sprintf("TLS handshakes: %d, average handshake time: %fms, sessions resumed: %d (%.2f%%)\n",
+			s.TLSHandshakes, s.TLSHandshake, s.TLSResumed, float64(s.TLSResumed)/float64(s.TLSHandshakes)*100)
+	}
+	if len(s.TLSHandshakePercentiles) > 0 {
+		output += s.TLSHandshakePercentiles.toString("TLS handshake time")
+	}
+	for _, proto := range slices.Sorted(maps.Keys(s.Protocols)) {
+		output += fmt.Sprintf("Responses received over %s: %d\n", proto, s.Protocols[proto])
+	}
+	if s.CompressedBytes > 0 {
+		output += fmt.Sprintf("Response bytes: %d, compressed: %d (%.2f%%), average decompression time: %fms\n",
+			s.Bytes, s.CompressedBytes, float64(s.CompressedBytes)/float64(s.Bytes)*100, s.Decompress)
+	}
+	if s.Decode > 0 {
+		output += fmt.Sprintf("Average response decode time: %fms\n", s.Decode)
+	}
+	if len(s.Histogram) > 0 {
+		output += s.Histogram.ToString()
+	}
+	if s.Errors.Total() > 0 {
+		output += s.Errors.ToString()
+	}
+	if s.AssertionFailures > 0 {
+		output += fmt.Sprintf("Assertion failures: %d (e.g. %s)\n", s.AssertionFailures, s.AssertionSample)
+	}
+	for _, w := range s.Workers {
+		output += fmt.Sprintf("Worker %d: %d requests, average query time %fms, idle %dms\n", w.Worker, w.Requests, w.Average, w.Idle)
+	}
+	return
+}
+
+// Quantile returns the q-quantile (0-1) of the given values using the nearest rank, sorting them.
+func Quantile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	i := int(math.Ceil(q*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
+
+// TrimmedFraction is the fraction of the fastest and, separately, of the slowest queries left out
+// of the trimmed mean.
+const TrimmedFraction = 0.05
+
+// Compute calculates the slowest, fastest, average, trimmed mean and median execution times of a
+// given Query list and their dispersion, whose Start and End hold the execution times in
+// milliseconds.
+func Compute(queryList []query.Query) *Stats {
+	if len(queryList) == 0 { // e.g. every query failed
+		return &Stats{}
+	}
+
+	var slowest int64
+	var average, median float64
+	fastest := int64(math.MaxInt64)
+
+	var timeDiffs []int64
+	var weighted, weights float64
+	var deduplicated bool
+	for i := range queryList {
+		timeDiff := queryList[i].End - queryList[i].Start
+		weight := float64(max(queryList[i].Weight, 1))
+		weighted += weight * float64(timeDiff)
+		weights += weight
+		deduplicated = deduplicated || queryList[i].Weight > 1
+		if timeDiff < fastest {
+			fastest = timeDiff
+		}
+		if timeDiff > slowest {
+			slowest = timeDiff
+		}
+		average += float64(timeDiff)
+		timeDiffs = append(timeDiffs, timeDiff)
+	}
+
+	// Calculate median
+	sort.Slice(timeDiffs, func(i, j int) bool { return timeDiffs[i] < timeDiffs[j] })
+	mNumber := len(timeDiffs) / 2
+	if len(timeDiffs)%2 != 0 { // if the number of elements is odd
+		median = float64(timeDiffs[mNumber])
+	} else {
+		median = float64((timeDiffs[mNumber-1] + timeDiffs[mNumber])) / 2
+	}
+
+	// Calculate average
+	average = float64(average) / float64(len(queryList))
+
+	// Calculate the variance, and the mean of the times left after trimming both ends
+	var variance, trimmed float64
+	for _, timeDiff := range timeDiffs {
+		variance += math.Pow(float64(timeDiff)-average, 2)
+	}
+	variance /= float64(len(timeDiffs))
+	trim := int(TrimmedFraction * float64(len(timeDiffs)))
+	for _, timeDiff := range timeDiffs[trim : len(timeDiffs)-trim] {
+		trimmed += float64(timeDiff)
+	}
+	trimmed /= float64(len(timeDiffs) - 2*trim)
+
+	stdDev := math.Sqrt(variance)
+	var cv float64
+	if average > 0 {
+		cv = stdDev / average
+	}
+	if !deduplicated {
+		weighted = 0
+	}
+
+	return &Stats{
+		Average:                average,
+		CoefficientOfVariation: cv,
+		Fastest:                fastest,
+		Median:                 median,
+		Slowest:                slowest,
+		StdDev:                 stdDev,
+		TrimmedMean:            trimmed,
+		Variance:               variance,
+		WeightedAverage:        weighted / weights,
+	}
+}
+
+// ErrorSummary counts query failures per client.ErrorClass, keeping the first error of every
+// class as a sample. A flat list of errors gets unreadable when running thousands of queries.
+type ErrorSummary struct {
+	Counts  map[client.ErrorClass]int
+	Samples map[client.ErrorClass]error
+}
+
+// Add classifies and accounts the given error.
+func (e *ErrorSummary) Add(err error) {
+	if e.Counts == nil {
+		e.Counts = make(map[client.ErrorClass]int)
+		e.Samples = make(map[client.ErrorClass]error)
+	}
+
+	class := client.Classify(err)
+	if e.Counts[class] == 0 {
+		e.Samples[class] = err
+	}
+	e.Counts[class]++
+}
+
+// Total returns the number of errors accounted across all classes.
+func (e *ErrorSummary) Total() (total int) {
+	for _, count := range e.Counts {
+		total += count
+	}
+	return
+}
+
+// errorSummaryJSON is the serialized form of an ErrorSummary, with the samples as plain messages.
+type errorSummaryJSON struct {
+	Counts  map[client.ErrorClass]int    `json:"counts,omitempty"`
+	Samples map[client.ErrorClass]string `json:"samples,omitempty"`
+}
+
+func (e ErrorSummary) MarshalJSON() ([]byte, error) {
+	out := errorSummaryJSON{Counts: e.Counts}
+	if len(e.Samples) > 0 {
+		out.Samples = make(map[client.ErrorClass]string, len(e.Samples))
+		for class, err := range e.Samples {
+			out.Samples[class] = err.Error()
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (e *ErrorSummary) UnmarshalJSON(b []byte) error {
+	var in errorSummaryJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	e.Counts, e.Samples = in.Counts, nil
+	if len(in.Samples) > 0 {
+		e.Samples = make(map[client.ErrorClass]error, len(in.Samples))
+		for class, msg := range in.Samples {
+			e.Samples[class] = errors.New(msg)
+		}
+	}
+	return nil
+}
+
+func (e *ErrorSummary) ToString() (output string) {
+	output += fmt.Sprintf("Number of errors: %d\n", e.Total())
+	for _, class := range client.ErrorClasses {
+		if count := e.Counts[class]; count > 0 {
+			output += fmt.Sprintf("  %s: %d (e.g. %v)\n", class, count, e.Samples[class])
+		}
+	}
+	return
+}
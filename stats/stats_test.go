@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name      string
+		queryList []query.Query
+		want      *Stats
+	}{
+		{
+			name: "OK",
+			queryList: []query.Query{
+				{Query: "", Start: 0, End: 2}, // 2
+				{Query: "", Start: 0, End: 2}, // 2
+				{Query: "", Start: 0, End: 2}, // 2
+			},
+			want: &Stats{Average: 2, Fastest: 2, Median: 2, Slowest: 2, TrimmedMean: 2},
+		},
+		{
+			name: "Odd number of queries",
+			queryList: []query.Query{
+				{Query: "", Start: 0, End: 1}, // 1
+				{Query: "", Start: 0, End: 2}, // 2
+				{Query: "", Start: 0, End: 3}, // 3
+			},
+			want: &Stats{
+				Average: 2, Fastest: 1, Median: 2, Slowest: 3, TrimmedMean: 2,
+				Variance: 2.0 / 3, StdDev: math.Sqrt(2.0 / 3), CoefficientOfVariation: math.Sqrt(2.0/3) / 2,
+			},
+		},
+		{
+			name: "Even number of queries",
+			queryList: []query.Query{
+				{Query: "", Start: 0, End: 1}, // 1
+				{Query: "", Start: 0, End: 2}, // 2
+				{Query: "", Start: 0, End: 3}, // 3
+				{Query: "", Start: 0, End: 4}, // 4
+			},
+			want: &Stats{
+				Average: 2.5, Fastest: 1, Median: 2.5, Slowest: 4, TrimmedMean: 2.5,
+				Variance: 1.25, StdDev: math.Sqrt(1.25), CoefficientOfVariation: math.Sqrt(1.25) / 2.5,
+			},
+		},
+		{
+			name: "Weighted by duplicates",
+			queryList: []query.Query{
+				{Query: "", Start: 0, End: 1, Weight: 3}, // 1
+				{Query: "", Start: 0, End: 3},            // 3
+			},
+			want: &Stats{
+				Average: 2, Fastest: 1, Median: 2, Slowest: 3, TrimmedMean: 2,
+				Variance: 1, StdDev: 1, CoefficientOfVariation: 0.5, WeightedAverage: 1.5,
+			},
+		},
+		{
+			name: "Trimmed outlier",
+			queryList: append(
+				slices.Repeat([]query.Query{{Query: "", Start: 0, End: 1}}, 19), // 1
+				query.Query{Query: "", Start: 0, End: 21},                       // 21
+			),
+			want: &Stats{
+				Average: 2, Fastest: 1, Median: 1, Slowest: 21, TrimmedMean: 1,
+				Variance: 19, StdDev: math.Sqrt(19), CoefficientOfVariation: math.Sqrt(19) / 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compute(tt.queryList); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorSummary_Add(t *testing.T) {
+	var e ErrorSummary
+	first := &client.StatusError{StatusCode: 500}
+	e.Add(first)
+	e.Add(&client.StatusError{StatusCode: 502})
+	e.Add(&client.StatusError{StatusCode: 404})
+
+	if got := e.Total(); got != 3 {
+		t.Errorf("ErrorSummary.Total() = %d, want 3", got)
+	}
+	if got := e.Counts[client.ErrorServerStatus]; got != 2 {
+		t.Errorf("ErrorSummary.Counts[5xx] = %d, want 2", got)
+	}
+	if got := e.Samples[client.ErrorServerStatus]; got != first {
+		t.Errorf("ErrorSummary.Samples[5xx] = %v, want %v", got, first)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{q: 0, want: 1},
+		{q: 0.5, want: 5},
+		{q: 0.9, want: 9},
+		{q: 0.99, want: 10},
+	}
+	for _, tt := range tests {
+		values := []float64{5, 1, 4, 2, 3, 6, 7, 8, 9, 10}
+		if got := Quantile(values, tt.q); got != tt.want {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
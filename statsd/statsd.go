@@ -0,0 +1,78 @@
+// Package statsd streams the timing and errors of every benchmarked request to a StatsD (or
+// DogStatsD) sink over UDP while the benchmark runs.
+package statsd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// Sink is a runner.Recorder sending the metrics of every Result to a StatsD server:
+//   - <prefix>request.latency, the latency of the request as a timer in milliseconds
+//   - <prefix>requests, a counter of the requests
+//   - <prefix>errors, a counter of the failed requests
+//
+// With Tags the metrics are tagged in the DogStatsD format with the endpoint, the HTTP status and,
+// for errors, the class of the error.
+type Sink struct {
+	// Prefix is prepended to the name of every metric, e.g. "pqlbench."
+	Prefix string
+	Tags   bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// New instantiates a Sink sending metrics to the StatsD server at the given address, e.g.
+// localhost:8125.
+func New(addr string) (*Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the statsd server %s. err=%w", addr, err)
+	}
+	return &Sink{Prefix: "pqlbench.", conn: conn}, nil
+}
+
+func (s *Sink) Record(r *runner.Result) error {
+	endpoint := r.Query.Endpoint
+	if r.Query.RangeQuery() {
+		endpoint = "query_range"
+	}
+	var tags string
+	if s.Tags {
+		tags = fmt.Sprintf("|#endpoint:%s,status:%d", sanitize(endpoint), r.Status)
+	}
+
+	var b strings.Builder
+	latency := float64(r.End.Sub(r.Start)) / float64(time.Millisecond)
+	fmt.Fprintf(&b, "%srequest.latency:%g|ms%s\n", s.Prefix, latency, tags)
+	fmt.Fprintf(&b, "%srequests:1|c%s", s.Prefix, tags)
+	if r.Err != nil {
+		if s.Tags {
+			tags += ",error_class:" + sanitize(string(client.Classify(r.Err)))
+		}
+		fmt.Fprintf(&b, "\n%serrors:1|c%s", s.Prefix, tags)
+	}
+
+	// Metrics are sent in a single datagram per request, which may be lost (e.g. while the server
+	// is down) without ever failing nor slowing the benchmark down
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.Write([]byte(b.String()))
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+// sanitize replaces the characters with a meaning in the DogStatsD format within tag values.
+func sanitize(v string) string {
+	return strings.NewReplacer(" ", "_", ",", "_", "|", "_", ":", "_", "#", "_").Replace(v)
+}
@@ -0,0 +1,69 @@
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestSink_Record(t *testing.T) {
+	start := time.Unix(1609459200, 0)
+	tests := []struct {
+		name   string
+		tags   bool
+		result *runner.Result
+		want   string
+	}{
+		{
+			name:   "statsd",
+			result: &runner.Result{Query: query.Query{Query: "up"}, Start: start, End: start.Add(1500 * time.Microsecond), Status: 200},
+			want:   "pqlbench.request.latency:1.5|ms\npqlbench.requests:1|c",
+		},
+		{
+			name:   "dogstatsd",
+			tags:   true,
+			result: &runner.Result{Query: query.Query{Endpoint: "label/job/values"}, Start: start, End: start.Add(2 * time.Millisecond), Status: 200},
+			want:   "pqlbench.request.latency:2|ms|#endpoint:label/job/values,status:200\npqlbench.requests:1|c|#endpoint:label/job/values,status:200",
+		},
+		{
+			name:   "error",
+			tags:   true,
+			result: &runner.Result{Query: query.Query{Query: "up"}, Start: start, End: start.Add(time.Millisecond), Status: 503, Err: &client.StatusError{StatusCode: 503}},
+			want: "pqlbench.request.latency:1|ms|#endpoint:query_range,status:503\npqlbench.requests:1|c|#endpoint:query_range,status:503\n" +
+				"pqlbench.errors:1|c|#endpoint:query_range,status:503,error_class:5xx",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			s, err := New(server.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			s.Tags = tt.tags
+			if err := s.Record(tt.result); err != nil {
+				t.Fatalf("Sink.Record() error = %v", err)
+			}
+
+			buf := make([]byte, 1024)
+			server.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := server.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Errorf("Sink.Record() sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+// Package store persists the configuration, per-request results and summary of benchmark runs in
+// a SQLite or PostgreSQL database, so runs can be compared over time.
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx database/sql driver
+	_ "modernc.org/sqlite"             // registers the sqlite database/sql driver
+
+	"github.com/noelruault/pqlbench/pgsql"
+	"github.com/noelruault/pqlbench/report"
+	"github.com/noelruault/pqlbench/runner"
+)
+
+// sqlitePrefix starts the store specs of SQLite databases, e.g. `sqlite:bench.db`.
+const sqlitePrefix = "sqlite:"
+
+// Store is a database of benchmark runs.
+type Store struct {
+	db *sql.DB
+	// postgres is true for PostgreSQL databases, false for SQLite ones
+	postgres bool
+}
+
+// Open opens the database of the given spec, either `sqlite:<path>` or a PostgreSQL connection
+// string (e.g. `postgres://postgres@localhost:5432/bench`), creating its tables if needed.
+func Open(spec string) (*Store, error) {
+	s := &Store{}
+	var err error
+	switch {
+	case strings.HasPrefix(spec, sqlitePrefix):
+		s.db, err = sql.Open("sqlite", strings.TrimPrefix(spec, sqlitePrefix))
+	case strings.HasPrefix(spec, "postgres://"), strings.HasPrefix(spec, "postgresql://"):
+		s.db, err = sql.Open("pgx", spec)
+		s.postgres = true
+	default:
+		return nil, fmt.Errorf("invalid store %q, want sqlite:<path> or a postgres:// connection string", spec)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open store %s. err=%w", Redact(spec), err)
+	}
+	if err := s.migrate(); err != nil {
+		s.db.Close()
+		return nil, fmt.Errorf("unable to create the tables of store %s. err=%w", Redact(spec), err)
+	}
+	return s, nil
+}
+
+// Redact hides the password of a store spec, so it can be logged and reported.
+func Redact(spec string) string {
+	if strings.HasPrefix(spec, sqlitePrefix) {
+		return spec
+	}
+	return pgsql.Redact(spec)
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) migrate() error {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if s.postgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS runs (
+			id ` + id + `,
+			started_at BIGINT NOT NULL,
+			target TEXT NOT NULL,
+			config TEXT NOT NULL,
+			summary TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS requests (
+			run_id BIGINT NOT NULL REFERENCES runs(id) ON DELETE CASCADE,
+			timestamp BIGINT NOT NULL,
+			query TEXT NOT NULL,
+			endpoint TEXT NOT NULL,
+			start_ms BIGINT NOT NULL,
+			end_ms BIGINT NOT NULL,
+			step INTEGER NOT NULL,
+			latency_ms DOUBLE PRECISION NOT NULL,
+			status INTEGER NOT NULL,
+			bytes BIGINT NOT NULL,
+			worker INTEGER NOT NULL,
+			error TEXT NOT NULL,
+			error_class TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS requests_run_id ON requests (run_id)`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var placeholderRegex = regexp.MustCompile(`\$\d+`)
+
+// rebind rewrites the $n placeholders of a statement into those of the database.
+func (s *Store) rebind(stmt string) string {
+	if s.postgres {
+		return stmt
+	}
+	return placeholderRegex.ReplaceAllString(stmt, "?")
+}
+
+// Run is a benchmark run kept in a Store.
+type Run struct {
+	ID      int64
+	Started time.Time
+	Target  string
+	// Config holds the JSON encoded configuration of the run
+	Config   json.RawMessage
+	Summary  *report.Summary
+	Requests int
+}
+
+// Save persists a run along with the results of its requests, returning the ID it was given.
+func (s *Store) Save(run *Run, results []runner.Result) (int64, error) {
+	summary, err := json.Marshal(run.Summary)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(s.rebind(`INSERT INTO runs (started_at, target, config, summary) VALUES ($1, $2, $3, $4) RETURNING id`),
+		run.Started.UnixMilli(), run.Target, string(run.Config), string(summary)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("unable to save run. err=%w", err)
+	}
+
+	stmt, err := tx.Prepare(s.rebind(`INSERT INTO requests (run_id, timestamp, query, endpoint, start_ms, end_ms, step, latency_ms, status, bytes, worker, error, error_class)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for i := range results {
+		e := report.NewRequestEvent(&results[i])
+		if _, err := stmt.Exec(id, e.Timestamp.UnixMilli(), e.Query, e.Endpoint, e.Start, e.End, e.Step, e.LatencyMs,
+			e.Status, e.Bytes, e.Worker, e.Error, e.ErrorClass); err != nil {
+			return 0, fmt.Errorf("unable to save request. err=%w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	run.ID = id
+	return id, nil
+}
+
+// List returns the runs kept in the store, most recent first, without their config and summary.
+func (s *Store) List() ([]Run, error) {
+	rows, err := s.db.Query(`SELECT r.id, r.started_at, r.target, (SELECT COUNT(*) FROM requests q WHERE q.run_id = r.id)
+		FROM runs r ORDER BY r.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		var started int64
+		if err := rows.Scan(&run.ID, &started, &run.Target, &run.Requests); err != nil {
+			return nil, err
+		}
+		run.Started = time.UnixMilli(started)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Get returns the run of the given ID.
+func (s *Store) Get(id int64) (*Run, error) {
+	run := &Run{ID: id}
+	var started int64
+	var config, summary string
+	err := s.db.QueryRow(s.rebind(`SELECT started_at, target, config, summary, (SELECT COUNT(*) FROM requests WHERE run_id = $1)
+		FROM runs WHERE id = $2`), id, id).Scan(&started, &run.Target, &config, &summary, &run.Requests)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("run %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	run.Started, run.Config = time.UnixMilli(started), json.RawMessage(config)
+	if err := json.Unmarshal([]byte(summary), &run.Summary); err != nil {
+		return nil, fmt.Errorf("unable to decode the summary of run %d. err=%w", id, err)
+	}
+	return run, nil
+}
+
+// ParseID parses the ID of a run.
+func ParseID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid run ID %q", s)
+	}
+	return id, nil
+}
+
+// Collector is a runner.Recorder keeping every Result, so they can be saved along with their run.
+type Collector struct {
+	mu      sync.Mutex
+	Results []runner.Result
+}
+
+func (c *Collector) Record(r *runner.Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Results = append(c.Results, *r)
+	return nil
+}
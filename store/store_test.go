@@ -0,0 +1,67 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/noelruault/pqlbench/client"
+	"github.com/noelruault/pqlbench/query"
+	"github.com/noelruault/pqlbench/report"
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(sqlitePrefix + t.TempDir() + "/bench.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.UnixMilli(1609459200000)
+	results := []runner.Result{
+		{Query: query.Query{Query: "up", Start: 1, End: 2, Step: 3}, Start: start, End: start.Add(2 * time.Millisecond), Status: 200},
+		{Query: query.Query{Query: "up", Start: 1, End: 2, Step: 3}, Start: start, End: start.Add(time.Millisecond), Status: 503, Err: &client.StatusError{StatusCode: 503}},
+	}
+	for i, target := range []string{"http://first:9201", "http://second:9201"} {
+		run := &Run{
+			Started: start.Add(time.Duration(i) * time.Hour),
+			Target:  target,
+			Config:  json.RawMessage(`{"Workers":4}`),
+			Summary: &report.Summary{Target: target, Stats: &stats.Stats{Processed: 1, Median: 2}},
+		}
+		id, err := s.Save(run, results[:i+1])
+		if err != nil {
+			t.Fatalf("Store.Save() error = %v", err)
+		}
+		if id != int64(i+1) || run.ID != id {
+			t.Errorf("Store.Save() = %d (run %d), want %d", id, run.ID, i+1)
+		}
+	}
+
+	runs, err := s.List()
+	if err != nil {
+		t.Fatalf("Store.List() error = %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != 2 || runs[0].Target != "http://second:9201" || runs[0].Requests != 2 || !runs[0].Started.Equal(start.Add(time.Hour)) {
+		t.Errorf("Store.List() = %+v, want the second run first", runs)
+	}
+
+	run, err := s.Get(1)
+	if err != nil {
+		t.Fatalf("Store.Get() error = %v", err)
+	}
+	if run.Target != "http://first:9201" || run.Requests != 1 || string(run.Config) != `{"Workers":4}` || run.Summary.Stats.Median != 2 {
+		t.Errorf("Store.Get() = %+v", run)
+	}
+	if _, err := s.Get(3); err == nil {
+		t.Errorf("Store.Get() of a missing run error = nil, want an error")
+	}
+}
+
+func TestOpen_invalid(t *testing.T) {
+	if _, err := Open("bench.db"); err == nil {
+		t.Errorf("Open() error = nil, want an error")
+	}
+}
@@ -0,0 +1,221 @@
+// Package ui serves a web UI showing the latency, throughput and errors of a benchmark live while
+// it runs, and the runs kept in a results store.
+package ui
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/noelruault/pqlbench/runner"
+	"github.com/noelruault/pqlbench/stats"
+	"github.com/noelruault/pqlbench/store"
+)
+
+//go:embed index.html
+var index []byte
+
+// Point aggregates the queries completed within an interval of a live run. Latencies are those of
+// the successful queries in milliseconds.
+type Point struct {
+	Time       time.Time `json:"time"`
+	Requests   int       `json:"requests"`
+	Errors     int       `json:"errors"`
+	Throughput float64   `json:"throughput_qps"`
+	Median     float64   `json:"median_ms"`
+	P95        float64   `json:"p95_ms"`
+	P99        float64   `json:"p99_ms"`
+}
+
+// Live is a runner.Recorder aggregating the Results of a run every Interval between Start and Stop
+// into Points, which are streamed to the browsers connected to the UI.
+type Live struct {
+	Interval time.Duration
+
+	mu        sync.Mutex
+	latencies []float64
+	errors    int
+	last      time.Time
+	// points holds every point of the run, so browsers connecting late get the whole history
+	points      []Point
+	subscribers map[chan Point]struct{}
+	finished    bool
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+func NewLive(interval time.Duration) *Live {
+	return &Live{Interval: interval, subscribers: map[chan Point]struct{}{}}
+}
+
+func (l *Live) Record(r *runner.Result) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r.Err != nil {
+		l.errors++
+	} else {
+		l.latencies = append(l.latencies, float64(r.End.Sub(r.Start).Microseconds())/1000)
+	}
+	return nil
+}
+
+// Start keeps aggregating the results recorded every Interval in the background.
+func (l *Live) Start() {
+	l.last = time.Now()
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(l.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case now := <-ticker.C:
+				l.aggregate(now)
+			}
+		}
+	}()
+}
+
+// Stop aggregates the last results and ends the streams.
+func (l *Live) Stop() {
+	close(l.stop)
+	<-l.done
+	l.aggregate(time.Now())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.finished = true
+	for ch := range l.subscribers {
+		close(ch)
+		delete(l.subscribers, ch)
+	}
+}
+
+func (l *Live) aggregate(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p := Point{Time: now.UTC(), Requests: len(l.latencies) + l.errors, Errors: l.errors}
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		p.Throughput = float64(p.Requests) / elapsed.Seconds()
+	}
+	if len(l.latencies) > 0 {
+		p.Median = stats.Quantile(l.latencies, 0.5)
+		p.P95 = stats.Quantile(l.latencies, 0.95)
+		p.P99 = stats.Quantile(l.latencies, 0.99)
+	}
+	l.latencies, l.errors, l.last = l.latencies[:0], 0, now
+	l.points = append(l.points, p)
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- p:
+		default: // A slow browser misses points rather than slowing down the run
+		}
+	}
+}
+
+// subscribe returns the points so far and a channel receiving the next ones until unsubscribed,
+// closed once the run is over.
+func (l *Live) subscribe() ([]Point, chan Point) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch := make(chan Point, 16)
+	if l.finished {
+		close(ch)
+	} else {
+		l.subscribers[ch] = struct{}{}
+	}
+	return append([]Point(nil), l.points...), ch
+}
+
+func (l *Live) unsubscribe(ch chan Point) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subscribers, ch)
+}
+
+// Handler serves the UI. The live charts are fed by the given Live recorder, if any, over a
+// WebSocket at /live, and the past runs are read from the given Store, if any, at /api/runs.
+func Handler(live *Live, db *store.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(index)
+	})
+	mux.Handle("/live", websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		if live == nil {
+			return
+		}
+		points, ch := live.subscribe()
+		defer live.unsubscribe(ch)
+		for _, p := range points {
+			if err := websocket.JSON.Send(ws, p); err != nil {
+				return
+			}
+		}
+		for p := range ch {
+			if err := websocket.JSON.Send(ws, p); err != nil {
+				return
+			}
+		}
+	}))
+	mux.HandleFunc("/api/runs", func(w http.ResponseWriter, req *http.Request) {
+		var runs []store.Run
+		if db != nil {
+			var err error
+			if runs, err = db.List(); err != nil {
+				slog.Warn("unable to list runs", "err", err)
+				http.Error(w, fmt.Sprintf("unable to list runs. err=%v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		if runs == nil {
+			runs = []store.Run{}
+		}
+		writeJSON(w, runs)
+	})
+	mux.HandleFunc("/api/runs/", func(w http.ResponseWriter, req *http.Request) {
+		if db == nil {
+			http.NotFound(w, req)
+			return
+		}
+		id, err := store.ParseID(strings.TrimPrefix(req.URL.Path, "/api/runs/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		run, err := db.Get(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, struct {
+			*store.Run
+			// Text is the summary as printed at the end of the run
+			Text string
+		}{run, run.Summary.ToString()})
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("unable to write response", "err", err)
+	}
+}
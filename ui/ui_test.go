@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/noelruault/pqlbench/runner"
+)
+
+func TestLive(t *testing.T) {
+	start := time.UnixMilli(0)
+	live := NewLive(time.Hour)
+	live.last = start
+	live.stop, live.done = make(chan struct{}), make(chan struct{})
+	close(live.done)
+
+	live.Record(&runner.Result{Start: start, End: start.Add(10 * time.Millisecond)})
+	live.Record(&runner.Result{Start: start, End: start.Add(30 * time.Millisecond)})
+	live.Record(&runner.Result{Start: start, End: start.Add(20 * time.Millisecond), Err: errors.New("timeout")})
+	live.aggregate(start.Add(time.Second))
+
+	srv := httptest.NewServer(Handler(live, nil))
+	defer srv.Close()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/live", "", srv.URL)
+	if err != nil {
+		t.Fatalf("websocket.Dial() error = %v", err)
+	}
+	defer ws.Close()
+
+	var p Point
+	if err := websocket.JSON.Receive(ws, &p); err != nil {
+		t.Fatalf("Receive() error = %v", err)
+	}
+	if p.Requests != 3 || p.Errors != 1 || p.Throughput != 3 || p.Median != 10 || p.P99 != 30 {
+		t.Errorf("Receive() history = %+v, want 3 requests, 1 error, 3 queries/s, median 10ms and p99 30ms", p)
+	}
+
+	live.Record(&runner.Result{Start: start, End: start.Add(50 * time.Millisecond)})
+	live.Stop()
+	if err := websocket.JSON.Receive(ws, &p); err != nil || p.Requests != 1 || p.Median != 50 {
+		t.Errorf("Receive() = %+v, %v, want the last point with 1 request", p, err)
+	}
+	if err := websocket.JSON.Receive(ws, &p); err != io.EOF {
+		t.Errorf("Receive() after the run error = %v, want EOF", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler(nil, nil))
+	defer srv.Close()
+
+	for path, want := range map[string]int{"/": 200, "/api/runs": 200, "/api/runs/1": 404, "/missing": 404} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}